@@ -0,0 +1,217 @@
+package hstspreload
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// An AddressResult holds the outcome of checking the HTTPS response from
+// a single IP address of a domain.
+type AddressResult struct {
+	Address string `json:"address"`
+	// Responded indicates that an HTTPS response was received from the
+	// address, even if it has issues.
+	Responded bool `json:"responded"`
+	// Iff a single HSTS header was received, Header contains its value.
+	Header *string `json:"header,omitempty"`
+	// Issues from TLS and HSTS header checks against this address.
+	Issues Issues `json:"issues"`
+	// The time taken to receive the initial response.
+	Latency time.Duration `json:"latency_ns"`
+}
+
+// PreloadableAddresses resolves `domain` and performs the TLS and HSTS
+// header checks of PreloadableDomain() against each of its addresses
+// separately. This can help to find a single misconfigured server in a
+// geo-distributed or anycast deployment, since PreloadableDomain() only
+// observes whichever address it happens to connect to.
+//
+// Results are returned in the order of the addresses returned by the
+// resolver.
+func PreloadableAddresses(domain string) ([]AddressResult, error) {
+	return defaultChecker.PreloadableAddresses(domain)
+}
+
+// PreloadableAddresses is like the package-level PreloadableAddresses(),
+// but uses the configuration of `c`. Names are always resolved locally,
+// using c.Resolver. If c.ConnectTo overrides the address of `domain` for
+// HTTPS, only that address is checked.
+func (c *Checker) PreloadableAddresses(domain string) ([]AddressResult, error) {
+	return c.PreloadableAddressesContext(context.Background(), domain)
+}
+
+// PreloadableAddressesContext is like PreloadableAddresses, but `ctx`
+// applies to all lookups and connections.
+func (c *Checker) PreloadableAddressesContext(ctx context.Context, domain string) ([]AddressResult, error) {
+	var resolver *net.Resolver
+	if c != nil {
+		resolver = c.Resolver
+	}
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	var ips []net.IPAddr
+	if override := c.connectToAddress(domain); override != nil {
+		ips = []net.IPAddr{{IP: override}}
+	} else {
+		lookupCtx, cancel := context.WithTimeout(ctx, c.timeout())
+		var err error
+		ips, err = resolver.LookupIPAddr(lookupCtx, domain)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	results := make([]AddressResult, len(ips))
+	done := make(chan bool)
+	for i, ip := range ips {
+		go func(i int, address string) {
+			results[i] = c.preloadableAddress(ctx, domain, address)
+			done <- true
+		}(i, ip.IP.String())
+	}
+	for range ips {
+		<-done
+	}
+
+	return results, nil
+}
+
+func (c *Checker) preloadableAddress(ctx context.Context, domain string, address string) AddressResult {
+	result := AddressResult{Address: address}
+
+	start := time.Now()
+	resp, issues := c.getAddressResponse(ctx, domain, address)
+	result.Latency = time.Since(start)
+	if len(issues.Errors) == 0 {
+		result.Responded = true
+		issues = combineIssues(issues, checkChain(*resp.TLS))
+		issues = combineIssues(issues, checkTLSConfig(*resp.TLS))
+		var preloadableIssues Issues
+		result.Header, preloadableIssues = c.PreloadableResponse(resp)
+		issues = combineIssues(issues, preloadableIssues)
+	}
+	result.Issues = issues
+
+	return result
+}
+
+// CheckAddressConsistency checks that all addresses of `domain` in
+// `results` (from PreloadableAddresses()) that responded serve equivalent
+// HSTS headers. Backends that serve a different or missing header are easy
+// to miss, since a single check only observes one of them, but make the
+// domain fail whenever a client (or a later check) reaches them.
+func CheckAddressConsistency(domain string, results []AddressResult) Issues {
+	return defaultChecker.CheckAddressConsistency(domain, results)
+}
+
+// CheckAddressConsistency is like the package-level
+// CheckAddressConsistency(), but applies the policies of `c` (e.g.
+// SeverityOverrides) to the issues.
+func (c *Checker) CheckAddressConsistency(domain string, results []AddressResult) Issues {
+	return c.applyPartialPolicy(domain, addressConsistency(results))
+}
+
+func addressConsistency(results []AddressResult) Issues {
+	issues := Issues{}
+
+	var responded []AddressResult
+	for _, r := range results {
+		if r.Responded {
+			responded = append(responded, r)
+		}
+	}
+	consistent := true
+	for _, r := range responded {
+		if !equivalentHeaders(responded[0].Header, r.Header) {
+			consistent = false
+		}
+	}
+	if consistent {
+		return issues
+	}
+
+	var addresses, descriptions []string
+	for _, r := range responded {
+		addresses = append(addresses, r.Address)
+		descriptions = append(descriptions, fmt.Sprintf("%s serves %s", r.Address, describeHeader(r.Header)))
+	}
+	return issues.addWarningWithParamsf(
+		IssueCode("domain.multi_ip.inconsistent_hsts"),
+		"Inconsistent HSTS header across addresses",
+		map[string]string{"addresses": strings.Join(addresses, ",")},
+		"The domain resolves to several addresses that serve different HSTS headers: %s. "+
+			"Clients may connect to any of them, so they should all serve an equivalent header.",
+		strings.Join(descriptions, "; "),
+	)
+}
+
+// connectToAddress returns the IP address that c.ConnectTo directs HTTPS
+// connections to `domain` to, or nil if there is none. Overrides that also
+// change the port are ignored, since the address checks connect to the
+// HTTPS port of each address. `c` may be nil.
+func (c *Checker) connectToAddress(domain string) net.IP {
+	addr := net.JoinHostPort(domain, c.httpsPort())
+	target := c.connectTo(addr)
+	if target == addr {
+		return nil
+	}
+	host, port, err := net.SplitHostPort(target)
+	if err != nil || port != c.httpsPort() {
+		return nil
+	}
+	return net.ParseIP(host)
+}
+
+// addressTransport returns a transport that connects to `address`
+// regardless of the host in the request URL. TLS verification (including
+// SNI) still uses the host from the URL.
+func (c *Checker) addressTransport(address string, insecure bool) *http.Transport {
+	t := c.transport(insecure)
+	t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		_, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		return c.dialContext(ctx, network, net.JoinHostPort(address, port))
+	}
+	return t
+}
+
+// getAddressResponse is like getResponse, but connects to a specific
+// address of `domain`.
+func (c *Checker) getAddressResponse(ctx context.Context, domain string, address string) (*http.Response, Issues) {
+	issues := Issues{}
+
+	attempt := newConnectAttempt()
+	resp, err := getFirstResponseAttempt(ctx, c.httpsURL(domain), c.addressTransport(address, false), c, attempt)
+	if err == nil {
+		return resp, issues
+	}
+
+	// Check if ignoring cert issues works.
+	if c.insecureFallback() && c.insecureAddressResponseWorks(ctx, domain, address) {
+		return nil, issues.addErrorWithParamsf(
+			IssueCode("domain.tls.invalid_cert_chain"),
+			"Invalid Certificate Chain",
+			map[string]string{"url": c.httpsURL(domain), "address": address},
+			"%s uses an incomplete or "+
+				"invalid certificate chain when connecting to %s. Check out your site at "+
+				"https://www.ssllabs.com/ssltest/",
+			c.httpsURL(domain),
+			address,
+		)
+	}
+
+	return nil, cannotConnectIssues(fmt.Sprintf("%s at %s", c.httpsURL(domain), address), attempt, err)
+}
+
+func (c *Checker) insecureAddressResponseWorks(ctx context.Context, domain string, address string) bool {
+	_, err := getFirstResponseWithTransport(ctx, c.httpsURL(domain), c.addressTransport(address, true), c)
+	return err == nil
+}
@@ -0,0 +1,111 @@
+package hstspreload
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAddressTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Host", r.Host)
+	}))
+	defer srv.Close()
+
+	_, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := http.Client{Transport: (*Checker)(nil).addressTransport("127.0.0.1", false)}
+	resp, err := client.Get("http://example.com:" + port + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := resp.Header.Get("X-Host"); h != "example.com:"+port {
+		t.Errorf("Request should keep the original host, but got %q.", h)
+	}
+}
+
+func TestPreloadableAddresses(t *testing.T) {
+	const header = "max-age=31536000; includeSubDomains; preload"
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", header)
+	}))
+	defer srv.Close()
+
+	c := &Checker{
+		HTTPSPort: srv.Listener.Addr().(*net.TCPAddr).Port,
+		ConnectTo: map[string]string{"example.com": "127.0.0.1"},
+		Transport: srv.Client().Transport.(*http.Transport),
+	}
+	results, err := c.PreloadableAddresses("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("Expected exactly one address, got %#v.", results)
+	}
+	r := results[0]
+	if r.Address != "127.0.0.1" || !r.Responded {
+		t.Errorf("Unexpected result: %#v", r)
+	}
+	if r.Header == nil || *r.Header != header {
+		t.Errorf("[%s] Expected the header %q.", r.Address, header)
+	}
+	if len(r.Issues.Errors) > 0 {
+		t.Errorf("[%s] Unexpected errors: %v", r.Address, r.Issues)
+	}
+}
+
+func TestCheckAddressConsistency(t *testing.T) {
+	header := "max-age=31536000; includeSubDomains; preload"
+	reordered := "preload; includeSubDomains; max-age=31536000"
+	short := "max-age=300"
+
+	var tests = []struct {
+		description string
+		results     []AddressResult
+		expected    Issues
+	}{
+		{
+			"equivalent headers",
+			[]AddressResult{
+				{Address: "192.0.2.1", Responded: true, Header: &header},
+				{Address: "192.0.2.2", Responded: true, Header: &reordered},
+			},
+			Issues{},
+		},
+		{
+			"unreachable address",
+			[]AddressResult{
+				{Address: "192.0.2.1", Responded: true, Header: &header},
+				{Address: "192.0.2.2"},
+			},
+			Issues{},
+		},
+		{
+			"different header",
+			[]AddressResult{
+				{Address: "192.0.2.1", Responded: true, Header: &header},
+				{Address: "192.0.2.2", Responded: true, Header: &short},
+			},
+			Issues{Warnings: []Issue{{Code: "domain.multi_ip.inconsistent_hsts"}}},
+		},
+		{
+			"missing header",
+			[]AddressResult{
+				{Address: "2001:db8::1", Responded: true},
+				{Address: "192.0.2.1", Responded: true, Header: &header},
+			},
+			Issues{Warnings: []Issue{{Code: "domain.multi_ip.inconsistent_hsts"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		if issues := CheckAddressConsistency("example.com", tt.results); !issues.Match(tt.expected) {
+			t.Errorf("[%s] "+issuesShouldMatch, tt.description, issues, tt.expected)
+		}
+	}
+}
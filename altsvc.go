@@ -0,0 +1,149 @@
+package hstspreload
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// An altSvcEndpoint is an alternative service advertised in an Alt-Svc
+// header (https://tools.ietf.org/html/rfc7838#section-3).
+type altSvcEndpoint struct {
+	Protocol string
+	// Host is empty if the alternative is on the same host as the origin.
+	Host string
+	Port string
+}
+
+// parseAltSvc returns the alternative services advertised in the values of
+// the Alt-Svc headers. Malformed alternatives are skipped.
+func parseAltSvc(values []string) []altSvcEndpoint {
+	var endpoints []altSvcEndpoint
+	for _, value := range values {
+		for _, alternative := range strings.Split(value, ",") {
+			// Ignore parameters like `ma` and `persist`.
+			alternative = strings.TrimSpace(strings.SplitN(alternative, ";", 2)[0])
+			if alternative == "" || alternative == "clear" {
+				continue
+			}
+
+			parts := strings.SplitN(alternative, "=", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			authority := strings.Trim(strings.TrimSpace(parts[1]), `"`)
+			host, port, err := net.SplitHostPort(authority)
+			if err != nil || port == "" {
+				continue
+			}
+
+			endpoints = append(endpoints, altSvcEndpoint{
+				Protocol: strings.TrimSpace(parts[0]),
+				Host:     host,
+				Port:     port,
+			})
+		}
+	}
+	return endpoints
+}
+
+// checkAltSvc checks that the alternative services advertised by `resp` on
+// other hosts or ports serve an HSTS header equivalent to the one in `resp`.
+// Browsers may send preloaded users to those alternatives, so they should
+// not behave differently.
+//
+// Alternatives are probed over TCP, even if they are only advertised for
+// HTTP/3.
+//
+// `c` may be nil.
+func (c *Checker) checkAltSvc(ctx context.Context, domain string, resp *http.Response) Issues {
+	issues := Issues{}
+
+	header, _ := checkSingleHeader(resp)
+	checked := make(map[string]bool)
+	for _, e := range parseAltSvc(resp.Header.Values("Alt-Svc")) {
+		host := e.Host
+		if host == "" {
+			host = domain
+		}
+		if host == domain && e.Port == c.httpsPort() {
+			continue
+		}
+		hostPort := net.JoinHostPort(host, e.Port)
+		if checked[hostPort] {
+			continue
+		}
+		checked[hostPort] = true
+
+		altResp, err := getFirstResponseWithTransport(ctx, c.httpsURL(domain), c.endpointTransport(hostPort), c)
+		if err != nil {
+			issues = issues.addWarningWithParamsf(
+				IssueCode("alt_svc.cannot_connect"),
+				"Cannot connect to alternative service",
+				map[string]string{"alt_svc": hostPort, "protocol": e.Protocol, "error": err.Error()},
+				"The site advertises an alternative service at `%s` (%s), but we could not connect to it "+
+					"to check its HSTS header (%s). Browsers may use the alternative service for preloaded users.",
+				hostPort,
+				e.Protocol,
+				err,
+			)
+			continue
+		}
+		// Close the body right away rather than deferring, since the loop
+		// may probe several alternatives.
+		altResp.Body.Close()
+
+		altHeader, _ := checkSingleHeader(altResp)
+		if !equivalentHeaders(header, altHeader) {
+			issues = issues.addWarningWithParamsf(
+				IssueCode("alt_svc.inconsistent_header"),
+				"Inconsistent HSTS header on alternative service",
+				map[string]string{"alt_svc": hostPort, "protocol": e.Protocol},
+				"The site advertises an alternative service at `%s` (%s), which serves %s instead of %s. "+
+					"Browsers may use the alternative service for preloaded users, so it should serve an equivalent HSTS header.",
+				hostPort,
+				e.Protocol,
+				describeHeader(altHeader),
+				describeHeader(header),
+			)
+		}
+	}
+
+	return issues
+}
+
+// endpointTransport returns a transport that connects to `hostPort`
+// regardless of the host and port in the request URL. TLS verification
+// (including SNI) still uses the host from the URL.
+func (c *Checker) endpointTransport(hostPort string) *http.Transport {
+	t := c.transport(false)
+	t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return c.dialContext(ctx, network, hostPort)
+	}
+	return t
+}
+
+// equivalentHeaders returns whether two headers (which may be nil) have the
+// same semantics.
+func equivalentHeaders(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	ha, _ := ParseHeaderString(*a)
+	hb, _ := ParseHeaderString(*b)
+	if (ha.MaxAge == nil) != (hb.MaxAge == nil) {
+		return false
+	}
+	if ha.MaxAge != nil && ha.MaxAge.Seconds != hb.MaxAge.Seconds {
+		return false
+	}
+	return ha.IncludeSubDomains == hb.IncludeSubDomains && ha.Preload == hb.Preload
+}
+
+func describeHeader(header *string) string {
+	if header == nil {
+		return "no single HSTS header"
+	}
+	return "`" + *header + "`"
+}
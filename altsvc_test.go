@@ -0,0 +1,93 @@
+package hstspreload
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+var parseAltSvcTests = []struct {
+	description string
+	values      []string
+	expected    []altSvcEndpoint
+}{
+	{
+		"none",
+		nil,
+		nil,
+	},
+	{
+		"clear",
+		[]string{"clear"},
+		nil,
+	},
+	{
+		"same host",
+		[]string{`h3=":443"; ma=86400`},
+		[]altSvcEndpoint{{Protocol: "h3", Port: "443"}},
+	},
+	{
+		"multiple alternatives and values",
+		[]string{`h3=":8443"; ma=86400, h2="alt.example.com:443"`, `h3-29="alt.example.com:443"; persist=1`},
+		[]altSvcEndpoint{
+			{Protocol: "h3", Port: "8443"},
+			{Protocol: "h2", Host: "alt.example.com", Port: "443"},
+			{Protocol: "h3-29", Host: "alt.example.com", Port: "443"},
+		},
+	},
+	{
+		"malformed",
+		[]string{`h2, h3="no-port", h2="alt.example.com:443"`},
+		[]altSvcEndpoint{{Protocol: "h2", Host: "alt.example.com", Port: "443"}},
+	},
+}
+
+func TestParseAltSvc(t *testing.T) {
+	for _, tt := range parseAltSvcTests {
+		if endpoints := parseAltSvc(tt.values); !reflect.DeepEqual(endpoints, tt.expected) {
+			t.Errorf("[%s] Unexpected endpoints: %#v (expected %#v)", tt.description, endpoints, tt.expected)
+		}
+	}
+}
+
+func TestCheckAltSvc(t *testing.T) {
+	const header = "max-age=31536000; includeSubDomains; preload"
+
+	same := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", "includeSubDomains; preload;  max-age=31536000")
+	}))
+	defer same.Close()
+	different := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", "max-age=300")
+	}))
+	defer different.Close()
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddr := closed.Addr().String()
+	closed.Close()
+
+	c := &Checker{Transport: same.Client().Transport.(*http.Transport)}
+	resp := &http.Response{Header: http.Header{}}
+	resp.Header.Set("Strict-Transport-Security", header)
+	resp.Header.Add("Alt-Svc", `h3=":443", h2="`+same.Listener.Addr().String()+`"`)
+	if issues := c.checkAltSvc(context.Background(), "example.com", resp); !issues.Match(Issues{}) {
+		t.Errorf(issuesShouldBeEmpty, issues)
+	}
+
+	resp.Header.Add("Alt-Svc", `h2="`+different.Listener.Addr().String()+`", h2="`+closedAddr+`"`)
+	expected := Issues{
+		Warnings: []Issue{
+			{Code: "alt_svc.inconsistent_header"},
+			{Code: "alt_svc.cannot_connect"},
+		},
+	}
+	if issues := c.checkAltSvc(context.Background(), "example.com", resp); !issues.Match(expected) {
+		t.Errorf(issuesShouldMatch, issues, expected)
+	}
+}
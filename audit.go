@@ -0,0 +1,130 @@
+package hstspreload
+
+import (
+	"context"
+
+	"github.com/chromium/hstspreload/chromium/preloadlist"
+)
+
+// AuditEntryHeader compares the preload list `entry` of a domain with the
+// HSTS header that the domain currently serves, and reports drift between
+// them. `hstsHeader` is nil if the domain does not serve a single valid
+// HSTS header.
+//
+// Errors describe live behavior that contradicts the entry. Warnings
+// describe differences that are likely unintended.
+//
+// To interpret the result, see the list of conventions in the
+// documentation for Issues.
+func AuditEntryHeader(entry preloadlist.Entry, hstsHeader *HSTSHeader) Issues {
+	issues := Issues{}
+
+	if entry.Mode != preloadlist.ForceHTTPS {
+		if hstsHeader != nil && hstsHeader.Preload {
+			issues = issues.addWarningWithParamsf(
+				"audit.mode.not_force_https",
+				"Entry does not force HTTPS",
+				map[string]string{"entry_name": entry.Name, "mode": entry.Mode},
+				"The preload list entry for `%s` does not have mode `%s`, but the site sends the `preload` directive.",
+				entry.Name,
+				preloadlist.ForceHTTPS)
+		}
+		return issues
+	}
+
+	if hstsHeader == nil {
+		return issues.addErrorWithParamsf(
+			"audit.header.missing",
+			"No HSTS header",
+			map[string]string{"entry_name": entry.Name},
+			"The preload list entry for `%s` forces HTTPS, but the site does not serve a single valid HSTS header.",
+			entry.Name)
+	}
+
+	if hstsHeader.MaxAge != nil && hstsHeader.MaxAge.Seconds == 0 {
+		issues = issues.addErrorWithParamsf(
+			"audit.max_age.zero",
+			"Max-age is 0",
+			map[string]string{"entry_name": entry.Name},
+			"The preload list entry for `%s` forces HTTPS, but the site sends max-age=0, which asks browsers to forget HSTS. "+
+				"If you want the domain to be removed from the list, visit hstspreload.org/removal/ instead.",
+			entry.Name)
+	}
+
+	if entry.IncludeSubDomains && !hstsHeader.IncludeSubDomains {
+		issues = issues.addErrorWithParamsf(
+			"audit.include_sub_domains.missing",
+			"No includeSubDomains directive",
+			map[string]string{"entry_name": entry.Name},
+			"The preload list entry for `%s` includes subdomains, but the header does not contain the `includeSubDomains` directive.",
+			entry.Name)
+	}
+	if !entry.IncludeSubDomains && hstsHeader.IncludeSubDomains {
+		issues = issues.addWarningWithParamsf(
+			"audit.include_sub_domains.not_preloaded",
+			"Subdomains are not preloaded",
+			map[string]string{"entry_name": entry.Name},
+			"The header contains the `includeSubDomains` directive, but the preload list entry for `%s` does not include subdomains.",
+			entry.Name)
+	}
+
+	if !hstsHeader.Preload {
+		issues = issues.addWarningWithParamsf(
+			"audit.preload.missing",
+			"No preload directive",
+			map[string]string{"entry_name": entry.Name},
+			"The domain `%s` is preloaded, but the header no longer contains the `preload` directive.",
+			entry.Name)
+	}
+
+	return issues
+}
+
+// AuditDomain looks up `domain` in `idx` and compares its preload list
+// entry with the live behavior of the domain (see AuditEntryHeader()).
+//
+// Iff a single HSTS header was received, `header` contains its value, else
+// `header` is `nil`.
+// To interpret `issues`, see the list of conventions in the
+// documentation for Issues.
+func AuditDomain(domain string, idx preloadlist.IndexedEntries) (header *string, issues Issues) {
+	return defaultChecker.AuditDomain(domain, idx)
+}
+
+// AuditDomain is like the package-level AuditDomain, but uses the
+// configuration of `c`.
+func (c *Checker) AuditDomain(domain string, idx preloadlist.IndexedEntries) (header *string, issues Issues) {
+	issues = Issues{}
+
+	entry, status := idx.Get(domain)
+	switch status {
+	case preloadlist.EntryNotFound:
+		return nil, issues.addErrorWithParamsf(
+			"audit.not_preloaded",
+			"Not preloaded",
+			map[string]string{"domain": domain},
+			"`%s` is not on the preload list, so there is no entry to compare with.",
+			domain)
+	case preloadlist.AncestorEntryFound:
+		return nil, issues.addWarningWithParamsf(
+			"audit.covered_by_parent",
+			"Covered by a parent domain",
+			map[string]string{"domain": domain, "preloaded_name": entry.Name},
+			"`%s` does not have its own preload list entry, but is covered by the entry for `%s`. Audit `%s` instead.",
+			domain,
+			entry.Name,
+			entry.Name)
+	}
+
+	resp, _, respIssues := getResponse(context.Background(), domain, c.withDNSCache())
+	issues = combineIssues(issues, respIssues)
+	if len(respIssues.Errors) == 0 {
+		var hstsHeader *HSTSHeader
+		// Problems with the header itself are reported as drift from the
+		// entry.
+		header, hstsHeader, _ = c.preloadableResponseParsed(resp)
+		issues = combineIssues(issues, AuditEntryHeader(entry, hstsHeader))
+	}
+
+	return header, c.applyDomainPolicy(domain, issues)
+}
@@ -0,0 +1,106 @@
+package hstspreload
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/chromium/hstspreload/chromium/preloadlist"
+)
+
+var auditEntryHeaderTests = []struct {
+	description    string
+	entry          preloadlist.Entry
+	header         string
+	expectedIssues Issues
+}{
+	{
+		"matching header",
+		preloadlist.Entry{Name: "example.com", Mode: "force-https", IncludeSubDomains: true},
+		"max-age=31536000; includeSubDomains; preload",
+		Issues{},
+	},
+	{
+		"no header",
+		preloadlist.Entry{Name: "example.com", Mode: "force-https", IncludeSubDomains: true},
+		"",
+		Issues{Errors: []Issue{{Code: "audit.header.missing"}}},
+	},
+	{
+		"dropped includeSubDomains and preload",
+		preloadlist.Entry{Name: "example.com", Mode: "force-https", IncludeSubDomains: true},
+		"max-age=31536000",
+		Issues{
+			Errors:   []Issue{{Code: "audit.include_sub_domains.missing"}},
+			Warnings: []Issue{{Code: "audit.preload.missing"}},
+		},
+	},
+	{
+		"subdomains not preloaded",
+		preloadlist.Entry{Name: "example.com", Mode: "force-https"},
+		"max-age=31536000; includeSubDomains; preload",
+		Issues{Warnings: []Issue{{Code: "audit.include_sub_domains.not_preloaded"}}},
+	},
+	{
+		"max-age=0",
+		preloadlist.Entry{Name: "example.com", Mode: "force-https"},
+		"max-age=0; preload",
+		Issues{Errors: []Issue{{Code: "audit.max_age.zero"}}},
+	},
+	{
+		"entry without force-https",
+		preloadlist.Entry{Name: "example.com"},
+		"max-age=31536000; includeSubDomains; preload",
+		Issues{Warnings: []Issue{{Code: "audit.mode.not_force_https"}}},
+	},
+	{
+		"entry without force-https, no header",
+		preloadlist.Entry{Name: "example.com"},
+		"",
+		Issues{},
+	},
+}
+
+func TestAuditEntryHeader(t *testing.T) {
+	for _, tt := range auditEntryHeaderTests {
+		var hstsHeader *HSTSHeader
+		if tt.header != "" {
+			parsed, _ := ParseHeaderString(tt.header)
+			hstsHeader = &parsed
+		}
+
+		issues := AuditEntryHeader(tt.entry, hstsHeader)
+		if !issues.Match(tt.expectedIssues) {
+			t.Errorf("[%s] "+issuesShouldMatch, tt.description, issues, tt.expectedIssues)
+		}
+	}
+}
+
+func TestAuditDomain(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", "max-age=31536000; preload")
+	}))
+	defer srv.Close()
+
+	c := &Checker{
+		ConnectTo: map[string]string{"example.com:443": srv.Listener.Addr().String()},
+		Transport: srv.Client().Transport.(*http.Transport),
+	}
+	idx := preloadlist.PreloadList{Entries: []preloadlist.Entry{
+		{Name: "example.com", Mode: "force-https", IncludeSubDomains: true},
+	}}.Index()
+
+	for _, tt := range []struct {
+		domain         string
+		expectedIssues Issues
+	}{
+		{"example.com", Issues{Errors: []Issue{{Code: "audit.include_sub_domains.missing"}}}},
+		{"www.example.com", Issues{Warnings: []Issue{{Code: "audit.covered_by_parent"}}}},
+		{"example.net", Issues{Errors: []Issue{{Code: "audit.not_preloaded"}}}},
+	} {
+		_, issues := c.AuditDomain(tt.domain, idx)
+		if !issues.Match(tt.expectedIssues) {
+			t.Errorf("[%s] "+issuesShouldMatch, tt.domain, issues, tt.expectedIssues)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package hstspreload
+
+import (
+	"context"
+	"time"
+)
+
+// HTTPSAvailable checks that `domain` can be visited over HTTPS: that it
+// resolves, and serves an HTTPS response with a valid certificate chain.
+// This is what subdomains of a domain that is preloaded with
+// includeSubDomains need, since browsers then only connect to them over
+// HTTPS and don't let users bypass certificate errors. Headers and
+// redirects are not checked.
+//
+// To interpret `issues`, see the list of conventions in the
+// documentation for Issues.
+func HTTPSAvailable(domain string) Issues {
+	return defaultChecker.HTTPSAvailable(domain)
+}
+
+// HTTPSAvailable is like the package-level HTTPSAvailable, but uses the
+// configuration of `c`.
+func (c *Checker) HTTPSAvailable(domain string) Issues {
+	return c.HTTPSAvailableContext(context.Background(), domain)
+}
+
+// HTTPSAvailableContext is like HTTPSAvailable, but the check is aborted
+// when `ctx` is done.
+func (c *Checker) HTTPSAvailableContext(ctx context.Context, domain string) Issues {
+	c = c.withDNSCache()
+	if ascii, idnIssues := toASCIIDomain(domain); len(idnIssues.Errors) == 0 {
+		domain = ascii
+	}
+
+	issues := c.checkDNS(ctx, domain)
+	if len(issues.Errors) > 0 {
+		return c.applyDomainPolicy(domain, issues)
+	}
+
+	resp, _, respIssues := getResponse(ctx, domain, c)
+	issues = combineIssues(issues, respIssues)
+	if resp != nil {
+		resp.Body.Close()
+	}
+	if len(respIssues.Errors) == 0 {
+		issues = combineIssues(issues, checkChain(*resp.TLS))
+		issues = combineIssues(issues, c.checkCertExpiry(*resp.TLS, time.Now()))
+	}
+
+	return c.applyDomainPolicy(domain, issues)
+}
@@ -0,0 +1,30 @@
+package hstspreload
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPSAvailable(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	c := &Checker{
+		HTTPSPort: srv.Listener.Addr().(*net.TCPAddr).Port,
+		ConnectTo: map[string]string{"sub.example.com": "127.0.0.1"},
+		Transport: srv.Client().Transport.(*http.Transport),
+	}
+	// No HSTS header is needed.
+	if issues := c.HTTPSAvailable("sub.example.com"); !issues.Match(Issues{}) {
+		t.Errorf(issuesShouldBeEmpty, issues)
+	}
+
+	// The certificate of the test server is not trusted by default.
+	c.Transport = nil
+	expected := Issues{Errors: []Issue{{Code: "domain.tls.invalid_cert_chain"}}}
+	if issues := c.HTTPSAvailable("sub.example.com"); !issues.Match(expected) {
+		t.Errorf(issuesShouldMatch, issues, expected)
+	}
+}
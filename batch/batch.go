@@ -1,18 +1,20 @@
 package batch
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/chromium/hstspreload"
 )
 
 const (
-	parallelism = 100
+	defaultParallelism = 100
 )
 
 // CertSummary summarizes interesting info about an X509.Certificate
@@ -25,94 +27,318 @@ type CertSummary struct {
 }
 
 // A Result holds the outcome of PreloadableDomain() for a given Domain.
+// If Domain contains punycode labels, UnicodeDomain holds its Unicode form.
+// If Domain resolves to more than one address, Addresses holds the results
+// of checking each address separately, and Issues includes
+// domain.multi_ip.inconsistent_hsts if they serve different HSTS headers
+// (see hstspreload.CheckAddressConsistency()). FirstRedirectHSTS holds the
+// first-redirect issues described in hstspreload.DomainResult.
+// Issues are in canonical order (see hstspreload.Issues.Sorted()), so that
+// results can be diffed between runs.
 type Result struct {
-	Domain          string                 `json:"domain"`
-	Header          string                 `json:"header,omitempty"`
-	ParsedHeader    hstspreload.HSTSHeader `json:"parsed_header,omitempty"`
-	Issues          hstspreload.Issues     `json:"issues"`
-	LeafCertSummary CertSummary            `json:"leaf_cert_summary,omitempty"`
+	Domain               string                        `json:"domain"`
+	UnicodeDomain        string                        `json:"unicode_domain,omitempty"`
+	Header               string                        `json:"header,omitempty"`
+	ParsedHeader         hstspreload.HSTSHeader        `json:"parsed_header,omitempty"`
+	Issues               hstspreload.Issues            `json:"issues"`
+	FirstRedirectHSTS    hstspreload.Issues            `json:"first_redirect_hsts"`
+	LeafCertSummary      CertSummary                   `json:"leaf_cert_summary,omitempty"`
+	Addresses            []hstspreload.AddressResult   `json:"addresses,omitempty"`
+	Response             *hstspreload.ResponseMetadata `json:"response,omitempty"`
+	InsecureFallbackUsed bool                          `json:"insecure_fallback_used,omitempty"`
+	HTTPRedirects        []hstspreload.RedirectHop     `json:"http_redirects,omitempty"`
+	HTTPSRedirects       []hstspreload.RedirectHop     `json:"https_redirects,omitempty"`
+	// TimedOut indicates that the check exceeded Options.Timeout, so the
+	// issues may be incomplete.
+	TimedOut bool `json:"timed_out,omitempty"`
+	// Started and Finished are when the check of the domain started and
+	// finished, and Duration is the time in between, e.g. to identify slow
+	// domains.
+	Started  time.Time     `json:"started"`
+	Finished time.Time     `json:"finished"`
+	Duration time.Duration `json:"duration_ns"`
 }
 
-func worker(in chan string, out chan Result) {
-	for d := range in {
+// checkDomain checks a single domain for Run(), and each of its addresses if
+// `checkAddresses` is set (see Options.CheckAddresses).
+func checkDomain(ctx context.Context, d string, c *hstspreload.Checker, checkAddresses bool) Result {
+	dr := c.PreloadableDomainResultContext(ctx, d)
+	resp := dr.Response
 
-		header, issues, resp := hstspreload.PreloadableDomainResponse(d)
-
-		r := Result{
-			Domain: d,
-			Issues: issues,
-		}
-		if resp != nil &&
-			resp.TLS != nil &&
-			resp.TLS.VerifiedChains != nil &&
-			len(resp.TLS.VerifiedChains) > 0 &&
-			len(resp.TLS.VerifiedChains[0]) > 0 {
-			leafCert := resp.TLS.VerifiedChains[0][0]
-			r.LeafCertSummary = CertSummary{
-				IssuerCommonName: leafCert.Issuer.CommonName,
-				NotBefore:        leafCert.NotBefore,
-				NotAfter:         leafCert.NotAfter,
-				SHA256Hash:       fmt.Sprintf("%x", sha256.Sum256(leafCert.Raw)),
-			}
+	r := Result{
+		Domain:               d,
+		Issues:               dr.Issues.Sorted(),
+		FirstRedirectHSTS:    dr.FirstRedirectHSTS.Sorted(),
+		Response:             dr.ResponseMetadata,
+		InsecureFallbackUsed: dr.InsecureFallbackUsed,
+		HTTPRedirects:        dr.HTTPRedirects,
+		HTTPSRedirects:       dr.HTTPSRedirects,
+	}
+	if u := hstspreload.UnicodeDomain(d); u != d {
+		r.UnicodeDomain = u
+	}
+	if resp != nil &&
+		resp.TLS != nil &&
+		resp.TLS.VerifiedChains != nil &&
+		len(resp.TLS.VerifiedChains) > 0 &&
+		len(resp.TLS.VerifiedChains[0]) > 0 {
+		leafCert := resp.TLS.VerifiedChains[0][0]
+		r.LeafCertSummary = CertSummary{
+			IssuerCommonName: leafCert.Issuer.CommonName,
+			NotBefore:        leafCert.NotBefore,
+			NotAfter:         leafCert.NotAfter,
+			SHA256Hash:       fmt.Sprintf("%x", sha256.Sum256(leafCert.Raw)),
 		}
-		if header != nil {
-			r.Header = *header
-			ParsedHeader, _ := hstspreload.ParseHeaderString(*header)
-			r.ParsedHeader = ParsedHeader
+	}
+	if dr.Header != nil {
+		r.Header = *dr.Header
+		r.ParsedHeader = *dr.ParsedHeader
+	}
+
+	if checkAddresses && resp != nil && (c == nil || !c.DomainChecks.HeaderOnly) {
+		if addresses, err := c.PreloadableAddressesContext(ctx, d); err == nil && len(addresses) > 1 {
+			r.Addresses = addresses
+			consistency := c.CheckAddressConsistency(d, addresses)
+			r.Issues.Errors = append(r.Issues.Errors, consistency.Errors...)
+			r.Issues.Warnings = append(r.Issues.Warnings, consistency.Warnings...)
+			r.Issues = r.Issues.Sorted()
 		}
+	}
+
+	return r
+}
+
+// Options configures a Runner.
+type Options struct {
+	// Checker is used to check all domains (e.g. for its
+	// SeverityOverrides). If nil, the default configuration is used.
+	Checker *hstspreload.Checker
+	// Parallelism is the maximum number of domains that are checked at the
+	// same time. If zero, 100 domains are checked at the same time.
+	Parallelism int
+	// Timeout limits the time spent checking each domain. Checks that
+	// exceed it are cancelled, and their results have TimedOut set. If
+	// zero, only the timeouts of the Checker apply.
+	Timeout time.Duration
+	// CheckAddresses enables checking each address of a reachable domain
+	// (see hstspreload.Checker.PreloadableAddresses()), and reporting
+	// inconsistencies between them. This makes another connection per
+	// address, so it is off by default.
+	CheckAddresses bool
+	// RateLimit is the maximum number of domains per second for which
+	// checks are started, so that large scans don't overwhelm small hosts.
+	// If zero, there is no limit.
+	RateLimit float64
+	// Progress is called after each result has been sent, with the number
+	// of results sent so far and the number of domains, e.g. to display
+	// an ETA. Calls are serialized. If nil, progress is not reported.
+	Progress func(done int, total int)
+}
+
+// A Runner checks batches of domains with bounded concurrency.
+type Runner struct {
+	opts Options
+
+	// checkDomain checks a single domain. It can be replaced in tests.
+	checkDomain func(ctx context.Context, d string, c *hstspreload.Checker) Result
+}
 
-		out <- r
+// New returns a Runner that uses `opts`.
+func New(opts Options) *Runner {
+	if opts.Parallelism <= 0 {
+		opts.Parallelism = defaultParallelism
 	}
+	return &Runner{opts: opts, checkDomain: func(ctx context.Context, d string, c *hstspreload.Checker) Result {
+		return checkDomain(ctx, d, c, opts.CheckAddresses)
+	}}
 }
 
-// Preloadable runs hstspreload.PreloadableDomain() over the given domains
-// in parallel, and returns the results in an arbitrary order.
-func Preloadable(domains []string) chan Result {
-	in := make(chan string)
-	out := make(chan Result)
-	for i := 0; i < parallelism; i++ {
-		go worker(in, out)
+// Run runs hstspreload.PreloadableDomain() over the given domains in
+// parallel, and sends the results to the returned channel in an arbitrary
+// order. The channel is closed after the last result.
+//
+// If `ctx` is done, Run stops starting checks, cancels the checks in
+// progress, and closes the channel without sending their results. Results
+// that were sent before are complete.
+func (r *Runner) Run(ctx context.Context, domains []string) <-chan Result {
+	return r.run(ctx, domains)
+}
+
+// RunRemovable is like Run, but runs hstspreload.RemovableDomain() instead
+// of PreloadableDomain(). Only the Domain, UnicodeDomain, Header,
+// ParsedHeader, Issues, and timing fields of the results are set.
+func (r *Runner) RunRemovable(ctx context.Context, domains []string) <-chan Result {
+	removable := *r
+	removable.checkDomain = checkRemovableDomain
+	return removable.run(ctx, domains)
+}
+
+// checkRemovableDomain checks a single domain for RunRemovable().
+func checkRemovableDomain(ctx context.Context, d string, c *hstspreload.Checker) Result {
+	header, issues := c.RemovableDomainContext(ctx, d)
+
+	r := Result{
+		Domain: d,
+		Issues: issues.Sorted(),
+	}
+	if u := hstspreload.UnicodeDomain(d); u != d {
+		r.UnicodeDomain = u
 	}
+	if header != nil {
+		r.Header = *header
+		r.ParsedHeader, _ = hstspreload.ParseHeaderString(*header)
+	}
+	return r
+}
 
+func (r *Runner) run(ctx context.Context, domains []string) chan Result {
+	in := make(chan string)
+	results := make(chan Result)
+
+	// Feed the domains to the workers, respecting the rate limit.
 	go func() {
-		for _, d := range domains {
-			in <- d
+		defer close(in)
+
+		var tick <-chan time.Time
+		if r.opts.RateLimit > 0 {
+			ticker := time.NewTicker(time.Duration(float64(time.Second) / r.opts.RateLimit))
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+
+		for i, d := range domains {
+			if tick != nil && i > 0 {
+				select {
+				case <-tick:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case in <- d:
+			case <-ctx.Done():
+				return
+			}
 		}
 	}()
 
-	results := make(chan Result)
-	go func() {
-		for range domains {
-			results <- (<-out)
+	var progressMu sync.Mutex
+	done := 0
+	reportProgress := func() {
+		if r.opts.Progress == nil {
+			return
 		}
-		close(in)
-		close(out)
+		progressMu.Lock()
+		defer progressMu.Unlock()
+		done++
+		r.opts.Progress(done, len(domains))
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < r.opts.Parallelism && i < len(domains); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range in {
+				result, ok := r.checkWithTimeout(ctx, d)
+				if !ok {
+					return
+				}
+				select {
+				case results <- result:
+				case <-ctx.Done():
+					return
+				}
+				reportProgress()
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
 		close(results)
 	}()
 
 	return results
 }
 
+// checkWithTimeout checks a single domain, and returns ok == false if `ctx`
+// is done.
+func (r *Runner) checkWithTimeout(ctx context.Context, d string) (result Result, ok bool) {
+	domainCtx := ctx
+	if r.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		domainCtx, cancel = context.WithTimeout(ctx, r.opts.Timeout)
+		defer cancel()
+	}
+
+	started := time.Now()
+	result = r.checkDomain(domainCtx, d, r.opts.Checker)
+	if ctx.Err() != nil {
+		return Result{}, false
+	}
+	result.TimedOut = domainCtx.Err() != nil
+	result.Started = started
+	result.Finished = time.Now()
+	result.Duration = result.Finished.Sub(started)
+	return result, true
+}
+
+// Preloadable runs hstspreload.PreloadableDomain() over the given domains
+// in parallel, and returns the results in an arbitrary order.
+func Preloadable(domains []string) chan Result {
+	return PreloadableWithChecker(domains, nil)
+}
+
+// PreloadableHeaders is like Preloadable, but only checks the HSTS header
+// of the initial HTTPS response of each domain, skipping the other checks
+// (see hstspreload.DomainCheckOptions.HeaderOnly) and the checks of the
+// individual addresses. This makes far fewer connections, e.g. to monitor
+// many preloaded domains.
+func PreloadableHeaders(domains []string) chan Result {
+	return PreloadableWithChecker(domains, &hstspreload.Checker{
+		DomainChecks: hstspreload.DomainCheckOptions{HeaderOnly: true},
+	})
+}
+
+// PreloadableWithChecker is like Preloadable, but checks all domains using
+// the configuration of `c` (e.g. its SeverityOverrides).
+func PreloadableWithChecker(domains []string, c *hstspreload.Checker) chan Result {
+	return New(Options{Checker: c}).run(context.Background(), domains)
+}
+
 // Fprint runs BatchPreloadable on the given domains and prints the results.
 // Aborts and returns an error if an error in JSON serialization is encountered..
 func Fprint(w io.Writer, domains []string) error {
-	fmt.Fprintln(w, "[")
-	results := Preloadable(domains)
-	for i := range domains {
-		r := <-results
-		j, err := json.MarshalIndent(r, "  ", "  ")
+	return FprintWithChecker(w, domains, nil)
+}
+
+// FprintWithChecker is like Fprint, but checks all domains using the
+// configuration of `c` (which may be nil).
+func FprintWithChecker(w io.Writer, domains []string, c *hstspreload.Checker) error {
+	return New(Options{Checker: c}).Fprint(context.Background(), w, domains)
+}
+
+// Fprint is like Run, but prints the results as a JSON array. Aborts and
+// returns an error if an error in JSON serialization is encountered, or if
+// `ctx` is done before all results are printed.
+func (r *Runner) Fprint(ctx context.Context, w io.Writer, domains []string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	fmt.Fprint(w, "[")
+	separator := "\n"
+	for result := range r.Run(ctx, domains) {
+		j, err := json.MarshalIndent(result, "  ", "  ")
 		if err != nil {
 			return err
 		}
-		comma := ""
-		if i != len(domains)-1 {
-			comma = ","
-		}
-		fmt.Fprintf(w, "  %s%s\n", j, comma)
+		fmt.Fprintf(w, "%s  %s", separator, j)
+		separator = ",\n"
 	}
-	fmt.Fprintln(w, "]")
+	fmt.Fprintln(w, "\n]")
 
-	return nil
+	return ctx.Err()
 }
 
 // Print is a wrapper for Fprint that prints to stdout.
@@ -0,0 +1,211 @@
+package batch
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chromium/hstspreload"
+)
+
+func testDomains(n int) []string {
+	domains := make([]string, n)
+	for i := range domains {
+		domains[i] = fmt.Sprintf("domain%d.example", i)
+	}
+	return domains
+}
+
+func TestRunParallelism(t *testing.T) {
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+
+	r := New(Options{Parallelism: 3})
+	r.checkDomain = func(ctx context.Context, d string, c *hstspreload.Checker) Result {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return Result{Domain: d}
+	}
+
+	domains := testDomains(20)
+	seen := make(map[string]bool)
+	for result := range r.Run(context.Background(), domains) {
+		seen[result.Domain] = true
+	}
+
+	if len(seen) != len(domains) {
+		t.Errorf("Expected %d results, got %d.", len(domains), len(seen))
+	}
+	if maxRunning > 3 {
+		t.Errorf("Expected at most 3 checks at the same time, got %d.", maxRunning)
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	r := New(Options{Timeout: 10 * time.Millisecond})
+	r.checkDomain = func(ctx context.Context, d string, c *hstspreload.Checker) Result {
+		if d == "slow.example" {
+			<-ctx.Done()
+		}
+		return Result{Domain: d}
+	}
+
+	timedOut := make(map[string]bool)
+	for result := range r.Run(context.Background(), []string{"fast.example", "slow.example"}) {
+		timedOut[result.Domain] = result.TimedOut
+	}
+
+	if len(timedOut) != 2 || timedOut["fast.example"] || !timedOut["slow.example"] {
+		t.Errorf("Unexpected results: %v", timedOut)
+	}
+}
+
+func TestRunProgressAndTiming(t *testing.T) {
+	var progress []int
+	r := New(Options{
+		Parallelism: 4,
+		Progress: func(done int, total int) {
+			if total != 10 {
+				t.Errorf("Unexpected total: %d", total)
+			}
+			progress = append(progress, done)
+		},
+	})
+	r.checkDomain = func(ctx context.Context, d string, c *hstspreload.Checker) Result {
+		time.Sleep(2 * time.Millisecond)
+		return Result{Domain: d}
+	}
+
+	for result := range r.Run(context.Background(), testDomains(10)) {
+		if result.Duration < 2*time.Millisecond || !result.Finished.Equal(result.Started.Add(result.Duration)) {
+			t.Errorf("Unexpected timing for %s: %s to %s (%s)", result.Domain, result.Started, result.Finished, result.Duration)
+		}
+	}
+
+	// The channel is closed after the last call to Progress.
+	if len(progress) != 10 {
+		t.Fatalf("Unexpected progress: %v", progress)
+	}
+	for i, done := range progress {
+		if done != i+1 {
+			t.Fatalf("Unexpected progress: %v", progress)
+		}
+	}
+}
+
+func TestRunRateLimit(t *testing.T) {
+	r := New(Options{RateLimit: 100})
+	r.checkDomain = func(ctx context.Context, d string, c *hstspreload.Checker) Result {
+		return Result{Domain: d}
+	}
+
+	start := time.Now()
+	n := 0
+	for range r.Run(context.Background(), testDomains(6)) {
+		n++
+	}
+
+	if n != 6 {
+		t.Errorf("Expected 6 results, got %d.", n)
+	}
+	// The checks after the first one start 10ms apart.
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Rate limit was not respected: 6 checks took %s.", elapsed)
+	}
+}
+
+func TestRunCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := New(Options{Parallelism: 2})
+	started := make(chan bool, 100)
+	r.checkDomain = func(ctx context.Context, d string, c *hstspreload.Checker) Result {
+		started <- true
+		<-ctx.Done()
+		return Result{Domain: d}
+	}
+
+	results := r.Run(ctx, testDomains(100))
+	<-started
+	cancel()
+
+	n := 0
+	for range results {
+		n++
+	}
+	if n != 0 {
+		t.Errorf("Cancelled checks should not have results, got %d.", n)
+	}
+	// One check was received from `started` above.
+	if n := len(started) + 1; n > 2 {
+		t.Errorf("Expected at most 2 checks to start, but %d did.", n)
+	}
+}
+
+func TestFprint(t *testing.T) {
+	r := New(Options{})
+	r.checkDomain = func(ctx context.Context, d string, c *hstspreload.Checker) Result {
+		return Result{Domain: d}
+	}
+
+	for _, tt := range []struct {
+		domains  []string
+		contains string
+	}{
+		{nil, "[\n]\n"},
+		{[]string{"a.example"}, "[\n  {\n    \"domain\": \"a.example\""},
+		{[]string{"a.example", "b.example"}, "\n  },\n  {\n"},
+	} {
+		var sb strings.Builder
+		if err := r.Fprint(context.Background(), &sb, tt.domains); err != nil {
+			t.Fatal(err)
+		}
+		out := sb.String()
+		if !strings.HasPrefix(out, "[\n") || !strings.HasSuffix(out, "]\n") || !strings.Contains(out, tt.contains) {
+			t.Errorf("Unexpected output for %v: %q", tt.domains, out)
+		}
+	}
+}
+
+func TestRunRemovable(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", "max-age=31536000")
+	}))
+	defer srv.Close()
+
+	c := &hstspreload.Checker{
+		HTTPSPort: srv.Listener.Addr().(*net.TCPAddr).Port,
+		ConnectTo: map[string]string{"example.com": "127.0.0.1"},
+		Transport: srv.Client().Transport.(*http.Transport),
+	}
+	r := New(Options{Checker: c})
+	r.checkDomain = nil // RunRemovable must not run the preload checks.
+
+	var results []Result
+	for result := range r.RunRemovable(context.Background(), []string{"example.com"}) {
+		results = append(results, result)
+	}
+	if len(results) != 1 {
+		t.Fatalf("Expected a single result, got %v", results)
+	}
+	if res := results[0]; res.Domain != "example.com" || res.Header != "max-age=31536000" || len(res.Issues.Errors) > 0 {
+		t.Errorf("Unexpected result: %#v", res)
+	}
+}
@@ -0,0 +1,157 @@
+package batch
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/chromium/hstspreload"
+)
+
+const defaultHeaderColumn = "header"
+
+// HeaderCSVOptions configures CheckHeaderCSV().
+type HeaderCSVOptions struct {
+	// HeaderColumn is the name of the column that holds the HSTS headers.
+	// If empty, the column named "header" is used. All other columns are
+	// passed through as metadata.
+	HeaderColumn string
+	// Removable checks the headers for removal requirements instead of
+	// preload requirements.
+	Removable bool
+	// Checker is used for its PolicyVersion, PromoteWarnings,
+	// SeverityOverrides, and MessageTemplates. It may be nil.
+	Checker *hstspreload.Checker
+}
+
+// A HeaderResult holds the outcome of checking the header of one CSV row.
+// Metadata holds the other columns of the row (e.g. a service name or
+// owner), keyed by column name.
+type HeaderResult struct {
+	Line        int                     `json:"line"`
+	Metadata    map[string]string       `json:"metadata,omitempty"`
+	Header      string                  `json:"header"`
+	Issues      hstspreload.Issues      `json:"issues"`
+	Eligibility hstspreload.Eligibility `json:"eligibility"`
+}
+
+// CheckHeaderCSV checks the HSTS headers in a CSV inventory (e.g. exported
+// from a CMDB) read from `r`. The first row names the columns. It returns
+// the names of the metadata columns in their original order, and one
+// result per row.
+func CheckHeaderCSV(r io.Reader, opts HeaderCSVOptions) (metadataColumns []string, results []HeaderResult, err error) {
+	headerColumn := opts.HeaderColumn
+	if headerColumn == "" {
+		headerColumn = defaultHeaderColumn
+	}
+
+	cr := csv.NewReader(r)
+	columns, err := cr.Read()
+	if err == io.EOF {
+		return nil, nil, errors.New("empty CSV input")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	headerIndex := -1
+	for i, name := range columns {
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		columns[i] = name
+		if name == headerColumn && headerIndex == -1 {
+			headerIndex = i
+		} else {
+			metadataColumns = append(metadataColumns, name)
+		}
+	}
+	if headerIndex == -1 {
+		return nil, nil, fmt.Errorf("no column named %q in %q", headerColumn, columns)
+	}
+
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return metadataColumns, results, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		line, _ := cr.FieldPos(0)
+
+		result := HeaderResult{Line: line, Metadata: make(map[string]string)}
+		for i, value := range record {
+			if i == headerIndex {
+				result.Header = strings.TrimSpace(value)
+			} else {
+				result.Metadata[columns[i]] = value
+			}
+		}
+
+		var issues hstspreload.Issues
+		if opts.Removable {
+			issues = opts.Checker.RemovableHeaderString(result.Header)
+		} else {
+			issues = opts.Checker.PreloadableHeaderString(result.Header)
+		}
+		result.Issues = applyHeaderPolicy(opts.Checker, issues).Sorted()
+		result.Eligibility = result.Issues.Eligibility()
+		results = append(results, result)
+	}
+}
+
+// applyHeaderPolicy applies the policies of `c` to `issues`, since header
+// checks don't use the checker. `c` may be nil.
+func applyHeaderPolicy(c *hstspreload.Checker, issues hstspreload.Issues) hstspreload.Issues {
+	if c == nil {
+		return issues
+	}
+	if len(c.PromoteWarnings) > 0 {
+		issues = issues.PromoteWarnings(c.PromoteWarnings...)
+	}
+	if len(c.SeverityOverrides) > 0 {
+		issues = issues.ApplySeverityOverrides(c.SeverityOverrides)
+	}
+	if len(c.MessageTemplates) > 0 {
+		issues = issues.ApplyMessageTemplates(c.MessageTemplates)
+	}
+	return issues
+}
+
+// WriteHeaderCSV writes `results` as CSV: the metadata columns (in the
+// order given by `metadataColumns`), followed by the header, its
+// eligibility, and the space-separated codes of its errors and warnings.
+func WriteHeaderCSV(w io.Writer, metadataColumns []string, results []HeaderResult) error {
+	cw := csv.NewWriter(w)
+	row := append(append([]string{}, metadataColumns...), "header", "eligibility", "errors", "warnings")
+	if err := cw.Write(row); err != nil {
+		return err
+	}
+	for _, result := range results {
+		row = row[:0]
+		for _, name := range metadataColumns {
+			row = append(row, result.Metadata[name])
+		}
+		row = append(row,
+			result.Header,
+			string(result.Eligibility),
+			issueCodes(result.Issues.Errors),
+			issueCodes(result.Issues.Warnings),
+		)
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// issueCodes joins the codes of `issues` with spaces.
+func issueCodes(issues []hstspreload.Issue) string {
+	codes := make([]string, len(issues))
+	for i, issue := range issues {
+		codes[i] = string(issue.Code)
+	}
+	return strings.Join(codes, " ")
+}
@@ -0,0 +1,87 @@
+package batch
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/chromium/hstspreload"
+)
+
+const testHeaderCSV = `service,hsts,owner
+billing,"max-age=63072000; includeSubDomains; preload",payments-team
+"legacy, internal",max-age=10,infra-team
+`
+
+func TestCheckHeaderCSV(t *testing.T) {
+	columns, results, err := CheckHeaderCSV(strings.NewReader(testHeaderCSV), HeaderCSVOptions{HeaderColumn: "hsts"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := []string{"service", "owner"}; !reflect.DeepEqual(columns, expected) {
+		t.Errorf("Expected metadata columns %v, got %v", expected, columns)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+
+	first := results[0]
+	if first.Line != 2 || first.Eligibility != hstspreload.Eligible ||
+		first.Header != "max-age=63072000; includeSubDomains; preload" {
+		t.Errorf("Unexpected result: %#v", first)
+	}
+	expectedMetadata := map[string]string{"service": "legacy, internal", "owner": "infra-team"}
+	if second := results[1]; !reflect.DeepEqual(second.Metadata, expectedMetadata) ||
+		second.Eligibility != hstspreload.Ineligible || second.Line != 3 {
+		t.Errorf("Unexpected result: %#v", second)
+	}
+}
+
+func TestCheckHeaderCSVPolicy(t *testing.T) {
+	c := &hstspreload.Checker{SeverityOverrides: hstspreload.SeverityOverrides{
+		"header.preloadable.max_age.below_1_year": hstspreload.SeverityWarning,
+	}}
+	_, results, err := CheckHeaderCSV(strings.NewReader("header\n\"max-age=10; includeSubDomains; preload\"\n"), HeaderCSVOptions{Checker: c})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Eligibility != hstspreload.EligibleWithWarnings {
+		t.Errorf("Expected the overrides to apply: %#v", results)
+	}
+}
+
+func TestCheckHeaderCSVErrors(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"service,owner\nbilling,payments-team\n",
+		"header,service\nmax-age=10\n",
+	} {
+		if _, _, err := CheckHeaderCSV(strings.NewReader(s), HeaderCSVOptions{}); err == nil {
+			t.Errorf("Expected an error for %q", s)
+		}
+	}
+}
+
+func TestWriteHeaderCSV(t *testing.T) {
+	columns, results, err := CheckHeaderCSV(strings.NewReader(testHeaderCSV), HeaderCSVOptions{HeaderColumn: "hsts"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b bytes.Buffer
+	if err := WriteHeaderCSV(&b, columns, results); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(b.String(), "\n")
+	if expected := "service,owner,header,eligibility,errors,warnings"; lines[0] != expected {
+		t.Errorf("Expected %q, got %q", expected, lines[0])
+	}
+	if !strings.HasPrefix(lines[1], "billing,payments-team,max-age=63072000; includeSubDomains; preload,eligible,,") {
+		t.Errorf("Unexpected row: %q", lines[1])
+	}
+	if !strings.HasPrefix(lines[2], `"legacy, internal",infra-team,max-age=10,ineligible,header.preloadable.`) {
+		t.Errorf("Unexpected row: %q", lines[2])
+	}
+}
@@ -0,0 +1,198 @@
+package batch
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/chromium/hstspreload"
+)
+
+// UnknownProvider is the provider of domains that don't match any entry of
+// a ProviderMap.
+const UnknownProvider = "unknown"
+
+// A ProviderMap identifies the hosting provider of an IP address, using
+// data supplied by the user (e.g. an IP-to-ASN dump, or known reverse DNS
+// names of a CDN).
+type ProviderMap struct {
+	networks    []providerNetwork
+	ptrSuffixes []providerPTRSuffix
+
+	// lookupAddr performs reverse DNS lookups. It can be replaced in
+	// tests.
+	lookupAddr func(ctx context.Context, addr string) ([]string, error)
+}
+
+type providerNetwork struct {
+	network  *net.IPNet
+	provider string
+}
+
+type providerPTRSuffix struct {
+	suffix   string
+	provider string
+}
+
+// ParseProviderMap reads a ProviderMap with one entry per line. Each entry
+// is either a network in CIDR notation or a reverse DNS suffix (starting
+// with a dot), followed by the name of the provider:
+//
+//	104.16.0.0/13 AS13335 Cloudflare
+//	.cloudfront.net Amazon CloudFront
+//
+// Empty lines and lines starting with # are ignored. If several networks
+// contain an address, the most specific one wins. Reverse DNS suffixes are
+// only used for addresses that are not in any network.
+func ParseProviderMap(r io.Reader) (*ProviderMap, error) {
+	m := &ProviderMap{}
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("line %d: expected a network or reverse DNS suffix followed by a provider", line)
+		}
+		provider := strings.Join(fields[1:], " ")
+
+		if strings.HasPrefix(fields[0], ".") {
+			m.ptrSuffixes = append(m.ptrSuffixes, providerPTRSuffix{
+				suffix:   strings.ToLower(strings.TrimSuffix(fields[0], ".")),
+				provider: provider,
+			})
+			continue
+		}
+		_, network, err := net.ParseCIDR(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err)
+		}
+		m.networks = append(m.networks, providerNetwork{network, provider})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	// Check more specific networks first.
+	sort.SliceStable(m.networks, func(i, j int) bool {
+		oi, _ := m.networks[i].network.Mask.Size()
+		oj, _ := m.networks[j].network.Mask.Size()
+		return oi > oj
+	})
+	return m, nil
+}
+
+// Provider returns the provider of `ip`, or UnknownProvider.
+func (m *ProviderMap) Provider(ip net.IP) string {
+	return m.provider(net.DefaultResolver, ip)
+}
+
+// provider is like Provider, but performs reverse DNS lookups using
+// `resolver`.
+func (m *ProviderMap) provider(resolver *net.Resolver, ip net.IP) string {
+	for _, n := range m.networks {
+		if n.network.Contains(ip) {
+			return n.provider
+		}
+	}
+
+	if len(m.ptrSuffixes) == 0 {
+		return UnknownProvider
+	}
+	lookupAddr := m.lookupAddr
+	if lookupAddr == nil {
+		lookupAddr = resolver.LookupAddr
+	}
+	names, err := lookupAddr(context.Background(), ip.String())
+	if err != nil {
+		return UnknownProvider
+	}
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSuffix(name, "."))
+		for _, s := range m.ptrSuffixes {
+			if strings.HasSuffix(name, s.suffix) {
+				return s.provider
+			}
+		}
+	}
+	return UnknownProvider
+}
+
+// DomainProvider resolves `domain` using c.Resolver and returns the
+// provider of its first address, or UnknownProvider. `c` may be nil, which
+// uses the default resolver.
+func (m *ProviderMap) DomainProvider(c *hstspreload.Checker, domain string) string {
+	resolver := net.DefaultResolver
+	if c != nil && c.Resolver != nil {
+		resolver = c.Resolver
+	}
+	ips, err := resolver.LookupIP(context.Background(), "ip", domain)
+	if err != nil || len(ips) == 0 {
+		return UnknownProvider
+	}
+	return m.provider(resolver, ips[0])
+}
+
+// A ProviderSummary summarizes the results for the domains of a single
+// hosting provider.
+type ProviderSummary struct {
+	Provider string `json:"provider"`
+	Domains  int    `json:"domains"`
+	// The number of domains with each eligibility verdict.
+	Eligibility map[hstspreload.Eligibility]int `json:"eligibility"`
+	// The number of domains with each issue (errors and warnings).
+	IssueCounts map[hstspreload.IssueCode]int `json:"issue_counts"`
+}
+
+// SummarizeByProvider groups `results` by the provider returned by
+// `providerOf` (e.g. using ProviderMap.DomainProvider), and counts the issues for
+// each provider. This helps registrars and hosts to find systemic HSTS
+// problems for their customers.
+//
+// Summaries are sorted by decreasing number of domains, then by provider.
+func SummarizeByProvider(results []Result, providerOf func(domain string) string) []ProviderSummary {
+	byProvider := make(map[string]*ProviderSummary)
+	for _, r := range results {
+		provider := providerOf(r.Domain)
+		s, ok := byProvider[provider]
+		if !ok {
+			s = &ProviderSummary{
+				Provider:    provider,
+				Eligibility: make(map[hstspreload.Eligibility]int),
+				IssueCounts: make(map[hstspreload.IssueCode]int),
+			}
+			byProvider[provider] = s
+		}
+
+		s.Domains++
+		s.Eligibility[r.Issues.Eligibility()]++
+		seen := make(map[hstspreload.IssueCode]bool)
+		for _, list := range [][]hstspreload.Issue{r.Issues.Errors, r.Issues.Warnings} {
+			for _, issue := range list {
+				if !seen[issue.Code] {
+					seen[issue.Code] = true
+					s.IssueCounts[issue.Code]++
+				}
+			}
+		}
+	}
+
+	summaries := make([]ProviderSummary, 0, len(byProvider))
+	for _, s := range byProvider {
+		summaries = append(summaries, *s)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Domains != summaries[j].Domains {
+			return summaries[i].Domains > summaries[j].Domains
+		}
+		return summaries[i].Provider < summaries[j].Provider
+	})
+	return summaries
+}
@@ -0,0 +1,106 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/chromium/hstspreload"
+)
+
+const testProviderMap = `
+# Test data.
+192.0.2.0/24 Example Hosting
+192.0.2.128/25 Example CDN
+.cdn.example.net Example Edge
+`
+
+func TestProviderMap(t *testing.T) {
+	m, err := ParseProviderMap(strings.NewReader(testProviderMap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.lookupAddr = func(ctx context.Context, addr string) ([]string, error) {
+		if addr == "198.51.100.1" {
+			return []string{"edge-1.cdn.example.net."}, nil
+		}
+		return nil, &net.DNSError{Err: "not found", Name: addr, IsNotFound: true}
+	}
+
+	for _, tt := range []struct {
+		ip       string
+		expected string
+	}{
+		{"192.0.2.1", "Example Hosting"},
+		{"192.0.2.200", "Example CDN"},
+		{"198.51.100.1", "Example Edge"},
+		{"203.0.113.1", UnknownProvider},
+	} {
+		if p := m.Provider(net.ParseIP(tt.ip)); p != tt.expected {
+			t.Errorf("[%s] Expected %q, got %q", tt.ip, tt.expected, p)
+		}
+	}
+
+	if _, err := ParseProviderMap(strings.NewReader("192.0.2.0/33 Invalid")); err == nil {
+		t.Errorf("Expected an error for an invalid network.")
+	}
+	if _, err := ParseProviderMap(strings.NewReader("192.0.2.0/24")); err == nil {
+		t.Errorf("Expected an error for a missing provider.")
+	}
+}
+
+func TestDomainProviderResolver(t *testing.T) {
+	m, err := ParseProviderMap(strings.NewReader(testProviderMap))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dials int32
+	c := &hstspreload.Checker{Resolver: &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			atomic.AddInt32(&dials, 1)
+			return nil, errors.New("no DNS server")
+		},
+	}}
+	if p := m.DomainProvider(c, "example.com"); p != UnknownProvider {
+		t.Errorf("Expected %q, got %q", UnknownProvider, p)
+	}
+	if atomic.LoadInt32(&dials) == 0 {
+		t.Errorf("Expected the lookup to use the resolver of the checker.")
+	}
+}
+
+func TestSummarizeByProvider(t *testing.T) {
+	noHeader := hstspreload.Issues{Errors: []hstspreload.Issue{{Code: "response.no_header"}}}
+	results := []Result{
+		{Domain: "a.example", Issues: noHeader},
+		{Domain: "b.example", Issues: hstspreload.Issues{}},
+		{Domain: "c.example", Issues: noHeader},
+		{Domain: "d.example", Issues: hstspreload.Issues{Warnings: []hstspreload.Issue{{Code: "tls.obsolete_cipher_suite"}}}},
+	}
+	providers := map[string]string{"a.example": "Host A", "b.example": "Host A", "c.example": "Host A", "d.example": "Host B"}
+
+	summaries := SummarizeByProvider(results, func(domain string) string { return providers[domain] })
+	expected := []ProviderSummary{
+		{
+			Provider:    "Host A",
+			Domains:     3,
+			Eligibility: map[hstspreload.Eligibility]int{hstspreload.Ineligible: 2, hstspreload.Eligible: 1},
+			IssueCounts: map[hstspreload.IssueCode]int{"response.no_header": 2},
+		},
+		{
+			Provider:    "Host B",
+			Domains:     1,
+			Eligibility: map[hstspreload.Eligibility]int{hstspreload.EligibleWithWarnings: 1},
+			IssueCounts: map[hstspreload.IssueCode]int{"tls.obsolete_cipher_suite": 1},
+		},
+	}
+	if !reflect.DeepEqual(summaries, expected) {
+		t.Errorf("Unexpected summaries: %#v", summaries)
+	}
+}
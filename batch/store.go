@@ -0,0 +1,32 @@
+package batch
+
+import (
+	"time"
+
+	"github.com/chromium/hstspreload/history"
+)
+
+// Record returns a history record of `r`, at the time when its check
+// finished (or now, if that is unknown).
+func (r Result) Record() history.Record {
+	t := r.Finished
+	if t.IsZero() {
+		t = time.Now()
+	}
+	var header *string
+	if r.Header != "" {
+		header = &r.Header
+	}
+	return history.NewRecord(r.Domain, t, header, r.Issues)
+}
+
+// StoreResults adds a record of each result to `s`, so that later runs can
+// be compared with this one (see history.IssueChanges()).
+func StoreResults(s history.Store, results []Result) error {
+	for _, r := range results {
+		if err := s.Add(r.Record()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package batch
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chromium/hstspreload"
+	"github.com/chromium/hstspreload/history"
+)
+
+func TestStoreResults(t *testing.T) {
+	s, err := history.NewDirStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	day0 := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	noHeader := hstspreload.Issues{Errors: []hstspreload.Issue{{Code: "response.no_header"}}}
+	runs := [][]Result{
+		{
+			{Domain: "a.example", Issues: noHeader, Finished: day0},
+			{Domain: "b.example", Header: "max-age=31536000", Issues: hstspreload.Issues{}, Finished: day0},
+		},
+		{
+			{Domain: "a.example", Header: "max-age=31536000", Issues: hstspreload.Issues{}, Finished: day0.Add(time.Hour)},
+			{Domain: "b.example", Header: "max-age=31536000", Issues: hstspreload.Issues{}, Finished: day0.Add(time.Hour)},
+		},
+	}
+	for _, results := range runs {
+		if err := StoreResults(s, results); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	records, err := s.Records("a.example", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Header != "" || records[1].Header != "max-age=31536000" {
+		t.Errorf("Unexpected records: %v", records)
+	}
+
+	changes, err := history.IssueChanges(s, []string{"a.example", "b.example"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Domain != "a.example" {
+		t.Errorf("Unexpected changes: %v", changes)
+	}
+}
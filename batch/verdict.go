@@ -0,0 +1,117 @@
+package batch
+
+import (
+	"sort"
+
+	"github.com/chromium/hstspreload"
+)
+
+// A Verdict summarizes a Result for an overview of a batch.
+type Verdict string
+
+// Verdicts, in the order in which GroupByVerdict() returns them.
+const (
+	// VerdictPreloadable indicates that the domain has no issues.
+	VerdictPreloadable Verdict = "preloadable"
+	// VerdictWarnings indicates that the domain has warnings, but no
+	// errors.
+	VerdictWarnings Verdict = "warnings"
+	// VerdictErrors indicates that the domain has errors.
+	VerdictErrors Verdict = "errors"
+	// VerdictUnreachable indicates that we could not connect to the domain,
+	// or that it rate limited our requests, so most checks did not run.
+	VerdictUnreachable Verdict = "unreachable"
+)
+
+var verdicts = []Verdict{VerdictPreloadable, VerdictWarnings, VerdictErrors, VerdictUnreachable}
+
+// unreachableCodes are the errors that make a domain unreachable.
+var unreachableCodes = map[hstspreload.IssueCode]bool{
+	"domain.tls.cannot_connect":     true,
+	"domain.tls.handshake_failure":  true,
+	"domain.tls.handshake_timeout":  true,
+	"domain.tcp.timeout":            true,
+	"domain.tcp.connection_refused": true,
+	"domain.dns.no_records":         true,
+	"domain.dns.timeout":            true,
+	"domain.http.response_timeout":  true,
+	"domain.http.rate_limited":      true,
+}
+
+// Verdict returns the verdict for `r`.
+func (r Result) Verdict() Verdict {
+	for _, e := range r.Issues.Errors {
+		if unreachableCodes[e.Code] {
+			return VerdictUnreachable
+		}
+	}
+	switch {
+	case len(r.Issues.Errors) > 0:
+		return VerdictErrors
+	case len(r.Issues.Warnings) > 0:
+		return VerdictWarnings
+	default:
+		return VerdictPreloadable
+	}
+}
+
+// A VerdictGroup contains the results with the same verdict, sorted by
+// domain.
+type VerdictGroup struct {
+	Verdict Verdict  `json:"verdict"`
+	Count   int      `json:"count"`
+	Results []Result `json:"results"`
+}
+
+// Pages splits the results of `g` into pages of at most `size` results. If
+// `size` is not positive, all results are on a single page. There is always
+// at least one page.
+func (g VerdictGroup) Pages(size int) [][]Result {
+	if size <= 0 || len(g.Results) <= size {
+		return [][]Result{g.Results}
+	}
+
+	var pages [][]Result
+	for start := 0; start < len(g.Results); start += size {
+		end := start + size
+		if end > len(g.Results) {
+			end = len(g.Results)
+		}
+		pages = append(pages, g.Results[start:end])
+	}
+	return pages
+}
+
+// GroupedResults is an overview of the results of a batch.
+type GroupedResults struct {
+	Total int `json:"total"`
+	// Groups contains a group for each verdict (even if it is empty), in a
+	// fixed order.
+	Groups []VerdictGroup `json:"groups"`
+}
+
+// GroupByVerdict groups `results` by their verdict.
+func GroupByVerdict(results []Result) GroupedResults {
+	byVerdict := make(map[Verdict][]Result)
+	for _, r := range results {
+		v := r.Verdict()
+		byVerdict[v] = append(byVerdict[v], r)
+	}
+
+	grouped := GroupedResults{Total: len(results), Groups: []VerdictGroup{}}
+	for _, v := range verdicts {
+		group := byVerdict[v]
+		if group == nil {
+			group = []Result{}
+		}
+		sort.Slice(group, func(i, j int) bool {
+			return group[i].Domain < group[j].Domain
+		})
+		grouped.Groups = append(grouped.Groups, VerdictGroup{
+			Verdict: v,
+			Count:   len(group),
+			Results: group,
+		})
+	}
+	return grouped
+}
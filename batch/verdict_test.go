@@ -0,0 +1,108 @@
+package batch
+
+import (
+	"testing"
+
+	"github.com/chromium/hstspreload"
+)
+
+var (
+	verdictPreloadable = Result{Domain: "b.example", Issues: hstspreload.Issues{}}
+	verdictWarnings    = Result{Domain: "c.example", Issues: hstspreload.Issues{
+		Warnings: []hstspreload.Issue{{Code: "redirects.http.serves_content"}},
+	}}
+	verdictErrors = Result{Domain: "d.example", Issues: hstspreload.Issues{
+		Errors:   []hstspreload.Issue{{Code: "header.preloadable.preload.missing"}},
+		Warnings: []hstspreload.Issue{{Code: "redirects.http.serves_content"}},
+	}}
+	verdictUnreachable = Result{Domain: "e.example", Issues: hstspreload.Issues{
+		Errors: []hstspreload.Issue{{Code: "domain.tls.cannot_connect"}},
+	}}
+)
+
+func TestVerdict(t *testing.T) {
+	for _, tt := range []struct {
+		result   Result
+		expected Verdict
+	}{
+		{verdictPreloadable, VerdictPreloadable},
+		{verdictWarnings, VerdictWarnings},
+		{verdictErrors, VerdictErrors},
+		{verdictUnreachable, VerdictUnreachable},
+		{Result{Domain: "f.example", Issues: hstspreload.Issues{
+			Errors: []hstspreload.Issue{{Code: "domain.http.rate_limited"}},
+		}}, VerdictUnreachable},
+		{Result{Domain: "g.example", Issues: hstspreload.Issues{
+			Errors: []hstspreload.Issue{{Code: "domain.dns.no_records"}},
+		}}, VerdictUnreachable},
+		{Result{Domain: "h.example", Issues: hstspreload.Issues{
+			Errors: []hstspreload.Issue{{Code: "domain.tcp.timeout"}},
+		}}, VerdictUnreachable},
+	} {
+		if v := tt.result.Verdict(); v != tt.expected {
+			t.Errorf("Verdict for %s should be %s, was %s.", tt.result.Domain, tt.expected, v)
+		}
+	}
+}
+
+func TestGroupByVerdict(t *testing.T) {
+	otherPreloadable := Result{Domain: "a.example", Issues: hstspreload.Issues{}}
+	grouped := GroupByVerdict([]Result{verdictUnreachable, verdictPreloadable, verdictErrors, otherPreloadable})
+
+	if grouped.Total != 4 {
+		t.Errorf("Wrong total: %d", grouped.Total)
+	}
+
+	expected := []struct {
+		verdict Verdict
+		domains []string
+	}{
+		{VerdictPreloadable, []string{"a.example", "b.example"}},
+		{VerdictWarnings, []string{}},
+		{VerdictErrors, []string{"d.example"}},
+		{VerdictUnreachable, []string{"e.example"}},
+	}
+	if len(grouped.Groups) != len(expected) {
+		t.Fatalf("Wrong number of groups: %d", len(grouped.Groups))
+	}
+	for i, g := range grouped.Groups {
+		if g.Verdict != expected[i].verdict || g.Count != len(expected[i].domains) || len(g.Results) != g.Count {
+			t.Errorf("Unexpected group #%d: %s with %d results", i, g.Verdict, g.Count)
+			continue
+		}
+		for j, r := range g.Results {
+			if r.Domain != expected[i].domains[j] {
+				t.Errorf("Unexpected domain in group %s: %s", g.Verdict, r.Domain)
+			}
+		}
+	}
+}
+
+func TestVerdictGroupPages(t *testing.T) {
+	g := VerdictGroup{Results: []Result{{Domain: "a"}, {Domain: "b"}, {Domain: "c"}}}
+
+	for _, tt := range []struct {
+		size  int
+		sizes []int
+	}{
+		{0, []int{3}},
+		{2, []int{2, 1}},
+		{3, []int{3}},
+		{10, []int{3}},
+	} {
+		pages := g.Pages(tt.size)
+		if len(pages) != len(tt.sizes) {
+			t.Errorf("Wrong number of pages for size %d: %d", tt.size, len(pages))
+			continue
+		}
+		for i, page := range pages {
+			if len(page) != tt.sizes[i] {
+				t.Errorf("Wrong size of page #%d for size %d: %d", i+1, tt.size, len(page))
+			}
+		}
+	}
+
+	if pages := (VerdictGroup{Results: []Result{}}).Pages(2); len(pages) != 1 || len(pages[0]) != 0 {
+		t.Errorf("An empty group should have a single empty page: %v", pages)
+	}
+}
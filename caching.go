@@ -0,0 +1,141 @@
+package hstspreload
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// A shared cache lifetime of at least this many seconds is considered
+	// long enough to risk serving a stale HSTS header.
+	longSharedCacheLifetime = 86400
+)
+
+// cacheStatusHeaders are the headers that common caches and CDNs use to
+// report whether a response was served from the cache.
+var cacheStatusHeaders = []string{"Cache-Status", "X-Cache", "X-Cache-Status", "CF-Cache-Status"}
+
+// CachingHeaders contains the caching-related headers of a response.
+type CachingHeaders struct {
+	CacheControl string `json:"cache_control,omitempty"`
+	Expires      string `json:"expires,omitempty"`
+	Age          string `json:"age,omitempty"`
+	Via          string `json:"via,omitempty"`
+	// The values of headers like X-Cache and CF-Cache-Status, in the form
+	// "Name: value".
+	CacheStatus string `json:"cache_status,omitempty"`
+}
+
+// newCachingHeaders returns the caching-related headers in `h`, or nil if
+// there are none.
+func newCachingHeaders(h http.Header) *CachingHeaders {
+	var statuses []string
+	for _, name := range cacheStatusHeaders {
+		if values := h.Values(name); len(values) > 0 {
+			statuses = append(statuses, name+": "+strings.Join(values, ", "))
+		}
+	}
+
+	c := CachingHeaders{
+		CacheControl: strings.Join(h.Values("Cache-Control"), ", "),
+		Expires:      h.Get("Expires"),
+		Age:          h.Get("Age"),
+		Via:          strings.Join(h.Values("Via"), ", "),
+		CacheStatus:  strings.Join(statuses, "; "),
+	}
+	if c == (CachingHeaders{}) {
+		return nil
+	}
+	return &c
+}
+
+// viaIntermediary returns whether the headers indicate that the response
+// passed through a cache between us and the origin server.
+func (c *CachingHeaders) viaIntermediary() bool {
+	return c.Age != "" || c.Via != "" || c.CacheStatus != ""
+}
+
+// sharedCacheLifetime returns how many seconds a shared cache may store the
+// response according to `cacheControl`, or 0 if it may not store it.
+func sharedCacheLifetime(cacheControl string) uint64 {
+	var maxAge, sMaxAge *uint64
+	for _, directive := range strings.Split(cacheControl, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(name) {
+		case "no-store", "no-cache", "private":
+			return 0
+		case "max-age", "s-maxage":
+			seconds, err := strconv.ParseUint(strings.Trim(value, `"`), 10, 64)
+			if err != nil {
+				continue
+			}
+			if strings.EqualFold(name, "s-maxage") {
+				sMaxAge = &seconds
+			} else {
+				maxAge = &seconds
+			}
+		}
+	}
+
+	switch {
+	case sMaxAge != nil:
+		return *sMaxAge
+	case maxAge != nil:
+		return *maxAge
+	}
+	return 0
+}
+
+// checkCaching warns if the HSTS-bearing response `resp` is stored by an
+// intermediary cache for a long time. If the cache also stores error pages
+// or outdated responses, clients can receive a stale (or no) HSTS header
+// long after the origin changes it.
+func checkCaching(resp *http.Response) Issues {
+	issues := Issues{}
+
+	c := newCachingHeaders(resp.Header)
+	if c == nil || !c.viaIntermediary() {
+		return issues
+	}
+	lifetime := sharedCacheLifetime(c.CacheControl)
+	if lifetime < longSharedCacheLifetime {
+		return issues
+	}
+
+	var evidence []string
+	for _, header := range []struct{ name, value string }{
+		{"Age", c.Age},
+		{"Via", c.Via},
+		{"", c.CacheStatus},
+	} {
+		switch {
+		case header.value == "":
+		case header.name == "":
+			evidence = append(evidence, "`"+header.value+"`")
+		default:
+			evidence = append(evidence, fmt.Sprintf("`%s: %s`", header.name, header.value))
+		}
+	}
+
+	return issues.addWarningWithParamsf(
+		IssueCode("response.caching.stale_header_risk"),
+		"Response cached by an intermediary",
+		map[string]string{
+			"cache_control":    c.CacheControl,
+			"age":              c.Age,
+			"via":              c.Via,
+			"cache_status":     c.CacheStatus,
+			"lifetime_seconds": strconv.FormatUint(lifetime, 10),
+		},
+		"The HTTPS response is served through a cache (%s), which may store it for %d seconds (%d days) (`Cache-Control: %s`). "+
+			"If the cache also stores error pages or outdated responses, browsers can receive them without "+
+			"the current HSTS header for that long, which has caused incidents for preloaded sites. "+
+			"Consider a shorter cache lifetime, or make sure that the cache adds the HSTS header to every response.",
+		strings.Join(evidence, ", "),
+		lifetime,
+		lifetime/86400,
+		c.CacheControl,
+	)
+}
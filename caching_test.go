@@ -0,0 +1,103 @@
+package hstspreload
+
+import (
+	"net/http"
+	"testing"
+)
+
+var sharedCacheLifetimeTests = []struct {
+	cacheControl string
+	expected     uint64
+}{
+	{"", 0},
+	{"max-age=3600", 3600},
+	{"public, max-age=86400", 86400},
+	{"max-age=60, s-maxage=604800", 604800},
+	{"S-MAXAGE=\"604800\"", 604800},
+	{"max-age=604800, private", 0},
+	{"no-store, max-age=604800", 0},
+	{"no-cache", 0},
+	{"max-age=invalid", 0},
+}
+
+func TestSharedCacheLifetime(t *testing.T) {
+	for _, tt := range sharedCacheLifetimeTests {
+		if lifetime := sharedCacheLifetime(tt.cacheControl); lifetime != tt.expected {
+			t.Errorf("Lifetime for %q should be %d, was %d.", tt.cacheControl, tt.expected, lifetime)
+		}
+	}
+}
+
+var checkCachingTests = []struct {
+	description    string
+	header         http.Header
+	expectedIssues Issues
+}{
+	{
+		"no caching headers",
+		http.Header{},
+		Issues{},
+	},
+	{
+		"long lifetime without an intermediary",
+		http.Header{"Cache-Control": {"public, max-age=604800"}},
+		Issues{},
+	},
+	{
+		"short lifetime with an intermediary",
+		http.Header{"Cache-Control": {"max-age=600"}, "Age": {"30"}},
+		Issues{},
+	},
+	{
+		"long lifetime with Age",
+		http.Header{"Cache-Control": {"public, max-age=604800"}, "Age": {"3000"}},
+		Issues{Warnings: []Issue{{Code: "response.caching.stale_header_risk"}}},
+	},
+	{
+		"long shared lifetime with Via",
+		http.Header{"Cache-Control": {"max-age=0, s-maxage=86400"}, "Via": {"1.1 varnish"}},
+		Issues{Warnings: []Issue{{Code: "response.caching.stale_header_risk"}}},
+	},
+	{
+		"long lifetime with a CDN cache status",
+		http.Header{"Cache-Control": {"max-age=31536000"}, "Cf-Cache-Status": {"HIT"}},
+		Issues{Warnings: []Issue{{Code: "response.caching.stale_header_risk"}}},
+	},
+	{
+		"private response with an intermediary",
+		http.Header{"Cache-Control": {"private, max-age=31536000"}, "X-Cache": {"MISS"}},
+		Issues{},
+	},
+}
+
+func TestCheckCaching(t *testing.T) {
+	for _, tt := range checkCachingTests {
+		issues := checkCaching(&http.Response{Header: tt.header})
+		if !issues.Match(tt.expectedIssues) {
+			t.Errorf("[%s] "+issuesShouldMatch, tt.description, issues, tt.expectedIssues)
+		}
+	}
+}
+
+func TestNewCachingHeaders(t *testing.T) {
+	if c := newCachingHeaders(http.Header{"Content-Type": {"text/html"}}); c != nil {
+		t.Errorf("Expected no caching headers, got %#v", c)
+	}
+
+	c := newCachingHeaders(http.Header{
+		"Cache-Control":   {"public", "max-age=600"},
+		"Age":             {"12"},
+		"Via":             {"1.1 varnish"},
+		"X-Cache":         {"HIT", "MISS"},
+		"Cf-Cache-Status": {"HIT"},
+	})
+	expected := CachingHeaders{
+		CacheControl: "public, max-age=600",
+		Age:          "12",
+		Via:          "1.1 varnish",
+		CacheStatus:  "X-Cache: HIT, MISS; CF-Cache-Status: HIT",
+	}
+	if c == nil || *c != expected {
+		t.Errorf("Unexpected caching headers: %#v", c)
+	}
+}
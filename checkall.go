@@ -0,0 +1,299 @@
+package hstspreload
+
+import (
+	"context"
+	"strings"
+
+	"github.com/chromium/hstspreload/chromium/preloadlist"
+)
+
+// CheckOptions configures CheckAll().
+type CheckOptions struct {
+	// Checker is used for all checks. If nil, the default configuration is
+	// used.
+	Checker *Checker
+	// PreloadList is used for the preload_status section. If nil, the
+	// section is left empty.
+	PreloadList *preloadlist.IndexedEntries
+}
+
+// CheckSections groups the issues of CheckAll() by the part of the site
+// configuration that they relate to. The names and JSON keys of the
+// sections are stable, so that frontends can rely on them.
+type CheckSections struct {
+	Format        Issues `json:"format"`
+	DNS           Issues `json:"dns"`
+	TLS           Issues `json:"tls"`
+	Header        Issues `json:"header"`
+	Redirects     Issues `json:"redirects"`
+	WWW           Issues `json:"www"`
+	PreloadStatus Issues `json:"preload_status"`
+	// Issues that don't belong to any other section, e.g. from checks
+	// registered using RegisterCheck().
+	Other Issues `json:"other"`
+}
+
+// A CheckAllResult is the outcome of CheckAll().
+type CheckAllResult struct {
+	Domain string `json:"domain"`
+	// Iff a single HSTS header was received, Header contains its value and
+	// ParsedHeader contains its parsed form.
+	Header       *string     `json:"header,omitempty"`
+	ParsedHeader *HSTSHeader `json:"parsed_header,omitempty"`
+	// Response summarizes the initial response over HTTPS, or is nil if we
+	// could not connect.
+	Response *ResponseMetadata `json:"response,omitempty"`
+	// Whether we retried without verifying certificates (see
+	// Checker.DisableInsecureFallback).
+	InsecureFallbackUsed bool `json:"insecure_fallback_used"`
+	// HTTPRedirects and HTTPSRedirects list the redirects followed from
+	// http://domain and https://domain.
+	HTTPRedirects  []RedirectHop `json:"http_redirects,omitempty"`
+	HTTPSRedirects []RedirectHop `json:"https_redirects,omitempty"`
+	// CNAME describes the CNAME target of the domain, or is nil if the
+	// domain has no CNAME records. Its TargetEntry is only set if
+	// CheckOptions.PreloadList is set.
+	CNAME *CNAMEInfo `json:"cname,omitempty"`
+	// TLSReport describes the TLS configuration of the domain, or is nil if
+	// we could not connect securely.
+	TLSReport *TLSReport `json:"tls_report,omitempty"`
+	// Sections contains the same issues as Issues, grouped by section.
+	Sections CheckSections `json:"sections"`
+	// All issues, in canonical order (see Issues.Sorted()).
+	Issues      Issues      `json:"issues"`
+	Eligibility Eligibility `json:"eligibility"`
+}
+
+// A section identifies a field of CheckSections. Sections are declared in
+// their canonical order.
+type section int
+
+const (
+	sectionFormat section = iota
+	sectionDNS
+	sectionTLS
+	sectionHeader
+	sectionRedirects
+	sectionWWW
+	sectionPreloadStatus
+	sectionOther
+)
+
+// sectionKeys contains the JSON keys of the fields of CheckSections.
+var sectionKeys = map[section]string{
+	sectionFormat:        "format",
+	sectionDNS:           "dns",
+	sectionTLS:           "tls",
+	sectionHeader:        "header",
+	sectionRedirects:     "redirects",
+	sectionWWW:           "www",
+	sectionPreloadStatus: "preload_status",
+	sectionOther:         "other",
+}
+
+// sectionPrefixes maps IssueCode prefixes to the section they belong to.
+var sectionPrefixes = []struct {
+	prefix  string
+	section section
+}{
+	{"domain.format.", sectionFormat},
+	{"domain.is_subdomain", sectionFormat},
+	{"internal.domain.name.", sectionFormat},
+	{"dns.", sectionDNS},
+	// Reported by getResponse(), like the other connection failures.
+	{"domain.dns.no_records", sectionTLS},
+	{"domain.dns.timeout", sectionTLS},
+	{"domain.dns.", sectionDNS},
+	{"domain.tcp.", sectionTLS},
+	{"domain.tls.", sectionTLS},
+	{"domain.ipv6.", sectionTLS},
+	{"domain.https.", sectionTLS},
+	{"domain.http.", sectionTLS},
+	{"tls.", sectionTLS},
+	{"response.", sectionHeader},
+	{"header.", sectionHeader},
+	{"alt_svc.", sectionHeader},
+	{"domain.multi_ip.", sectionHeader},
+	{"config.", sectionHeader},
+	{"redirects.", sectionRedirects},
+	{"domain.www.", sectionWWW},
+	{"internal.domain.www.", sectionWWW},
+	{"preload_status.", sectionPreloadStatus},
+	{"audit.", sectionPreloadStatus},
+	{"removal.", sectionPreloadStatus},
+}
+
+// sectionOf returns the section that issues with `code` belong to.
+func sectionOf(code IssueCode) section {
+	for _, sp := range sectionPrefixes {
+		if strings.HasPrefix(string(code), sp.prefix) {
+			return sp.section
+		}
+	}
+	return sectionOther
+}
+
+// issues returns the field of `s` for `sec`.
+func (s *CheckSections) issues(sec section) *Issues {
+	switch sec {
+	case sectionFormat:
+		return &s.Format
+	case sectionDNS:
+		return &s.DNS
+	case sectionTLS:
+		return &s.TLS
+	case sectionHeader:
+		return &s.Header
+	case sectionRedirects:
+		return &s.Redirects
+	case sectionWWW:
+		return &s.WWW
+	case sectionPreloadStatus:
+		return &s.PreloadStatus
+	default:
+		return &s.Other
+	}
+}
+
+// newCheckSections groups `issues` into sections.
+func newCheckSections(issues Issues) CheckSections {
+	s := CheckSections{}
+	for _, e := range issues.Errors {
+		iss := s.issues(sectionOf(e.Code))
+		iss.Errors = append(iss.Errors, e)
+	}
+	for _, w := range issues.Warnings {
+		iss := s.issues(sectionOf(w.Code))
+		iss.Warnings = append(iss.Warnings, w)
+	}
+	return s
+}
+
+// CheckAll performs all checks for `domain`, and returns the outcome as a
+// single document with the issues grouped into sections. This is intended
+// as a stable contract for frontends like hstspreload.org.
+//
+// If `ctx` is done before the checks finish, CheckAll returns ctx.Err().
+func CheckAll(ctx context.Context, domain string, opts CheckOptions) (CheckAllResult, error) {
+	c := opts.Checker.withDNSCache()
+	// Internationalized domains are checked in their ASCII form.
+	asciiDomain := domain
+	if ascii, idnIssues := toASCIIDomain(domain); len(idnIssues.Errors) == 0 {
+		asciiDomain = ascii
+	}
+
+	done := make(chan CheckAllResult, 1)
+	go func() {
+		r := c.preloadableDomainResult(ctx, domain)
+		issues := r.Issues
+		var cname *CNAMEInfo
+		if len(checkDomainFormat(domain).Errors) == 0 {
+			issues = combineIssues(issues, c.applyPartialPolicy(asciiDomain, c.checkDNS(ctx, asciiDomain)))
+			issues = combineIssues(issues, c.applyPartialPolicy(asciiDomain, c.checkDNSRecords(ctx, asciiDomain)))
+			if canonicalName := c.lookupCNAME(ctx, asciiDomain); canonicalName != "" {
+				cname = &CNAMEInfo{CanonicalName: canonicalName}
+				if opts.PreloadList != nil {
+					info, cnameIssues := checkCNAMETarget(asciiDomain, canonicalName, *opts.PreloadList)
+					cname = &info
+					issues = combineIssues(issues, c.applyPartialPolicy(asciiDomain, cnameIssues))
+				}
+			}
+		}
+		if opts.PreloadList != nil {
+			issues = combineIssues(issues, c.applyPartialPolicy(asciiDomain, checkPreloadStatus(asciiDomain, *opts.PreloadList)))
+		}
+
+		var tlsReport *TLSReport
+		if r.Response != nil && !r.InsecureFallbackUsed {
+			tlsReport, _ = c.TLSReportForDomainContext(ctx, asciiDomain)
+		}
+
+		issues = issues.Sorted()
+		done <- CheckAllResult{
+			Domain:               domain,
+			Header:               r.Header,
+			ParsedHeader:         r.ParsedHeader,
+			Response:             r.ResponseMetadata,
+			InsecureFallbackUsed: r.InsecureFallbackUsed,
+			HTTPRedirects:        r.HTTPRedirects,
+			HTTPSRedirects:       r.HTTPSRedirects,
+			CNAME:                cname,
+			TLSReport:            tlsReport,
+			Sections:             newCheckSections(issues),
+			Issues:               issues,
+			Eligibility:          issues.Eligibility(),
+		}
+	}()
+
+	select {
+	case r := <-done:
+		return r, nil
+	case <-ctx.Done():
+		return CheckAllResult{}, ctx.Err()
+	}
+}
+
+// checkDNS checks that `domain` resolves. This is skipped if `c` uses a
+// Dialer or a proxy, since names are then resolved remotely, or if c.ConnectTo
+// overrides the address of `domain`.
+//
+// `c` may be nil.
+func (c *Checker) checkDNS(ctx context.Context, domain string) Issues {
+	issues := Issues{}
+	if c.resolvesRemotely(domain) || c.hasConnectTo(domain) {
+		return issues
+	}
+
+	cache := NewDNSCache()
+	if c != nil && c.DNSCache != nil {
+		cache = c.DNSCache
+	}
+	addrs, err := cache.lookup(ctx, c.netDialer().Resolver, domain, c.timeout())
+	if err != nil {
+		return issues.addErrorWithParamsf(
+			IssueCode("dns.lookup_failed"),
+			"Cannot resolve domain",
+			map[string]string{"domain": domain, "error": err.Error()},
+			"We cannot resolve `%s` (%s).",
+			domain,
+			err,
+		)
+	}
+	if len(addrs) == 0 {
+		return issues.addErrorWithParamsf(
+			IssueCode("dns.no_addresses"),
+			"No addresses",
+			map[string]string{"domain": domain},
+			"`%s` does not resolve to any addresses.",
+			domain,
+		)
+	}
+	return issues
+}
+
+// checkPreloadStatus reports whether `domain` is already preloaded
+// according to `idx`.
+func checkPreloadStatus(domain string, idx preloadlist.IndexedEntries) Issues {
+	issues := Issues{}
+	entry, found := idx.Get(domain)
+	switch found {
+	case preloadlist.ExactEntryFound:
+		return issues.addWarningWithParamsf(
+			IssueCode("preload_status.preloaded"),
+			"Already preloaded",
+			map[string]string{"domain": domain},
+			"`%s` is already on the preload list.",
+			domain,
+		)
+	case preloadlist.AncestorEntryFound:
+		return issues.addWarningWithParamsf(
+			IssueCode("preload_status.covered_by_parent"),
+			"Already preloaded by a parent domain",
+			map[string]string{"domain": domain, "preloaded_name": entry.Name},
+			"`%s` is already preloaded, since its parent domain `%s` is on the preload list with include_subdomains.",
+			domain,
+			entry.Name,
+		)
+	}
+	return issues
+}
@@ -0,0 +1,101 @@
+package hstspreload
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/chromium/hstspreload/chromium/preloadlist"
+)
+
+func TestNewCheckSections(t *testing.T) {
+	issues := Issues{
+		Errors: []Issue{
+			{Code: "domain.is_subdomain"},
+			{Code: "domain.tls.cannot_connect"},
+			{Code: "example_org.caa.missing"},
+		},
+		Warnings: []Issue{
+			{Code: "redirects.http.serves_content"},
+			{Code: "domain.www.http.no_redirect"},
+			{Code: "alt_svc.inconsistent_header"},
+		},
+	}
+
+	s := newCheckSections(issues)
+	expected := []struct {
+		name     string
+		actual   Issues
+		expected Issues
+	}{
+		{"format", s.Format, Issues{Errors: []Issue{{Code: "domain.is_subdomain"}}}},
+		{"dns", s.DNS, Issues{}},
+		{"tls", s.TLS, Issues{Errors: []Issue{{Code: "domain.tls.cannot_connect"}}}},
+		{"header", s.Header, Issues{Warnings: []Issue{{Code: "alt_svc.inconsistent_header"}}}},
+		{"redirects", s.Redirects, Issues{Warnings: []Issue{{Code: "redirects.http.serves_content"}}}},
+		{"www", s.WWW, Issues{Warnings: []Issue{{Code: "domain.www.http.no_redirect"}}}},
+		{"preload_status", s.PreloadStatus, Issues{}},
+		{"other", s.Other, Issues{Errors: []Issue{{Code: "example_org.caa.missing"}}}},
+	}
+	for _, tt := range expected {
+		if !tt.actual.Match(tt.expected) {
+			t.Errorf("[%s] "+issuesShouldMatch, tt.name, tt.actual, tt.expected)
+		}
+	}
+}
+
+func TestCheckPreloadStatus(t *testing.T) {
+	idx := preloadlist.PreloadList{Entries: []preloadlist.Entry{
+		{Name: "example.com", Mode: "force-https", IncludeSubDomains: true},
+	}}.Index()
+
+	tests := []struct {
+		domain   string
+		expected Issues
+	}{
+		{"example.com", Issues{Warnings: []Issue{{Code: "preload_status.preloaded"}}}},
+		{"sub.example.com", Issues{Warnings: []Issue{{Code: "preload_status.covered_by_parent"}}}},
+		{"example.net", Issues{}},
+	}
+	for _, tt := range tests {
+		if issues := checkPreloadStatus(tt.domain, idx); !issues.Match(tt.expected) {
+			t.Errorf("[%s] "+issuesShouldMatch, tt.domain, issues, tt.expected)
+		}
+	}
+}
+
+func TestCheckAllDomainFormat(t *testing.T) {
+	idx := preloadlist.PreloadList{}.Index()
+	r, err := CheckAll(context.Background(), "example..com", CheckOptions{PreloadList: &idx})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Issues{Errors: []Issue{{Code: "domain.format.contains_double_dot"}}}
+	if !r.Issues.Match(expected) {
+		t.Errorf(issuesShouldMatch, r.Issues, expected)
+	}
+	if !r.Sections.Format.Match(expected) {
+		t.Errorf(issuesShouldMatch, r.Sections.Format, expected)
+	}
+	if r.Eligibility != Ineligible {
+		t.Errorf("Unexpected eligibility: %s", r.Eligibility)
+	}
+}
+
+func TestCheckAllCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Use a dialer that blocks until the check is abandoned.
+	block := make(chan struct{})
+	defer close(block)
+	c := &Checker{Dialer: DialerFunc(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		<-block
+		return nil, context.Canceled
+	})}
+
+	if _, err := CheckAll(ctx, "example.com", CheckOptions{Checker: c}); err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got: %v", err)
+	}
+}
@@ -0,0 +1,255 @@
+package hstspreload
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNilChecker(t *testing.T) {
+	var c *Checker
+	if c.timeout() != defaultTimeout {
+		t.Errorf("A nil checker should use the default timeout.")
+	}
+	if c.withDNSCache().DNSCache == nil {
+		t.Errorf("withDNSCache() should add a cache to a nil checker.")
+	}
+	if c.transport(false).TLSClientConfig != nil {
+		t.Errorf("A nil checker should use the default TLS config.")
+	}
+	if !c.transport(true).TLSClientConfig.InsecureSkipVerify {
+		t.Errorf("An insecure transport should skip verification.")
+	}
+}
+
+func TestCheckerTransport(t *testing.T) {
+	template := &http.Transport{
+		TLSClientConfig: &tls.Config{ServerName: "template.example"},
+	}
+	c := &Checker{Timeout: time.Second, Transport: template}
+
+	secure := c.transport(false)
+	if secure.TLSHandshakeTimeout != time.Second {
+		t.Errorf("Transport should use the checker's timeout.")
+	}
+	if secure.TLSClientConfig.ServerName != "template.example" {
+		t.Errorf("Transport should be based on the template.")
+	}
+
+	insecure := c.transport(true)
+	if !insecure.TLSClientConfig.InsecureSkipVerify {
+		t.Errorf("An insecure transport should skip verification.")
+	}
+	if template.TLSClientConfig.InsecureSkipVerify {
+		t.Errorf("The template transport must not be modified.")
+	}
+}
+
+func TestCheckersAreIndependent(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	fast := &Checker{Timeout: 50 * time.Millisecond}
+	slow := &Checker{Timeout: 5 * time.Second}
+
+	fastErr := make(chan error)
+	go func() {
+		_, err := getFirstResponse(context.Background(), srv.URL, fast)
+		fastErr <- err
+	}()
+	if _, err := getFirstResponse(context.Background(), srv.URL, slow); err != nil {
+		t.Errorf("The slow checker should not time out: %s", err)
+	}
+	if err := <-fastErr; err == nil {
+		t.Errorf("The fast checker should time out.")
+	}
+}
+
+func TestCheckerApplyPolicy(t *testing.T) {
+	issues := Issues{}.addWarningf("redirects.http.useless_header", "Summary", "Message")
+
+	var nilChecker *Checker
+	if !nilChecker.applyPolicy(issues).Match(issues) {
+		t.Errorf("A nil checker should not change issues.")
+	}
+
+	c := &Checker{PromoteWarnings: []IssueCode{"redirects.http.useless_header"}}
+	expected := Issues{}.addErrorf("redirects.http.useless_header", "Summary", "Message")
+	if promoted := c.applyPolicy(issues); !promoted.Match(expected) {
+		t.Errorf(issuesShouldMatch, promoted, expected)
+	}
+}
+
+var connectToTests = []struct {
+	addr     string
+	expected string
+}{
+	{"example.com:443", "192.0.2.1:8443"},
+	{"example.com:80", "192.0.2.2:80"},
+	{"www.example.com:443", "[2001:db8::1]:443"},
+	{"other.example:443", "other.example:443"},
+}
+
+func TestCheckerConnectTo(t *testing.T) {
+	c := &Checker{ConnectTo: map[string]string{
+		"example.com:443": "192.0.2.1:8443",
+		"example.com":     "192.0.2.2",
+		"www.example.com": "[2001:db8::1]",
+	}}
+	for _, tt := range connectToTests {
+		if addr := c.connectTo(tt.addr); addr != tt.expected {
+			t.Errorf("[%s] Expected %s, got %s", tt.addr, tt.expected, addr)
+		}
+	}
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Host", r.Host)
+	}))
+	defer srv.Close()
+
+	c = &Checker{
+		ConnectTo: map[string]string{"example.com:443": srv.Listener.Addr().String()},
+		Transport: srv.Client().Transport.(*http.Transport),
+	}
+	resp, err := getFirstResponse(context.Background(), "https://example.com/", c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := resp.Header.Get("X-Host"); h != "example.com" {
+		t.Errorf("Request should keep the original host, but got %q.", h)
+	}
+}
+
+func TestCheckerPorts(t *testing.T) {
+	var nilChecker *Checker
+	c := &Checker{HTTPSPort: 8443, HTTPPort: 8080}
+
+	tests := []struct {
+		actual, expected string
+	}{
+		{nilChecker.httpsURL("example.com"), "https://example.com"},
+		{nilChecker.httpURL("example.com"), "http://example.com"},
+		{nilChecker.httpsPort(), "443"},
+		{c.httpsURL("example.com"), "https://example.com:8443"},
+		{c.httpURL("example.com"), "http://example.com:8080"},
+		{c.httpsPort(), "8443"},
+	}
+	for _, tt := range tests {
+		if tt.actual != tt.expected {
+			t.Errorf("Expected %q, got %q.", tt.expected, tt.actual)
+		}
+	}
+}
+
+func TestGetResponseHTTPSPort(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	port := srv.Listener.Addr().(*net.TCPAddr).Port
+	resp, _, issues := getResponse(context.Background(), "127.0.0.1", &Checker{HTTPSPort: port})
+	expected := Issues{Errors: []Issue{{Code: "domain.tls.invalid_cert_chain"}}}
+	if !issues.Match(expected) {
+		t.Errorf(issuesShouldMatch, issues, expected)
+	}
+	if resp == nil {
+		t.Errorf("Expected a response from the configured port.")
+	}
+}
+
+func TestCheckerHasConnectTo(t *testing.T) {
+	c := &Checker{ConnectTo: map[string]string{
+		"example.com:443": "192.0.2.1",
+		"example.org":     "192.0.2.2",
+	}}
+	for host, expected := range map[string]bool{
+		"example.com":     true,
+		"example.org":     true,
+		"www.example.com": false,
+		"example.net":     false,
+	} {
+		if c.hasConnectTo(host) != expected {
+			t.Errorf("Expected hasConnectTo(%q) to be %t", host, expected)
+		}
+	}
+
+	var nilChecker *Checker
+	if nilChecker.hasConnectTo("example.com") {
+		t.Errorf("A nil checker has no ConnectTo entries.")
+	}
+}
+
+func TestCheckerConnectToBeforeDNS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", "max-age=31536000; includeSubDomains; preload")
+	}))
+	defer srv.Close()
+
+	c := &Checker{
+		HTTPSPort: srv.Listener.Addr().(*net.TCPAddr).Port,
+		ConnectTo: map[string]string{"example.com": "127.0.0.1"},
+		Transport: srv.Client().Transport.(*http.Transport),
+	}
+
+	// The domain doesn't have to resolve to the new server yet.
+	if issues := c.checkDNS(context.Background(), "example.com"); !issues.Match(Issues{}) {
+		t.Errorf(issuesShouldBeEmpty, issues)
+	}
+
+	results, err := c.PreloadableAddresses("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Address != "127.0.0.1" || results[0].Header == nil {
+		t.Fatalf("Expected a single result for the overridden address, got %v", results)
+	}
+	if issues := results[0].Issues; len(issues.Errors) > 0 {
+		t.Errorf("Unexpected errors: %v", issues.Errors)
+	}
+}
+
+func TestCheckerRootCAsAndCertificates(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	srv.StartTLS()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+	c := &Checker{
+		HTTPSPort: srv.Listener.Addr().(*net.TCPAddr).Port,
+		ConnectTo: map[string]string{"example.com": "127.0.0.1"},
+	}
+	connect := func() error {
+		if _, err := getFirstResponseWithTransport(context.Background(), c.httpsURL("example.com"), c.transport(false), c); err != nil {
+			return err
+		}
+		conn, err := c.dialTLS(context.Background(), "example.com:"+c.httpsPort())
+		if err != nil {
+			return err
+		}
+		conn.Close()
+		return nil
+	}
+
+	c.Certificates = srv.TLS.Certificates
+	if err := connect(); err == nil {
+		t.Errorf("The server certificate should not be trusted without RootCAs.")
+	}
+
+	c.RootCAs, c.Certificates = roots, nil
+	if err := connect(); err == nil {
+		t.Errorf("The server should require a client certificate.")
+	}
+
+	c.Certificates = srv.TLS.Certificates
+	if err := connect(); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+}
@@ -0,0 +1,181 @@
+package hstspreload
+
+// A NetworkRequirement describes the network access that a check needs.
+type NetworkRequirement string
+
+// Values of CheckInfo.Network.
+const (
+	// NetworkDNS indicates that the check resolves names.
+	NetworkDNS NetworkRequirement = "dns"
+	// NetworkHTTP indicates that the check connects over plain HTTP.
+	NetworkHTTP NetworkRequirement = "http"
+	// NetworkHTTPS indicates that the check connects over HTTPS.
+	NetworkHTTPS NetworkRequirement = "https"
+	// NetworkIPv6 indicates that the check connects over IPv6, and is
+	// skipped without IPv6 connectivity.
+	NetworkIPv6 NetworkRequirement = "ipv6"
+)
+
+// CheckInfo describes a check that PreloadableDomain() or CheckAll() can
+// run, so that frontends can render lists of checks without hard-coding
+// them.
+type CheckInfo struct {
+	// A stable identifier for the check, e.g. "http_redirects".
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// IssuePrefixes contains the prefixes of the IssueCodes that the check
+	// can report. Prefixes may be shared by several checks. Empty for
+	// checks registered using RegisterCheck().
+	IssuePrefixes []string `json:"issue_prefixes"`
+	// Section is the JSON key of the CheckSections field that contains the
+	// issues of the check (e.g. "redirects").
+	Section string `json:"section"`
+	// Network lists the network access that the check needs. Empty if the
+	// check runs offline.
+	Network []NetworkRequirement `json:"network"`
+	// CheckAllOnly indicates that the check is only run by CheckAll(), not
+	// by PreloadableDomain().
+	CheckAllOnly bool `json:"check_all_only"`
+	// Registered indicates that the check was registered using
+	// RegisterCheck().
+	Registered bool `json:"registered"`
+}
+
+// builtinChecks describes the built-in checks, in the order in which their
+// issues are reported.
+var builtinChecks = []CheckInfo{
+	{
+		Name:          "domain_format",
+		Description:   "Checks that the domain name is well-formed and can be preloaded (e.g. that it is not a public suffix).",
+		IssuePrefixes: []string{"domain.format.", "internal.domain.name."},
+	},
+	{
+		Name:          "domain_level",
+		Description:   "Checks that the domain is a registrable domain rather than a subdomain.",
+		IssuePrefixes: []string{"domain.is_subdomain"},
+	},
+	{
+		Name:          "dns",
+		Description:   "Checks that the domain resolves to at least one address.",
+		IssuePrefixes: []string{"dns."},
+		Network:       []NetworkRequirement{NetworkDNS},
+		CheckAllOnly:  true,
+	},
+	{
+		Name: "dns_records",
+		Description: "Checks that the domain (or a parent domain) has CAA records, and that its DNSSEC " +
+			"records validate. Only enabled by Checker.CheckDNSRecords, and only reports warnings.",
+		IssuePrefixes: []string{"domain.dns."},
+		Network:       []NetworkRequirement{NetworkDNS},
+		CheckAllOnly:  true,
+	},
+	{
+		Name: "cname",
+		Description: "Checks whether the CNAME target of the domain is preloaded, if CheckOptions.PreloadList is set. " +
+			"Only reports warnings, since the preloaded status of the target does not carry over to the domain.",
+		IssuePrefixes: []string{"dns.cname."},
+		Network:       []NetworkRequirement{NetworkDNS},
+		CheckAllOnly:  true,
+	},
+	{
+		Name: "tls",
+		Description: "Connects to the domain over HTTPS, and checks its certificate chain " +
+			"(signature algorithms, key usage, and expiry) and cipher suite.",
+		IssuePrefixes: []string{"domain.tls.", "domain.tcp.", "domain.dns.no_records", "domain.dns.timeout", "domain.https.", "domain.http.", "tls."},
+		Network:       []NetworkRequirement{NetworkDNS, NetworkHTTPS},
+	},
+	{
+		Name:          "hsts_header",
+		Description:   "Checks that the HTTPS response has a single HSTS header that satisfies the preload requirements.",
+		IssuePrefixes: []string{"response.", "header."},
+		Network:       []NetworkRequirement{NetworkDNS, NetworkHTTPS},
+	},
+	{
+		Name: "http_redirects",
+		Description: "Checks that http://domain immediately redirects to https://domain, " +
+			"and that the first redirect serves an HSTS header.",
+		IssuePrefixes: []string{"redirects.http.", "redirects.too_many", "redirects.follow_error", "redirects.insecure.", "redirects.target.", "redirects.retried"},
+		Network:       []NetworkRequirement{NetworkDNS, NetworkHTTP, NetworkHTTPS},
+	},
+	{
+		Name:          "https_redirects",
+		Description:   "Checks that redirects from https://domain stay on HTTPS and are not too long.",
+		IssuePrefixes: []string{"redirects.too_many", "redirects.follow_error", "redirects.insecure.", "redirects.target.", "redirects.retried"},
+		Network:       []NetworkRequirement{NetworkDNS, NetworkHTTPS},
+	},
+	{
+		Name: "www",
+		Description: "Checks that the www subdomain (if it exists) supports HTTPS, " +
+			"redirects from HTTP to HTTPS, and sends a consistent HSTS header.",
+		IssuePrefixes: []string{"domain.www.", "internal.domain.www."},
+		Network:       []NetworkRequirement{NetworkDNS, NetworkHTTP, NetworkHTTPS},
+	},
+	{
+		Name:          "alt_svc",
+		Description:   "Checks that alternative services advertised in the Alt-Svc header send a consistent HSTS header.",
+		IssuePrefixes: []string{"alt_svc."},
+		Network:       []NetworkRequirement{NetworkDNS, NetworkHTTPS},
+	},
+	{
+		Name: "protocols",
+		Description: "Checks that the domain serves the same HSTS header over HTTP/1.1, HTTP/2, and HTTP/3 " +
+			"(with Checker.HTTP3Transport). Only enabled by Checker.CheckProtocols, and only reports warnings.",
+		IssuePrefixes: []string{"response.protocol_mismatch"},
+		Network:       []NetworkRequirement{NetworkDNS, NetworkHTTPS},
+	},
+	{
+		Name: "security_headers",
+		Description: "Checks the security headers next to HSTS (Expect-CT, X-Content-Type-Options, and " +
+			"Content-Security-Policy upgrade-insecure-requests). Only enabled by Checker.CheckSecurityHeaders, " +
+			"and only reports informational warnings.",
+		IssuePrefixes: []string{"response.security_headers."},
+		Network:       []NetworkRequirement{NetworkDNS, NetworkHTTPS},
+	},
+	{
+		Name:          "ipv6",
+		Description:   "Checks that the domain serves the same TLS configuration and HSTS header over IPv6.",
+		IssuePrefixes: []string{"domain.ipv6."},
+		Network:       []NetworkRequirement{NetworkDNS, NetworkHTTPS, NetworkIPv6},
+	},
+	{
+		Name: "multi_ip",
+		Description: "Checks that all addresses of the domain serve an equivalent HSTS header. Only run by " +
+			"CheckAddressConsistency() (e.g. in batch checks with Options.CheckAddresses), and only reports warnings.",
+		IssuePrefixes: []string{"domain.multi_ip."},
+		Network:       []NetworkRequirement{NetworkDNS, NetworkHTTPS},
+	},
+	{
+		Name:          "preload_status",
+		Description:   "Checks whether the domain is already preloaded, if CheckOptions.PreloadList is set.",
+		IssuePrefixes: []string{"preload_status."},
+		CheckAllOnly:  true,
+	},
+}
+
+// DescribeChecks returns descriptions of the built-in checks, followed by
+// the checks registered using RegisterCheck() in the order in which they
+// are run.
+func DescribeChecks() []CheckInfo {
+	checks := make([]CheckInfo, 0, len(builtinChecks))
+	for _, info := range builtinChecks {
+		info.IssuePrefixes = append([]string{}, info.IssuePrefixes...)
+		info.Network = append([]NetworkRequirement{}, info.Network...)
+		info.Section = sectionKeys[sectionOf(IssueCode(info.IssuePrefixes[0]))]
+		checks = append(checks, info)
+	}
+
+	for _, name := range RegisteredChecks() {
+		checks = append(checks, CheckInfo{
+			Name:          name,
+			Description:   "Registered using RegisterCheck().",
+			IssuePrefixes: []string{},
+			Section:       sectionKeys[sectionOther],
+			// Registered checks receive the HTTPS response, and may make
+			// arbitrary requests.
+			Network:    []NetworkRequirement{NetworkDNS, NetworkHTTPS},
+			Registered: true,
+		})
+	}
+
+	return checks
+}
@@ -0,0 +1,62 @@
+package hstspreload
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestDescribeChecks(t *testing.T) {
+	defer resetRegisteredChecks()
+	RegisterCheck("example_org.caa", func(ctx context.Context, domain string, resp *http.Response) Issues {
+		return Issues{}
+	})
+
+	checks := DescribeChecks()
+	if len(checks) != len(builtinChecks)+1 {
+		t.Fatalf("Unexpected number of checks: %d", len(checks))
+	}
+
+	names := make(map[string]bool)
+	for _, info := range checks[:len(builtinChecks)] {
+		if names[info.Name] {
+			t.Errorf("Duplicate check name: %s", info.Name)
+		}
+		names[info.Name] = true
+
+		if info.Description == "" || len(info.IssuePrefixes) == 0 || info.Registered {
+			t.Errorf("Incomplete description of check %s: %#v", info.Name, info)
+		}
+		// All issues of a check should end up in the same section.
+		for _, prefix := range info.IssuePrefixes {
+			if section := sectionKeys[sectionOf(IssueCode(prefix))]; section != info.Section {
+				t.Errorf("Prefix %s of check %s is in section %s, expected %s.", prefix, info.Name, section, info.Section)
+			}
+		}
+	}
+
+	registered := checks[len(checks)-1]
+	if registered.Name != "example_org.caa" || !registered.Registered || registered.Section != "other" {
+		t.Errorf("Unexpected description of registered check: %#v", registered)
+	}
+}
+
+func TestDescribeChecksSections(t *testing.T) {
+	expected := map[string]string{
+		"domain_format":  "format",
+		"dns":            "dns",
+		"cname":          "dns",
+		"tls":            "tls",
+		"ipv6":           "tls",
+		"hsts_header":    "header",
+		"multi_ip":       "header",
+		"http_redirects": "redirects",
+		"www":            "www",
+		"preload_status": "preload_status",
+	}
+	for _, info := range DescribeChecks() {
+		if section, ok := expected[info.Name]; ok && info.Section != section {
+			t.Errorf("Check %s should be in section %s, but is in %s.", info.Name, section, info.Section)
+		}
+	}
+}
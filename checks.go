@@ -0,0 +1,76 @@
+package hstspreload
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// A Check is an additional requirement that runs alongside the built-in
+// checks in PreloadableDomain() (and therefore also in the batch package).
+//
+// `resp` is the initial response over HTTPS, and is never `nil` when a
+// Check is called: registered checks are skipped if we cannot connect to
+// the domain.
+// The returned Issues are combined with the built-in issues, so a Check
+// should use its own IssueCode namespace (e.g. "example_org.caa.missing").
+type Check func(ctx context.Context, domain string, resp *http.Response) Issues
+
+type registeredCheck struct {
+	name  string
+	check Check
+}
+
+var (
+	registeredChecksMu sync.RWMutex
+	registeredChecks   []registeredCheck
+)
+
+// RegisterCheck makes a Check available to PreloadableDomain() under the
+// provided name. Checks are run in the order in which they were registered.
+//
+// This is intended to be called from the init() function of an external
+// package. If RegisterCheck is called twice with the same name or if
+// `check` is nil, it panics.
+func RegisterCheck(name string, check Check) {
+	registeredChecksMu.Lock()
+	defer registeredChecksMu.Unlock()
+
+	if check == nil {
+		panic("hstspreload: RegisterCheck check is nil")
+	}
+	for _, rc := range registeredChecks {
+		if rc.name == name {
+			panic("hstspreload: RegisterCheck called twice for check " + name)
+		}
+	}
+	registeredChecks = append(registeredChecks, registeredCheck{name, check})
+}
+
+// RegisteredChecks returns the names of the registered checks, in the
+// order in which they are run.
+func RegisteredChecks() []string {
+	registeredChecksMu.RLock()
+	defer registeredChecksMu.RUnlock()
+
+	names := make([]string, 0, len(registeredChecks))
+	for _, rc := range registeredChecks {
+		names = append(names, rc.name)
+	}
+	return names
+}
+
+// runRegisteredChecks runs all registered checks in registration order and
+// combines their issues.
+func runRegisteredChecks(ctx context.Context, domain string, resp *http.Response) Issues {
+	registeredChecksMu.RLock()
+	checks := make([]registeredCheck, len(registeredChecks))
+	copy(checks, registeredChecks)
+	registeredChecksMu.RUnlock()
+
+	issues := Issues{}
+	for _, rc := range checks {
+		issues = combineIssues(issues, rc.check(ctx, domain, resp))
+	}
+	return issues
+}
@@ -0,0 +1,54 @@
+package hstspreload
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func resetRegisteredChecks() {
+	registeredChecksMu.Lock()
+	registeredChecks = nil
+	registeredChecksMu.Unlock()
+}
+
+func TestRegisterCheck(t *testing.T) {
+	defer resetRegisteredChecks()
+
+	RegisterCheck("first", func(ctx context.Context, domain string, resp *http.Response) Issues {
+		return Issues{}.addErrorf("test.first", "First", "First check for %s", domain)
+	})
+	RegisterCheck("second", func(ctx context.Context, domain string, resp *http.Response) Issues {
+		return Issues{}.addWarningf("test.second", "Second", "Second check for %s", domain)
+	})
+
+	names := RegisteredChecks()
+	if len(names) != 2 || names[0] != "first" || names[1] != "second" {
+		t.Errorf("Unexpected registered checks: %v", names)
+	}
+
+	issues := runRegisteredChecks(context.Background(), "example.com", &http.Response{})
+	expected := Issues{
+		Errors:   []Issue{{Code: "test.first", Message: "First check for example.com"}},
+		Warnings: []Issue{{Code: "test.second", Message: "Second check for example.com"}},
+	}
+	if !issues.Match(expected) {
+		t.Errorf(issuesShouldMatch, issues, expected)
+	}
+}
+
+func TestRegisterCheckDuplicate(t *testing.T) {
+	defer resetRegisteredChecks()
+
+	check := func(ctx context.Context, domain string, resp *http.Response) Issues {
+		return Issues{}
+	}
+	RegisterCheck("duplicate", check)
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Registering a duplicate check should panic.")
+		}
+	}()
+	RegisterCheck("duplicate", check)
+}
@@ -0,0 +1,133 @@
+// Package preloadapi is a client for the API of the HSTS preload list
+// submission site at https://hstspreload.org/.
+package preloadapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/chromium/hstspreload"
+	"github.com/chromium/hstspreload/chromium/preloadlist"
+)
+
+const (
+	// DefaultBaseURL is the base URL of the v2 API of hstspreload.org.
+	DefaultBaseURL = "https://hstspreload.org/api/v2"
+
+	defaultTimeout = 10 * time.Second
+)
+
+// A Status is the state of a domain on hstspreload.org.
+type Status string
+
+// Values of DomainStatus.Status.
+const (
+	StatusUnknown                 Status = "unknown"
+	StatusPending                 Status = "pending"
+	StatusPreloaded               Status = "preloaded"
+	StatusRejected                Status = "rejected"
+	StatusRemoved                 Status = "removed"
+	StatusPendingRemoval          Status = "pending-removal"
+	StatusPendingAutomatedRemoval Status = "pending-automated-removal"
+)
+
+// DomainStatus is the response of the /status endpoint.
+type DomainStatus struct {
+	// The domain name, in the canonical form used by the site.
+	Name   string `json:"name"`
+	Status Status `json:"status"`
+	// An explanation of the status, e.g. the reason for a rejection. May
+	// be empty.
+	Message string `json:"message,omitempty"`
+}
+
+// A Client makes requests to the hstspreload.org API.
+//
+// The zero value is ready to use.
+type Client struct {
+	// BaseURL is the URL that endpoint paths are appended to. If empty,
+	// DefaultBaseURL is used.
+	BaseURL string
+	// HTTPClient makes the requests. If nil, a client with a timeout of 10
+	// seconds is used.
+	HTTPClient *http.Client
+}
+
+// Status returns the status of `domain` on hstspreload.org.
+func (c *Client) Status(ctx context.Context, domain string) (DomainStatus, error) {
+	var status DomainStatus
+	err := c.do(ctx, "GET", "/status", url.Values{"domain": {domain}}, &status)
+	return status, err
+}
+
+// Pending returns the entries of the domains that have been submitted, but
+// are not on the preload list yet.
+func (c *Client) Pending(ctx context.Context) ([]preloadlist.Entry, error) {
+	var entries []preloadlist.Entry
+	err := c.do(ctx, "GET", "/pending", nil, &entries)
+	return entries, err
+}
+
+// PendingRemoval returns the names of the domains that are pending removal
+// from the preload list.
+func (c *Client) PendingRemoval(ctx context.Context) ([]string, error) {
+	var domains []string
+	err := c.do(ctx, "GET", "/pending-removal", nil, &domains)
+	return domains, err
+}
+
+// Submit submits `domain` for inclusion in the preload list. hstspreload.org
+// checks the domain before accepting the submission, and returns the
+// issues that it found (see hstspreload.PreloadableDomain()). The domain is
+// only accepted if there are no errors.
+func (c *Client) Submit(ctx context.Context, domain string) (hstspreload.Issues, error) {
+	var issues hstspreload.Issues
+	err := c.do(ctx, "POST", "/submit", url.Values{"domain": {domain}}, &issues)
+	return issues, err
+}
+
+// do makes a request to the endpoint at `path`, and decodes the JSON
+// response into `v`.
+func (c *Client) do(ctx context.Context, method string, path string, query url.Values, v interface{}) error {
+	u := c.baseURL() + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s: status code %d", method, path, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("%s %s: invalid response: %s", method, path, err)
+	}
+	return nil
+}
+
+func (c *Client) baseURL() string {
+	if c == nil || c.BaseURL == "" {
+		return DefaultBaseURL
+	}
+	return strings.TrimSuffix(c.BaseURL, "/")
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c == nil || c.HTTPClient == nil {
+		return &http.Client{Timeout: defaultTimeout}
+	}
+	return c.HTTPClient
+}
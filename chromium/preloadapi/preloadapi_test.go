@@ -0,0 +1,104 @@
+package preloadapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/chromium/hstspreload/chromium/preloadlist"
+)
+
+func testServer(t *testing.T) (*httptest.Server, *Client) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v2/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"name": "` + r.URL.Query().Get("domain") + `", "status": "rejected", "message": "No HSTS header."}`))
+	})
+	mux.HandleFunc("/api/v2/pending", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"name": "example.com", "include_subdomains": true, "mode": "force-https"}]`))
+	})
+	mux.HandleFunc("/api/v2/pending-removal", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`["example.net", "example.org"]`))
+	})
+	mux.HandleFunc("/api/v2/submit", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			http.Error(w, "Wrong method", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Write([]byte(`{"errors": [{"code": "response.no_header", "summary": "No HSTS header", "message": "..."}], "warnings": []}`))
+	})
+	mux.HandleFunc("/api/v2/broken", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{`))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	return srv, &Client{BaseURL: srv.URL + "/api/v2/", HTTPClient: srv.Client()}
+}
+
+func TestStatus(t *testing.T) {
+	_, c := testServer(t)
+
+	status, err := c.Status(context.Background(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := DomainStatus{Name: "example.com", Status: StatusRejected, Message: "No HSTS header."}
+	if status != expected {
+		t.Errorf("Unexpected status: %#v", status)
+	}
+}
+
+func TestPending(t *testing.T) {
+	_, c := testServer(t)
+
+	entries, err := c.Pending(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []preloadlist.Entry{{Name: "example.com", Mode: preloadlist.ForceHTTPS, IncludeSubDomains: true}}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("Unexpected entries: %#v", entries)
+	}
+}
+
+func TestPendingRemoval(t *testing.T) {
+	_, c := testServer(t)
+
+	domains, err := c.PendingRemoval(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(domains, []string{"example.net", "example.org"}) {
+		t.Errorf("Unexpected domains: %#v", domains)
+	}
+}
+
+func TestSubmit(t *testing.T) {
+	_, c := testServer(t)
+
+	issues, err := c.Submit(context.Background(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues.Errors) != 1 || issues.Errors[0].Code != "response.no_header" || len(issues.Warnings) != 0 {
+		t.Errorf("Unexpected issues: %#v", issues)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	srv, c := testServer(t)
+
+	if err := c.do(context.Background(), "GET", "/missing", nil, &struct{}{}); err == nil {
+		t.Errorf("Expected an error for a missing endpoint.")
+	}
+	if err := c.do(context.Background(), "GET", "/broken", nil, &struct{}{}); err == nil {
+		t.Errorf("Expected an error for an invalid response.")
+	}
+
+	srv.Close()
+	if _, err := c.Status(context.Background(), "example.com"); err == nil {
+		t.Errorf("Expected an error if the server is unavailable.")
+	}
+}
@@ -0,0 +1,213 @@
+package preloadlist
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	cacheListFile = "preloadlist.json"
+	cacheMetaFile = "preloadlist.meta.json"
+)
+
+// A Cache keeps a copy of the latest preload list in a directory, so that
+// the multi-megabyte list doesn't have to be downloaded on every use. The
+// copy is revalidated with conditional requests (using the ETag and
+// Last-Modified headers of the previous response), and used as a fallback
+// if the list cannot be retrieved.
+//
+// A Cache must not be used by several processes at the same time.
+type Cache struct {
+	// Dir is the directory that holds the cached list. It is created if
+	// necessary.
+	Dir string
+	// URL is the URL of the list in base 64 (see NewFromChromiumURL()). If
+	// empty, LatestChromiumURL is used.
+	URL string
+	// HTTPClient makes the requests. If nil, a client with a timeout of 10
+	// seconds is used.
+	HTTPClient *http.Client
+	// MaxAge is how long a cached list is used without revalidating it.
+	// If zero, the cached list is revalidated on every use.
+	MaxAge time.Duration
+}
+
+// NewCached returns a Cache of the latest preload list in `dir`.
+func NewCached(dir string) *Cache {
+	return &Cache{Dir: dir}
+}
+
+// CacheInfo describes the list returned by Cache.Load().
+type CacheInfo struct {
+	// Retrieved is when the list was downloaded.
+	Retrieved time.Time `json:"retrieved"`
+	// Validated is when the list was last known to be the latest one.
+	Validated time.Time `json:"validated"`
+	// Stale indicates that the list could not be revalidated (e.g. because
+	// we are offline), so the cached list may be out of date.
+	Stale bool `json:"stale"`
+}
+
+// cacheMeta is the format of the metadata file of a Cache.
+type cacheMeta struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	Retrieved    time.Time `json:"retrieved"`
+	Validated    time.Time `json:"validated"`
+}
+
+func (c *Cache) url() string {
+	if c.URL == "" {
+		return LatestChromiumURL
+	}
+	return c.URL
+}
+
+func (c *Cache) client() *http.Client {
+	if c.HTTPClient == nil {
+		return &http.Client{Timeout: 10 * time.Second}
+	}
+	return c.HTTPClient
+}
+
+// Load returns the latest preload list: the cached list if it is younger
+// than c.MaxAge or has not changed, or else the list that is downloaded
+// (and cached). If the list cannot be retrieved, Load falls back to the
+// cached list and sets info.Stale. It only returns an error if there is no
+// usable list.
+func (c *Cache) Load(ctx context.Context) (list PreloadList, info CacheInfo, err error) {
+	meta, cached, cacheErr := c.read()
+	if cacheErr == nil {
+		info = CacheInfo{Retrieved: meta.Retrieved, Validated: meta.Validated}
+		if c.MaxAge > 0 && time.Since(meta.Validated) < c.MaxAge {
+			return cached, info, nil
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url(), nil)
+	if err != nil {
+		return PreloadList{}, CacheInfo{}, err
+	}
+	if cacheErr == nil {
+		if meta.ETag != "" {
+			req.Header.Set("If-None-Match", meta.ETag)
+		}
+		if meta.LastModified != "" {
+			req.Header.Set("If-Modified-Since", meta.LastModified)
+		}
+	}
+
+	fresh, meta, err := c.fetch(req, meta, cacheErr == nil)
+	if err != nil {
+		if cacheErr == nil {
+			info.Stale = true
+			return cached, info, nil
+		}
+		return PreloadList{}, CacheInfo{}, err
+	}
+	list = cached
+	if fresh != nil {
+		list = *fresh
+	}
+
+	// The list is up to date even if it cannot be cached.
+	c.write(meta, fresh)
+	return list, CacheInfo{Retrieved: meta.Retrieved, Validated: meta.Validated}, nil
+}
+
+// fetch makes the (conditional) request `req`, and returns the downloaded
+// list and the updated metadata. If the cached list is still current, the
+// returned list is nil.
+func (c *Cache) fetch(req *http.Request, meta cacheMeta, conditional bool) (*PreloadList, cacheMeta, error) {
+	resp, err := c.client().Do(req)
+	if err != nil {
+		return nil, meta, err
+	}
+	defer resp.Body.Close()
+
+	now := time.Now()
+	switch {
+	case resp.StatusCode == http.StatusNotModified && conditional:
+		meta.Validated = now
+		return nil, meta, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, meta, fmt.Errorf("status code %d", resp.StatusCode)
+	}
+
+	list, err := Parse(base64.NewDecoder(base64.StdEncoding, resp.Body))
+	if err != nil {
+		return nil, meta, err
+	}
+	return &list, cacheMeta{
+		URL:          c.url(),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Retrieved:    now,
+		Validated:    now,
+	}, nil
+}
+
+// read returns the cached list and its metadata.
+func (c *Cache) read() (cacheMeta, PreloadList, error) {
+	var meta cacheMeta
+	var list PreloadList
+	if err := readJSONFile(filepath.Join(c.Dir, cacheMetaFile), &meta); err != nil {
+		return meta, list, err
+	}
+	if meta.URL != c.url() {
+		return meta, list, fmt.Errorf("the cached list is from %s", meta.URL)
+	}
+	if err := readJSONFile(filepath.Join(c.Dir, cacheListFile), &list); err != nil {
+		return meta, list, err
+	}
+	return meta, list, nil
+}
+
+// write updates the cached metadata, and the cached list unless `list` is
+// nil.
+func (c *Cache) write(meta cacheMeta, list *PreloadList) error {
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return err
+	}
+	if list != nil {
+		if err := writeJSONFile(filepath.Join(c.Dir, cacheListFile), list); err != nil {
+			return err
+		}
+	}
+	return writeJSONFile(filepath.Join(c.Dir, cacheMetaFile), meta)
+}
+
+func readJSONFile(fileName string, v interface{}) error {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewDecoder(f).Decode(v)
+}
+
+// writeJSONFile replaces the file atomically, so that an interrupted write
+// doesn't corrupt the cache.
+func writeJSONFile(fileName string, v interface{}) error {
+	f, err := os.CreateTemp(filepath.Dir(fileName), filepath.Base(fileName)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if err := json.NewEncoder(f).Encode(v); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), fileName)
+}
@@ -0,0 +1,116 @@
+package preloadlist
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// listServer serves testJSON in base 64 with an ETag, and counts the
+// requests and the 304 responses.
+type listServer struct {
+	*httptest.Server
+	requests    int
+	notModified int
+	down        bool
+}
+
+func newListServer(t *testing.T) *listServer {
+	s := &listServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests++
+		if s.down {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			s.notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(base64.StdEncoding.EncodeToString([]byte(testJSON))))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *listServer) cache(dir string) *Cache {
+	c := NewCached(dir)
+	c.URL = s.URL
+	c.HTTPClient = s.Client()
+	return c
+}
+
+func loadCache(t *testing.T, c *Cache) (PreloadList, CacheInfo) {
+	list, info, err := c.Load(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(list, testParsed) {
+		t.Errorf("Loaded list does not match expected.\n%#v", list)
+	}
+	return list, info
+}
+
+func TestCacheRevalidation(t *testing.T) {
+	s := newListServer(t)
+	dir := t.TempDir()
+
+	_, first := loadCache(t, s.cache(dir))
+	if first.Stale || first.Retrieved.IsZero() {
+		t.Errorf("Unexpected info for a downloaded list: %#v", first)
+	}
+
+	_, second := loadCache(t, s.cache(dir))
+	if s.requests != 2 || s.notModified != 1 {
+		t.Errorf("Expected a conditional request, got %d requests and %d 304 responses", s.requests, s.notModified)
+	}
+	if !second.Retrieved.Equal(first.Retrieved) || second.Validated.Before(first.Validated) {
+		t.Errorf("Expected a revalidated list, got %#v after %#v", second, first)
+	}
+}
+
+func TestCacheMaxAge(t *testing.T) {
+	s := newListServer(t)
+	c := s.cache(t.TempDir())
+	c.MaxAge = time.Hour
+
+	loadCache(t, c)
+	loadCache(t, c)
+	if s.requests != 1 {
+		t.Errorf("Expected a single request, got %d", s.requests)
+	}
+}
+
+func TestCacheOffline(t *testing.T) {
+	s := newListServer(t)
+	dir := t.TempDir()
+	loadCache(t, s.cache(dir))
+
+	s.down = true
+	if _, info := loadCache(t, s.cache(dir)); !info.Stale {
+		t.Errorf("Expected a stale list, got %#v", info)
+	}
+
+	if _, _, err := s.cache(t.TempDir()).Load(context.Background()); err == nil {
+		t.Errorf("Expected an error without a cached list.")
+	}
+}
+
+func TestCacheOtherURL(t *testing.T) {
+	s := newListServer(t)
+	dir := t.TempDir()
+	loadCache(t, s.cache(dir))
+
+	c := s.cache(dir)
+	c.URL += "/other"
+	loadCache(t, c)
+	if s.notModified != 0 {
+		t.Errorf("Expected the list for another URL to be downloaded.")
+	}
+}
@@ -0,0 +1,56 @@
+package preloadlist
+
+import (
+	"strings"
+)
+
+// ListDiff describes the changes between two versions of the preload list.
+//
+// Added and Modified are in the order of the new list, and Removed is in
+// the order of the old list. Entries are matched by their name,
+// case-insensitively.
+type ListDiff struct {
+	Added    []Entry       `json:"added"`
+	Removed  []Entry       `json:"removed"`
+	Modified []EntryChange `json:"modified"`
+}
+
+// EntryChange describes an entry whose mode or include_subdomains value
+// differs between two versions of the preload list.
+type EntryChange struct {
+	Old Entry `json:"old"`
+	New Entry `json:"new"`
+}
+
+// Diff compares `oldList` with `newList`.
+//
+// Changes to other fields of an entry (e.g. Policy) are not reported as
+// modifications.
+func Diff(oldList, newList PreloadList) ListDiff {
+	diff := ListDiff{
+		Added:    []Entry{},
+		Removed:  []Entry{},
+		Modified: []EntryChange{},
+	}
+
+	oldIndex := oldList.Index()
+	newIndex := newList.Index()
+
+	for _, entry := range newList.Entries {
+		oldEntry, ok := oldIndex.index[strings.ToLower(entry.Name)]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, entry)
+		case oldEntry.Mode != entry.Mode || oldEntry.IncludeSubDomains != entry.IncludeSubDomains:
+			diff.Modified = append(diff.Modified, EntryChange{Old: oldEntry, New: entry})
+		}
+	}
+
+	for _, entry := range oldList.Entries {
+		if _, ok := newIndex.index[strings.ToLower(entry.Name)]; !ok {
+			diff.Removed = append(diff.Removed, entry)
+		}
+	}
+
+	return diff
+}
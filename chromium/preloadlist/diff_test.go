@@ -0,0 +1,48 @@
+package preloadlist
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	oldList := PreloadList{
+		Entries: []Entry{
+			{Name: "unchanged.com", Mode: ForceHTTPS, IncludeSubDomains: true},
+			{Name: "removed.com", Mode: ForceHTTPS, IncludeSubDomains: true},
+			{Name: "subdomains.com", Mode: ForceHTTPS, IncludeSubDomains: true},
+			{Name: "mode.com", Mode: "", IncludeSubDomains: false},
+			{Name: "policy.com", Mode: ForceHTTPS, IncludeSubDomains: true, Policy: PolicyBulk18Weeks},
+			{Name: "CASE.com", Mode: ForceHTTPS, IncludeSubDomains: true},
+		},
+	}
+	newList := PreloadList{
+		Entries: []Entry{
+			{Name: "added.com", Mode: ForceHTTPS, IncludeSubDomains: true},
+			{Name: "unchanged.com", Mode: ForceHTTPS, IncludeSubDomains: true},
+			{Name: "subdomains.com", Mode: ForceHTTPS, IncludeSubDomains: false},
+			{Name: "mode.com", Mode: ForceHTTPS, IncludeSubDomains: false},
+			{Name: "policy.com", Mode: ForceHTTPS, IncludeSubDomains: true, Policy: PolicyBulk1Year},
+			{Name: "case.com", Mode: ForceHTTPS, IncludeSubDomains: true},
+		},
+	}
+
+	expected := ListDiff{
+		Added:   []Entry{newList.Entries[0]},
+		Removed: []Entry{oldList.Entries[1]},
+		Modified: []EntryChange{
+			{Old: oldList.Entries[2], New: newList.Entries[2]},
+			{Old: oldList.Entries[3], New: newList.Entries[3]},
+		},
+	}
+
+	diff := Diff(oldList, newList)
+	if !reflect.DeepEqual(diff, expected) {
+		t.Errorf("Unexpected diff: %#v", diff)
+	}
+
+	diff = Diff(newList, newList)
+	if len(diff.Added) != 0 || len(diff.Removed) != 0 || len(diff.Modified) != 0 {
+		t.Errorf("Diff of a list with itself should be empty: %#v", diff)
+	}
+}
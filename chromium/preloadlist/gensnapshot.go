@@ -0,0 +1,67 @@
+//go:build ignore
+// +build ignore
+
+// gensnapshot retrieves the latest preload list and writes it to
+// snapshot.json.gz, which is embedded by snapshot.go.
+//
+// Usage: go generate ./chromium/preloadlist
+//
+// Without network access, pass the transport_security_state_static.json of
+// a Chromium checkout instead:
+//
+//	go run gensnapshot.go path/to/transport_security_state_static.json
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"log"
+	"os"
+	"time"
+
+	"github.com/chromium/hstspreload/chromium/preloadlist"
+)
+
+func main() {
+	var list preloadlist.PreloadList
+	var err error
+	source := preloadlist.LatestChromiumURL
+	if len(os.Args) > 1 {
+		source = os.Args[1]
+		list, err = preloadlist.NewFromFile(source)
+	} else {
+		list, err = preloadlist.NewFromLatest()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(list.Entries) == 0 {
+		log.Fatalf("%s has no entries", source)
+	}
+
+	f, err := os.Create("snapshot.json.gz")
+	if err != nil {
+		log.Fatal(err)
+	}
+	w, err := gzip.NewWriterLevel(f, gzip.BestCompression)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Keep this in sync with snapshotFile in snapshot.go.
+	snapshot := struct {
+		Retrieved time.Time `json:"retrieved"`
+		Source    string    `json:"source"`
+		preloadlist.PreloadList
+	}{time.Now().UTC().Truncate(time.Second), source, list}
+	if err := json.NewEncoder(w).Encode(snapshot); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := w.Close(); err != nil {
+		log.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
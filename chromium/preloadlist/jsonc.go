@@ -0,0 +1,219 @@
+package preloadlist
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// A ParseError is an error in the JSON of a preload list, annotated with
+// its position in the input.
+type ParseError struct {
+	// Line and Column are 1-based. Column counts bytes.
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// jsoncReader converts the JSON-with-comments format of the Chromium list
+// to JSON while it is being read. It accepts `//` and `/* */` comments
+// anywhere outside of strings, and trailing commas before `]` and `}`.
+//
+// Comments and trailing commas are replaced with spaces (keeping newlines),
+// so that offsets in the output are the same as in the input. This allows
+// errors from encoding/json to be mapped back to a line and column (see
+// annotate()).
+type jsoncReader struct {
+	r   *bufio.Reader
+	buf []byte
+	err error
+
+	// offset is the number of bytes read from `r`, and lineStarts holds the
+	// offset of the start of every line after the first.
+	offset     int64
+	lineStarts []int64
+
+	inString bool
+	escaped  bool
+	// comma is the index in `buf` of a comma that may be a trailing comma,
+	// or -1. Output after it is held back until the next token shows
+	// whether it is.
+	comma int
+}
+
+func newJSONCReader(r io.Reader) *jsoncReader {
+	return &jsoncReader{r: bufio.NewReader(r), comma: -1}
+}
+
+func (j *jsoncReader) Read(p []byte) (int, error) {
+	for j.ready() == 0 && j.err == nil {
+		j.err = j.step()
+	}
+	ready := j.ready()
+	if ready == 0 {
+		if j.err == io.EOF {
+			// The input ended after a comma, which JSON will reject.
+			j.comma = -1
+			ready = len(j.buf)
+		}
+		if ready == 0 {
+			return 0, j.err
+		}
+	}
+	n := copy(p, j.buf[:ready])
+	j.buf = j.buf[n:]
+	if j.comma != -1 {
+		j.comma -= n
+	}
+	return n, nil
+}
+
+// ready returns how many bytes of j.buf can be returned.
+func (j *jsoncReader) ready() int {
+	if j.comma != -1 {
+		return j.comma
+	}
+	return len(j.buf)
+}
+
+func (j *jsoncReader) readByte() (byte, error) {
+	b, err := j.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	j.offset++
+	if b == '\n' {
+		j.lineStarts = append(j.lineStarts, j.offset)
+	}
+	return b, nil
+}
+
+// step converts the next byte (or comment) of the input.
+func (j *jsoncReader) step() error {
+	start := j.offset
+	b, err := j.readByte()
+	if err != nil {
+		return err
+	}
+
+	if j.inString {
+		switch {
+		case j.escaped:
+			j.escaped = false
+		case b == '\\':
+			j.escaped = true
+		case b == '"':
+			j.inString = false
+		}
+		j.buf = append(j.buf, b)
+		return nil
+	}
+
+	switch b {
+	case ' ', '\t', '\r', '\n':
+		j.buf = append(j.buf, b)
+		return nil
+	case '/':
+		next, err := j.r.Peek(1)
+		if err == nil && (next[0] == '/' || next[0] == '*') {
+			return j.skipComment(start)
+		}
+	}
+
+	// `b` starts a token, which decides whether a pending comma is a
+	// trailing comma.
+	if j.comma != -1 {
+		if b == ']' || b == '}' {
+			j.buf[j.comma] = ' '
+		}
+		j.comma = -1
+	}
+	if b == ',' {
+		j.comma = len(j.buf)
+	}
+	if b == '"' {
+		j.inString = true
+	}
+	j.buf = append(j.buf, b)
+	return nil
+}
+
+// skipComment replaces the comment that starts at `start` (after its first
+// `/` has been read) with spaces.
+func (j *jsoncReader) skipComment(start int64) error {
+	kind, _ := j.readByte()
+	j.buf = append(j.buf, ' ', ' ')
+
+	var prev byte
+	for {
+		b, err := j.readByte()
+		if err == io.EOF && kind == '*' {
+			return j.errorAt(start, errors.New("unterminated block comment"))
+		}
+		if err != nil {
+			return err
+		}
+		if b == '\n' {
+			j.buf = append(j.buf, b)
+			if kind == '/' {
+				return nil
+			}
+		} else {
+			j.buf = append(j.buf, ' ')
+		}
+		if kind == '*' && prev == '*' && b == '/' {
+			return nil
+		}
+		prev = b
+	}
+}
+
+// errorAt returns a ParseError for `err` at the input offset `offset`.
+func (j *jsoncReader) errorAt(offset int64, err error) error {
+	line := sort.Search(len(j.lineStarts), func(i int) bool {
+		return j.lineStarts[i] > offset
+	})
+	lineStart := int64(0)
+	if line > 0 {
+		lineStart = j.lineStarts[line-1]
+	}
+	return &ParseError{Line: line + 1, Column: int(offset-lineStart) + 1, Err: err}
+}
+
+// annotate returns `err` from decoding the output of `j` as a ParseError,
+// using the position of syntax and type errors, or else `offset`. It
+// returns nil if `err` is nil.
+func (j *jsoncReader) annotate(err error, offset int64) error {
+	if err == nil {
+		return nil
+	}
+	var parseErr *ParseError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &parseErr):
+		return err
+	case errors.As(err, &syntaxErr):
+		// The offset is after the byte that caused the error.
+		offset = syntaxErr.Offset - 1
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset - 1
+	case err == io.EOF:
+		err = io.ErrUnexpectedEOF
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return j.errorAt(offset, err)
+}
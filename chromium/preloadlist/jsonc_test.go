@@ -0,0 +1,82 @@
+package preloadlist
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTolerant(t *testing.T) {
+	list, err := Parse(strings.NewReader(`{
+  /* The pinsets.
+     Block comments may span lines. */
+  "pinsets": [],
+  "entries": [
+    {"name": "a.example", "mode": "force-https", }, // Trailing comment.
+    {"name": "b.example", /* inline */ "policy": "custom"},
+    {"name": "c.example", "expect_ct_report_uri": "https://report.example/*//"},
+  ],
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := PreloadList{
+		Entries: []Entry{
+			{Name: "a.example", Mode: ForceHTTPS},
+			{Name: "b.example", Policy: PolicyCustom},
+			{Name: "c.example", ExpectCTReportURI: "https://report.example/*//"},
+		},
+		Pinsets: []Pinset{},
+	}
+	if !reflect.DeepEqual(list, expected) {
+		t.Errorf("Parsed list does not match expected. %#v", list)
+	}
+}
+
+func TestParseErrorPosition(t *testing.T) {
+	var tests = []struct {
+		description string
+		json        string
+		line        int
+		column      int
+	}{
+		{
+			"syntax error",
+			"{\n  \"entries\": [\n    {\"name\": \"a.example\" \"mode\": \"\"}\n  ]\n}",
+			3, 26,
+		},
+		{
+			"type error",
+			"{\"entries\": [\n  {\"name\": 42}\n]}",
+			2, 13,
+		},
+		{
+			"unterminated comment",
+			"{\n  \"entries\": [] /* never closed\n}",
+			2, 17,
+		},
+	}
+
+	for _, tt := range tests {
+		for name, parse := range map[string]func() error{
+			"Parse": func() error {
+				_, err := Parse(strings.NewReader(tt.json))
+				return err
+			},
+			"ParseStream": func() error {
+				return ParseStream(strings.NewReader(tt.json), func(Entry) error { return nil })
+			},
+		} {
+			var parseErr *ParseError
+			if err := parse(); !errors.As(err, &parseErr) {
+				t.Errorf("[%s] %s: expected a *ParseError, got %v", tt.description, name, err)
+				continue
+			}
+			if parseErr.Line != tt.line || parseErr.Column != tt.column {
+				t.Errorf("[%s] %s: expected an error at %d:%d, got %s", tt.description, name, tt.line, tt.column, parseErr)
+			}
+		}
+	}
+}
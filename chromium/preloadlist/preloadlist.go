@@ -1,8 +1,6 @@
 package preloadlist
 
 import (
-	"bufio"
-	"bytes"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -12,7 +10,6 @@ import (
 	"os"
 	"strings"
 	"time"
-	"unicode"
 )
 
 const (
@@ -21,6 +18,22 @@ const (
 	ForceHTTPS = "force-https"
 )
 
+// Values of the Policy field of an Entry.
+//
+// Entries with a "bulk" policy were added through hstspreload.org, and are
+// subject to automated removal if they stop satisfying the requirements
+// under which they were preloaded.
+const (
+	PolicyTest                  = "test"
+	PolicyGoogle                = "google"
+	PolicyCustom                = "custom"
+	PolicyBulkLegacy            = "bulk-legacy"
+	PolicyBulk18Weeks           = "bulk-18-weeks"
+	PolicyBulk1Year             = "bulk-1-year"
+	PolicyPublicSuffix          = "public-suffix"
+	PolicyPublicSuffixRequested = "public-suffix-requested"
+)
+
 // HstsPreloadEntryFound indicates if a domain is preloaded.
 //
 // A domain can be preloaded by virtue of itself being on the preload list,
@@ -41,10 +54,12 @@ const (
 
 // PreloadList contains a parsed form of the Chromium Preload list.
 //
-// The full list contains information about more than just HSTS, but only
-// HSTS-related contents are currently exposed in this struct.
+// Besides HSTS, the full list configures public key pinning and Expect-CT
+// for some entries. Use HSTSOnly() to ignore the entries that are only
+// present for those features.
 type PreloadList struct {
-	Entries []Entry `json:"entries"`
+	Entries []Entry  `json:"entries"`
+	Pinsets []Pinset `json:"pinsets,omitempty"`
 }
 
 // A Entry contains the data from an entry in the Chromium
@@ -56,10 +71,64 @@ type PreloadList struct {
 //
 // - IncludeSubDomains: If Mode == ForceHTTPS, forces HSTS to apply to
 //   all subdomains.
+//
+// - Policy: The policy under which the domain is preloaded (e.g.
+//   PolicyBulk1Year). Empty if the list does not specify one.
+//
+// - Pins: The name of the Pinset that applies to the domain, if any.
+//
+// - IncludeSubDomainsForPinning: Applies Pins to all subdomains, even if
+//   IncludeSubDomains is false.
+//
+// - ExpectCT: Whether Expect-CT reports are sent for the domain, to
+//   ExpectCTReportURI.
 type Entry struct {
-	Name              string `json:"name"`
-	Mode              string `json:"mode"`
-	IncludeSubDomains bool   `json:"include_subdomains"`
+	Name                        string `json:"name"`
+	Mode                        string `json:"mode"`
+	IncludeSubDomains           bool   `json:"include_subdomains"`
+	Policy                      string `json:"policy,omitempty"`
+	Pins                        string `json:"pins,omitempty"`
+	IncludeSubDomainsForPinning bool   `json:"include_subdomains_for_pinning,omitempty"`
+	ExpectCT                    bool   `json:"expect_ct,omitempty"`
+	ExpectCTReportURI           string `json:"expect_ct_report_uri,omitempty"`
+}
+
+// A Pinset is a named set of public key pins, which entries refer to by
+// name (see Entry.Pins). The hashes are the names of SPKI hashes in
+// Chromium's transport_security_state_static.pins file.
+type Pinset struct {
+	Name                string   `json:"name"`
+	StaticSPKIHashes    []string `json:"static_spki_hashes,omitempty"`
+	BadStaticSPKIHashes []string `json:"bad_static_spki_hashes,omitempty"`
+	ReportURI           string   `json:"report_uri,omitempty"`
+}
+
+// IsHSTS returns whether the entry preloads HSTS, as opposed to only
+// configuring other features (e.g. pinning).
+func (e Entry) IsHSTS() bool {
+	return e.Mode == ForceHTTPS
+}
+
+// HSTSOnly returns a copy of `p` without pinsets and with only the entries
+// that preload HSTS (see Entry.IsHSTS()).
+func (p PreloadList) HSTSOnly() PreloadList {
+	hsts := PreloadList{Entries: []Entry{}}
+	for _, entry := range p.Entries {
+		if entry.IsHSTS() {
+			hsts.Entries = append(hsts.Entries, entry)
+		}
+	}
+	return hsts
+}
+
+// IsBulk returns whether the entry was added under one of the bulk
+// (hstspreload.org) policies.
+func (e Entry) IsBulk() bool {
+	switch e.Policy {
+	case PolicyBulkLegacy, PolicyBulk18Weeks, PolicyBulk1Year:
+		return true
+	}
+	return false
 }
 
 // IndexedEntries is case-insensitive index of
@@ -99,7 +168,7 @@ func (idx IndexedEntries) Get(domain string) (Entry, HstsPreloadEntryFound) {
 			return entry, AncestorEntryFound
 		}
 	}
-	return Entry{"", "", false}, EntryNotFound
+	return Entry{}, EntryNotFound
 }
 
 // parentDomain finds the parent (immediate ancestor) domain of the input domain.
@@ -116,47 +185,29 @@ const (
 	LatestChromiumURL = "https://chromium.googlesource.com/chromium/src/+/main/net/http/transport_security_state_static.json?format=TEXT"
 )
 
-// Parse reads a preload list in JSON format (with certain possible comments)
-// and returns a parsed version.
+// Parse reads a preload list in JSON format and returns a parsed version.
+// Like the Chromium source, the JSON may contain comments and trailing
+// commas. Syntax errors are returned as a *ParseError.
 func Parse(r io.Reader) (PreloadList, error) {
 	var list PreloadList
 
-	jsonBytes, err := removeComments(r)
+	j := newJSONCReader(r)
+	jsonBytes, err := io.ReadAll(j)
 	if err != nil {
+		var parseErr *ParseError
+		if errors.As(err, &parseErr) {
+			return list, err
+		}
 		return list, errors.New("could not decode body")
 	}
 
 	if err := json.Unmarshal(jsonBytes, &list); err != nil {
-		return list, err
+		return list, j.annotate(err, int64(len(jsonBytes)))
 	}
 
 	return list, nil
 }
 
-// removeComments reads the contents of |r| and removes any lines beginning
-// with optional whitespace followed by "//"
-func removeComments(r io.Reader) ([]byte, error) {
-	var buf bytes.Buffer
-
-	sc := bufio.NewScanner(r)
-	for sc.Scan() {
-		line := sc.Text()
-		if isCommentLine(line) {
-			fmt.Fprintln(&buf, line)
-		}
-	}
-	if err := sc.Err(); err != nil {
-		return nil, err
-	}
-
-	return buf.Bytes(), nil
-}
-
-func isCommentLine(line string) bool {
-	trimmed := strings.TrimLeftFunc(line, unicode.IsSpace)
-	return !strings.HasPrefix(trimmed, "//")
-}
-
 // NewFromChromiumURL retrieves the PreloadList from a URL that returns the list
 // in base 64.
 func NewFromChromiumURL(u string) (PreloadList, error) {
@@ -4,6 +4,7 @@ import (
 	"io/ioutil"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -86,6 +87,24 @@ func TestIndexing(t *testing.T) {
 	}
 }
 
+func TestIsBulk(t *testing.T) {
+	for _, tt := range []struct {
+		policy string
+		bulk   bool
+	}{
+		{"", false},
+		{PolicyCustom, false},
+		{PolicyGoogle, false},
+		{PolicyBulkLegacy, true},
+		{PolicyBulk18Weeks, true},
+		{PolicyBulk1Year, true},
+	} {
+		if (Entry{Policy: tt.policy}).IsBulk() != tt.bulk {
+			t.Errorf("IsBulk() for policy %q should be %t.", tt.policy, tt.bulk)
+		}
+	}
+}
+
 func TestNewFromLatest(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping test to avoid preload list download.")
@@ -120,9 +139,9 @@ var (
 	testJSON = `{
   "entries": [
   	// This is a comment.
-    {"name": "garron.net", "include_subdomains": true, "mode": "force-https"},
+    {"name": "garron.net", "policy": "bulk-18-weeks", "include_subdomains": true, "mode": "force-https"},
     {"name": "example.com", "include_subdomains": false, "mode": "force-https"},
-    {"name": "gmail.com", "mode": "force-https"},
+    {"name": "gmail.com", "policy": "google", "mode": "force-https"},
 
     // Line above intentionally left blank.
     {"name": "google.com"},
@@ -130,11 +149,11 @@ var (
   ]
 }`
 	testParsed = PreloadList{Entries: []Entry{
-		{"garron.net", "force-https", true},
-		{"example.com", "force-https", false},
-		{"gmail.com", "force-https", false},
-		{"google.com", "", false},
-		{"pinned.badssl.com", "", false}},
+		{Name: "garron.net", Mode: "force-https", IncludeSubDomains: true, Policy: "bulk-18-weeks"},
+		{Name: "example.com", Mode: "force-https"},
+		{Name: "gmail.com", Mode: "force-https", Policy: "google"},
+		{Name: "google.com"},
+		{Name: "pinned.badssl.com", Pins: "pinnymcpinnedkey"}},
 	}
 )
 
@@ -158,3 +177,40 @@ func TestNewFromFile(t *testing.T) {
 		t.Errorf("Parsed list does not match expected. %#v", list)
 	}
 }
+
+func TestParseMetadata(t *testing.T) {
+	list, err := Parse(strings.NewReader(`{
+  "pinsets": [
+    // Pinsets are referenced by name.
+    {"name": "test", "static_spki_hashes": ["TestSPKI"], "bad_static_spki_hashes": ["BadSPKI"], "report_uri": "https://report.example/pins"}
+  ],
+  "entries": [
+    {"name": "pinned.example", "mode": "force-https", "pins": "test", "include_subdomains_for_pinning": true},
+    {"name": "ct.example", "expect_ct": true, "expect_ct_report_uri": "https://report.example/ct"}
+  ]
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := PreloadList{
+		Entries: []Entry{
+			{Name: "pinned.example", Mode: ForceHTTPS, Pins: "test", IncludeSubDomainsForPinning: true},
+			{Name: "ct.example", ExpectCT: true, ExpectCTReportURI: "https://report.example/ct"},
+		},
+		Pinsets: []Pinset{{
+			Name:                "test",
+			StaticSPKIHashes:    []string{"TestSPKI"},
+			BadStaticSPKIHashes: []string{"BadSPKI"},
+			ReportURI:           "https://report.example/pins",
+		}},
+	}
+	if !reflect.DeepEqual(list, expected) {
+		t.Errorf("Parsed list does not match expected. %#v", list)
+	}
+
+	hsts := PreloadList{Entries: expected.Entries[:1]}
+	if !reflect.DeepEqual(list.HSTSOnly(), hsts) {
+		t.Errorf("HSTS-only list does not match expected. %#v", list.HSTSOnly())
+	}
+}
@@ -0,0 +1,98 @@
+package preloadlist
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// A MatchType selects how Query.Pattern is matched against the names of
+// entries.
+type MatchType string
+
+// Values of Query.Match.
+const (
+	// MatchSubstring selects entries whose name contains the pattern.
+	MatchSubstring MatchType = "substring"
+	// MatchGlob selects entries whose whole name matches the pattern,
+	// where `*` matches any sequence of characters (including dots) and
+	// `?` matches a single character, e.g. "*.gov".
+	MatchGlob MatchType = "glob"
+	// MatchRegexp selects entries whose name matches the regular
+	// expression (RE2 syntax). Use anchors to match the whole name.
+	MatchRegexp MatchType = "regex"
+)
+
+// A Query selects entries of a preload list (see Search()). The zero value
+// selects all entries.
+type Query struct {
+	// Pattern is matched case-insensitively against the names of entries.
+	// An empty pattern matches all names.
+	Pattern string
+	// Match is the type of Pattern. If empty, MatchSubstring is used.
+	Match MatchType
+	// If Mode is not empty, only entries with this mode are selected.
+	Mode string
+	// If IncludeSubDomains is not nil, only entries with this value of
+	// include_subdomains are selected.
+	IncludeSubDomains *bool
+}
+
+// matcher returns a function that reports whether a (lowercase) name
+// matches q.Pattern.
+func (q Query) matcher() (func(name string) bool, error) {
+	pattern := strings.ToLower(q.Pattern)
+	if pattern == "" {
+		return func(string) bool { return true }, nil
+	}
+
+	switch q.Match {
+	case MatchSubstring, "":
+		return func(name string) bool {
+			return strings.Contains(name, pattern)
+		}, nil
+
+	case MatchGlob:
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %s", q.Pattern, err)
+		}
+		return func(name string) bool {
+			matched, _ := path.Match(pattern, name)
+			return matched
+		}, nil
+
+	case MatchRegexp:
+		re, err := regexp.Compile("(?i)" + q.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %q: %s", q.Pattern, err)
+		}
+		return re.MatchString, nil
+
+	default:
+		return nil, fmt.Errorf("unknown match type %q", q.Match)
+	}
+}
+
+// Search returns the entries of `p` that match `q`, in the order of the
+// list. It returns an error if the pattern or match type of `q` is invalid.
+func (p PreloadList) Search(q Query) ([]Entry, error) {
+	matches, err := q.matcher()
+	if err != nil {
+		return nil, err
+	}
+
+	results := []Entry{}
+	for _, entry := range p.Entries {
+		if q.Mode != "" && entry.Mode != q.Mode {
+			continue
+		}
+		if q.IncludeSubDomains != nil && entry.IncludeSubDomains != *q.IncludeSubDomains {
+			continue
+		}
+		if matches(strings.ToLower(entry.Name)) {
+			results = append(results, entry)
+		}
+	}
+	return results, nil
+}
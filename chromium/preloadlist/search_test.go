@@ -0,0 +1,66 @@
+package preloadlist
+
+import (
+	"reflect"
+	"testing"
+)
+
+var searchList = PreloadList{
+	Entries: []Entry{
+		{Name: "agency.gov", Mode: ForceHTTPS, IncludeSubDomains: true},
+		{Name: "Sub.Agency.GOV", Mode: ForceHTTPS},
+		{Name: "gov"},
+		{Name: "example.com", Mode: ForceHTTPS, IncludeSubDomains: true},
+		{Name: "government.example", Mode: ForceHTTPS},
+	},
+}
+
+func searchNames(t *testing.T, q Query) []string {
+	entries, err := searchList.Search(q)
+	if err != nil {
+		t.Fatalf("Unexpected error for %#v: %s", q, err)
+	}
+	names := []string{}
+	for _, e := range entries {
+		names = append(names, e.Name)
+	}
+	return names
+}
+
+func TestSearch(t *testing.T) {
+	yes, no := true, false
+
+	var tests = []struct {
+		description string
+		query       Query
+		expected    []string
+	}{
+		{"all", Query{}, []string{"agency.gov", "Sub.Agency.GOV", "gov", "example.com", "government.example"}},
+		{"substring", Query{Pattern: "GOV"}, []string{"agency.gov", "Sub.Agency.GOV", "gov", "government.example"}},
+		{"glob", Query{Pattern: "*.gov", Match: MatchGlob}, []string{"agency.gov", "Sub.Agency.GOV"}},
+		{"glob single character", Query{Pattern: "go?", Match: MatchGlob}, []string{"gov"}},
+		{"regex", Query{Pattern: `^[a-z]+\.gov$`, Match: MatchRegexp}, []string{"agency.gov"}},
+		{"mode", Query{Pattern: "gov", Mode: ForceHTTPS}, []string{"agency.gov", "Sub.Agency.GOV", "government.example"}},
+		{"include subdomains", Query{Pattern: "*.gov", Match: MatchGlob, IncludeSubDomains: &yes}, []string{"agency.gov"}},
+		{"exclude subdomains", Query{Pattern: "*.gov", Match: MatchGlob, IncludeSubDomains: &no}, []string{"Sub.Agency.GOV"}},
+		{"no matches", Query{Pattern: "example.org"}, []string{}},
+	}
+
+	for _, tt := range tests {
+		if names := searchNames(t, tt.query); !reflect.DeepEqual(names, tt.expected) {
+			t.Errorf("[%s] Expected %v, got %v", tt.description, tt.expected, names)
+		}
+	}
+}
+
+func TestSearchInvalidQuery(t *testing.T) {
+	for _, q := range []Query{
+		{Pattern: "[", Match: MatchGlob},
+		{Pattern: "(", Match: MatchRegexp},
+		{Pattern: "gov", Match: "fuzzy"},
+	} {
+		if _, err := searchList.Search(q); err == nil {
+			t.Errorf("Expected an error for %#v", q)
+		}
+	}
+}
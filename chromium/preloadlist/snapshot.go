@@ -0,0 +1,82 @@
+package preloadlist
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"time"
+
+	_ "embed" // for snapshot.json.gz
+)
+
+//go:generate go run gensnapshot.go
+
+// snapshotGzip is a gzipped snapshotFile, written by gensnapshot.go.
+//
+//go:embed snapshot.json.gz
+var snapshotGzip []byte
+
+// snapshotFile is the format of snapshot.json.gz.
+type snapshotFile struct {
+	Retrieved time.Time `json:"retrieved"`
+	Source    string    `json:"source"`
+	PreloadList
+}
+
+// SnapshotInfo describes the snapshot of the preload list that is embedded
+// in this package.
+type SnapshotInfo struct {
+	// The time when the snapshot was retrieved from Source.
+	Retrieved time.Time `json:"retrieved"`
+	Source    string    `json:"source"`
+}
+
+// Age returns how long ago the snapshot was retrieved.
+func (s SnapshotInfo) Age() time.Duration {
+	return time.Since(s.Retrieved)
+}
+
+// ErrNoSnapshot indicates that this package was built without a snapshot of
+// the preload list. Run `go generate` in this package to embed one.
+var ErrNoSnapshot = errors.New("no preload list snapshot is embedded")
+
+// NewFromSnapshot returns the snapshot of the preload list that is embedded
+// in this package. This works offline, but the snapshot is only as fresh as
+// the build: check `info` to report its staleness.
+func NewFromSnapshot() (list PreloadList, info SnapshotInfo, err error) {
+	r, err := gzip.NewReader(bytes.NewReader(snapshotGzip))
+	if err != nil {
+		return PreloadList{}, SnapshotInfo{}, err
+	}
+	defer r.Close()
+
+	var snapshot snapshotFile
+	if err := json.NewDecoder(r).Decode(&snapshot); err != nil {
+		return PreloadList{}, SnapshotInfo{}, err
+	}
+	if len(snapshot.Entries) == 0 {
+		return PreloadList{}, SnapshotInfo{}, ErrNoSnapshot
+	}
+
+	return snapshot.PreloadList, SnapshotInfo{Retrieved: snapshot.Retrieved, Source: snapshot.Source}, nil
+}
+
+// NewFromLatestOrSnapshot retrieves the latest PreloadList like
+// NewFromLatest(), but falls back to the embedded snapshot (see
+// NewFromSnapshot()) if the latest list cannot be retrieved.
+//
+// `snapshot` is nil iff the latest list was retrieved. If the snapshot is
+// also unavailable, `err` is the error from retrieving the latest list.
+func NewFromLatestOrSnapshot() (list PreloadList, snapshot *SnapshotInfo, err error) {
+	list, err = NewFromLatest()
+	if err == nil {
+		return list, nil, nil
+	}
+
+	snapshotList, info, snapshotErr := NewFromSnapshot()
+	if snapshotErr != nil {
+		return list, nil, err
+	}
+	return snapshotList, &info, nil
+}
@@ -0,0 +1,82 @@
+package preloadlist
+
+import (
+	"bytes"
+	"compress/gzip"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func withSnapshot(t *testing.T, json string) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write([]byte(json)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	original := snapshotGzip
+	snapshotGzip = buf.Bytes()
+	t.Cleanup(func() { snapshotGzip = original })
+}
+
+func TestNewFromSnapshot(t *testing.T) {
+	withSnapshot(t, `{
+  "retrieved": "2020-01-02T03:04:05Z",
+  "source": "https://example.com/list",
+  "entries": [
+    {"name": "garron.net", "policy": "bulk-18-weeks", "include_subdomains": true, "mode": "force-https"},
+    {"name": "example.com", "include_subdomains": false, "mode": "force-https"}
+  ]
+}`)
+
+	list, info, err := NewFromSnapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := PreloadList{Entries: []Entry{
+		{Name: "garron.net", Mode: "force-https", IncludeSubDomains: true, Policy: "bulk-18-weeks"},
+		{Name: "example.com", Mode: "force-https"},
+	}}
+	if !reflect.DeepEqual(list, expected) {
+		t.Errorf("Parsed list does not match expected. %#v", list)
+	}
+
+	if !info.Retrieved.Equal(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("Wrong retrieval time: %s", info.Retrieved)
+	}
+	if info.Source != "https://example.com/list" {
+		t.Errorf("Wrong source: %s", info.Source)
+	}
+	if info.Age() < 24*time.Hour {
+		t.Errorf("Wrong age: %s", info.Age())
+	}
+}
+
+func TestNewFromSnapshotEmpty(t *testing.T) {
+	withSnapshot(t, `{"retrieved": "0001-01-01T00:00:00Z", "source": "", "entries": []}`)
+
+	if _, _, err := NewFromSnapshot(); err != ErrNoSnapshot {
+		t.Errorf("Expected ErrNoSnapshot, got %v", err)
+	}
+}
+
+func TestEmbeddedSnapshot(t *testing.T) {
+	// Like the download tests, since the snapshot can only be generated
+	// with network access (or a Chromium checkout).
+	if testing.Short() {
+		t.Skip("Skipping test of the generated preload list snapshot.")
+	}
+
+	list, info, err := NewFromSnapshot()
+	if err != nil {
+		t.Fatalf("The embedded snapshot is unusable (run `go generate ./chromium/preloadlist`): %s", err)
+	}
+	if len(list.Entries) == 0 || info.Retrieved.IsZero() || info.Source == "" {
+		t.Errorf("The embedded snapshot is incomplete: %d entries, %#v", len(list.Entries), info)
+	}
+}
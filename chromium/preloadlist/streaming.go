@@ -0,0 +1,245 @@
+package preloadlist
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ParseStream reads a preload list like Parse(), but calls `f` for each
+// entry as soon as it has been read, rather than returning the whole list
+// at the end. If `f` returns an error, parsing stops and ParseStream
+// returns that error.
+func ParseStream(r io.Reader, f func(Entry) error) error {
+	j := newJSONCReader(r)
+	dec := json.NewDecoder(j)
+	annotate := func(err error) error {
+		return j.annotate(err, dec.InputOffset())
+	}
+
+	if err := expectDelim(dec, '{'); err != nil {
+		return annotate(err)
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return annotate(err)
+		}
+		if key, _ := tok.(string); key != "entries" {
+			var skipped json.RawMessage
+			if err := dec.Decode(&skipped); err != nil {
+				return annotate(err)
+			}
+			continue
+		}
+
+		tok, err = dec.Token()
+		if err != nil {
+			return annotate(err)
+		}
+		if tok == nil {
+			continue
+		}
+		if tok != json.Delim('[') {
+			return annotate(fmt.Errorf("expected a list of entries, found %v", tok))
+		}
+		for dec.More() {
+			// Decoding each entry separately gives type errors an offset
+			// relative to the entry.
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				return annotate(err)
+			}
+			var entry Entry
+			if err := json.Unmarshal(raw, &entry); err != nil {
+				var typeErr *json.UnmarshalTypeError
+				if errors.As(err, &typeErr) {
+					typeErr.Offset += dec.InputOffset() - int64(len(raw))
+				}
+				return annotate(err)
+			}
+			if err := f(entry); err != nil {
+				return err
+			}
+		}
+		if err := expectDelim(dec, ']'); err != nil {
+			return annotate(err)
+		}
+	}
+	return annotate(expectDelim(dec, '}'))
+}
+
+// expectDelim reads the next token from `dec`, and returns an error unless
+// it is `delim`.
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != delim {
+		return fmt.Errorf("expected %v, found %v", delim, tok)
+	}
+	return nil
+}
+
+// A StreamingIndex is an index of a preload list that is built while the
+// list is still being read, so that lookups don't need to wait for the
+// whole list. It is safe for concurrent use.
+type StreamingIndex struct {
+	mu    sync.Mutex
+	index map[string]Entry
+	// waiters are notified when the entry for a domain has been indexed.
+	waiters map[string][]chan struct{}
+	// done is closed when the whole list has been read; err is only valid
+	// after that.
+	done chan struct{}
+	err  error
+}
+
+// NewStreamingIndex starts to index the preload list from `r` (see
+// ParseStream()) in the background. If `r` is an io.Closer, it is closed
+// when the whole list has been read.
+func NewStreamingIndex(r io.Reader) *StreamingIndex {
+	s := &StreamingIndex{
+		index:   make(map[string]Entry),
+		waiters: make(map[string][]chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go func() {
+		err := ParseStream(r, s.add)
+		if c, ok := r.(io.Closer); ok {
+			c.Close()
+		}
+
+		s.mu.Lock()
+		s.err = err
+		s.waiters = nil
+		s.mu.Unlock()
+		close(s.done)
+	}()
+	return s
+}
+
+// NewStreamingIndexFromChromiumURL is like NewFromChromiumURL(), but
+// returns as soon as the response starts, and indexes the list while it is
+// being downloaded.
+func NewStreamingIndexFromChromiumURL(u string) (*StreamingIndex, error) {
+	client := http.Client{
+		Timeout: time.Second * 10,
+	}
+
+	resp, err := client.Get(u)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != 200 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("status code %d", resp.StatusCode)
+	}
+
+	body := base64.NewDecoder(base64.StdEncoding, resp.Body)
+	return NewStreamingIndex(struct {
+		io.Reader
+		io.Closer
+	}{body, resp.Body}), nil
+}
+
+// NewStreamingIndexFromLatest is like NewFromLatest(), but indexes the list
+// while it is being downloaded (see NewStreamingIndexFromChromiumURL()).
+func NewStreamingIndexFromLatest() (*StreamingIndex, error) {
+	return NewStreamingIndexFromChromiumURL(LatestChromiumURL)
+}
+
+// add indexes `entry` and notifies the waiters for its domain.
+func (s *StreamingIndex) add(entry Entry) error {
+	d := strings.ToLower(entry.Name)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.index[d] = entry
+	for _, ch := range s.waiters[d] {
+		close(ch)
+	}
+	delete(s.waiters, d)
+	return nil
+}
+
+// Done returns a channel that is closed when the whole list has been read.
+func (s *StreamingIndex) Done() <-chan struct{} {
+	return s.done
+}
+
+// Wait blocks until the whole list has been read, and returns the complete
+// index. It returns an error if the list could not be read, or if `ctx` is
+// done first.
+func (s *StreamingIndex) Wait(ctx context.Context) (IndexedEntries, error) {
+	select {
+	case <-s.done:
+	case <-ctx.Done():
+		return IndexedEntries{}, ctx.Err()
+	}
+	if s.err != nil {
+		return IndexedEntries{}, s.err
+	}
+	// The index is not modified after done is closed.
+	return IndexedEntries{index: s.index}, nil
+}
+
+// Get is like IndexedEntries.Get(), but blocks until the answer is final:
+// either the exact entry of `domain` has been read, or the whole list has
+// been read. It returns an error if the list could not be read completely
+// (unless the exact entry was found before that), or if `ctx` is done
+// first.
+func (s *StreamingIndex) Get(ctx context.Context, domain string) (Entry, HstsPreloadEntryFound, error) {
+	domain = strings.ToLower(domain)
+
+	s.mu.Lock()
+	if entry, ok := s.index[domain]; ok {
+		s.mu.Unlock()
+		return entry, ExactEntryFound, nil
+	}
+	var found chan struct{}
+	if s.waiters != nil {
+		found = make(chan struct{})
+		s.waiters[domain] = append(s.waiters[domain], found)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-found:
+	case <-s.done:
+	case <-ctx.Done():
+		return Entry{}, EntryNotFound, ctx.Err()
+	}
+
+	entry, status, complete := s.GetBestEffort(domain)
+	if status != ExactEntryFound && complete && s.err != nil {
+		return Entry{}, EntryNotFound, s.err
+	}
+	return entry, status, nil
+}
+
+// GetBestEffort is like IndexedEntries.Get(), but only uses the entries
+// that have been read so far. The answer is final iff `complete` is true,
+// or the exact entry of `domain` was found: an ancestor entry may still be
+// superseded by an exact entry later in the list.
+func (s *StreamingIndex) GetBestEffort(domain string) (entry Entry, status HstsPreloadEntryFound, complete bool) {
+	select {
+	case <-s.done:
+		complete = true
+	default:
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, status = IndexedEntries{index: s.index}.Get(domain)
+	return entry, status, complete
+}
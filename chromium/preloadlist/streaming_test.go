@@ -0,0 +1,128 @@
+package preloadlist
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseStream(t *testing.T) {
+	var list PreloadList
+	err := ParseStream(strings.NewReader(testJSON), func(e Entry) error {
+		list.Entries = append(list.Entries, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(list, testParsed) {
+		t.Errorf("Parsed list does not match expected. %#v", list)
+	}
+}
+
+func TestParseStreamSkipsOtherKeys(t *testing.T) {
+	var names []string
+	err := ParseStream(strings.NewReader(`{
+  "pinsets": [{"name": "test", "static_spki_hashes": ["TestSPKI"]}],
+  "entries": [{"name": "example.com"}],
+  "version": 2
+}`), func(e Entry) error {
+		names = append(names, e.Name)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(names, []string{"example.com"}) {
+		t.Errorf("Unexpected entries: %v", names)
+	}
+}
+
+func TestParseStreamErrors(t *testing.T) {
+	for _, s := range []string{
+		``,
+		`[]`,
+		`{"entries": {}}`,
+		`{"entries": [{"name": "example.com"}`,
+	} {
+		err := ParseStream(strings.NewReader(s), func(e Entry) error { return nil })
+		if err == nil {
+			t.Errorf("Expected an error for %q", s)
+		}
+	}
+}
+
+func TestStreamingIndex(t *testing.T) {
+	r, w := io.Pipe()
+	s := NewStreamingIndex(r)
+	ctx := context.Background()
+
+	w.Write([]byte(`{"entries": [
+    {"name": "Example.com", "include_subdomains": true, "mode": "force-https"},
+`))
+
+	// The exact entry is available before the rest of the list is read.
+	entry, status, err := s.Get(ctx, "example.COM")
+	if err != nil || status != ExactEntryFound || entry.Name != "Example.com" {
+		t.Errorf("Unexpected result: %v %v %v", entry, status, err)
+	}
+
+	// An ancestor entry may still be superseded.
+	if _, status, complete := s.GetBestEffort("www.example.com"); status != AncestorEntryFound || complete {
+		t.Errorf("Unexpected best-effort result: %v %v", status, complete)
+	}
+	shortCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, _, err := s.Get(shortCtx, "www.example.com"); err != context.DeadlineExceeded {
+		t.Errorf("Get() should wait for the rest of the list, got %v", err)
+	}
+
+	// A blocked lookup returns as soon as its entry is read.
+	result := make(chan HstsPreloadEntryFound)
+	go func() {
+		_, status, _ := s.Get(ctx, "www.example.com")
+		result <- status
+	}()
+	w.Write([]byte(`    {"name": "www.example.com", "mode": "force-https"},
+`))
+	if status := <-result; status != ExactEntryFound {
+		t.Errorf("Expected the exact entry, got %v", status)
+	}
+
+	w.Write([]byte(`    {"name": "example.org"}
+]}`))
+	w.Close()
+
+	idx, err := s.Wait(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, status := idx.Get("sub.example.com"); status != AncestorEntryFound {
+		t.Errorf("Expected the ancestor entry, got %v", status)
+	}
+	if _, status, err := s.Get(ctx, "example.net"); status != EntryNotFound || err != nil {
+		t.Errorf("Unexpected result: %v %v", status, err)
+	}
+	if _, _, complete := s.GetBestEffort("example.net"); !complete {
+		t.Errorf("The index should be complete.")
+	}
+}
+
+func TestStreamingIndexError(t *testing.T) {
+	s := NewStreamingIndex(strings.NewReader(`{"entries": [{"name": "example.com"}, `))
+	ctx := context.Background()
+
+	if _, err := s.Wait(ctx); err == nil {
+		t.Errorf("Expected an error.")
+	}
+	// Entries that were read before the error are still final.
+	if _, status, err := s.Get(ctx, "example.com"); status != ExactEntryFound || err != nil {
+		t.Errorf("Unexpected result: %v %v", status, err)
+	}
+	if _, _, err := s.Get(ctx, "example.org"); err == nil {
+		t.Errorf("Expected an error for an incomplete index.")
+	}
+}
@@ -0,0 +1,201 @@
+package preloadlist
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Format returns the entry on one line in the style of the Chromium
+// preload list (without a trailing comma), e.g.
+//
+//	{ "name": "example.com", "policy": "bulk-1-year", "mode": "force-https", "include_subdomains": true }
+//
+// Fields are in the same order as in the Chromium list, and fields with
+// their zero value are omitted.
+func (e Entry) Format() string {
+	fields := []string{`"name": ` + quote(e.Name)}
+	if e.Policy != "" {
+		fields = append(fields, `"policy": `+quote(e.Policy))
+	}
+	if e.Mode != "" {
+		fields = append(fields, `"mode": `+quote(e.Mode))
+	}
+	if e.IncludeSubDomains {
+		fields = append(fields, `"include_subdomains": true`)
+	}
+	if e.Pins != "" {
+		fields = append(fields, `"pins": `+quote(e.Pins))
+	}
+	if e.IncludeSubDomainsForPinning {
+		fields = append(fields, `"include_subdomains_for_pinning": true`)
+	}
+	if e.ExpectCT {
+		fields = append(fields, `"expect_ct": true`)
+	}
+	if e.ExpectCTReportURI != "" {
+		fields = append(fields, `"expect_ct_report_uri": `+quote(e.ExpectCTReportURI))
+	}
+	return "{ " + strings.Join(fields, ", ") + " }"
+}
+
+// quote returns `s` as a JSON string, without escaping HTML characters.
+func quote(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// Write writes `list` to `w` in the format of the Chromium preload list:
+// pinsets as indented JSON, followed by one entry per line (see
+// Entry.Format()).
+func Write(w io.Writer, list PreloadList) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "{")
+	if len(list.Pinsets) > 0 {
+		fmt.Fprintln(bw, `  "pinsets": [`)
+		for i, p := range list.Pinsets {
+			j, err := json.MarshalIndent(p, "    ", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(bw, "    %s%s\n", j, separator(i, len(list.Pinsets)))
+		}
+		fmt.Fprintln(bw, "  ],")
+	}
+	fmt.Fprintln(bw, `  "entries": [`)
+	for i, e := range list.Entries {
+		fmt.Fprintf(bw, "    %s%s\n", e.Format(), separator(i, len(list.Entries)))
+	}
+	fmt.Fprintln(bw, "  ]")
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// separator returns the separator after item `i` of `n` in a JSON list.
+func separator(i int, n int) string {
+	if i == n-1 {
+		return ""
+	}
+	return ","
+}
+
+// WriteFromTemplate writes the entries of `list` to `w` using `template`
+// (typically the current Chromium list) for everything else: lines outside
+// of the entries (including the pinsets) and comments between entries are
+// copied as they are. Each entry of the template is replaced by the entry
+// of `list` with the same name, or dropped if there is none. The entries of
+// `list` that are not in the template are added at the end.
+//
+// The template must have one entry per line, like the Chromium list.
+func WriteFromTemplate(w io.Writer, list PreloadList, template io.Reader) error {
+	remaining := make(map[string]Entry)
+	for _, e := range list.Entries {
+		remaining[strings.ToLower(e.Name)] = e
+	}
+
+	sc := bufio.NewScanner(template)
+	sc.Buffer(nil, 1<<20)
+	bw := bufio.NewWriter(w)
+	inEntries, sawEntries := false, false
+	// section holds the lines of the entries, to add commas once the last
+	// entry is known.
+	var section []templateLine
+	for sc.Scan() {
+		line := sc.Text()
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case !inEntries:
+			fmt.Fprintln(bw, line)
+			if strings.HasPrefix(trimmed, `"entries"`) && strings.HasSuffix(trimmed, "[") {
+				inEntries, sawEntries = true, true
+			}
+
+		case strings.HasPrefix(trimmed, "]"):
+			for _, e := range list.Entries {
+				if _, ok := remaining[strings.ToLower(e.Name)]; ok {
+					section = append(section, templateLine{text: "    " + e.Format(), entry: true})
+				}
+			}
+			writeSection(bw, section)
+			fmt.Fprintln(bw, line)
+			inEntries = false
+
+		default:
+			entry, comment, ok := parseEntryLine(trimmed)
+			if !ok {
+				section = append(section, templateLine{text: line})
+				continue
+			}
+			updated, ok := remaining[strings.ToLower(entry.Name)]
+			if !ok {
+				continue
+			}
+			delete(remaining, strings.ToLower(entry.Name))
+			indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+			section = append(section, templateLine{text: indent + updated.Format(), entry: true, comment: comment})
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	if !sawEntries {
+		return errors.New("the template has no list of entries")
+	}
+	if inEntries {
+		return errors.New("the list of entries in the template is not terminated")
+	}
+	return bw.Flush()
+}
+
+type templateLine struct {
+	text  string
+	entry bool
+	// comment is a comment after an entry on the same line.
+	comment string
+}
+
+// writeSection writes the lines of the entries, with a comma after each
+// entry but the last.
+func writeSection(w io.Writer, section []templateLine) {
+	last := -1
+	for i, l := range section {
+		if l.entry {
+			last = i
+		}
+	}
+	for i, l := range section {
+		if l.entry && i != last {
+			l.text += ","
+		}
+		if l.comment != "" {
+			l.text += " " + l.comment
+		}
+		fmt.Fprintln(w, l.text)
+	}
+}
+
+// parseEntryLine parses a line of the template that contains an entry,
+// with an optional trailing comma and comment, and returns the entry and
+// the comment.
+func parseEntryLine(line string) (entry Entry, comment string, ok bool) {
+	if !strings.HasPrefix(line, "{") {
+		return Entry{}, "", false
+	}
+	dec := json.NewDecoder(strings.NewReader(line))
+	if err := dec.Decode(&entry); err != nil || entry.Name == "" {
+		return Entry{}, "", false
+	}
+	rest := strings.TrimSpace(line[dec.InputOffset():])
+	rest = strings.TrimSpace(strings.TrimPrefix(rest, ","))
+	if rest != "" && !strings.HasPrefix(rest, "//") {
+		return Entry{}, "", false
+	}
+	return entry, rest, true
+}
@@ -0,0 +1,128 @@
+package preloadlist
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEntryFormat(t *testing.T) {
+	var tests = []struct {
+		entry    Entry
+		expected string
+	}{
+		{
+			Entry{Name: "example.com", Mode: ForceHTTPS, IncludeSubDomains: true, Policy: PolicyBulk1Year},
+			`{ "name": "example.com", "policy": "bulk-1-year", "mode": "force-https", "include_subdomains": true }`,
+		},
+		{
+			Entry{Name: "example.org", Mode: ForceHTTPS},
+			`{ "name": "example.org", "mode": "force-https" }`,
+		},
+		{
+			Entry{Name: "pinned.example", Policy: PolicyGoogle, Pins: "google", IncludeSubDomainsForPinning: true},
+			`{ "name": "pinned.example", "policy": "google", "pins": "google", "include_subdomains_for_pinning": true }`,
+		},
+		{
+			Entry{Name: "ct.example", ExpectCT: true, ExpectCTReportURI: "https://report.example/?a=1&b=2"},
+			`{ "name": "ct.example", "expect_ct": true, "expect_ct_report_uri": "https://report.example/?a=1&b=2" }`,
+		},
+	}
+
+	for _, tt := range tests {
+		if line := tt.entry.Format(); line != tt.expected {
+			t.Errorf("Expected %s, got %s", tt.expected, line)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	list := PreloadList{
+		Entries: []Entry{
+			{Name: "example.com", Mode: ForceHTTPS, IncludeSubDomains: true, Policy: PolicyBulk1Year},
+			{Name: "pinned.example", Pins: "test"},
+		},
+		Pinsets: []Pinset{{Name: "test", StaticSPKIHashes: []string{"TestSPKI"}}},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, list); err != nil {
+		t.Fatal(err)
+	}
+	expected := `{
+  "pinsets": [
+    {
+      "name": "test",
+      "static_spki_hashes": [
+        "TestSPKI"
+      ]
+    }
+  ],
+  "entries": [
+    { "name": "example.com", "policy": "bulk-1-year", "mode": "force-https", "include_subdomains": true },
+    { "name": "pinned.example", "pins": "test" }
+  ]
+}
+`
+	if buf.String() != expected {
+		t.Errorf("Unexpected output:\n%s", buf.String())
+	}
+
+	parsed, err := Parse(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed, list) {
+		t.Errorf("The written list does not parse back to the original. %#v", parsed)
+	}
+}
+
+func TestWriteFromTemplate(t *testing.T) {
+	template := `// Header comment.
+{
+  "pinsets": [],
+  "entries": [
+    // Section A.
+    { "name": "a.example", "mode": "force-https" },
+    { "name": "removed.example", "mode": "force-https" },
+
+    // Section B.
+    { "name": "B.example", "mode": "force-https" }, // Keep this comment.
+    { "name": "c.example", "mode": "force-https" }
+  ]
+}
+`
+	list := PreloadList{Entries: []Entry{
+		{Name: "a.example", Mode: ForceHTTPS},
+		{Name: "b.example", Mode: ForceHTTPS, IncludeSubDomains: true},
+		{Name: "c.example", Mode: ForceHTTPS},
+		{Name: "new.example", Mode: ForceHTTPS, Policy: PolicyBulk1Year},
+	}}
+
+	var buf bytes.Buffer
+	if err := WriteFromTemplate(&buf, list, strings.NewReader(template)); err != nil {
+		t.Fatal(err)
+	}
+	expected := `// Header comment.
+{
+  "pinsets": [],
+  "entries": [
+    // Section A.
+    { "name": "a.example", "mode": "force-https" },
+
+    // Section B.
+    { "name": "b.example", "mode": "force-https", "include_subdomains": true }, // Keep this comment.
+    { "name": "c.example", "mode": "force-https" },
+    { "name": "new.example", "policy": "bulk-1-year", "mode": "force-https" }
+  ]
+}
+`
+	if buf.String() != expected {
+		t.Errorf("Unexpected output:\n%s", buf.String())
+	}
+
+	if err := WriteFromTemplate(&buf, list, strings.NewReader(`{"pinsets": []}`)); err == nil {
+		t.Errorf("Expected an error for a template without entries.")
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/chromium/hstspreload"
+	"github.com/chromium/hstspreload/ct"
+)
+
+// CTSummary prints a JSON summary of the certificates of `domain` and its
+// subdomains in Certificate Transparency logs.
+func CTSummary(domain string) error {
+	fmt.Fprintf(os.Stderr, "Searching the Certificate Transparency logs for %s...\n", hstspreload.DisplayDomain(domain))
+	certs, err := (&ct.CrtSh{}).Certificates(context.Background(), domain)
+	if err != nil {
+		return err
+	}
+
+	var expiryWarning time.Duration
+	if checker != nil {
+		expiryWarning = checker.CertExpiryWarning
+	}
+	printJSON(ct.Summarize(domain, certs, time.Now(), expiryWarning))
+	return nil
+}
+
+func handleCT(domain string) {
+	err := CTSummary(domain)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+
+	os.Exit(0)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/chromium/hstspreload"
+	"github.com/chromium/hstspreload/chromium/preloadlist"
+)
+
+// DiffLists prints the changes between the preload list JSON files
+// `oldFileName` and `newFileName`.
+func DiffLists(oldFileName string, newFileName string) error {
+	oldList, err := preloadlist.NewFromFile(oldFileName)
+	if err != nil {
+		return fmt.Errorf("could not read %s: %s", oldFileName, err)
+	}
+	newList, err := preloadlist.NewFromFile(newFileName)
+	if err != nil {
+		return fmt.Errorf("could not read %s: %s", newFileName, err)
+	}
+
+	diff := preloadlist.Diff(oldList, newList)
+	if jsonOutput {
+		printJSON(diff)
+		return nil
+	}
+
+	printEntries(diff.Added, "Added", green)
+	printEntries(diff.Removed, "Removed", red)
+
+	if len(diff.Modified) > 0 {
+		fmt.Printf("%sModified (%d):%s\n", yellow, len(diff.Modified), resetFormat)
+		for _, change := range diff.Modified {
+			fmt.Printf("  %s\n    - %s\n    + %s\n",
+				hstspreload.DisplayDomain(change.New.Name), entrySettings(change.Old), entrySettings(change.New))
+		}
+		fmt.Println()
+	}
+
+	fmt.Printf("%d added, %d removed, %d modified.\n",
+		len(diff.Added), len(diff.Removed), len(diff.Modified))
+	return nil
+}
+
+func printEntries(entries []preloadlist.Entry, title string, fs string) {
+	if len(entries) == 0 {
+		return
+	}
+
+	fmt.Printf("%s%s (%d):%s\n", fs, title, len(entries), resetFormat)
+	for _, entry := range entries {
+		fmt.Printf("  %s (%s)\n", hstspreload.DisplayDomain(entry.Name), entrySettings(entry))
+	}
+	fmt.Println()
+}
+
+// entrySettings describes the fields of `entry` that Diff() compares.
+func entrySettings(entry preloadlist.Entry) string {
+	mode := entry.Mode
+	if mode == "" {
+		mode = `""`
+	}
+	return fmt.Sprintf("mode: %s, include_subdomains: %t",
+		hstspreload.EscapeControlCharacters(mode), entry.IncludeSubDomains)
+}
+
+func handleDiff(oldFileName string, newFileName string) {
+	err := DiffLists(oldFileName, newFileName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+
+	os.Exit(0)
+}
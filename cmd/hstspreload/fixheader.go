@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/chromium/hstspreload"
+)
+
+// fixHeaderResult is the output of the fix-header command with --json.
+type fixHeaderResult struct {
+	Header          string             `json:"header"`
+	SuggestedHeader string             `json:"suggested_header"`
+	Issues          hstspreload.Issues `json:"issues"`
+}
+
+func handleFixHeader(header string) {
+	warnIfNotHeader(header)
+
+	suggested, issues := checker.SuggestHeaderString(header)
+	if checker != nil {
+		issues = issues.ApplySeverityOverrides(checker.SeverityOverrides)
+		issues = issues.ApplyMessageTemplates(checker.MessageTemplates)
+	}
+	issues = issues.Sorted()
+
+	if jsonOutput {
+		printJSON(fixHeaderResult{Header: header, SuggestedHeader: suggested, Issues: issues})
+		os.Exit(0)
+	}
+
+	fmt.Printf("Suggested header: %s%s%s\n\n", bold, suggested, resetFormat)
+	printList(issues.Errors, "Fixed error", red)
+	printList(issues.Warnings, "Warning", yellow)
+
+	os.Exit(0)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/chromium/hstspreload/batch"
+)
+
+// groupedOptions configures the output of the batch command.
+var groupedOptions struct {
+	// Group the results by verdict instead of printing a flat array.
+	enabled bool
+	// If set, write each group to files in this directory, and only print
+	// a summary.
+	outputDir string
+	// If positive, split each group into files with at most this many
+	// results.
+	pageSize int
+}
+
+// groupSummary describes a group in the summary that is printed when the
+// groups are written to files.
+type groupSummary struct {
+	Verdict batch.Verdict `json:"verdict"`
+	Count   int           `json:"count"`
+	Files   []string      `json:"files"`
+}
+
+// PrintGrouped checks the given domains, and prints the results grouped by
+// verdict (see batch.GroupByVerdict()).
+func PrintGrouped(domains []string) error {
+	grouped := batch.GroupByVerdict(collectResults(domains))
+	if groupedOptions.outputDir == "" {
+		printJSON(grouped)
+		return nil
+	}
+
+	if err := os.MkdirAll(groupedOptions.outputDir, 0755); err != nil {
+		return err
+	}
+
+	summaries := []groupSummary{}
+	for _, g := range grouped.Groups {
+		summary := groupSummary{Verdict: g.Verdict, Count: g.Count, Files: []string{}}
+		pages := g.Pages(groupedOptions.pageSize)
+		for i, page := range pages {
+			name := string(g.Verdict) + ".json"
+			if len(pages) > 1 {
+				name = fmt.Sprintf("%s-%d.json", g.Verdict, i+1)
+			}
+			fileName := filepath.Join(groupedOptions.outputDir, name)
+			if err := writeJSONFile(fileName, page); err != nil {
+				return err
+			}
+			summary.Files = append(summary.Files, fileName)
+		}
+		summaries = append(summaries, summary)
+	}
+
+	printJSON(struct {
+		Total  int            `json:"total"`
+		Groups []groupSummary `json:"groups"`
+	}{grouped.Total, summaries})
+	return nil
+}
+
+func writeJSONFile(fileName string, v interface{}) error {
+	j, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fileName, append(j, '\n'), 0644)
+}
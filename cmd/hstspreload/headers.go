@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/chromium/hstspreload/batch"
+)
+
+// headersOptions is set by the options of the headers command.
+var headersOptions struct {
+	headerColumn string
+	csvOutput    bool
+}
+
+// Headers checks the CSV header inventory on stdin, and prints the results
+// as JSON, or as CSV with --csv.
+func Headers() error {
+	columns, results, err := batch.CheckHeaderCSV(os.Stdin, batch.HeaderCSVOptions{
+		HeaderColumn: headersOptions.headerColumn,
+		Checker:      checker,
+	})
+	if err != nil {
+		return err
+	}
+
+	if headersOptions.csvOutput {
+		return batch.WriteHeaderCSV(os.Stdout, columns, results)
+	}
+	if results == nil {
+		results = []batch.HeaderResult{}
+	}
+	printJSON(results)
+	return nil
+}
+
+func handleHeaders() {
+	err := Headers()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+
+	os.Exit(0)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/chromium/hstspreload"
+	"github.com/chromium/hstspreload/chromium/preloadlist"
+)
+
+const (
+	removalRiskParallelism = 50
+)
+
+// removalRiskResult is the outcome of hstspreload.AutomatedRemovalRiskDomain()
+// for a single domain.
+type removalRiskResult struct {
+	Domain string             `json:"domain"`
+	Policy string             `json:"policy"`
+	AtRisk bool               `json:"at_risk"`
+	Header string             `json:"header,omitempty"`
+	Issues hstspreload.Issues `json:"issues"`
+}
+
+// RemovalRisk checks the given domains against the latest preload list, and
+// prints a JSON report of which preloaded domains are at risk of automated
+// removal. Domains that are not preloaded with an exact entry are skipped.
+func RemovalRisk(domains []string) error {
+	list, err := preloadlist.NewFromLatest()
+	if err != nil {
+		return err
+	}
+	idx := list.Index()
+
+	var entries []preloadlist.Entry
+	for _, d := range domains {
+		entry, status := idx.Get(d)
+		if status != preloadlist.ExactEntryFound {
+			fmt.Fprintf(os.Stderr, "Skipping %s: not preloaded.\n", d)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	results := make([]removalRiskResult, len(entries))
+	sem := make(chan struct{}, removalRiskParallelism)
+	var wg sync.WaitGroup
+	for i, entry := range entries {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, entry preloadlist.Entry) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			header, issues := checker.AutomatedRemovalRiskDomain(entry.Name, entry.Policy)
+			results[i] = removalRiskResult{
+				Domain: entry.Name,
+				Policy: entry.Policy,
+				AtRisk: len(issues.Errors) > 0,
+				Issues: issues,
+			}
+			if header != nil {
+				results[i].Header = *header
+			}
+		}(i, entry)
+	}
+	wg.Wait()
+
+	j, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(j))
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/chromium/hstspreload"
+	"github.com/chromium/hstspreload/chromium/preloadapi"
+	"github.com/chromium/hstspreload/history"
+	"github.com/chromium/hstspreload/report"
+)
+
+// reportOptions is set by the options of the report command.
+var reportOptions struct {
+	html     bool
+	markdown bool
+}
+
+// Report prints the report of `domain` as JSON, as HTML with --html, or as
+// Markdown with --markdown.
+func Report(domain string) error {
+	l, _, err := latestList()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+	}
+	idx := l.Index()
+
+	opts := report.Options{
+		Checker:     checker,
+		PreloadList: &idx,
+		API:         &preloadapi.Client{},
+		Subdomains:  &report.CrtSh{},
+	}
+	if historyDir != "" {
+		store, err := history.NewDirStore(historyDir)
+		if err != nil {
+			return err
+		}
+		opts.History = store
+	}
+
+	// The report is always a document on stdout.
+	fmt.Fprintf(os.Stderr, "Generating the report for %s...\n", hstspreload.DisplayDomain(domain))
+	rep, err := report.New(opts).Report(context.Background(), domain)
+	if err != nil {
+		return err
+	}
+
+	if reportOptions.html {
+		return report.WriteHTML(os.Stdout, rep)
+	}
+	if reportOptions.markdown {
+		return report.WriteMarkdown(os.Stdout, rep)
+	}
+	printJSON(rep)
+	return nil
+}
+
+func handleReport(domain string) {
+	err := Report(domain)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+
+	os.Exit(0)
+}
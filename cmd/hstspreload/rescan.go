@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/chromium/hstspreload/batch"
+	"github.com/chromium/hstspreload/history"
+)
+
+// rescanOptions is set by the options of the rescan command.
+var rescanOptions struct {
+	diff bool
+}
+
+// Rescan checks `domains`, records the results in the history in
+// --history-dir, and prints the results, or with --diff the domains whose
+// issues changed since their previous check.
+func Rescan(domains []string) error {
+	if historyDir == "" {
+		return errors.New("the rescan command requires --history-dir")
+	}
+	store, err := history.NewDirStore(historyDir)
+	if err != nil {
+		return err
+	}
+
+	results := collectResults(domains)
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Domain < results[j].Domain
+	})
+	if err := batch.StoreResults(store, results); err != nil {
+		return err
+	}
+
+	if !rescanOptions.diff {
+		printJSON(results)
+		return nil
+	}
+	checked := make([]string, len(results))
+	for i, r := range results {
+		checked[i] = r.Domain
+	}
+	changes, err := history.IssueChanges(store, checked)
+	if err != nil {
+		return err
+	}
+	printJSON(changes)
+	return nil
+}
+
+func handleRescan() {
+	err := Rescan(readDomains())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+
+	os.Exit(0)
+}
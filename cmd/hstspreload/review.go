@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/chromium/hstspreload"
+	"github.com/chromium/hstspreload/batch"
+	"github.com/chromium/hstspreload/chromium/preloadlist"
+	"github.com/chromium/hstspreload/scanner"
+)
+
+// Review scans all pending submitted domains using the same checks as
+// PreloadableDomain(), and prints:
+//
+// - The entries for the domains that pass, ready to be pasted into the
+// Chromium preload list.
+//
+// - The number of rejected domains in each rejection category.
+//
+// - The rejection messages for the domains that fail, as JSON for the
+// /setmessages endpoint of hstspreload.org.
+func Review() error {
+	results, err := newScanner().ScanPending(context.Background())
+	if err != nil {
+		return err
+	}
+
+	// The results are sorted by domain.
+	var approved []batch.Result
+	for _, r := range results {
+		if len(r.Issues.Errors) == 0 {
+			approved = append(approved, r)
+		}
+	}
+
+	policy := hstspreload.DefaultPolicyVersion
+	if checker != nil && checker.PolicyVersion.MinimumMaxAge != 0 {
+		policy = checker.PolicyVersion
+	}
+	fmt.Printf("// Approved (%d):\n", len(approved))
+	for _, r := range approved {
+		fmt.Println(listEntryLine(r.Domain, r.ParsedHeader, policy))
+	}
+
+	// Bucket rejections by the category of their first error in sorted
+	// order, which is the one used for the rejection message.
+	categories := make(map[hstspreload.RejectionCategory]int)
+	for _, r := range results {
+		if len(r.Issues.Errors) > 0 {
+			categories[hstspreload.RejectionCategoryOf(r.Issues.Sorted().Errors[0].Code)]++
+		}
+	}
+	fmt.Printf("\n// Rejections by category:\n")
+	for _, c := range []hstspreload.RejectionCategory{
+		hstspreload.RejectionTLS,
+		hstspreload.RejectionRedirect,
+		hstspreload.RejectionHeader,
+		hstspreload.RejectionSubdomain,
+		hstspreload.RejectionOther,
+	} {
+		fmt.Printf("//   %s: %d\n", c, categories[c])
+	}
+
+	messages := scanner.SetMessages(results)
+	j, err := json.MarshalIndent(messages, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\n// Rejected (%d), for /setmessages:\n%s\n", len(messages), j)
+
+	return nil
+}
+
+// listEntryLine formats a bulk entry for `domain` in the style of the
+// Chromium preload list. The policy of the entry is the name of the policy
+// version that `domain` was checked against, and it includes subdomains iff
+// `header` does.
+func listEntryLine(domain string, header hstspreload.HSTSHeader, policy hstspreload.PolicyVersion) string {
+	return "    " + preloadlist.Entry{
+		Name:              domain,
+		Policy:            policy.Name,
+		Mode:              preloadlist.ForceHTTPS,
+		IncludeSubDomains: header.IncludeSubDomains,
+	}.Format() + ","
+}
@@ -1,75 +1,103 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
-	"net/http"
+	"fmt"
+	"os"
 
 	"github.com/chromium/hstspreload/batch"
-	"github.com/chromium/hstspreload/chromium/preloadlist"
+	"github.com/chromium/hstspreload/scanner"
 )
 
-// ScanPending scans all pending submitted domains.
-func ScanPending() error {
-	domains, err := pendingDomains()
-	if err != nil {
-		return err
+// collectResults runs the batch runner and waits for all results.
+func collectResults(domains []string) []batch.Result {
+	collected := make([]batch.Result, 0, len(domains))
+	for r := range batchRunner().Run(context.Background(), domains) {
+		collected = append(collected, r)
 	}
+	return collected
+}
 
-	err = batch.Print(domains)
-	if err != nil {
-		return err
+// newScanner returns a scanner for the scan commands, which reports its
+// progress on stderr (with an estimate of the remaining time if --progress
+// is given).
+func newScanner() *scanner.Scanner {
+	opts := batchOptions
+	opts.Checker = checker
+	var progress func(checked int, total int, result batch.Result)
+	if opts.Progress == nil {
+		progress = func(checked int, total int, result batch.Result) {
+			if checked%100 == 0 || checked == total {
+				fmt.Fprintf(os.Stderr, "Checked %d of %d domains.\n", checked, total)
+			}
+		}
 	}
+	return scanner.New(scanner.Options{Batch: opts, Progress: progress})
+}
 
-	return nil
+// scanPendingOptions is set by the options of the scan-pending command.
+var scanPendingOptions struct {
+	setMessages bool
 }
 
-// ScanPreloaded scans all preloaded domains.
-func ScanPreloaded() error {
-	domains, err := preloadedDomains()
+// ScanPending scans all pending submitted domains. With --set-messages, it
+// prints the rejection messages for /setmessages instead of the full
+// results.
+func ScanPending() error {
+	results, err := newScanner().ScanPending(context.Background())
 	if err != nil {
 		return err
 	}
 
-	err = batch.Print(domains)
-	if err != nil {
-		return err
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if scanPendingOptions.setMessages {
+		return enc.Encode(scanner.SetMessages(results))
 	}
-
-	return nil
+	return enc.Encode(results)
 }
 
-// PendingDomains gets the list of pending domains from the submission site.
-func pendingDomains() ([]string, error) {
-	resp, err := http.Get("https://hstspreload.org/api/v2/pending")
-	if err != nil {
-		return []string{}, err
-	}
+// removalScan is the output of the scan-pending-removals command.
+type removalScan struct {
+	// Removable holds the results for domains that meet the removal
+	// requirements, and NotRemovable those for the other domains.
+	Removable    []batch.Result `json:"removable"`
+	NotRemovable []batch.Result `json:"not_removable"`
+}
 
-	var entries []preloadlist.Entry
-	err = json.NewDecoder(resp.Body).Decode(&entries)
+// ScanPendingRemovals checks all domains that are pending removal on
+// hstspreload.org for removal requirements, and prints the results grouped
+// by whether the domains meet them.
+func ScanPendingRemovals() error {
+	usePreloadListForRemovals()
+	results, err := newScanner().ScanPendingRemovals(context.Background())
 	if err != nil {
-		return []string{}, err
+		return err
 	}
 
-	var domains []string
-	for _, entry := range entries {
-		domains = append(domains, entry.Name)
+	scan := removalScan{Removable: []batch.Result{}, NotRemovable: []batch.Result{}}
+	for _, r := range results {
+		if len(r.Issues.Errors) == 0 {
+			scan.Removable = append(scan.Removable, r)
+		} else {
+			scan.NotRemovable = append(scan.NotRemovable, r)
+		}
 	}
 
-	return domains, nil
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(scan)
 }
 
-// PreloadedDomains gets the list of pending domains from the Chromium source.
-func preloadedDomains() ([]string, error) {
-	list, err := preloadlist.NewFromLatest()
+// ScanPreloaded scans all preloaded domains.
+func ScanPreloaded() error {
+	results, err := newScanner().ScanPreloaded(context.Background())
 	if err != nil {
-		return []string{}, err
-	}
-
-	var domains []string
-	for _, entry := range list.Entries {
-		domains = append(domains, entry.Name)
+		return err
 	}
 
-	return domains, nil
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(results)
 }
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/chromium/hstspreload"
+	"github.com/chromium/hstspreload/chromium/preloadlist"
+)
+
+// searchOptions is set by the options of the search command.
+var searchOptions struct {
+	query preloadlist.Query
+	count bool
+}
+
+// Search prints the entries of the latest preload list that match
+// `pattern` and the search options.
+func Search(pattern string) error {
+	l, _, err := latestList()
+	if err != nil {
+		return err
+	}
+
+	q := searchOptions.query
+	q.Pattern = pattern
+	entries, err := l.Search(q)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case searchOptions.count && jsonOutput:
+		printJSON(map[string]int{"count": len(entries)})
+	case searchOptions.count:
+		fmt.Println(len(entries))
+	case jsonOutput:
+		printJSON(entries)
+	default:
+		for _, entry := range entries {
+			fmt.Printf("%s (%s)\n", hstspreload.DisplayDomain(entry.Name), entrySettings(entry))
+		}
+		fmt.Fprintf(os.Stderr, "%d matching entries.\n", len(entries))
+	}
+	return nil
+}
+
+func handleSearch(pattern string) {
+	err := Search(pattern)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+
+	os.Exit(0)
+}
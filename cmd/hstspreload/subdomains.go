@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/chromium/hstspreload"
+	"github.com/chromium/hstspreload/ct"
+	"github.com/chromium/hstspreload/subdomains"
+)
+
+// subdomainsOptions is set by the options of the subdomains command.
+var subdomainsOptions struct {
+	discover bool
+}
+
+// CheckSubdomains prints a JSON report of the subdomains of `domain` that
+// would break if it was preloaded with includeSubDomains. The subdomains
+// are read from stdin, or discovered in Certificate Transparency logs with
+// --discover. It returns false if any subdomain would break.
+func CheckSubdomains(domain string) (bool, error) {
+	s := subdomains.New(subdomains.Options{
+		Checker:     checker,
+		Parallelism: batchOptions.Parallelism,
+		Source:      &ct.CrtSh{},
+	})
+
+	var rep *subdomains.Report
+	var err error
+	if subdomainsOptions.discover {
+		fmt.Fprintf(os.Stderr, "Discovering the subdomains of %s...\n", hstspreload.DisplayDomain(domain))
+		rep, err = s.CheckDiscovered(context.Background(), domain)
+	} else {
+		rep, err = s.Check(context.Background(), domain, readDomains())
+	}
+	if err != nil {
+		return false, err
+	}
+
+	printJSON(rep)
+	fmt.Fprintf(os.Stderr, "%d of %d subdomains would break.\n", rep.Counts[subdomains.StatusBreaks], len(rep.Results))
+	return rep.Counts[subdomains.StatusBreaks] == 0, nil
+}
+
+func handleSubdomains(domain string) {
+	ok, err := CheckSubdomains(domain)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+	if !ok {
+		os.Exit(1)
+	}
+
+	os.Exit(0)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/chromium/hstspreload"
+	"github.com/chromium/hstspreload/chromium/preloadapi"
+)
+
+// submitDomain checks `domain` like preloadableDomain(), and submits it to
+// hstspreload.org if it passes (see exitStatus()). If hstspreload.org
+// rejects the submission, `issues` are the issues that it returned.
+// `submission` is the status of the domain on hstspreload.org after a
+// successful submission, and nil otherwise.
+func submitDomain(domain string) (header *string, issues hstspreload.Issues, submission *preloadapi.DomainStatus) {
+	domain = mustBeDomain(domain)
+	header, issues = preloadableDomain(domain)
+	if len(issues.Errors) > 0 || exitStatus(issues) == 1 {
+		progressf("Not submitting %s, since it does not pass the checks.\n", hstspreload.DisplayDomain(domain))
+		return header, issues, nil
+	}
+
+	progressf("Submitting %s%s%s to hstspreload.org...\n", underline, hstspreload.DisplayDomain(domain), resetFormat)
+	client := &preloadapi.Client{}
+	siteIssues, err := client.Submit(context.Background(), domain)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot submit %s: %s\n", hstspreload.DisplayDomain(domain), err)
+		os.Exit(1)
+	}
+	if len(siteIssues.Errors) > 0 {
+		progressf("hstspreload.org rejected the submission.\n")
+		return header, siteIssues, nil
+	}
+
+	status, err := client.Status(context.Background(), domain)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Submitted %s, but cannot retrieve its status: %s\n", hstspreload.DisplayDomain(domain), err)
+		return header, issues, nil
+	}
+	return header, issues, &status
+}
@@ -0,0 +1,84 @@
+package hstspreload
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/chromium/hstspreload/chromium/preloadlist"
+)
+
+// CNAMEInfo describes where the CNAME records of a domain lead.
+//
+// Only the canonical name at the end of the CNAME chain is reported, since
+// that is all that the system resolver exposes.
+type CNAMEInfo struct {
+	// CanonicalName is the name that the CNAME chain of the domain ends at.
+	CanonicalName string `json:"canonical_name"`
+	// TargetEntry is the preload list entry that covers CanonicalName
+	// (either its own entry, or that of an ancestor with
+	// include_subdomains), or nil if it is not preloaded.
+	TargetEntry *preloadlist.Entry `json:"target_entry,omitempty"`
+}
+
+// lookupCNAME returns the canonical name of `domain`, or "" if it has no
+// CNAME records or cannot be resolved. Like checkDNS(), this is skipped if
+// `c` uses a Dialer or a proxy, or overrides the address of `domain`.
+//
+// `c` may be nil.
+func (c *Checker) lookupCNAME(ctx context.Context, domain string) string {
+	if c.resolvesRemotely(domain) || c.hasConnectTo(domain) {
+		return ""
+	}
+
+	resolver := c.netDialer().Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	lookupCtx, cancel := context.WithTimeout(ctx, c.timeout())
+	defer cancel()
+	cname, err := resolver.LookupCNAME(lookupCtx, domain)
+	if err != nil {
+		return ""
+	}
+
+	cname = strings.ToLower(strings.TrimSuffix(cname, "."))
+	if cname == strings.ToLower(strings.TrimSuffix(domain, ".")) {
+		return ""
+	}
+	return cname
+}
+
+// checkCNAMETarget reports whether `canonicalName` (the CNAME target of
+// `domain`) is covered by the preload list `idx`. Submitters who point
+// their domain at a platform domain (e.g. a hosting provider) often assume
+// that the preloaded status of the platform carries over, but HSTS applies
+// to the name in the URL rather than to the DNS target.
+func checkCNAMETarget(domain string, canonicalName string, idx preloadlist.IndexedEntries) (CNAMEInfo, Issues) {
+	info := CNAMEInfo{CanonicalName: canonicalName}
+	issues := Issues{}
+
+	entry, found := idx.Get(canonicalName)
+	if found == preloadlist.EntryNotFound {
+		return info, issues
+	}
+	info.TargetEntry = &entry
+
+	return info, issues.addWarningWithParamsf(
+		IssueCode("dns.cname.target_preloaded"),
+		"CNAME target is preloaded",
+		map[string]string{
+			"canonical_name":     canonicalName,
+			"preloaded_name":     entry.Name,
+			"include_subdomains": strconv.FormatBool(entry.IncludeSubDomains),
+		},
+		"`%s` is a CNAME for `%s`, which is preloaded (by the entry for `%s`). "+
+			"This does not preload `%s`: browsers apply HSTS to the name in the URL, not to the DNS target. "+
+			"Your own submission is independent, and stays in effect if you move to a different provider.",
+		domain,
+		canonicalName,
+		entry.Name,
+		domain,
+	)
+}
@@ -0,0 +1,65 @@
+package hstspreload
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/chromium/hstspreload/chromium/preloadlist"
+)
+
+func TestCheckCNAMETarget(t *testing.T) {
+	idx := preloadlist.PreloadList{Entries: []preloadlist.Entry{
+		{Name: "platform.example", Mode: preloadlist.ForceHTTPS, IncludeSubDomains: true},
+		{Name: "exact.example", Mode: preloadlist.ForceHTTPS},
+	}}.Index()
+
+	for _, tt := range []struct {
+		description   string
+		canonicalName string
+		preloadedName string
+	}{
+		{"target not preloaded", "origin.example", ""},
+		{"target preloaded", "exact.example", "exact.example"},
+		{"target covered by ancestor", "customer.platform.example", "platform.example"},
+		{"target not covered by ancestor without include_subdomains", "customer.exact.example", ""},
+	} {
+		info, issues := checkCNAMETarget("example.com", tt.canonicalName, idx)
+		if info.CanonicalName != tt.canonicalName {
+			t.Errorf("[%s] Unexpected canonical name: %s", tt.description, info.CanonicalName)
+		}
+
+		if tt.preloadedName == "" {
+			if info.TargetEntry != nil {
+				t.Errorf("[%s] Unexpected target entry: %v", tt.description, info.TargetEntry)
+			}
+			if !issues.Match(Issues{}) {
+				t.Errorf("[%s] "+issuesShouldBeEmpty, tt.description, issues)
+			}
+			continue
+		}
+
+		if info.TargetEntry == nil || info.TargetEntry.Name != tt.preloadedName {
+			t.Errorf("[%s] Expected the entry for %s, got %v", tt.description, tt.preloadedName, info.TargetEntry)
+		}
+		expected := Issues{Warnings: []Issue{{Code: "dns.cname.target_preloaded"}}}
+		if !issues.Match(expected) {
+			t.Errorf("[%s] "+issuesShouldMatch, tt.description, issues, expected)
+			continue
+		}
+		if p := issues.Warnings[0].Params; p["canonical_name"] != tt.canonicalName || p["preloaded_name"] != tt.preloadedName {
+			t.Errorf("[%s] Unexpected params: %#v", tt.description, p)
+		}
+	}
+}
+
+func TestLookupCNAMESkippedWithDialer(t *testing.T) {
+	c := &Checker{Dialer: DialerFunc(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		t.Errorf("Unexpected dial to %s", addr)
+		return nil, errors.New("unexpected dial")
+	})}
+	if cname := c.lookupCNAME(context.Background(), "example.com"); cname != "" {
+		t.Errorf("Names should not be resolved locally with a Dialer, got %q", cname)
+	}
+}
@@ -0,0 +1,231 @@
+package hstspreload
+
+import (
+	"strings"
+)
+
+const hstsHeaderName = "strict-transport-security"
+
+// A ConfigHeader is an HSTS header that is set in a web server
+// configuration.
+type ConfigHeader struct {
+	// The server software whose syntax was recognized: "nginx", "apache",
+	// or "caddy".
+	Server string `json:"server"`
+	// The line number of the directive, starting at 1.
+	Line int `json:"line"`
+	// The header value, without quotes.
+	Value string `json:"value"`
+	// Whether the header is also sent on error responses (e.g. nginx
+	// `always`). Caddy always sends headers.
+	Always bool `json:"always"`
+	// The issues from checking Value for preload requirements.
+	Issues Issues `json:"issues"`
+}
+
+// PreloadableConfig extracts the HSTS headers that are set by a web server
+// configuration snippet (nginx `add_header`, Apache `Header set`, or Caddy
+// `header`), and checks them for preload requirements. This allows users to
+// validate a configuration before deploying it.
+//
+// The returned issues contain problems with the configuration as a whole
+// (e.g. no HSTS header, or inconsistent headers), followed by the issues of
+// the first header. Each header has its own issues, too.
+// To interpret `issues`, see the list of conventions in the
+// documentation for Issues.
+func PreloadableConfig(config string) (headers []ConfigHeader, issues Issues) {
+	issues = Issues{}
+	headers = extractConfigHeaders(config)
+
+	if len(headers) == 0 {
+		return headers, issues.addErrorf(
+			IssueCode("config.no_header"),
+			"No HSTS header",
+			"Config error: We could not find a directive that sets the Strict-Transport-Security header. "+
+				"We recognize nginx `add_header`, Apache `Header set`, and Caddy `header` directives.",
+		)
+	}
+
+	for i := range headers {
+		headers[i].Issues = PreloadableHeaderString(headers[i].Value)
+		if headers[i].Value != headers[0].Value {
+			issues = issues.addUniqueWarningf(
+				IssueCode("config.inconsistent_headers"),
+				"Inconsistent HSTS headers",
+				"Config warning: The configuration sets different HSTS headers (`%s` on line %d, but `%s` on line %d). "+
+					"Make sure that every server for the domain sends the same preloadable header.",
+				headers[0].Value,
+				headers[0].Line,
+				headers[i].Value,
+				headers[i].Line,
+			)
+		}
+		if !headers[i].Always {
+			issues = issues.addUniqueWarningf(
+				IssueCode("config.not_always"),
+				"Header not sent on all responses",
+				"Config warning: The HSTS header on line %d is only sent on successful responses. "+
+					"Use `always` (nginx: `add_header ... always;`, Apache: `Header always set ...`) "+
+					"so that it is also sent on redirects and error pages.",
+				headers[i].Line,
+			)
+		}
+	}
+
+	return headers, combineIssues(issues, headers[0].Issues)
+}
+
+// extractConfigHeaders finds the directives that set the HSTS header.
+func extractConfigHeaders(config string) []ConfigHeader {
+	var headers []ConfigHeader
+	inCaddyHeaderBlock := false
+
+	for i, line := range strings.Split(config, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		tokens := configTokens(line)
+		if len(tokens) == 0 {
+			continue
+		}
+
+		h := ConfigHeader{Line: i + 1}
+		switch {
+		// nginx: add_header Strict-Transport-Security "..." [always];
+		case tokens[0] == "add_header" && len(tokens) >= 3 && strings.EqualFold(tokens[1], hstsHeaderName):
+			h.Server = "nginx"
+			h.Value = tokens[2]
+			h.Always = len(tokens) >= 4 && tokens[3] == "always"
+
+		// Apache: Header [always|onsuccess] set|append|add Strict-Transport-Security "..."
+		case strings.EqualFold(tokens[0], "Header") && isApacheHeaderDirective(tokens[1:]):
+			rest := tokens[1:]
+			if isApacheCondition(rest[0]) {
+				h.Always = strings.EqualFold(rest[0], "always")
+				rest = rest[1:]
+			}
+			if len(rest) < 3 || !isApacheSetAction(rest[0]) || !strings.EqualFold(rest[1], hstsHeaderName) {
+				continue
+			}
+			h.Server = "apache"
+			h.Value = rest[2]
+
+		// Caddy: header [matcher] Strict-Transport-Security "..."
+		case tokens[0] == "header":
+			if tokens[len(tokens)-1] == "{" {
+				inCaddyHeaderBlock = true
+				continue
+			}
+			value, ok := caddyHeaderValue(tokens[1:])
+			if !ok {
+				continue
+			}
+			h.Server = "caddy"
+			h.Value = value
+			h.Always = true
+
+		// Caddy: a line in a `header {...}` block.
+		case inCaddyHeaderBlock:
+			if tokens[0] == "}" {
+				inCaddyHeaderBlock = false
+				continue
+			}
+			value, ok := caddyHeaderValue(tokens)
+			if !ok {
+				continue
+			}
+			h.Server = "caddy"
+			h.Value = value
+			h.Always = true
+
+		default:
+			continue
+		}
+
+		headers = append(headers, h)
+	}
+
+	return headers
+}
+
+// isApacheHeaderDirective returns whether the arguments of a `Header`
+// directive use the Apache syntax (rather than Caddy's).
+func isApacheHeaderDirective(args []string) bool {
+	if len(args) > 0 && isApacheCondition(args[0]) {
+		args = args[1:]
+	}
+	return len(args) > 0 && (isApacheSetAction(args[0]) || isApacheOtherAction(args[0]))
+}
+
+func isApacheCondition(condition string) bool {
+	return strings.EqualFold(condition, "always") || strings.EqualFold(condition, "onsuccess")
+}
+
+func isApacheOtherAction(action string) bool {
+	switch strings.ToLower(action) {
+	case "unset", "echo", "edit", "edit*", "note":
+		return true
+	}
+	return false
+}
+
+func isApacheSetAction(action string) bool {
+	switch strings.ToLower(action) {
+	case "set", "add", "append", "merge":
+		return true
+	}
+	return false
+}
+
+// caddyHeaderValue returns the value of `tokens` if they set the HSTS
+// header. A leading matcher (e.g. `*` or `@name`) is skipped.
+func caddyHeaderValue(tokens []string) (string, bool) {
+	for len(tokens) >= 2 {
+		name := strings.TrimPrefix(tokens[0], "+")
+		if strings.EqualFold(name, hstsHeaderName) {
+			return tokens[1], true
+		}
+		tokens = tokens[1:]
+	}
+	return "", false
+}
+
+// configTokens splits a configuration line into tokens, respecting single
+// and double quotes (which are removed) and ignoring trailing semicolons
+// and comments.
+func configTokens(line string) []string {
+	var tokens []string
+	var current strings.Builder
+	inToken := false
+	var quote rune
+
+	for _, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inToken = true
+		case r == '#' && !inToken:
+			return tokens
+		case r == ' ' || r == '\t' || r == ';':
+			if inToken {
+				tokens = append(tokens, current.String())
+				current.Reset()
+				inToken = false
+			}
+		default:
+			current.WriteRune(r)
+			inToken = true
+		}
+	}
+	if inToken {
+		tokens = append(tokens, current.String())
+	}
+	return tokens
+}
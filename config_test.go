@@ -0,0 +1,103 @@
+package hstspreload
+
+import "testing"
+
+const preloadableValue = "max-age=63072000; includeSubDomains; preload"
+
+var preloadableConfigTests = []struct {
+	description     string
+	config          string
+	expectedHeaders []ConfigHeader
+	expectedIssues  Issues
+}{
+	{
+		"nginx",
+		`server {
+    listen 443 ssl;
+    add_header Strict-Transport-Security "max-age=63072000; includeSubDomains; preload" always;
+}`,
+		[]ConfigHeader{{Server: "nginx", Line: 3, Value: preloadableValue, Always: true}},
+		Issues{},
+	},
+	{
+		"nginx without always",
+		`add_header Strict-Transport-Security 'max-age=63072000; includeSubDomains; preload';`,
+		[]ConfigHeader{{Server: "nginx", Line: 1, Value: preloadableValue}},
+		Issues{Warnings: []Issue{{Code: "config.not_always"}}},
+	},
+	{
+		"Apache",
+		`<VirtualHost *:443>
+  # Header always set Strict-Transport-Security "max-age=0"
+  Header always set Strict-Transport-Security "max-age=63072000; includeSubDomains; preload"
+</VirtualHost>`,
+		[]ConfigHeader{{Server: "apache", Line: 3, Value: preloadableValue, Always: true}},
+		Issues{},
+	},
+	{
+		"Apache onsuccess with bad header",
+		`Header set Strict-Transport-Security "max-age=300"`,
+		[]ConfigHeader{{Server: "apache", Line: 1, Value: "max-age=300"}},
+		Issues{
+			Errors: []Issue{
+				{Code: "header.preloadable.include_sub_domains.missing"},
+				{Code: "header.preloadable.preload.missing"},
+				{Code: "header.preloadable.max_age.below_1_year"},
+			},
+			Warnings: []Issue{{Code: "config.not_always"}},
+		},
+	},
+	{
+		"Caddy",
+		`example.com {
+	header Strict-Transport-Security "max-age=63072000; includeSubDomains; preload"
+}`,
+		[]ConfigHeader{{Server: "caddy", Line: 2, Value: preloadableValue, Always: true}},
+		Issues{},
+	},
+	{
+		"Caddy block and inconsistent headers",
+		`example.com {
+	header {
+		X-Frame-Options DENY
+		+Strict-Transport-Security "max-age=63072000; includeSubDomains; preload"
+	}
+}
+www.example.com {
+	header * Strict-Transport-Security "max-age=31536000"
+}`,
+		[]ConfigHeader{
+			{Server: "caddy", Line: 4, Value: preloadableValue, Always: true},
+			{Server: "caddy", Line: 8, Value: "max-age=31536000", Always: true},
+		},
+		Issues{Warnings: []Issue{{Code: "config.inconsistent_headers"}}},
+	},
+	{
+		"no header",
+		`add_header X-Frame-Options DENY;
+Header unset Strict-Transport-Security`,
+		nil,
+		Issues{Errors: []Issue{{Code: "config.no_header"}}},
+	},
+}
+
+func TestPreloadableConfig(t *testing.T) {
+	for _, tt := range preloadableConfigTests {
+		headers, issues := PreloadableConfig(tt.config)
+
+		if len(headers) != len(tt.expectedHeaders) {
+			t.Errorf("[%s] Expected %d headers, got %#v", tt.description, len(tt.expectedHeaders), headers)
+		} else {
+			for i, h := range headers {
+				e := tt.expectedHeaders[i]
+				if h.Server != e.Server || h.Line != e.Line || h.Value != e.Value || h.Always != e.Always {
+					t.Errorf("[%s] Unexpected header: %#v (expected %#v)", tt.description, h, e)
+				}
+			}
+		}
+
+		if !issues.Match(tt.expectedIssues) {
+			t.Errorf("[%s] "+issuesShouldMatch, tt.description, issues, tt.expectedIssues)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package hstspreload
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http/httptrace"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// A connectPhase is a step of making an HTTPS request.
+type connectPhase string
+
+const (
+	phaseDNS          connectPhase = "dns"
+	phaseTCPConnect   connectPhase = "tcp_connect"
+	phaseTLSHandshake connectPhase = "tls_handshake"
+	phaseHTTPResponse connectPhase = "http_response"
+)
+
+// A connectAttempt records how far a request got, so that failures can be
+// classified.
+type connectAttempt struct {
+	mu      sync.Mutex
+	phase   connectPhase
+	start   time.Time
+	elapsed time.Duration
+}
+
+func newConnectAttempt() *connectAttempt {
+	return &connectAttempt{phase: phaseTCPConnect, start: time.Now()}
+}
+
+// setPhase records that the attempt has reached `phase`. `a` may be nil.
+func (a *connectAttempt) setPhase(phase connectPhase) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	a.phase = phase
+	a.mu.Unlock()
+}
+
+// connectAttemptKey is the context key for the connectAttempt of a request.
+type connectAttemptKey struct{}
+
+// connectAttemptFrom returns the attempt that `ctx` belongs to (see
+// withTrace()), or nil.
+func connectAttemptFrom(ctx context.Context) *connectAttempt {
+	a, _ := ctx.Value(connectAttemptKey{}).(*connectAttempt)
+	return a
+}
+
+// withTrace returns a context that updates the phase of `a` as the request
+// progresses. Connections made by a remote Dialer skip the DNS phase.
+// Lookups in a DNSCache bypass the trace, so Checker.dialContext() sets the
+// phase of the attempt in the context itself.
+func (a *connectAttempt) withTrace(ctx context.Context) context.Context {
+	ctx = context.WithValue(ctx, connectAttemptKey{}, a)
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { a.setPhase(phaseDNS) },
+		DNSDone:           func(httptrace.DNSDoneInfo) { a.setPhase(phaseTCPConnect) },
+		TLSHandshakeStart: func() { a.setPhase(phaseTLSHandshake) },
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			if err == nil {
+				a.setPhase(phaseHTTPResponse)
+			}
+		},
+		GotConn: func(httptrace.GotConnInfo) { a.setPhase(phaseHTTPResponse) },
+	})
+}
+
+// finish records the elapsed time of the attempt.
+func (a *connectAttempt) finish() {
+	a.mu.Lock()
+	a.elapsed = time.Since(a.start)
+	a.mu.Unlock()
+}
+
+// isTimeout returns whether `err` was caused by a timeout.
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
+
+// The causes of a ConnectError, for use with errors.Is().
+var (
+	ErrDNS          = errors.New("DNS lookup failed")
+	ErrTimeout      = errors.New("connection timed out")
+	ErrTLSHandshake = errors.New("TLS handshake failed")
+	ErrConnRefused  = errors.New("connection refused")
+)
+
+// A ConnectError is a failure to connect to a server, classified by its
+// cause, so that failures can be grouped by root cause (e.g.
+// errors.Is(err, ErrDNS)).
+type ConnectError struct {
+	// Kind is ErrDNS, ErrTimeout, ErrTLSHandshake, or ErrConnRefused, or
+	// nil if the cause is not one of these (e.g. an invalid certificate).
+	Kind error
+	// Phase is the step in which the connection failed: "dns",
+	// "tcp_connect", "tls_handshake", or "http_response".
+	Phase string
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *ConnectError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ConnectError) Unwrap() error {
+	return e.Err
+}
+
+// Is returns whether `target` is the Kind of `e`.
+func (e *ConnectError) Is(target error) bool {
+	return e.Kind != nil && target == e.Kind
+}
+
+// newConnectError classifies `err`, which occurred in `phase`.
+func newConnectError(phase connectPhase, err error) *ConnectError {
+	e := &ConnectError{Phase: string(phase), Err: err}
+
+	var dnsErr *net.DNSError
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var certInvalidErr x509.CertificateInvalidError
+	var hostnameErr x509.HostnameError
+	switch {
+	case errors.As(err, &dnsErr):
+		// Including DNS timeouts, so that all failed lookups are grouped.
+		e.Kind = ErrDNS
+		e.Phase = string(phaseDNS)
+	case isTimeout(err):
+		e.Kind = ErrTimeout
+	case errors.Is(err, syscall.ECONNREFUSED):
+		e.Kind = ErrConnRefused
+	case errors.As(err, &unknownAuthorityErr), errors.As(err, &certInvalidErr), errors.As(err, &hostnameErr):
+		// The handshake worked, but the certificate is invalid.
+	case phase == phaseTLSHandshake:
+		e.Kind = ErrTLSHandshake
+	}
+	return e
+}
+
+// cannotConnectIssues reports that we could not make a request to `target`
+// (e.g. "https://example.com") in `attempt`. The error code depends on the
+// cause (see ConnectError): domain.dns.no_records,
+// domain.tcp.connection_refused, domain.tls.handshake_failure, a timeout
+// code for the phase in which the timeout occurred (see timeoutCodes), or
+// domain.tls.cannot_connect for other causes. Classifying timeouts by phase
+// lets users tell a firewall that drops connections from a slow server.
+//
+// The error has the params "phase" (see connectPhase), "timeout" ("true" or
+// "false"), and "elapsed_ms".
+func cannotConnectIssues(target string, attempt *connectAttempt, err error) Issues {
+	attempt.mu.Lock()
+	phase, elapsed := attempt.phase, attempt.elapsed
+	attempt.mu.Unlock()
+
+	connectErr := newConnectError(phase, err)
+	phase = connectPhase(connectErr.Phase)
+	params := map[string]string{
+		"phase":      string(phase),
+		"timeout":    strconv.FormatBool(isTimeout(err)),
+		"elapsed_ms": strconv.FormatInt(elapsed.Milliseconds(), 10),
+	}
+	switch connectErr.Kind {
+	case ErrTimeout:
+		// Handled below.
+	case ErrDNS:
+		return Issues{}.addErrorWithParamsf(
+			IssueCode("domain.dns.no_records"),
+			"Cannot resolve the domain",
+			params,
+			"We cannot connect to %s: the DNS lookup failed (%q). "+
+				"Check that the domain has A or AAAA records, and that its nameservers respond.",
+			target,
+			err,
+		)
+	case ErrConnRefused:
+		return Issues{}.addErrorWithParamsf(
+			IssueCode("domain.tcp.connection_refused"),
+			"Connection refused",
+			params,
+			"We cannot connect to %s: the server refused the connection on port %s (%q). "+
+				"Check that the server listens for HTTPS connections.",
+			target,
+			targetPort(target),
+			err,
+		)
+	case ErrTLSHandshake:
+		return Issues{}.addErrorWithParamsf(
+			IssueCode("domain.tls.handshake_failure"),
+			"TLS handshake failed",
+			params,
+			"We cannot connect to %s: the TLS handshake failed (%q). "+
+				"Check that the server serves a certificate for the domain, and supports TLS 1.2 or later.",
+			target,
+			err,
+		)
+	default:
+		return Issues{}.addErrorWithParamsf(
+			IssueCode("domain.tls.cannot_connect"),
+			"Cannot connect using TLS",
+			params,
+			"We cannot connect to %s using TLS (%q).",
+			target,
+			err,
+		)
+	}
+
+	summary, explanation := "Connection timed out", ""
+	switch phase {
+	case phaseDNS:
+		summary = "DNS lookup timed out"
+		explanation = "Check that the nameservers of the domain respond."
+	case phaseTCPConnect:
+		summary = "TCP connect timed out"
+		explanation = fmt.Sprintf("The server did not accept the connection on port %s. ", targetPort(target)) +
+			"This usually means that a firewall drops the connections."
+	case phaseTLSHandshake:
+		summary = "TLS handshake timed out"
+		explanation = "The server accepted the connection, but did not complete the TLS handshake."
+	case phaseHTTPResponse:
+		summary = "HTTP response timed out"
+		explanation = "The server completed the TLS handshake, but did not send a response in time."
+	}
+	return Issues{}.addErrorWithParamsf(
+		timeoutCodes[phase],
+		summary,
+		params,
+		"We cannot connect to %s using TLS: the %s timed out after %s (%q). %s",
+		target,
+		phaseDescriptions[phase],
+		elapsed.Round(time.Millisecond),
+		err,
+		explanation,
+	)
+}
+
+// targetPort returns the port of the URL at the start of `target` (e.g.
+// "https://example.com:8443 at 192.0.2.1"), or "443" if it has none.
+func targetPort(target string) string {
+	u, err := url.Parse(strings.SplitN(target, " ", 2)[0])
+	if err != nil || u.Port() == "" {
+		return "443"
+	}
+	return u.Port()
+}
+
+// timeoutCodes are the codes of timeouts in each phase.
+var timeoutCodes = map[connectPhase]IssueCode{
+	phaseDNS:          "domain.dns.timeout",
+	phaseTCPConnect:   "domain.tcp.timeout",
+	phaseTLSHandshake: "domain.tls.handshake_timeout",
+	phaseHTTPResponse: "domain.http.response_timeout",
+}
+
+var phaseDescriptions = map[connectPhase]string{
+	phaseDNS:          "DNS lookup",
+	phaseTCPConnect:   "TCP connection",
+	phaseTLSHandshake: "TLS handshake",
+	phaseHTTPResponse: "HTTP response",
+}
@@ -0,0 +1,183 @@
+package hstspreload
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+var cannotConnectIssuesTests = []struct {
+	description     string
+	phase           connectPhase
+	err             error
+	expectedCode    IssueCode
+	expectedSummary string
+	expectedTimeout string
+}{
+	{"DNS timeout", phaseDNS, context.DeadlineExceeded, "domain.dns.timeout", "DNS lookup timed out", "true"},
+	{"TCP timeout", phaseTCPConnect, &net.OpError{Op: "dial", Err: context.DeadlineExceeded}, "domain.tcp.timeout", "TCP connect timed out", "true"},
+	{"TLS timeout", phaseTLSHandshake, context.DeadlineExceeded, "domain.tls.handshake_timeout", "TLS handshake timed out", "true"},
+	{"HTTP timeout", phaseHTTPResponse, context.DeadlineExceeded, "domain.http.response_timeout", "HTTP response timed out", "true"},
+	{"no such host", phaseDNS, &net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "example.com", IsNotFound: true}}, "domain.dns.no_records", "Cannot resolve the domain", "false"},
+	{"DNS error timeout", phaseDNS, &net.OpError{Op: "dial", Err: &net.DNSError{Err: "i/o timeout", Name: "example.com", IsTimeout: true}}, "domain.dns.no_records", "Cannot resolve the domain", "true"},
+	{"connection refused", phaseTCPConnect, &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, "domain.tcp.connection_refused", "Connection refused", "false"},
+	{"handshake failure", phaseTLSHandshake, errors.New("remote error: tls: handshake failure"), "domain.tls.handshake_failure", "TLS handshake failed", "false"},
+	{"invalid certificate", phaseTLSHandshake, x509.UnknownAuthorityError{}, "domain.tls.cannot_connect", "Cannot connect using TLS", "false"},
+	{"other error", phaseTCPConnect, errors.New("connection reset"), "domain.tls.cannot_connect", "Cannot connect using TLS", "false"},
+}
+
+func TestCannotConnectIssues(t *testing.T) {
+	for _, tt := range cannotConnectIssuesTests {
+		attempt := &connectAttempt{phase: tt.phase, elapsed: 1500 * time.Millisecond}
+		issues := cannotConnectIssues("https://example.com", attempt, tt.err)
+
+		expected := Issues{Errors: []Issue{{Code: tt.expectedCode}}}
+		if !issues.Match(expected) {
+			t.Errorf("[%s] "+issuesShouldMatch, tt.description, issues, expected)
+			continue
+		}
+		issue := issues.Errors[0]
+		if issue.Summary != tt.expectedSummary {
+			t.Errorf("[%s] Unexpected summary: %q", tt.description, issue.Summary)
+		}
+		if issue.Params["phase"] != string(tt.phase) || issue.Params["timeout"] != tt.expectedTimeout || issue.Params["elapsed_ms"] != "1500" {
+			t.Errorf("[%s] Unexpected params: %#v", tt.description, issue.Params)
+		}
+	}
+}
+
+func TestCannotConnectIssuesPort(t *testing.T) {
+	for _, tt := range []struct {
+		target string
+		port   string
+	}{
+		{"https://example.com", "443"},
+		{"https://example.com:8443", "8443"},
+		{"https://example.com:8443 at 192.0.2.1:8443", "8443"},
+	} {
+		attempt := &connectAttempt{phase: phaseTCPConnect}
+		issues := cannotConnectIssues(tt.target, attempt, &net.OpError{Op: "dial", Err: context.DeadlineExceeded})
+		if len(issues.Errors) != 1 || !strings.Contains(issues.Errors[0].Message, "on port "+tt.port+".") {
+			t.Errorf("[%s] The message should mention port %s: %#v", tt.target, tt.port, issues)
+		}
+	}
+}
+
+func TestConnectError(t *testing.T) {
+	dnsErr := &net.DNSError{Err: "no such host", Name: "example.com", IsNotFound: true}
+	err := error(newConnectError(phaseTCPConnect, &net.OpError{Op: "dial", Err: dnsErr}))
+
+	if !errors.Is(err, ErrDNS) || errors.Is(err, ErrTimeout) {
+		t.Errorf("Unexpected kind of error: %#v", err)
+	}
+	var unwrapped *net.DNSError
+	if !errors.As(err, &unwrapped) {
+		t.Errorf("The ConnectError should wrap the underlying error.")
+	}
+	var connectErr *ConnectError
+	if !errors.As(err, &connectErr) || connectErr.Phase != "dns" {
+		t.Errorf("Unexpected error: %#v", err)
+	}
+
+	timeoutErr := &net.DNSError{Err: "i/o timeout", Name: "example.com", IsTimeout: true}
+	if err := newConnectError(phaseDNS, timeoutErr); !errors.Is(err, ErrDNS) || errors.Is(err, ErrTimeout) {
+		t.Errorf("DNS timeouts should be classified as DNS errors: %#v", err)
+	}
+
+	if err := newConnectError(phaseTLSHandshake, x509.UnknownAuthorityError{}); err.Kind != nil || errors.Is(err, ErrTLSHandshake) {
+		t.Errorf("Certificate errors should not be classified: %#v", err)
+	}
+}
+
+func TestGetResponseTimeouts(t *testing.T) {
+	// A server that accepts connections, but never responds.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		var conns []net.Conn
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				for _, conn := range conns {
+					conn.Close()
+				}
+				return
+			}
+			conns = append(conns, conn)
+		}
+	}()
+
+	// A server that completes the handshake, but responds slowly.
+	done := make(chan struct{})
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(done)
+
+	for _, tt := range []struct {
+		description string
+		address     string
+		phase       string
+		code        IssueCode
+	}{
+		{"TLS handshake timeout", ln.Addr().String(), "tls_handshake", "domain.tls.handshake_timeout"},
+		{"HTTP response timeout", srv.Listener.Addr().String(), "http_response", "domain.http.response_timeout"},
+	} {
+		c := &Checker{
+			Timeout:                 200 * time.Millisecond,
+			ConnectTo:               map[string]string{"example.com:443": tt.address},
+			Transport:               srv.Client().Transport.(*http.Transport),
+			DisableInsecureFallback: true,
+		}
+		_, _, issues := getResponse(context.Background(), "example.com", c)
+
+		expected := Issues{Errors: []Issue{{Code: tt.code}}}
+		if !issues.Match(expected) {
+			t.Errorf("[%s] "+issuesShouldMatch, tt.description, issues, expected)
+			continue
+		}
+		if params := issues.Errors[0].Params; params["phase"] != tt.phase || params["timeout"] != "true" {
+			t.Errorf("[%s] Unexpected params: %#v", tt.description, params)
+		}
+	}
+}
+
+func TestGetResponseDNSTimeout(t *testing.T) {
+	// A resolver that never responds.
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}
+	c := &Checker{
+		Timeout:                 200 * time.Millisecond,
+		Resolver:                resolver,
+		DisableInsecureFallback: true,
+		Retry:                   &RetryPolicy{MaxAttempts: 1},
+	}
+	_, _, issues := getResponse(context.Background(), "example.com", c.withDNSCache())
+
+	if len(issues.Errors) != 1 {
+		t.Fatalf("Expected exactly one error: %#v", issues)
+	}
+	if phase := issues.Errors[0].Params["phase"]; phase != "dns" {
+		t.Errorf("Expected the error to be in the dns phase, got %q: %#v", phase, issues.Errors[0])
+	}
+}
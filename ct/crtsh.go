@@ -0,0 +1,138 @@
+package ct
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultCrtShURL is the search endpoint of https://crt.sh/.
+	DefaultCrtShURL = "https://crt.sh/"
+
+	// crt.sh can be slow for domains with many certificates.
+	defaultCrtShTimeout = 60 * time.Second
+
+	// crt.sh reports times in UTC, without a time zone.
+	crtShTimeLayout = "2006-01-02T15:04:05"
+)
+
+// CrtSh is a Source that searches the Certificate Transparency logs using
+// https://crt.sh/.
+//
+// The zero value is ready to use.
+type CrtSh struct {
+	// BaseURL is the URL of the search endpoint. If empty, DefaultCrtShURL
+	// is used.
+	BaseURL string
+	// HTTPClient makes the requests. If nil, a client with a timeout of 60
+	// seconds is used.
+	HTTPClient *http.Client
+}
+
+// crtShEntry is an entry of the JSON output of crt.sh. NameValue contains
+// the names of the certificate, separated by newlines.
+type crtShEntry struct {
+	ID           int64  `json:"id"`
+	IssuerName   string `json:"issuer_name"`
+	CommonName   string `json:"common_name"`
+	NameValue    string `json:"name_value"`
+	NotBefore    string `json:"not_before"`
+	NotAfter     string `json:"not_after"`
+	SerialNumber string `json:"serial_number"`
+}
+
+// Certificates returns the logged certificates of `domain` and its
+// subdomains. A precertificate and the final certificate with the same
+// issuer and serial number are only returned once.
+func (c *CrtSh) Certificates(ctx context.Context, domain string) ([]Certificate, error) {
+	entries, err := c.search(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	certs := []Certificate{}
+	for _, e := range entries {
+		key := e.IssuerName + "\n" + e.SerialNumber
+		if e.SerialNumber != "" && seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		cert := Certificate{
+			ID:           e.ID,
+			Issuer:       e.IssuerName,
+			SerialNumber: e.SerialNumber,
+			CommonName:   e.CommonName,
+			Names:        strings.Split(e.NameValue, "\n"),
+		}
+		if cert.NotBefore, err = time.Parse(crtShTimeLayout, e.NotBefore); err != nil {
+			return nil, fmt.Errorf("crt.sh: invalid not_before of certificate %d: %s", e.ID, err)
+		}
+		if cert.NotAfter, err = time.Parse(crtShTimeLayout, e.NotAfter); err != nil {
+			return nil, fmt.Errorf("crt.sh: invalid not_after of certificate %d: %s", e.ID, err)
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
+
+// Subdomains returns the subdomains of `domain` that occur in logged
+// certificates, in sorted order and without duplicates. Wildcard names are
+// reported without the wildcard label.
+func (c *CrtSh) Subdomains(ctx context.Context, domain string) ([]string, error) {
+	entries, err := c.search(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, e := range entries {
+		names = append(names, strings.Split(e.NameValue, "\n")...)
+	}
+	return SubdomainsOf(domain, names), nil
+}
+
+// search returns the entries of crt.sh for `domain` and its subdomains.
+func (c *CrtSh) search(ctx context.Context, domain string) ([]crtShEntry, error) {
+	domain = strings.ToLower(domain)
+	u := c.baseURL() + "?" + url.Values{"q": {"%." + domain}, "output": {"json"}}.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("crt.sh: status code %d", resp.StatusCode)
+	}
+	var entries []crtShEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("crt.sh: invalid response: %s", err)
+	}
+	return entries, nil
+}
+
+func (c *CrtSh) baseURL() string {
+	if c == nil || c.BaseURL == "" {
+		return DefaultCrtShURL
+	}
+	return c.BaseURL
+}
+
+func (c *CrtSh) httpClient() *http.Client {
+	if c == nil || c.HTTPClient == nil {
+		return &http.Client{Timeout: defaultCrtShTimeout}
+	}
+	return c.HTTPClient
+}
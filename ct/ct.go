@@ -0,0 +1,136 @@
+// Package ct looks up the certificates that were issued for a domain and
+// its subdomains in Certificate Transparency logs, and summarizes them:
+// which CAs issue certificates for the domain, which certificates are
+// about to expire, and which subdomains they cover. The subdomains feed
+// the analysis of the subdomains package.
+package ct
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultExpiryWarning is the window in which Summarize() reports
+// certificates as expiring, if none is given.
+const DefaultExpiryWarning = 30 * 24 * time.Hour
+
+// A Certificate is a certificate that was found in the logs.
+type Certificate struct {
+	// ID identifies the certificate at the source (e.g. the crt.sh ID).
+	ID           int64     `json:"id"`
+	Issuer       string    `json:"issuer"`
+	SerialNumber string    `json:"serial_number"`
+	CommonName   string    `json:"common_name,omitempty"`
+	Names        []string  `json:"names"`
+	NotBefore    time.Time `json:"not_before"`
+	NotAfter     time.Time `json:"not_after"`
+}
+
+// Valid returns whether `now` is in the validity period of the certificate.
+func (c Certificate) Valid(now time.Time) bool {
+	return !now.Before(c.NotBefore) && now.Before(c.NotAfter)
+}
+
+// A Source looks up the certificates that were issued for a domain and its
+// subdomains. CrtSh implements it.
+type Source interface {
+	Certificates(ctx context.Context, domain string) ([]Certificate, error)
+}
+
+// An IssuerCount is the number of certificates of an issuer.
+type IssuerCount struct {
+	Issuer       string `json:"issuer"`
+	Certificates int    `json:"certificates"`
+	// Valid is the number of certificates that are currently valid.
+	Valid int `json:"valid"`
+}
+
+// A Summary is the outcome of Summarize().
+type Summary struct {
+	Domain string `json:"domain"`
+	// Certificates is the number of certificates, and Valid the number of
+	// them that are currently valid.
+	Certificates int `json:"certificates"`
+	Valid        int `json:"valid"`
+	// Issuers are sorted by decreasing number of certificates.
+	Issuers []IssuerCount `json:"issuers"`
+	// Expiring holds the valid certificates that expire within the warning
+	// window, sorted by expiry.
+	Expiring []Certificate `json:"expiring"`
+	// Subdomains holds the subdomains of Domain that occur in any of the
+	// certificates, in sorted order. Wildcard names are reported without
+	// the wildcard label.
+	Subdomains []string `json:"subdomains"`
+}
+
+// Summarize summarizes the `certs` of `domain` at time `now`. Certificates
+// that expire within `expiryWarning` (or DefaultExpiryWarning, if zero) are
+// reported as expiring.
+func Summarize(domain string, certs []Certificate, now time.Time, expiryWarning time.Duration) *Summary {
+	if expiryWarning == 0 {
+		expiryWarning = DefaultExpiryWarning
+	}
+	domain = strings.ToLower(domain)
+
+	s := &Summary{
+		Domain:       domain,
+		Certificates: len(certs),
+		Issuers:      []IssuerCount{},
+		Expiring:     []Certificate{},
+	}
+	issuers := make(map[string]*IssuerCount)
+	var names []string
+	for _, c := range certs {
+		ic, ok := issuers[c.Issuer]
+		if !ok {
+			ic = &IssuerCount{Issuer: c.Issuer}
+			issuers[c.Issuer] = ic
+		}
+		ic.Certificates++
+
+		if c.Valid(now) {
+			s.Valid++
+			ic.Valid++
+			if c.NotAfter.Sub(now) <= expiryWarning {
+				s.Expiring = append(s.Expiring, c)
+			}
+		}
+		names = append(names, c.Names...)
+	}
+
+	for _, ic := range issuers {
+		s.Issuers = append(s.Issuers, *ic)
+	}
+	sort.Slice(s.Issuers, func(i, j int) bool {
+		if s.Issuers[i].Certificates != s.Issuers[j].Certificates {
+			return s.Issuers[i].Certificates > s.Issuers[j].Certificates
+		}
+		return s.Issuers[i].Issuer < s.Issuers[j].Issuer
+	})
+	sort.SliceStable(s.Expiring, func(i, j int) bool {
+		return s.Expiring[i].NotAfter.Before(s.Expiring[j].NotAfter)
+	})
+	s.Subdomains = SubdomainsOf(domain, names)
+	return s
+}
+
+// SubdomainsOf returns the subdomains of `domain` in `names`, lowercased,
+// in sorted order and without duplicates. Wildcard names are reported
+// without the wildcard label.
+func SubdomainsOf(domain string, names []string) []string {
+	domain = strings.ToLower(domain)
+	seen := make(map[string]bool)
+	subdomains := []string{}
+	for _, name := range names {
+		name = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(name)), "*.")
+		if !strings.HasSuffix(name, "."+domain) || seen[name] {
+			continue
+		}
+		seen[name] = true
+		subdomains = append(subdomains, name)
+	}
+	sort.Strings(subdomains)
+	return subdomains
+}
@@ -0,0 +1,99 @@
+package ct
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+
+func TestSummarize(t *testing.T) {
+	certs := []Certificate{
+		{ID: 1, Issuer: "CN=R3", Names: []string{"example.com", "www.example.com"},
+			NotBefore: now.AddDate(0, -2, 0), NotAfter: now.AddDate(0, 2, 0)},
+		{ID: 2, Issuer: "CN=R3", Names: []string{"*.Dev.Example.com"},
+			NotBefore: now.AddDate(0, -3, 0), NotAfter: now.AddDate(0, 0, 10)},
+		{ID: 3, Issuer: "CN=Other CA", Names: []string{"old.example.com", "example.org"},
+			NotBefore: now.AddDate(-2, 0, 0), NotAfter: now.AddDate(-1, 0, 0)},
+		{ID: 4, Issuer: "CN=R3", Names: []string{"api.example.com"},
+			NotBefore: now.AddDate(0, 0, -1), NotAfter: now.AddDate(0, 0, 5)},
+	}
+
+	s := Summarize("example.com", certs, now, 0)
+	if s.Certificates != 4 || s.Valid != 3 {
+		t.Errorf("Expected 4 certificates, 3 valid, got %d, %d valid", s.Certificates, s.Valid)
+	}
+	expectedIssuers := []IssuerCount{
+		{Issuer: "CN=R3", Certificates: 3, Valid: 3},
+		{Issuer: "CN=Other CA", Certificates: 1, Valid: 0},
+	}
+	if !reflect.DeepEqual(s.Issuers, expectedIssuers) {
+		t.Errorf("Expected issuers %v, got %v", expectedIssuers, s.Issuers)
+	}
+	var expiring []int64
+	for _, c := range s.Expiring {
+		expiring = append(expiring, c.ID)
+	}
+	if expected := []int64{4, 2}; !reflect.DeepEqual(expiring, expected) {
+		t.Errorf("Expected expiring certificates %v, got %v", expected, expiring)
+	}
+	expectedSubdomains := []string{"api.example.com", "dev.example.com", "old.example.com", "www.example.com"}
+	if !reflect.DeepEqual(s.Subdomains, expectedSubdomains) {
+		t.Errorf("Expected subdomains %v, got %v", expectedSubdomains, s.Subdomains)
+	}
+
+	if s := Summarize("example.com", certs, now, 7*24*time.Hour); len(s.Expiring) != 1 {
+		t.Errorf("Expected 1 expiring certificate within 7 days, got %v", s.Expiring)
+	}
+}
+
+func TestCrtShCertificates(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if q := r.URL.Query().Get("q"); q != "%.example.com" {
+			t.Errorf("Unexpected query: %q", q)
+		}
+		w.Write([]byte(`[
+			{"id": 2, "issuer_name": "CN=R3", "common_name": "example.com",
+			 "name_value": "example.com\nwww.example.com", "serial_number": "0a",
+			 "not_before": "2020-05-01T00:00:00", "not_after": "2020-07-30T23:59:59"},
+			{"id": 1, "issuer_name": "CN=R3", "common_name": "example.com",
+			 "name_value": "example.com\nwww.example.com", "serial_number": "0a",
+			 "not_before": "2020-05-01T00:00:00", "not_after": "2020-07-30T23:59:59"}
+		]`))
+	}))
+	defer srv.Close()
+
+	c := &CrtSh{BaseURL: srv.URL, HTTPClient: srv.Client()}
+	certs, err := c.Certificates(context.Background(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Certificate{{
+		ID:           2,
+		Issuer:       "CN=R3",
+		SerialNumber: "0a",
+		CommonName:   "example.com",
+		Names:        []string{"example.com", "www.example.com"},
+		NotBefore:    time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2020, 7, 30, 23, 59, 59, 0, time.UTC),
+	}}
+	if !reflect.DeepEqual(certs, expected) {
+		t.Errorf("Expected %v, got %v", expected, certs)
+	}
+}
+
+func TestCrtShInvalidTime(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"id": 1, "not_before": "yesterday", "not_after": "2020-07-30T23:59:59"}]`))
+	}))
+	defer srv.Close()
+
+	c := &CrtSh{BaseURL: srv.URL, HTTPClient: srv.Client()}
+	if _, err := c.Certificates(context.Background(), "example.com"); err == nil {
+		t.Errorf("Expected an error.")
+	}
+}
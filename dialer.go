@@ -0,0 +1,24 @@
+package hstspreload
+
+import (
+	"context"
+	"net"
+)
+
+// A Dialer makes the network connections for a check.
+//
+// *net.Dialer implements Dialer, as do the SOCKS5 dialers from
+// golang.org/x/net/proxy. This allows checks to run from a remote vantage
+// point. Other transports (e.g. SSH jump hosts or a custom probe agent)
+// can be adapted using DialerFunc.
+type Dialer interface {
+	DialContext(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// DialerFunc adapts an ordinary function to a Dialer.
+type DialerFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// DialContext implements Dialer.
+func (f DialerFunc) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	return f(ctx, network, addr)
+}
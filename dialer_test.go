@@ -0,0 +1,34 @@
+package hstspreload
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestCheckerRemoteDialer(t *testing.T) {
+	errDialed := errors.New("dialed")
+	var dialed []string
+	c := &Checker{
+		Dialer: DialerFunc(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			dialed = append(dialed, addr)
+			return nil, errDialed
+		}),
+		DNSCache: NewDNSCache(),
+	}
+
+	if _, err := c.dialContext(context.Background(), "tcp", "www.example.com:443"); err != errDialed {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if _, err := getFirstResponse(context.Background(), "https://example.com", c); err == nil {
+		t.Errorf("Expected an error from the remote dialer.")
+	}
+
+	if len(dialed) != 2 || dialed[0] != "www.example.com:443" || dialed[1] != "example.com:443" {
+		t.Errorf("Remote dialer should receive unresolved addresses, got: %v", dialed)
+	}
+	if len(c.DNSCache.entries) != 0 {
+		t.Errorf("Names should not be resolved locally when using a remote dialer.")
+	}
+}
@@ -0,0 +1,133 @@
+package hstspreload
+
+import (
+	"strconv"
+	"strings"
+)
+
+// A headerDirective is a single directive of an HSTS header, as split by
+// tokenizeDirectives().
+type headerDirective struct {
+	// The directive, without surrounding optional whitespace.
+	text string
+	// The byte offset of `text` in the header.
+	offset int
+	// The directive name, i.e. `text` up to the first "=".
+	name string
+	// The directive value after the first "=" (including any quotes), if
+	// hasValue is true.
+	value    string
+	hasValue bool
+	// Whether the directive is non-empty and its name is not a token, or
+	// its value is neither a token nor a quoted-string.
+	invalid bool
+}
+
+// params returns the Params for an issue about the directive.
+func (d headerDirective) params() map[string]string {
+	return map[string]string{
+		"directive": d.text,
+		"offset":    strconv.Itoa(d.offset),
+	}
+}
+
+// tokenizeDirectives splits an HSTS header into its directives, following
+// the grammar in https://tools.ietf.org/html/rfc6797#section-6.1 and
+// https://tools.ietf.org/html/rfc7230#section-3.2.6: directives are
+// separated by semicolons that are not part of a quoted-string, and
+// surrounded by optional whitespace (spaces and tabs only).
+//
+// There is always at least one directive, even if the header is empty.
+func tokenizeDirectives(header string) []headerDirective {
+	var directives []headerDirective
+	start := 0
+	inQuotes, escaped := false, false
+	for i := 0; i < len(header); i++ {
+		c := header[i]
+		switch {
+		case escaped:
+			escaped = false
+		case inQuotes && c == '\\':
+			escaped = true
+		case c == '"':
+			inQuotes = !inQuotes
+		case c == ';' && !inQuotes:
+			directives = append(directives, newHeaderDirective(header, start, i))
+			start = i + 1
+		}
+	}
+	return append(directives, newHeaderDirective(header, start, len(header)))
+}
+
+// newHeaderDirective returns the directive in header[start:end].
+func newHeaderDirective(header string, start int, end int) headerDirective {
+	for start < end && isOWS(header[start]) {
+		start++
+	}
+	for end > start && isOWS(header[end-1]) {
+		end--
+	}
+
+	d := headerDirective{text: header[start:end], offset: start}
+	d.name = d.text
+	if i := strings.IndexByte(d.text, '='); i >= 0 {
+		d.name, d.value, d.hasValue = d.text[:i], d.text[i+1:], true
+	}
+	d.invalid = d.text != "" &&
+		(!isToken(d.name) || (d.hasValue && !isToken(d.value) && !isQuotedString(d.value)))
+	return d
+}
+
+// isOWS returns whether `c` is optional whitespace (a space or a tab).
+func isOWS(c byte) bool {
+	return c == ' ' || c == '\t'
+}
+
+// isToken returns whether `s` is a non-empty token.
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isTokenChar(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// isTokenChar returns whether `c` is a tchar.
+func isTokenChar(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	default:
+		return strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
+	}
+}
+
+// isQuotedString returns whether `s` is a complete quoted-string.
+func isQuotedString(s string) bool {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return false
+	}
+	for i := 1; i < len(s)-1; i++ {
+		c := s[i]
+		switch {
+		case c == '\\':
+			// A quoted-pair may not escape the closing quote.
+			i++
+			if i >= len(s)-1 || !isQuotedTextChar(s[i]) && s[i] != '"' && s[i] != '\\' {
+				return false
+			}
+		case !isQuotedTextChar(c):
+			return false
+		}
+	}
+	return true
+}
+
+// isQuotedTextChar returns whether `c` is a qdtext character.
+func isQuotedTextChar(c byte) bool {
+	return c == '\t' || c == ' ' || c == 0x21 || (0x23 <= c && c <= 0x5b) || (0x5d <= c && c <= 0x7e) || c >= 0x80
+}
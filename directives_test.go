@@ -0,0 +1,74 @@
+package hstspreload
+
+import (
+	"reflect"
+	"testing"
+)
+
+var tokenizeDirectivesTests = []struct {
+	description string
+	header      string
+	expected    []headerDirective
+}{
+	{
+		"empty",
+		"",
+		[]headerDirective{{}},
+	},
+	{
+		"optional whitespace",
+		" max-age=10 ;\tpreload\t",
+		[]headerDirective{
+			{text: "max-age=10", offset: 1, name: "max-age", value: "10", hasValue: true},
+			{text: "preload", offset: 14, name: "preload"},
+		},
+	},
+	{
+		"only spaces and tabs are optional whitespace",
+		"preload\n",
+		[]headerDirective{{text: "preload\n", name: "preload\n", invalid: true}},
+	},
+	{
+		"quoted string with semicolon",
+		`max-age=10; foo="a;\"b"; preload`,
+		[]headerDirective{
+			{text: "max-age=10", name: "max-age", value: "10", hasValue: true},
+			{text: `foo="a;\"b"`, offset: 12, name: "foo", value: `"a;\"b"`, hasValue: true},
+			{text: "preload", offset: 25, name: "preload"},
+		},
+	},
+	{
+		"unterminated quoted string",
+		`foo="a; preload`,
+		[]headerDirective{{text: `foo="a; preload`, name: "foo", value: `"a; preload`, hasValue: true, invalid: true}},
+	},
+	{
+		"invalid name",
+		"max age=10",
+		[]headerDirective{{text: "max age=10", name: "max age", value: "10", hasValue: true, invalid: true}},
+	},
+	{
+		"empty directives",
+		";",
+		[]headerDirective{{}, {offset: 1}},
+	},
+}
+
+func TestTokenizeDirectives(t *testing.T) {
+	for _, tt := range tokenizeDirectivesTests {
+		if directives := tokenizeDirectives(tt.header); !reflect.DeepEqual(directives, tt.expected) {
+			t.Errorf("[%s] tokenizeDirectives(%q) = %+v, want %+v", tt.description, tt.header, directives, tt.expected)
+		}
+	}
+}
+
+func TestParseHeaderStringOffsets(t *testing.T) {
+	_, issues := ParseHeaderString("max-age=10; \tfoo=\"a;b\"; max-age=abc")
+
+	if len(issues.Warnings) != 1 || issues.Warnings[0].Params["offset"] != "13" {
+		t.Errorf("Unexpected warnings: %#v", issues)
+	}
+	if len(issues.Errors) != 1 || issues.Errors[0].Params["offset"] != "24" {
+		t.Errorf("Unexpected errors: %#v", issues)
+	}
+}
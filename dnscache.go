@@ -0,0 +1,93 @@
+package hstspreload
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// dnsCacheTTL is how long a DNSCache keeps a resolution. This does not
+	// respect the TTL of DNS records, but a check (or a batch of checks)
+	// should not rely on DNS changes while it is running anyway.
+	dnsCacheTTL = 5 * time.Minute
+)
+
+// A DNSCache caches DNS resolutions, so that the different probes of a check
+// (the initial HTTPS request, HTTP and HTTPS redirects, and the www check)
+// don't each resolve the same names again.
+//
+// Failed lookups are not cached, so that a transient resolver failure
+// doesn't affect later probes (e.g. retries), but concurrent lookups of the
+// same name are coalesced, whether they succeed or not. A DNSCache is safe
+// for concurrent use. It may be shared between checks (e.g. across a batch
+// run, see Checker.DNSCache) to reduce the load on resolvers even further.
+type DNSCache struct {
+	mu      sync.Mutex
+	entries map[string]*dnsCacheEntry
+}
+
+type dnsCacheEntry struct {
+	// done is closed when the lookup has finished.
+	done    chan struct{}
+	addrs   []string
+	err     error
+	expires time.Time
+}
+
+// NewDNSCache returns an empty DNSCache.
+func NewDNSCache() *DNSCache {
+	return &DNSCache{
+		entries: make(map[string]*dnsCacheEntry),
+	}
+}
+
+// lookup resolves `host` using `resolver` (which may be nil), unless it is
+// already cached or being resolved. The lookup is not aborted when `ctx` is
+// done, since other checks may be waiting for it, but lookup returns early.
+func (c *DNSCache) lookup(ctx context.Context, resolver *net.Resolver, host string, timeout time.Duration) ([]string, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[host]
+	if ok {
+		select {
+		case <-entry.done:
+			if time.Now().After(entry.expires) {
+				ok = false
+			}
+		default:
+			// A lookup is in progress.
+		}
+	}
+	if !ok {
+		entry = &dnsCacheEntry{done: make(chan struct{})}
+		c.entries[host] = entry
+		c.mu.Unlock()
+
+		if resolver == nil {
+			resolver = net.DefaultResolver
+		}
+		go func() {
+			lookupCtx, cancel := context.WithTimeout(context.Background(), timeout)
+			entry.addrs, entry.err = resolver.LookupHost(lookupCtx, host)
+			cancel()
+			c.mu.Lock()
+			if entry.err == nil {
+				entry.expires = time.Now().Add(dnsCacheTTL)
+			} else if c.entries[host] == entry {
+				delete(c.entries, host)
+			}
+			close(entry.done)
+			c.mu.Unlock()
+		}()
+	} else {
+		c.mu.Unlock()
+	}
+
+	select {
+	case <-entry.done:
+		return entry.addrs, entry.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,68 @@
+package hstspreload
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDNSCache(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	_, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache := NewDNSCache()
+	c := &Checker{DNSCache: cache}
+	for i := 0; i < 2; i++ {
+		conn, err := c.dialContext(context.Background(), "tcp", "localhost:"+port)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+	}
+	if len(cache.entries) != 1 {
+		t.Errorf("Expected exactly one cache entry, got %d.", len(cache.entries))
+	}
+
+	resp, err := getFirstResponse(context.Background(), "http://localhost:"+port+"/", c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Unexpected status code: %d", resp.StatusCode)
+	}
+}
+
+func TestDNSCacheErrors(t *testing.T) {
+	// A resolver that fails to reach its nameserver.
+	var dials int32
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			atomic.AddInt32(&dials, 1)
+			return nil, errors.New("nameserver unreachable")
+		},
+	}
+
+	cache := NewDNSCache()
+	for i := 0; i < 2; i++ {
+		if _, err := cache.lookup(context.Background(), resolver, "example.com", time.Second); err == nil {
+			t.Fatal("Expected the lookup to fail.")
+		}
+		if n := atomic.SwapInt32(&dials, 0); n == 0 {
+			t.Errorf("Lookup %d should not use a cached error.", i+1)
+		}
+	}
+	if len(cache.entries) != 0 {
+		t.Errorf("Expected no cache entries, got %d.", len(cache.entries))
+	}
+}
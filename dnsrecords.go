@@ -0,0 +1,240 @@
+package hstspreload
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"strings"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	// typeCAA is the RR type of CAA records (RFC 8659), which dnsmessage
+	// doesn't define.
+	typeCAA dnsmessage.Type = 257
+
+	// dnsFlagCD is the "checking disabled" bit (RFC 4035) in the second
+	// flags byte of a DNS message, which dnsmessage doesn't expose.
+	dnsFlagCD = 0x10
+
+	// maxDNSUDPSize is the EDNS(0) UDP payload size that we advertise.
+	maxDNSUDPSize = 1232
+)
+
+// resolvConfPath is read to find a resolver if Checker.DNSServer is empty.
+var resolvConfPath = "/etc/resolv.conf"
+
+// dnsServer returns the address of the resolver for the advisory DNS
+// checks: c.DNSServer, or else the first nameserver in /etc/resolv.conf.
+func (c *Checker) dnsServer() (string, error) {
+	if c.DNSServer != "" {
+		return c.DNSServer, nil
+	}
+
+	f, err := os.Open(resolvConfPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			return net.JoinHostPort(fields[1], "53"), nil
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return "", err
+	}
+	return "", errors.New("no nameserver in " + resolvConfPath)
+}
+
+// queryDNS sends a recursive query for `name` and `qtype` to `server`
+// (with the DNSSEC OK bit set, and with DNSSEC validation disabled iff
+// `checkingDisabled`), and returns the response. Truncated responses are
+// retried over TCP.
+func (c *Checker) queryDNS(ctx context.Context, server string, name string, qtype dnsmessage.Type, checkingDisabled bool) (*dnsmessage.Message, error) {
+	qname, err := dnsmessage.NewName(strings.TrimSuffix(name, ".") + ".")
+	if err != nil {
+		return nil, err
+	}
+	var opt dnsmessage.ResourceHeader
+	if err := opt.SetEDNS0(maxDNSUDPSize, dnsmessage.RCodeSuccess, true); err != nil {
+		return nil, err
+	}
+	query := dnsmessage.Message{
+		Header: dnsmessage.Header{ID: uint16(rand.Uint32()), RecursionDesired: true},
+		Questions: []dnsmessage.Question{{
+			Name:  qname,
+			Type:  qtype,
+			Class: dnsmessage.ClassINET,
+		}},
+		Additionals: []dnsmessage.Resource{{Header: opt, Body: &dnsmessage.OPTResource{}}},
+	}
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, err
+	}
+	if checkingDisabled {
+		packed[3] |= dnsFlagCD
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout())
+	defer cancel()
+
+	resp, err := c.exchangeDNS(ctx, "udp", server, packed)
+	if err == nil && resp.Truncated {
+		resp, err = c.exchangeDNS(ctx, "tcp", server, packed)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if resp.ID != query.ID || !resp.Response {
+		return nil, fmt.Errorf("unexpected DNS response from %s", server)
+	}
+	return resp, nil
+}
+
+// exchangeDNS sends the packed DNS message `query` to `server` over
+// `network` ("udp" or "tcp"), and returns the response.
+func (c *Checker) exchangeDNS(ctx context.Context, network string, server string, query []byte) (*dnsmessage.Message, error) {
+	conn, err := c.netDialer().DialContext(ctx, network, server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	var buf []byte
+	if network == "tcp" {
+		// Messages over TCP are prefixed with their length (RFC 1035).
+		query = append(binary.BigEndian.AppendUint16(nil, uint16(len(query))), query...)
+		if _, err := conn.Write(query); err != nil {
+			return nil, err
+		}
+		var length [2]byte
+		if _, err := io.ReadFull(conn, length[:]); err != nil {
+			return nil, err
+		}
+		buf = make([]byte, binary.BigEndian.Uint16(length[:]))
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return nil, err
+		}
+	} else {
+		if _, err := conn.Write(query); err != nil {
+			return nil, err
+		}
+		buf = make([]byte, maxDNSUDPSize)
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		buf = buf[:n]
+	}
+
+	var resp dnsmessage.Message
+	if err := resp.Unpack(buf); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// hasAnswer returns whether `resp` contains an answer of type `t`.
+func hasAnswer(resp *dnsmessage.Message, t dnsmessage.Type) bool {
+	for _, a := range resp.Answers {
+		if a.Header.Type == t {
+			return true
+		}
+	}
+	return false
+}
+
+// checkDNSRecords performs the advisory DNS checks that are enabled by
+// c.CheckDNSRecords: whether `domain` has CAA records, and whether its
+// DNSSEC records validate. They only report warnings, and are skipped if
+// the resolver cannot be queried, or if `c` uses a Dialer or a proxy (since
+// names are then resolved remotely).
+//
+// `c` may be nil.
+func (c *Checker) checkDNSRecords(ctx context.Context, domain string) Issues {
+	issues := Issues{}
+	if c == nil || !c.CheckDNSRecords || c.resolvesRemotely(domain) {
+		return issues
+	}
+	server, err := c.dnsServer()
+	if err != nil {
+		return issues
+	}
+
+	issues = combineIssues(issues, c.checkDNSSEC(ctx, server, domain))
+	return combineIssues(issues, c.checkCAA(ctx, server, domain))
+}
+
+// checkDNSSEC reports whether DNSSEC validation fails for `domain`: a
+// validating resolver answers SERVFAIL, but succeeds with checking
+// disabled.
+func (c *Checker) checkDNSSEC(ctx context.Context, server string, domain string) Issues {
+	issues := Issues{}
+	resp, err := c.queryDNS(ctx, server, domain, dnsmessage.TypeA, false)
+	if err != nil || resp.RCode != dnsmessage.RCodeServerFailure {
+		return issues
+	}
+	resp, err = c.queryDNS(ctx, server, domain, dnsmessage.TypeA, true)
+	if err != nil || resp.RCode == dnsmessage.RCodeServerFailure {
+		return issues
+	}
+
+	return issues.addWarningWithParamsf(
+		IssueCode("domain.dns.dnssec.bogus"),
+		"DNSSEC validation fails",
+		map[string]string{"domain": domain, "resolver": server},
+		"The DNSSEC records of `%s` do not validate: a validating resolver (%s) "+
+			"only resolves the domain with validation disabled. "+
+			"Users behind validating resolvers cannot reach the domain, "+
+			"and preloaded domains don't have an insecure fallback. "+
+			"Please fix the DNSSEC configuration (e.g. stale DS records) before preloading.",
+		domain,
+		server,
+	)
+}
+
+// checkCAA reports whether neither `domain` nor any of its parent domains
+// has CAA records (RFC 8659), so that any certificate authority may issue
+// certificates for it.
+func (c *Checker) checkCAA(ctx context.Context, server string, domain string) Issues {
+	issues := Issues{}
+	for name := domain; name != ""; {
+		resp, err := c.queryDNS(ctx, server, name, typeCAA, false)
+		if err != nil || (resp.RCode != dnsmessage.RCodeSuccess && resp.RCode != dnsmessage.RCodeNameError) {
+			return issues
+		}
+		if hasAnswer(resp, typeCAA) {
+			return issues
+		}
+
+		i := strings.Index(name, ".")
+		if i == -1 {
+			break
+		}
+		name = name[i+1:]
+	}
+
+	return issues.addWarningWithParamsf(
+		IssueCode("domain.dns.caa.missing"),
+		"No CAA records",
+		map[string]string{"domain": domain},
+		"Neither `%s` nor its parent domains have CAA records, so any certificate authority "+
+			"may issue certificates for it. This is not required for preloading, but "+
+			"consider restricting issuance to the CAs that you use.",
+		domain,
+	)
+}
@@ -0,0 +1,205 @@
+package hstspreload
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// fakeResolver answers DNS queries over UDP and TCP on the same port using
+// `handle`, which receives the query name (without trailing dot), type,
+// and whether checking is disabled. If `truncate` is set, UDP responses are
+// truncated and empty.
+type fakeResolver struct {
+	addr     string
+	truncate bool
+	handle   func(name string, qtype dnsmessage.Type, cd bool) (dnsmessage.RCode, []dnsmessage.Resource)
+}
+
+func newFakeResolver(t *testing.T, truncate bool, handle func(name string, qtype dnsmessage.Type, cd bool) (dnsmessage.RCode, []dnsmessage.Resource)) *fakeResolver {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", pc.LocalAddr().String())
+	if err != nil {
+		pc.Close()
+		t.Skipf("Cannot listen on TCP and UDP on the same port: %s", err)
+	}
+	t.Cleanup(func() {
+		pc.Close()
+		l.Close()
+	})
+
+	r := &fakeResolver{addr: pc.LocalAddr().String(), truncate: truncate, handle: handle}
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if resp := r.respond(buf[:n], r.truncate); resp != nil {
+				pc.WriteTo(resp, addr)
+			}
+		}
+	}()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			var length [2]byte
+			if _, err := io.ReadFull(conn, length[:]); err == nil {
+				query := make([]byte, binary.BigEndian.Uint16(length[:]))
+				if _, err := io.ReadFull(conn, query); err == nil {
+					resp := r.respond(query, false)
+					conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(resp))), resp...))
+				}
+			}
+			conn.Close()
+		}
+	}()
+	return r
+}
+
+func (r *fakeResolver) respond(query []byte, truncate bool) []byte {
+	var q dnsmessage.Message
+	if err := q.Unpack(query); err != nil || len(q.Questions) != 1 {
+		return nil
+	}
+	question := q.Questions[0]
+	resp := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: q.ID, Response: true, RecursionAvailable: true},
+		Questions: q.Questions,
+	}
+	if truncate {
+		resp.Truncated = true
+	} else {
+		name := strings.TrimSuffix(question.Name.String(), ".")
+		resp.RCode, resp.Answers = r.handle(name, question.Type, query[3]&dnsFlagCD != 0)
+		for i := range resp.Answers {
+			resp.Answers[i].Header.Name = question.Name
+			resp.Answers[i].Header.Class = dnsmessage.ClassINET
+		}
+	}
+	packed, err := resp.Pack()
+	if err != nil {
+		return nil
+	}
+	return packed
+}
+
+var (
+	testCAARecord = dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{Type: typeCAA},
+		Body:   &dnsmessage.UnknownResource{Type: typeCAA, Data: []byte("\x00\x05issueletsencrypt.org")},
+	}
+	testARecord = dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{Type: dnsmessage.TypeA},
+		Body:   &dnsmessage.AResource{A: [4]byte{192, 0, 2, 1}},
+	}
+)
+
+// signedZone resolves the names in `caa` with CAA records, and all names
+// with an A record.
+func signedZone(caa ...string) func(name string, qtype dnsmessage.Type, cd bool) (dnsmessage.RCode, []dnsmessage.Resource) {
+	return func(name string, qtype dnsmessage.Type, cd bool) (dnsmessage.RCode, []dnsmessage.Resource) {
+		switch qtype {
+		case dnsmessage.TypeA:
+			return dnsmessage.RCodeSuccess, []dnsmessage.Resource{testARecord}
+		case typeCAA:
+			for _, n := range caa {
+				if n == name {
+					return dnsmessage.RCodeSuccess, []dnsmessage.Resource{testCAARecord}
+				}
+			}
+		}
+		return dnsmessage.RCodeSuccess, nil
+	}
+}
+
+func TestCheckDNSRecords(t *testing.T) {
+	bogus := func(name string, qtype dnsmessage.Type, cd bool) (dnsmessage.RCode, []dnsmessage.Resource) {
+		if !cd {
+			return dnsmessage.RCodeServerFailure, nil
+		}
+		return signedZone("example.com")(name, qtype, cd)
+	}
+	lame := func(name string, qtype dnsmessage.Type, cd bool) (dnsmessage.RCode, []dnsmessage.Resource) {
+		return dnsmessage.RCodeServerFailure, nil
+	}
+
+	var tests = []struct {
+		description    string
+		handle         func(name string, qtype dnsmessage.Type, cd bool) (dnsmessage.RCode, []dnsmessage.Resource)
+		expectedIssues Issues
+	}{
+		{
+			"CAA on the domain",
+			signedZone("www.example.com"),
+			Issues{},
+		},
+		{
+			"CAA on a parent domain",
+			signedZone("example.com"),
+			Issues{},
+		},
+		{
+			"no CAA",
+			signedZone(),
+			Issues{Warnings: []Issue{{Code: "domain.dns.caa.missing"}}},
+		},
+		{
+			"bogus DNSSEC",
+			bogus,
+			Issues{Warnings: []Issue{{Code: "domain.dns.dnssec.bogus"}}},
+		},
+		{
+			"resolver failure",
+			lame,
+			Issues{},
+		},
+	}
+
+	for _, tt := range tests {
+		r := newFakeResolver(t, false, tt.handle)
+		c := &Checker{CheckDNSRecords: true, DNSServer: r.addr}
+		issues := c.checkDNSRecords(context.Background(), "www.example.com")
+		if !issues.Match(tt.expectedIssues) {
+			t.Errorf("[%s] "+issuesShouldMatch, tt.description, issues, tt.expectedIssues)
+		}
+	}
+}
+
+func TestCheckDNSRecordsTCPFallback(t *testing.T) {
+	r := newFakeResolver(t, true, signedZone("example.com"))
+
+	c := &Checker{CheckDNSRecords: true, DNSServer: r.addr}
+	if issues := c.checkDNSRecords(context.Background(), "example.com"); !issues.Match(Issues{}) {
+		t.Errorf(issuesShouldBeEmpty, issues)
+	}
+}
+
+func TestCheckDNSRecordsDisabled(t *testing.T) {
+	queried := false
+	r := newFakeResolver(t, false, func(name string, qtype dnsmessage.Type, cd bool) (dnsmessage.RCode, []dnsmessage.Resource) {
+		queried = true
+		return dnsmessage.RCodeSuccess, nil
+	})
+
+	for _, c := range []*Checker{nil, {DNSServer: r.addr}} {
+		if issues := c.checkDNSRecords(context.Background(), "example.com"); !issues.Match(Issues{}) {
+			t.Errorf(issuesShouldBeEmpty, issues)
+		}
+	}
+	if queried {
+		t.Errorf("The advisory DNS checks should be disabled by default.")
+	}
+}
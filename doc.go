@@ -1,8 +1,24 @@
-// Package hstspreload has 3 parts:
+// Package hstspreload has 11 parts:
 //
 // - The `hstspreload` package with functions to check HSTS preload requirements.
 //
 // - The `chromium/preloadlist` package, to query Chromium preload list state.
 //
+// - The `chromium/preloadapi` package, a client for the hstspreload.org API.
+//
+// - The `batch` package, to check many domains (or HSTS headers) concurrently.
+//
+// - The `scanner` package, to scan the domains pending on hstspreload.org or already preloaded.
+//
+// - The `ct` package, to summarize the certificates of a domain in Certificate Transparency logs.
+//
+// - The `subdomains` package, to find subdomains that would break with includeSubDomains.
+//
+// - The `report` package, to combine everything known about a domain into one document.
+//
+// - The `history` package, to record check results over time and detect regressions.
+//
+// - The `notify` package, to send notifications (webhook, Slack, email) when a domain's state changes.
+//
 // - The `hstspreload` command line tool.
 package hstspreload
@@ -1,31 +1,20 @@
 package hstspreload
 
 import (
+	"context"
 	"crypto/tls"
+	"errors"
+	"fmt"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/chromium/hstspreload/chromium/preloadlist"
 	"golang.org/x/net/publicsuffix"
 )
 
-const (
-	// dialTimeout specifies the amount of time that TCP or TLS connections
-	// can take to complete.
-	dialTimeout = 10 * time.Second
-)
-
-// dialer is a global net.Dialer that's used whenever making TLS connections in
-// order to enforce dialTimeout.
-var dialer = net.Dialer{
-	Timeout: dialTimeout,
-}
-
-var clientWithTimeout = http.Client{
-	Timeout: dialTimeout,
-}
-
 // List of eTLDs for which:
 // - `www` subdomains are commonly available over HTTP, but
 // - site owners have no way to serve valid HTTPS on the `www` subdomain.
@@ -35,6 +24,31 @@ var allowedWWWeTLDs = map[string]bool{
 	"appspot.com": true,
 }
 
+// Special-use TLDs that can never be preloaded, with an explanation of why.
+// These are checked before the public suffix check, since names under them
+// would otherwise be reported as public suffixes or fail later with
+// confusing TLS errors.
+var specialUseTLDs = map[string]struct {
+	code   IssueCode
+	reason string
+}{
+	"onion": {
+		"domain.format.onion",
+		"Onion services are only reachable over Tor, which already provides " +
+			"transport security, and they are not part of the public DNS.",
+	},
+	"local": {
+		"domain.format.local",
+		"`.local` names are resolved using multicast DNS on the local network " +
+			"and can refer to different hosts on different networks.",
+	},
+	"internal": {
+		"domain.format.internal",
+		"`.internal` is reserved for private use and will never be delegated in " +
+			"the public DNS, so it is not possible to preload these names for all users.",
+	},
+}
+
 // PreloadableDomain checks whether the domain passes HSTS preload
 // requirements for Chromium. This includes:
 //
@@ -50,55 +64,200 @@ var allowedWWWeTLDs = map[string]bool{
 // To interpret `issues`, see the list of conventions in the
 // documentation for Issues.
 func PreloadableDomain(domain string) (header *string, issues Issues) {
-	header, issues, _ = PreloadableDomainResponse(domain)
-	return header, issues
+	return defaultChecker.PreloadableDomain(domain)
+}
+
+// PreloadableDomain is like the package-level PreloadableDomain, but uses
+// the configuration of `c`.
+func (c *Checker) PreloadableDomain(domain string) (header *string, issues Issues) {
+	return c.PreloadableDomainContext(context.Background(), domain)
+}
+
+// PreloadableDomainWithPort is like PreloadableDomain, but connects to
+// `httpsPort` for HTTPS and `httpPort` for plain HTTP (see
+// Checker.HTTPSPort). A port of 0 means the default port.
+func PreloadableDomainWithPort(domain string, httpsPort, httpPort int) (header *string, issues Issues) {
+	c := &Checker{HTTPSPort: httpsPort, HTTPPort: httpPort}
+	return c.PreloadableDomain(domain)
+}
+
+// PreloadableDomainContext is like PreloadableDomain, but the check is
+// aborted when `ctx` is done (in addition to the timeout of each
+// connection). Connections that are cut short are reported as issues.
+func PreloadableDomainContext(ctx context.Context, domain string) (header *string, issues Issues) {
+	return defaultChecker.PreloadableDomainContext(ctx, domain)
+}
+
+// PreloadableDomainContext is like the package-level
+// PreloadableDomainContext, but uses the configuration of `c`.
+func (c *Checker) PreloadableDomainContext(ctx context.Context, domain string) (header *string, issues Issues) {
+	r := c.preloadableDomainResult(ctx, domain)
+	return r.Header, r.Issues
+}
+
+// A DomainResult holds the outcome of checking a domain for preload
+// requirements.
+type DomainResult struct {
+	// Iff a single HSTS header was received, Header contains its value and
+	// ParsedHeader contains its parsed form.
+	Header       *string
+	ParsedHeader *HSTSHeader
+	// To interpret Issues, see the list of conventions in the
+	// documentation for Issues.
+	Issues Issues
+	// Issues where HTTP redirects to a page without a preloadable HSTS
+	// header (e.g. "redirects.http.first_redirect.no_hsts"). These are
+	// also included in Issues, unless they are masked by errors in the
+	// HSTS header of the initial response.
+	FirstRedirectHSTS Issues
+	// The initial response over HTTPS, or nil if we could not connect.
+	Response *http.Response
+	// A summary of Response, or nil if we could not connect.
+	// ResponseTime includes any retries needed to connect.
+	ResponseMetadata *ResponseMetadata
+	// Whether we retried without verifying certificates after failing to
+	// connect normally (see Checker.DisableInsecureFallback).
+	InsecureFallbackUsed bool
+	// The redirects followed from http://domain and https://domain, even if
+	// they are fine. These are nil if there were no redirects or we could
+	// not connect.
+	HTTPRedirects  []RedirectHop
+	HTTPSRedirects []RedirectHop
 }
 
 // PreloadableDomainResponse is like PreloadableDomain, but also returns
 // the initial response over HTTPS.
 func PreloadableDomainResponse(domain string) (header *string, issues Issues, resp *http.Response) {
+	return defaultChecker.PreloadableDomainResponse(domain)
+}
+
+// PreloadableDomainResponse is like the package-level
+// PreloadableDomainResponse, but uses the configuration of `c`.
+func (c *Checker) PreloadableDomainResponse(domain string) (header *string, issues Issues, resp *http.Response) {
+	r := c.PreloadableDomainResult(domain)
+	return r.Header, r.Issues, r.Response
+}
+
+// PreloadableDomainResult is like PreloadableDomain, but returns all
+// details of the check, including the parsed header.
+func PreloadableDomainResult(domain string) DomainResult {
+	return defaultChecker.PreloadableDomainResult(domain)
+}
+
+// PreloadableDomainFrom is like PreloadableDomainResult, but makes all
+// connections using `d`. Names are resolved by `d` rather than locally, so
+// that a remote Dialer (e.g. a SOCKS5 proxy) checks the domain as seen from
+// its own vantage point.
+func PreloadableDomainFrom(domain string, d Dialer) DomainResult {
+	return (&Checker{Dialer: d}).PreloadableDomainResult(domain)
+}
+
+// PreloadableDomainWithOptions is like PreloadableDomainResult, but skips
+// the checks selected by `opts`.
+func PreloadableDomainWithOptions(domain string, opts DomainCheckOptions) DomainResult {
+	return (&Checker{DomainChecks: opts}).PreloadableDomainResult(domain)
+}
+
+// PreloadableSubdomain is like PreloadableDomainResult, but checks
+// subdomains like registrable domains (see
+// DomainCheckOptions.AllowSubdomain), e.g. to validate a subdomain before
+// preloading its parent with includeSubDomains.
+func PreloadableSubdomain(domain string) DomainResult {
+	return PreloadableDomainWithOptions(domain, DomainCheckOptions{AllowSubdomain: true})
+}
+
+// PreloadableDomainResult is like the package-level PreloadableDomainResult,
+// but uses the configuration of `c`.
+func (c *Checker) PreloadableDomainResult(domain string) DomainResult {
+	return c.preloadableDomainResult(context.Background(), domain)
+}
+
+// PreloadableDomainResultContext is like PreloadableDomainResult, but the
+// check is aborted when `ctx` is done.
+func PreloadableDomainResultContext(ctx context.Context, domain string) DomainResult {
+	return defaultChecker.PreloadableDomainResultContext(ctx, domain)
+}
+
+// PreloadableDomainResultContext is like the package-level
+// PreloadableDomainResultContext, but uses the configuration of `c`.
+func (c *Checker) PreloadableDomainResultContext(ctx context.Context, domain string) DomainResult {
+	return c.preloadableDomainResult(ctx, domain)
+}
+
+// `ctx` applies to all connections, and is passed to registered checks.
+// `c` may be nil.
+func (c *Checker) preloadableDomainResult(ctx context.Context, domain string) (result DomainResult) {
+	c = c.withDNSCache()
+	issues := Issues{}
 	// Check domain format issues first, since we can report something
 	// useful even if the other checks fail.
 	issues = combineIssues(issues, checkDomainFormat(domain))
 	if len(issues.Errors) > 0 {
-		return header, issues, nil
+		result.Issues = c.applyDomainPolicy(domain, issues)
+		return result
 	}
+	domain, _ = toASCIIDomain(domain)
 
 	// We don't currently allow automatic submissions of subdomains.
 	levelIssues := preloadableDomainLevel(domain)
-	issues = combineIssues(issues, levelIssues)
+	if !c.domainChecks().AllowSubdomain || !levelIssues.HasCode("domain.is_subdomain") {
+		issues = combineIssues(issues, levelIssues)
+	}
 
 	// Start with an initial probe, and don't do the follow-up checks if
 	// we can't connect.
-	resp, respIssues := getResponse(domain)
+	start := time.Now()
+	resp, insecureFallback, respIssues := getResponse(ctx, domain, c)
+	result.InsecureFallbackUsed = insecureFallback
+	responseTime := time.Since(start)
 	issues = combineIssues(issues, respIssues)
 	if len(respIssues.Errors) == 0 {
-		issues = combineIssues(issues, checkChain(*resp.TLS))
-		issues = combineIssues(issues, checkCipherSuite(*resp.TLS))
+		result.ResponseMetadata = newResponseMetadata(resp, responseTime)
+		skip := c.domainChecks()
+		if !skip.SkipTLSChecks {
+			issues = combineIssues(issues, checkChain(*resp.TLS))
+			issues = combineIssues(issues, c.checkCertExpiry(*resp.TLS, time.Now()))
+			issues = combineIssues(issues, checkTLSConfig(*resp.TLS))
+		}
 
 		preloadableResponse := make(chan Issues)
 		httpRedirectsGeneral := make(chan Issues)
 		httpFirstRedirectHSTS := make(chan Issues)
 		httpsRedirects := make(chan Issues)
 		www := make(chan Issues)
+		altSvc := make(chan Issues)
+		protocols := make(chan Issues)
+		ipv6 := make(chan Issues)
 
 		// PreloadableResponse
 		go func() {
 			var preloadableIssues Issues
-			header, preloadableIssues = PreloadableResponse(resp)
+			result.Header, result.ParsedHeader, preloadableIssues = c.preloadableResponseParsed(resp)
 			preloadableResponse <- preloadableIssues
 		}()
 
 		// checkHTTPRedirects
 		go func() {
-			general, firstRedirectHSTS := preloadableHTTPRedirects(domain)
+			if skip.SkipHTTPRedirects {
+				httpRedirectsGeneral <- Issues{}
+				httpFirstRedirectHSTS <- Issues{}
+				return
+			}
+			var general, firstRedirectHSTS Issues
+			result.HTTPRedirects, general, firstRedirectHSTS = preloadableHTTPRedirects(ctx, domain, c)
 			httpRedirectsGeneral <- general
 			httpFirstRedirectHSTS <- firstRedirectHSTS
 		}()
 
 		// checkHTTPSRedirects
 		go func() {
-			httpsRedirects <- preloadableHTTPSRedirects(domain)
+			if skip.SkipHTTPSRedirects {
+				httpsRedirects <- Issues{}
+				return
+			}
+			var httpsRedirectsIssues Issues
+			result.HTTPSRedirects, httpsRedirectsIssues = preloadableHTTPSRedirects(ctx, domain, c)
+			httpsRedirects <- httpsRedirectsIssues
 		}()
 
 		// checkWWW
@@ -107,16 +266,45 @@ func PreloadableDomainResponse(domain string) (header *string, issues Issues, re
 
 			// Skip the WWW check if the domain is not eTLD+1, or if the
 			// eTLD is allowed.
-			if len(levelIssues.Errors) != 0 || allowedWWWeTLDs[eTLD] {
+			if skip.SkipWWW || len(levelIssues.Errors) != 0 || allowedWWWeTLDs[eTLD] {
 				www <- Issues{}
 			} else {
-				www <- checkWWW(domain)
+				www <- checkWWW(ctx, domain, resp, c)
+			}
+		}()
+
+		// checkAltSvc
+		go func() {
+			if skip.HeaderOnly {
+				altSvc <- Issues{}
+				return
+			}
+			altSvc <- c.checkAltSvc(ctx, domain, resp)
+		}()
+
+		// checkProtocols
+		go func() {
+			if skip.HeaderOnly {
+				protocols <- Issues{}
+				return
+			}
+			protocols <- c.checkProtocols(ctx, domain, resp)
+		}()
+
+		// checkIPv6
+		go func() {
+			if skip.HeaderOnly {
+				ipv6 <- Issues{}
+				return
 			}
+			ipv6 <- c.checkIPv6(ctx, domain, resp)
 		}()
 
 		// Combine the issues in deterministic order.
 		preloadableResponseIssues := <-preloadableResponse
 		issues = combineIssues(issues, preloadableResponseIssues)
+		issues = combineIssues(issues, checkCaching(resp))
+		issues = combineIssues(issues, c.checkSecurityHeaders(resp))
 		issues = combineIssues(issues, <-httpRedirectsGeneral)
 		// If there are issues with the HSTS header in the main
 		// PreloadableResponse() check, it is redundant to report
@@ -125,11 +313,23 @@ func PreloadableDomainResponse(domain string) (header *string, issues Issues, re
 		if len(preloadableResponseIssues.Errors) == 0 {
 			issues = combineIssues(issues, firstRedirectHSTS)
 		}
+		result.FirstRedirectHSTS = c.applyPartialPolicy(domain, firstRedirectHSTS)
 		issues = combineIssues(issues, <-httpsRedirects)
 		issues = combineIssues(issues, <-www)
+		issues = combineIssues(issues, <-altSvc)
+		issues = combineIssues(issues, <-protocols)
+		issues = combineIssues(issues, <-ipv6)
+
+		// Checks registered by other packages run last, so that their
+		// issues never mask the built-in ones.
+		if !skip.HeaderOnly {
+			issues = combineIssues(issues, runRegisteredChecks(ctx, domain, resp))
+		}
 	}
 
-	return header, issues, resp
+	result.Issues = c.applyDomainPolicy(domain, issues)
+	result.Response = resp
+	return result
 }
 
 // RemovableDomain checks whether the domain satisfies the requirements
@@ -139,58 +339,193 @@ func PreloadableDomainResponse(domain string) (header *string, issues Issues, re
 //
 // - The header must not contain the `preload` directive..
 //
+// - If Checker.PreloadList is set (see the method), having its own entry
+// on the list, rather than being covered by an entry for a parent domain.
+//
 // Iff a single HSTS header was received, `header` contains its value, else
 // `header` is `nil`.
 // To interpret `issues`, see the list of conventions in the
 // documentation for Issues.
 func RemovableDomain(domain string) (header *string, issues Issues) {
-	resp, respIssues := getResponse(domain)
+	return defaultChecker.RemovableDomain(domain)
+}
+
+// RemovableDomain is like the package-level RemovableDomain, but uses the
+// configuration of `c`.
+func (c *Checker) RemovableDomain(domain string) (header *string, issues Issues) {
+	return c.RemovableDomainContext(context.Background(), domain)
+}
+
+// RemovableDomainContext is like RemovableDomain, but the check is aborted
+// when `ctx` is done.
+func RemovableDomainContext(ctx context.Context, domain string) (header *string, issues Issues) {
+	return defaultChecker.RemovableDomainContext(ctx, domain)
+}
+
+// RemovableDomainContext is like the package-level RemovableDomainContext,
+// but uses the configuration of `c`.
+func (c *Checker) RemovableDomainContext(ctx context.Context, domain string) (header *string, issues Issues) {
+	if ascii, idnIssues := toASCIIDomain(domain); len(idnIssues.Errors) == 0 {
+		domain = ascii
+	}
+	resp, _, respIssues := getResponse(ctx, domain, c.withDNSCache())
 	issues = combineIssues(issues, respIssues)
 	if len(respIssues.Errors) == 0 {
 		var removableIssues Issues
-		header, removableIssues = RemovableResponse(resp)
+		header, removableIssues = c.RemovableResponse(resp)
 		issues = combineIssues(issues, removableIssues)
 	}
+	if c != nil && c.PreloadList != nil {
+		issues = combineIssues(issues, checkRemovalStatus(domain, *c.PreloadList))
+	}
 
-	return header, issues
+	return header, c.applyDomainPolicy(domain, issues)
 }
 
-func getResponse(domain string) (*http.Response, Issues) {
+// checkRemovalStatus checks whether `domain` can be removed from the
+// preload list `idx`: only domains with their own entry can be removed,
+// and only entries with a bulk policy can be removed through
+// hstspreload.org.
+func checkRemovalStatus(domain string, idx preloadlist.IndexedEntries) Issues {
 	issues := Issues{}
+	entry, found := idx.Get(domain)
+	switch found {
+	case preloadlist.EntryNotFound:
+		return issues.addWarningWithParamsf(
+			IssueCode("removal.not_preloaded"),
+			"Not preloaded",
+			map[string]string{"domain": domain},
+			"`%s` is not on the preload list, so there is nothing to remove.",
+			DisplayDomain(domain),
+		)
+	case preloadlist.AncestorEntryFound:
+		return issues.addErrorWithParamsf(
+			IssueCode("removal.covered_by_parent"),
+			"Preloaded by a parent domain",
+			map[string]string{"domain": domain, "preloaded_name": entry.Name},
+			"`%s` does not have its own preload list entry, but is preloaded because its parent domain `%s` "+
+				"is on the list with include_subdomains. It cannot be removed on its own: `%s` would have to be removed instead.",
+			DisplayDomain(domain),
+			DisplayDomain(entry.Name),
+			DisplayDomain(entry.Name),
+		)
+	}
 
-	// Try #1
-	resp, err := getFirstResponse("https://" + domain)
-	if err == nil {
-		return resp, issues
+	if entry.IsBulk() {
+		return issues.addWarningWithParamsf(
+			IssueCode("removal.policy.bulk"),
+			"Bulk entry",
+			map[string]string{"domain": domain, "policy": entry.Policy},
+			"`%s` was preloaded through hstspreload.org (policy `%s`), so its removal can be requested at hstspreload.org/removal/.",
+			DisplayDomain(domain),
+			entry.Policy,
+		)
+	}
+	policy := entry.Policy
+	if policy == "" {
+		policy = "none"
 	}
+	return issues.addWarningWithParamsf(
+		IssueCode("removal.policy.not_bulk"),
+		"Custom entry",
+		map[string]string{"domain": domain, "policy": policy},
+		"`%s` was not preloaded through hstspreload.org (policy `%s`), so it cannot be removed there. "+
+			"Its removal has to be requested from the Chromium HSTS preload list maintainers.",
+		DisplayDomain(domain),
+		policy,
+	)
+}
+
+// checkNotHTTP returns an error if `err` (from a request to `domain` over
+// HTTPS on `port`) shows that the port is used by a service that doesn't
+// speak HTTPS, e.g. a mail server or plain HTTP.
+func checkNotHTTP(domain string, port string, err error) Issues {
+	issues := Issues{}
+
+	var description string
+	var recordHeaderErr tls.RecordHeaderError
+	switch {
+	case errors.As(err, &recordHeaderErr):
+		description = fmt.Sprintf("it sent %q instead of a TLS handshake", recordHeaderErr.RecordHeader[:])
+	case strings.Contains(err.Error(), "malformed HTTP"):
+		description = fmt.Sprintf("it sent a malformed HTTP response: %s", err)
+	default:
+		return issues
+	}
+
+	return issues.addErrorWithParamsf(
+		IssueCode("domain.https.not_http"),
+		"Not an HTTPS server",
+		map[string]string{"domain": domain, "port": port},
+		"The service on port %s of `%s` does not speak HTTPS (%s). "+
+			"Port %s must serve the site over HTTPS.",
+		port,
+		DisplayDomain(domain),
+		description,
+		port,
+	)
+}
 
-	// Try #2
-	resp, err = getFirstResponse("https://" + domain)
+// getResponse makes the initial request over HTTPS, retrying transient
+// errors according to c.Retry. If that fails, it retries without verifying
+// certificates (unless c.DisableInsecureFallback is set), so that it can
+// report an invalid certificate chain. Iff it used this fallback,
+// `insecureFallback` is true.
+//
+// `c` may be nil.
+func getResponse(ctx context.Context, domain string, c *Checker) (resp *http.Response, insecureFallback bool, issues Issues) {
+	issues = Issues{}
+
+	var attempt *connectAttempt
+	attempts, err := c.withRetries(ctx, func() (err error) {
+		attempt = newConnectAttempt()
+		resp, err = getFirstResponseAttempt(ctx, c.httpsURL(domain), c.transport(false), c, attempt)
+		if err != nil {
+			return err
+		}
+		if err = rateLimitError(c.httpsURL(domain), resp, time.Now()); err != nil {
+			resp.Body.Close()
+		}
+		return err
+	})
 	if err == nil {
-		return resp, issues
+		return resp, false, retriedIssues(IssueCode("domain.https.retried"), c.httpsURL(domain), attempts)
+	}
+
+	var rl *RateLimitError
+	if errors.As(err, &rl) {
+		return nil, false, rateLimitedIssues(c.httpsURL(domain), rl)
+	}
+
+	if notHTTPIssues := checkNotHTTP(domain, c.httpsPort(), err); len(notHTTPIssues.Errors) > 0 {
+		return nil, false, notHTTPIssues
+	}
+
+	if !c.insecureFallback() {
+		return nil, false, cannotConnectIssues(c.httpsURL(domain), attempt, err)
 	}
 
 	// Check if ignoring cert issues works.
-	transport := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
-	resp, err = getFirstResponseWithTransport("https://"+domain, transport)
-	if err == nil {
-		return resp, issues.addErrorf(
+	insecureAttempt := newConnectAttempt()
+	insecureResp, insecureErr := getFirstResponseAttempt(ctx, c.httpsURL(domain), c.transport(true), c, insecureAttempt)
+	if insecureErr == nil {
+		issues = issues.addErrorWithParamsf(
 			IssueCode("domain.tls.invalid_cert_chain"),
 			"Invalid Certificate Chain",
-			"https://%s uses an incomplete or "+
+			map[string]string{"url": c.httpsURL(domain)},
+			"%s uses an incomplete or "+
 				"invalid certificate chain. Check out your site at "+
 				"https://www.ssllabs.com/ssltest/",
-			domain,
+			c.httpsURL(domain),
 		)
+		// An expired certificate is a common reason for the chain to be
+		// invalid. Warnings are reported by the follow-up checks if the
+		// chain is valid.
+		expiryIssues := c.checkCertExpiry(*insecureResp.TLS, time.Now())
+		return insecureResp, true, combineIssues(issues, Issues{Errors: expiryIssues.Errors})
 	}
 
-	return resp, issues.addErrorf(
-		IssueCode("domain.tls.cannot_connect"),
-		"Cannot connect using TLS",
-		"We cannot connect to https://%s using TLS (%q).",
-		domain,
-		err,
-	)
+	return nil, true, cannotConnectIssues(c.httpsURL(domain), insecureAttempt, insecureErr)
 }
 
 func checkDomainFormat(domain string) Issues {
@@ -215,7 +550,38 @@ func checkDomainFormat(domain string) Issues {
 			"Please provide a domain that does not contain `..`")
 	}
 
-	ps, _ := publicsuffix.PublicSuffix(domain)
+	// Check internationalized domains in their ASCII form. Issues still
+	// show the Unicode form (see DisplayDomain()).
+	ascii, idnIssues := toASCIIDomain(domain)
+	if len(idnIssues.Errors) > 0 {
+		return idnIssues
+	}
+	domain = ascii
+
+	labels := strings.Split(strings.ToLower(domain), ".")
+	if special, ok := specialUseTLDs[labels[len(labels)-1]]; ok {
+		return issues.addErrorWithParamsf(
+			special.code,
+			"Special-use domain",
+			map[string]string{"domain": DisplayDomain(domain), "tld": labels[len(labels)-1]},
+			"`%s` is under the special-use `.%s` TLD. %s",
+			DisplayDomain(domain),
+			labels[len(labels)-1],
+			special.reason)
+	}
+
+	ps, icann := publicsuffix.PublicSuffix(domain)
+	if len(labels) == 1 && !icann {
+		return issues.addErrorWithParamsf(
+			IssueCode("domain.format.single_label"),
+			"Single-label domain name",
+			map[string]string{"domain": DisplayDomain(domain)},
+			"`%s` is a single-label name, which is usually only resolvable on an intranet. "+
+				"Only publicly registered domains can be preloaded. "+
+				"If you intended to query for a normal website, make sure to enter all of its labels "+
+				"(e.g. `example.com` rather than `example`).",
+			DisplayDomain(domain))
+	}
 	if ps == domain {
 		return issues.addErrorf(
 			IssueCode("domain.format.public_suffix"),
@@ -249,44 +615,60 @@ func preloadableDomainLevel(domain string) Issues {
 	}
 
 	if eTLD1 != domain {
-		return issues.addErrorf(
+		return issues.addErrorWithParamsf(
 			IssueCode("domain.is_subdomain"),
 			"Subdomain",
+			map[string]string{"domain": DisplayDomain(domain), "registrable_domain": DisplayDomain(eTLD1)},
 			"`%s` is a subdomain. Please preload `%s` instead. "+
 				"(Due to the size of the preload list and the behaviour of "+
 				"cookies across subdomains, we only accept automated preload list "+
 				"submissions of whole registered domains.)",
-			domain,
-			eTLD1,
+			DisplayDomain(domain),
+			DisplayDomain(eTLD1),
 		)
 	}
 
 	return issues
 }
 
-func checkWWW(host string) Issues {
+// checkWWW checks the www subdomain of `host`. `apexResp` is the response
+// from `host` over HTTPS, whose HSTS header the www subdomain should match.
+// `c` may be nil.
+func checkWWW(ctx context.Context, host string, apexResp *http.Response, c *Checker) Issues {
 	issues := Issues{}
 
 	hasWWW := false
-	if conn, err := net.DialTimeout("tcp", "www."+host+":443", dialTimeout); err == nil {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout())
+	defer cancel()
+	wwwAddr := net.JoinHostPort("www."+host, c.httpsPort())
+	var conn net.Conn
+	if _, err := c.withRetries(ctx, func() (err error) {
+		conn, err = c.dialContext(ctx, "tcp", wwwAddr)
+		return err
+	}); err == nil {
 		hasWWW = true
 		if err = conn.Close(); err != nil {
 			return issues.addErrorf(
 				"internal.domain.www.first_dial.no_close",
 				"Internal error",
 				"Error while closing a connection to %s: %s",
-				"www."+host,
+				DisplayDomain("www."+host),
 				err,
 			)
 		}
 	}
 
 	if hasWWW {
-		wwwConn, err := tls.DialWithDialer(&dialer, "tcp", "www."+host+":443", nil)
+		var wwwConn *tls.Conn
+		attempts, err := c.withRetries(ctx, func() (err error) {
+			wwwConn, err = c.dialTLS(ctx, wwwAddr)
+			return err
+		})
 		if err != nil {
-			return issues.addErrorf(
+			return issues.addErrorWithParamsf(
 				IssueCode("domain.www.no_tls"),
 				"www subdomain does not support HTTPS",
+				map[string]string{"host": "www." + host, "error": err.Error()},
 				"Domain error: The www subdomain exists, but we couldn't connect to it using HTTPS (%q). "+
 					"Since many people type this by habit, HSTS preloading would likely "+
 					"cause issues for your site.",
@@ -298,11 +680,153 @@ func checkWWW(host string) Issues {
 				"internal.domain.www.second_dial.no_close",
 				"Internal error",
 				"Error while closing a connection to %s: %s",
-				"www."+host,
+				DisplayDomain("www."+host),
 				err,
 			)
 		}
+
+		issues = combineIssues(issues, retriedIssues(IssueCode("domain.www.retried"), c.httpsURL("www."+host), attempts))
+		issues = combineIssues(issues, checkWWWOverHTTPURL(ctx, c.httpURL("www."+host), host, c))
+
+		// Only compare headers if the apex has a single one; other cases
+		// are already reported by the main header check.
+		if apexHeaders := apexResp.Header.Values(hstsHeaderName); len(apexHeaders) == 1 {
+			issues = combineIssues(issues, checkWWWHeaderConsistencyURL(ctx, c.httpsURL("www."+host), apexHeaders[0], c))
+		}
 	}
 
 	return issues
 }
+
+// checkWWWOverHTTPURL checks that `initialURL` (the www subdomain of
+// `domain` over HTTP) immediately redirects to HTTPS on the www subdomain or
+// on `domain` itself. Taking a URL allows us to test more easily.
+//
+// `c` may be nil.
+func checkWWWOverHTTPURL(ctx context.Context, initialURL string, domain string, c *Checker) Issues {
+	issues := Issues{}
+
+	var resp *http.Response
+	attempts, err := c.withRetries(ctx, func() (err error) {
+		resp, err = getFirstResponse(ctx, initialURL, c)
+		return err
+	})
+	if err != nil {
+		// It's fine for the www subdomain not to support HTTP at all.
+		return issues
+	}
+	issues = combineIssues(issues, retriedIssues(IssueCode("domain.www.retried"), initialURL, attempts))
+
+	location, err := resp.Location()
+	if resp.StatusCode < 300 || resp.StatusCode >= 400 || err != nil {
+		return issues.addWarningWithParamsf(
+			IssueCode("domain.www.http.no_redirect"),
+			"www subdomain does not redirect to HTTPS",
+			map[string]string{"url": initialURL, "status_code": strconv.Itoa(resp.StatusCode)},
+			"`%s` (HTTP) does not redirect to HTTPS (status code %d). "+
+				"Since many people type the www subdomain by habit, it should immediately redirect to `%s` or `%s`.",
+			displayURL(initialURL),
+			resp.StatusCode,
+			displayURL(c.httpsURL("www."+domain)),
+			displayURL(c.httpsURL(domain)),
+		)
+	}
+
+	if location.Scheme != httpsScheme || (location.Hostname() != domain && location.Hostname() != "www."+domain) {
+		return issues.addWarningWithParamsf(
+			IssueCode("domain.www.http.bad_redirect"),
+			"Bad redirect from www over HTTP",
+			map[string]string{"url": initialURL, "location": location.String()},
+			"`%s` (HTTP) redirects to `%s`. "+
+				"Since many people type the www subdomain by habit, it should immediately redirect to `%s` or `%s`.",
+			displayURL(initialURL),
+			displayURL(location.String()),
+			displayURL(c.httpsURL("www."+domain)),
+			displayURL(c.httpsURL(domain)),
+		)
+	}
+
+	return issues
+}
+
+// checkWWWHeaderConsistencyURL checks that `wwwURL` (the www subdomain over
+// HTTPS) sends an HSTS header that matches `apexHeader`. A browser that
+// visits both hosts keeps the policy it saw last for each of them, so
+// divergent headers lead to confusing behavior once the domain is preloaded.
+// The headers are not compared if the apex header already covers the www
+// subdomain with includeSubDomains, or if the www subdomain redirects (e.g.
+// to the apex domain). Taking a URL allows us to test more easily.
+//
+// `c` may be nil.
+func checkWWWHeaderConsistencyURL(ctx context.Context, wwwURL string, apexHeader string, c *Checker) Issues {
+	issues := Issues{}
+
+	apex, _ := ParseHeaderString(apexHeader)
+	if apex.IncludeSubDomains {
+		return issues
+	}
+
+	resp, err := getFirstResponse(ctx, wwwURL, c)
+	if err != nil {
+		// Connection problems are reported by the other www checks.
+		return issues
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 && resp.StatusCode < 400 {
+		return issues
+	}
+
+	wwwHeaders := resp.Header.Values(hstsHeaderName)
+	if len(wwwHeaders) != 1 {
+		return issues.addWarningWithParamsf(
+			IssueCode("domain.www.hsts.missing"),
+			"www subdomain does not send a single HSTS header",
+			map[string]string{"url": wwwURL, "header_count": strconv.Itoa(len(wwwHeaders)), "apex_header": apexHeader},
+			"`%s` sent %d HSTS headers, but the apex domain sends `%s`. "+
+				"The www subdomain should send the same header as the apex domain.",
+			displayURL(wwwURL),
+			len(wwwHeaders),
+			apexHeader,
+		)
+	}
+	if wwwHeaders[0] == apexHeader {
+		return issues
+	}
+
+	www, _ := ParseHeaderString(wwwHeaders[0])
+	if !maxAgeEqual(apex.MaxAge, www.MaxAge) {
+		issues = issues.addWarningWithParamsf(
+			IssueCode("domain.www.hsts.max_age_mismatch"),
+			"www subdomain sends a different max-age",
+			map[string]string{"url": wwwURL, "header": wwwHeaders[0], "apex_header": apexHeader},
+			"`%s` sends the HSTS header `%s`, but the apex domain sends `%s`. "+
+				"The www subdomain should send the same max-age as the apex domain.",
+			displayURL(wwwURL),
+			wwwHeaders[0],
+			apexHeader,
+		)
+	}
+	if apex.IncludeSubDomains != www.IncludeSubDomains || apex.Preload != www.Preload {
+		issues = issues.addWarningWithParamsf(
+			IssueCode("domain.www.hsts.directives_mismatch"),
+			"www subdomain sends different HSTS directives",
+			map[string]string{"url": wwwURL, "header": wwwHeaders[0], "apex_header": apexHeader},
+			"`%s` sends the HSTS header `%s`, but the apex domain sends `%s`. "+
+				"The www subdomain should send the same `includeSubDomains` and `preload` directives as the apex domain.",
+			displayURL(wwwURL),
+			wwwHeaders[0],
+			apexHeader,
+		)
+	}
+
+	return issues
+}
+
+// maxAgeEqual returns whether `a` and `b` are both absent or have the same
+// value.
+func maxAgeEqual(a, b *MaxAge) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Seconds == b.Seconds
+}
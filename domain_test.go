@@ -1,9 +1,16 @@
 package hstspreload
 
 import (
+	"context"
 	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"sync"
 	"testing"
+	"time"
+
+	"github.com/chromium/hstspreload/chromium/preloadlist"
 )
 
 func ExamplePreloadableDomain() {
@@ -30,14 +37,41 @@ var testCheckDomainFormatTests = []struct {
 		Issues{Errors: []Issue{{Code: "domain.format.contains_double_dot"}}},
 	},
 	{"example",
+		Issues{Errors: []Issue{{Code: "domain.format.single_label"}}},
+	},
+	{"com",
 		Issues{Errors: []Issue{{Code: "domain.format.public_suffix"}}},
 	},
+	{"example.onion",
+		Issues{Errors: []Issue{{Code: "domain.format.onion"}}},
+	},
+	{"printer.LOCAL",
+		Issues{Errors: []Issue{{Code: "domain.format.local"}}},
+	},
+	{"corp.internal",
+		Issues{Errors: []Issue{{Code: "domain.format.internal"}}},
+	},
 	{"co.uk",
 		Issues{Errors: []Issue{{Code: "domain.format.public_suffix"}}},
 	},
 	{"example&co.com",
 		Issues{Errors: []Issue{{Code: "domain.format.invalid_characters"}}},
 	},
+	{"bücher.example.com",
+		Issues{},
+	},
+	{"BÜCHER.example.com",
+		Issues{},
+	},
+	{"bücher.onion",
+		Issues{Errors: []Issue{{Code: "domain.format.onion"}}},
+	},
+	{"bücher-.example.com",
+		Issues{Errors: []Issue{{Code: "domain.format.invalid_idna_label"}}},
+	},
+	{"bücher&co.com",
+		Issues{Errors: []Issue{{Code: "domain.format.invalid_idna_label"}}},
+	},
 }
 
 func TestCheckDomainFormat(t *testing.T) {
@@ -70,6 +104,12 @@ var testPreloadableDomainLevel = []struct {
 			Message: "`subdomain.example.com` is a subdomain. Please preload `example.com` instead. (Due to the size of the preload list and the behaviour of cookies across subdomains, we only accept automated preload list submissions of whole registered domains.)",
 		}}},
 	},
+	{"www.xn--bcher-kva.com",
+		Issues{Errors: []Issue{{
+			Code:    "domain.is_subdomain",
+			Message: "`www.xn--bcher-kva.com (www.bücher.com)` is a subdomain. Please preload `xn--bcher-kva.com (bücher.com)` instead. (Due to the size of the preload list and the behaviour of cookies across subdomains, we only accept automated preload list submissions of whole registered domains.)",
+		}}},
+	},
 }
 
 func TestPreloadableDomainLevel(t *testing.T) {
@@ -91,6 +131,162 @@ func skipIfShort(t *testing.T) {
 	}
 }
 
+var checkWWWOverHTTPTests = []struct {
+	description    string
+	handler        http.HandlerFunc
+	expectedIssues Issues
+}{
+	{
+		"redirect to www",
+		func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "https://www.example.com/", http.StatusMovedPermanently)
+		},
+		Issues{},
+	},
+	{
+		"redirect to apex",
+		func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "https://example.com/", http.StatusMovedPermanently)
+		},
+		Issues{},
+	},
+	{
+		"content",
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("<html>Welcome!</html>"))
+		},
+		Issues{Warnings: []Issue{{Code: "domain.www.http.no_redirect"}}},
+	},
+	{
+		"redirect to HTTP",
+		func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "http://example.com/", http.StatusMovedPermanently)
+		},
+		Issues{Warnings: []Issue{{Code: "domain.www.http.bad_redirect"}}},
+	},
+	{
+		"redirect to a different domain",
+		func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "https://example.net/", http.StatusMovedPermanently)
+		},
+		Issues{Warnings: []Issue{{Code: "domain.www.http.bad_redirect"}}},
+	},
+}
+
+func TestCheckWWWOverHTTP(t *testing.T) {
+	for _, tt := range checkWWWOverHTTPTests {
+		srv := httptest.NewServer(tt.handler)
+		issues := checkWWWOverHTTPURL(context.Background(), srv.URL, "example.com", nil)
+		srv.Close()
+
+		if !issues.Match(tt.expectedIssues) {
+			t.Errorf("[%s] "+issuesShouldMatch, tt.description, issues, tt.expectedIssues)
+		}
+	}
+
+	if issues := checkWWWOverHTTPURL(context.Background(), "http://127.0.0.1:0", "example.com", nil); !issues.Match(Issues{}) {
+		t.Errorf("An unavailable www subdomain over HTTP should be fine: "+issuesShouldBeEmpty, issues)
+	}
+}
+
+const apexHeader = "max-age=63072000; preload"
+
+var checkWWWHeaderConsistencyTests = []struct {
+	description    string
+	apexHeader     string
+	wwwStatus      int
+	wwwHeaders     []string
+	expectedIssues Issues
+}{
+	{
+		"same header",
+		apexHeader, http.StatusOK,
+		[]string{apexHeader},
+		Issues{},
+	},
+	{
+		"equivalent header",
+		apexHeader, http.StatusOK,
+		[]string{"max-age=63072000;preload"},
+		Issues{},
+	},
+	{
+		"no header",
+		apexHeader, http.StatusOK,
+		nil,
+		Issues{Warnings: []Issue{{Code: "domain.www.hsts.missing"}}},
+	},
+	{
+		"multiple headers",
+		apexHeader, http.StatusOK,
+		[]string{apexHeader, apexHeader},
+		Issues{Warnings: []Issue{{Code: "domain.www.hsts.missing"}}},
+	},
+	{
+		"different max-age",
+		apexHeader, http.StatusOK,
+		[]string{"max-age=31536000; preload"},
+		Issues{Warnings: []Issue{{Code: "domain.www.hsts.max_age_mismatch"}}},
+	},
+	{
+		"different directives",
+		apexHeader, http.StatusOK,
+		[]string{"max-age=63072000"},
+		Issues{Warnings: []Issue{{Code: "domain.www.hsts.directives_mismatch"}}},
+	},
+	{
+		"different max-age and directives",
+		apexHeader, http.StatusOK,
+		[]string{"max-age=300; includeSubDomains; preload"},
+		Issues{Warnings: []Issue{
+			{Code: "domain.www.hsts.max_age_mismatch"},
+			{Code: "domain.www.hsts.directives_mismatch"},
+		}},
+	},
+	{
+		"redirect without header",
+		apexHeader, http.StatusMovedPermanently,
+		nil,
+		Issues{},
+	},
+	{
+		"redirect with different header",
+		apexHeader, http.StatusMovedPermanently,
+		[]string{"max-age=300"},
+		Issues{},
+	},
+	{
+		"apex includes subdomains",
+		"max-age=63072000; includeSubDomains; preload", http.StatusOK,
+		[]string{"max-age=300"},
+		Issues{},
+	},
+}
+
+func TestCheckWWWHeaderConsistency(t *testing.T) {
+	for _, tt := range checkWWWHeaderConsistencyTests {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, h := range tt.wwwHeaders {
+				w.Header().Add("Strict-Transport-Security", h)
+			}
+			if tt.wwwStatus != http.StatusOK {
+				w.Header().Set("Location", "https://example.com/")
+			}
+			w.WriteHeader(tt.wwwStatus)
+		}))
+		issues := checkWWWHeaderConsistencyURL(context.Background(), srv.URL, tt.apexHeader, nil)
+		srv.Close()
+
+		if !issues.Match(tt.expectedIssues) {
+			t.Errorf("[%s] "+issuesShouldMatch, tt.description, issues, tt.expectedIssues)
+		}
+	}
+
+	if issues := checkWWWHeaderConsistencyURL(context.Background(), "http://127.0.0.1:0", apexHeader, nil); !issues.Match(Issues{}) {
+		t.Errorf("An unavailable www subdomain should be reported elsewhere: "+issuesShouldBeEmpty, issues)
+	}
+}
+
 type preloadableDomainTest struct {
 	function       func(domain string) (*string, Issues)
 	description    string
@@ -233,7 +429,7 @@ var preloadableDomainTests = []preloadableDomainTest{
 		"bogus domain",
 		"example.notadomain",
 		false, "",
-		Issues{Errors: []Issue{{Code: "domain.tls.cannot_connect"}}},
+		Issues{Errors: []Issue{{Code: "domain.dns.no_records"}}},
 	},
 
 	/******** RemovableDomain() ********/
@@ -295,3 +491,260 @@ func TestPreloadableDomainAndRemovableDomain(t *testing.T) {
 
 	wg.Wait()
 }
+
+func TestGetResponseInsecureFallback(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	host := srv.Listener.Addr().String()
+
+	resp, insecureFallback, issues := getResponse(context.Background(), host, &Checker{})
+	expected := Issues{Errors: []Issue{{Code: "domain.tls.invalid_cert_chain"}}}
+	if !issues.Match(expected) {
+		t.Errorf(issuesShouldMatch, issues, expected)
+	}
+	if !insecureFallback || resp == nil {
+		t.Errorf("Expected a response from the insecure fallback.")
+	}
+
+	resp, insecureFallback, issues = getResponse(context.Background(), host, &Checker{DisableInsecureFallback: true})
+	expected = Issues{Errors: []Issue{{Code: "domain.tls.cannot_connect"}}}
+	if !issues.Match(expected) {
+		t.Errorf(issuesShouldMatch, issues, expected)
+	}
+	if insecureFallback || resp != nil {
+		t.Errorf("The insecure fallback should not be used.")
+	}
+}
+
+func TestGetResponseNotHTTP(t *testing.T) {
+	// A mail server on port 443.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("220 mail.example.com ESMTP\r\n"))
+			conn.Close()
+		}
+	}()
+
+	// A TLS server that doesn't speak HTTP.
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		conn.Write([]byte("garbage\r\n\r\n"))
+		conn.Close()
+	}))
+	srv.StartTLS()
+	defer srv.Close()
+
+	for _, tt := range []struct {
+		description string
+		address     string
+	}{
+		{"non-TLS service", ln.Addr().String()},
+		{"non-HTTP service over TLS", srv.Listener.Addr().String()},
+	} {
+		c := &Checker{
+			ConnectTo: map[string]string{"example.com:443": tt.address},
+			Transport: srv.Client().Transport.(*http.Transport),
+		}
+		_, _, issues := getResponse(context.Background(), "example.com", c)
+		expected := Issues{Errors: []Issue{{Code: "domain.https.not_http"}}}
+		if !issues.Match(expected) {
+			t.Errorf("[%s] "+issuesShouldMatch, tt.description, issues, expected)
+		}
+	}
+}
+
+func TestPreloadableDomainContext(t *testing.T) {
+	// A server that accepts connections, but never responds.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		var conns []net.Conn
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				for _, conn := range conns {
+					conn.Close()
+				}
+				return
+			}
+			conns = append(conns, conn)
+		}
+	}()
+
+	c := &Checker{
+		Timeout:                 time.Minute,
+		ConnectTo:               map[string]string{"example.com": ln.Addr().String()},
+		DisableInsecureFallback: true,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, issues := c.PreloadableDomainContext(ctx, "example.com")
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("The check was not aborted by the context (took %s).", elapsed)
+	}
+	expected := Issues{Errors: []Issue{{Code: "domain.tls.handshake_timeout"}}}
+	if !issues.Match(expected) {
+		t.Errorf(issuesShouldMatch, issues, expected)
+	}
+}
+
+func TestPreloadableDomainSkipChecks(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", "max-age=31536000; includeSubDomains; preload")
+	}))
+	defer srv.Close()
+
+	// Nothing listens on the HTTP port.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.Close()
+
+	c := &Checker{
+		HTTPSPort: srv.Listener.Addr().(*net.TCPAddr).Port,
+		HTTPPort:  ln.Addr().(*net.TCPAddr).Port,
+		ConnectTo: map[string]string{"example.com": "127.0.0.1"},
+		Transport: srv.Client().Transport.(*http.Transport),
+	}
+	if r := c.PreloadableDomainResult("example.com"); !r.Issues.HasCode("redirects.http.does_not_exist") {
+		t.Errorf("Expected the HTTP redirect check to fail: %v", r.Issues)
+	}
+
+	c.DomainChecks = DomainCheckOptions{
+		SkipTLSChecks:      true,
+		SkipHTTPRedirects:  true,
+		SkipHTTPSRedirects: true,
+		SkipWWW:            true,
+	}
+	r := c.PreloadableDomainResult("example.com")
+	if len(r.Issues.Errors) > 0 {
+		t.Errorf("Unexpected errors: %v", r.Issues.Errors)
+	}
+	if r.Header == nil || r.HTTPRedirects != nil {
+		t.Errorf("Unexpected result: %#v", r)
+	}
+
+	c.DomainChecks = DomainCheckOptions{HeaderOnly: true}
+	r = c.PreloadableDomainResult("example.com")
+	if len(r.Issues.Errors) > 0 {
+		t.Errorf("Unexpected errors: %v", r.Issues.Errors)
+	}
+	if r.Header == nil || r.HTTPRedirects != nil || r.HTTPSRedirects != nil {
+		t.Errorf("Unexpected result: %#v", r)
+	}
+}
+
+func TestCheckRemovalStatus(t *testing.T) {
+	idx := preloadlist.PreloadList{Entries: []preloadlist.Entry{
+		{Name: "example.com", Mode: preloadlist.ForceHTTPS, IncludeSubDomains: true, Policy: preloadlist.PolicyBulk1Year},
+		{Name: "custom.example", Mode: preloadlist.ForceHTTPS, Policy: preloadlist.PolicyCustom},
+		{Name: "legacy.example", Mode: preloadlist.ForceHTTPS},
+	}}.Index()
+
+	tests := []struct {
+		domain   string
+		expected Issues
+	}{
+		{"example.com", Issues{Warnings: []Issue{{Code: "removal.policy.bulk"}}}},
+		{"sub.example.com", Issues{Errors: []Issue{{Code: "removal.covered_by_parent"}}}},
+		{"custom.example", Issues{Warnings: []Issue{{Code: "removal.policy.not_bulk"}}}},
+		{"legacy.example", Issues{Warnings: []Issue{{Code: "removal.policy.not_bulk"}}}},
+		{"example.net", Issues{Warnings: []Issue{{Code: "removal.not_preloaded"}}}},
+	}
+	for _, tt := range tests {
+		if issues := checkRemovalStatus(tt.domain, idx); !issues.Match(tt.expected) {
+			t.Errorf("[%s] "+issuesShouldMatch, tt.domain, issues, tt.expected)
+		}
+	}
+}
+
+func TestRemovableDomainPreloadList(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", "max-age=31536000")
+	}))
+	defer srv.Close()
+
+	c := &Checker{
+		HTTPSPort: srv.Listener.Addr().(*net.TCPAddr).Port,
+		ConnectTo: map[string]string{"sub.example.com": "127.0.0.1"},
+		Transport: srv.Client().Transport.(*http.Transport),
+	}
+	if _, issues := c.RemovableDomain("sub.example.com"); !issues.Match(Issues{}) {
+		t.Errorf(issuesShouldBeEmpty, issues)
+	}
+
+	idx := preloadlist.PreloadList{Entries: []preloadlist.Entry{
+		{Name: "example.com", Mode: preloadlist.ForceHTTPS, IncludeSubDomains: true},
+	}}.Index()
+	c.PreloadList = &idx
+	expected := Issues{Errors: []Issue{{Code: "removal.covered_by_parent"}}}
+	if _, issues := c.RemovableDomain("sub.example.com"); !issues.Match(expected) {
+		t.Errorf(issuesShouldMatch, issues, expected)
+	}
+}
+
+func TestRemovableDomainRemovalMaxAge(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", "max-age=31536000")
+	}))
+	defer srv.Close()
+
+	maxAge := uint64(0)
+	c := &Checker{
+		HTTPSPort:     srv.Listener.Addr().(*net.TCPAddr).Port,
+		ConnectTo:     map[string]string{"example.com": "127.0.0.1"},
+		Transport:     srv.Client().Transport.(*http.Transport),
+		RemovalMaxAge: &maxAge,
+	}
+	expected := Issues{Errors: []Issue{{Code: "header.removable.max_age.too_high"}}}
+	if _, issues := c.RemovableDomain("example.com"); !issues.Match(expected) {
+		t.Errorf(issuesShouldMatch, issues, expected)
+	}
+
+	maxAge = 31536000
+	if _, issues := c.RemovableDomain("example.com"); !issues.Match(Issues{}) {
+		t.Errorf(issuesShouldBeEmpty, issues)
+	}
+}
+
+func TestPreloadableDomainAllowSubdomain(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", "max-age=31536000; includeSubDomains; preload")
+	}))
+	defer srv.Close()
+
+	c := &Checker{
+		HTTPSPort: srv.Listener.Addr().(*net.TCPAddr).Port,
+		ConnectTo: map[string]string{"sub.example.com": "127.0.0.1"},
+		Transport: srv.Client().Transport.(*http.Transport),
+		DomainChecks: DomainCheckOptions{
+			SkipHTTPRedirects: true,
+		},
+	}
+	if r := c.PreloadableDomainResult("sub.example.com"); !r.Issues.HasCode("domain.is_subdomain") {
+		t.Errorf("Expected domain.is_subdomain: %v", r.Issues)
+	}
+
+	c.DomainChecks.AllowSubdomain = true
+	if r := c.PreloadableDomainResult("sub.example.com"); len(r.Issues.Errors) > 0 {
+		t.Errorf("Unexpected errors: %v", r.Issues.Errors)
+	}
+}
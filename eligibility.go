@@ -0,0 +1,141 @@
+package hstspreload
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Eligibility is an overall verdict for a set of Issues.
+type Eligibility string
+
+const (
+	// Eligible indicates that there are no errors or warnings.
+	Eligible Eligibility = "eligible"
+	// EligibleWithWarnings indicates that there are warnings, but no errors.
+	EligibleWithWarnings Eligibility = "eligible_with_warnings"
+	// Ineligible indicates that there is at least one error.
+	Ineligible Eligibility = "ineligible"
+)
+
+// Eligibility computes the overall verdict for `iss`.
+func (iss Issues) Eligibility() Eligibility {
+	switch {
+	case len(iss.Errors) > 0:
+		return Ineligible
+	case len(iss.Warnings) > 0:
+		return EligibleWithWarnings
+	default:
+		return Eligible
+	}
+}
+
+// PromoteWarnings returns a copy of `iss` in which all warnings with one of
+// the given codes are treated as errors. This allows organizations to
+// enforce a stricter bar than Chromium, e.g. by promoting
+// "tls.obsolete_cipher_suite". To apply this to the results of all checks,
+// set Checker.PromoteWarnings instead.
+//
+// Promoted warnings are appended to the errors in their original order.
+func (iss Issues) PromoteWarnings(codes ...IssueCode) Issues {
+	promote := make(map[IssueCode]bool)
+	for _, c := range codes {
+		promote[c] = true
+	}
+
+	return iss.promoteWarningsIf(func(w Issue) bool {
+		return promote[w.Code]
+	})
+}
+
+// PromoteAllWarnings returns a copy of `iss` in which all warnings are
+// treated as errors.
+func (iss Issues) PromoteAllWarnings() Issues {
+	return iss.promoteWarningsIf(func(w Issue) bool {
+		return true
+	})
+}
+
+func (iss Issues) promoteWarningsIf(shouldPromote func(Issue) bool) Issues {
+	result := Issues{
+		Errors: append([]Issue{}, iss.Errors...),
+	}
+	for _, w := range iss.Warnings {
+		if shouldPromote(w) {
+			result.Errors = append(result.Errors, w)
+		} else {
+			result.Warnings = append(result.Warnings, w)
+		}
+	}
+	return result
+}
+
+// A Severity reclassifies an issue (see SeverityOverrides).
+type Severity string
+
+const (
+	// SeverityError treats an issue as an error.
+	SeverityError Severity = "error"
+	// SeverityWarning treats an issue as a warning.
+	SeverityWarning Severity = "warning"
+	// SeverityInfo is the severity of warnings that are purely
+	// informational (see Issues.Filter()). It cannot be used in
+	// SeverityOverrides.
+	SeverityInfo Severity = "info"
+	// SeverityIgnore drops an issue.
+	SeverityIgnore Severity = "ignore"
+)
+
+// SeverityOverrides reclassifies issues with specific codes, to implement
+// an organization's own policy. Issues with codes that are not in the map
+// keep their original severity.
+type SeverityOverrides map[IssueCode]Severity
+
+// ParseSeverityOverrides reads SeverityOverrides from a JSON object that
+// maps issue codes to severities, e.g.:
+//
+//	{"tls.obsolete_cipher_suite": "error", "domain.www.no_tls": "ignore"}
+func ParseSeverityOverrides(r io.Reader) (SeverityOverrides, error) {
+	var o SeverityOverrides
+	if err := json.NewDecoder(r).Decode(&o); err != nil {
+		return nil, err
+	}
+	for code, severity := range o {
+		switch severity {
+		case SeverityError, SeverityWarning, SeverityIgnore:
+		default:
+			return nil, fmt.Errorf("invalid severity %q for issue code %q", severity, code)
+		}
+	}
+	return o, nil
+}
+
+// ApplySeverityOverrides returns a copy of `iss` in which issues are
+// reclassified according to `o`.
+//
+// Errors that become warnings are placed before the original warnings, and
+// warnings that become errors are placed after the original errors.
+func (iss Issues) ApplySeverityOverrides(o SeverityOverrides) Issues {
+	result := Issues{}
+	var demoted []Issue
+	for _, e := range iss.Errors {
+		switch o[e.Code] {
+		case SeverityIgnore:
+		case SeverityWarning:
+			demoted = append(demoted, e)
+		default:
+			result.Errors = append(result.Errors, e)
+		}
+	}
+	result.Warnings = demoted
+	for _, w := range iss.Warnings {
+		switch o[w.Code] {
+		case SeverityIgnore:
+		case SeverityError:
+			result.Errors = append(result.Errors, w)
+		default:
+			result.Warnings = append(result.Warnings, w)
+		}
+	}
+	return result
+}
@@ -0,0 +1,86 @@
+package hstspreload
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEligibility(t *testing.T) {
+	for _, tt := range []struct {
+		issues   Issues
+		expected Eligibility
+	}{
+		{Issues{}, Eligible},
+		{Issues{}.addWarningf("tls.obsolete_cipher_suite", "", ""), EligibleWithWarnings},
+		{Issues{}.addErrorf("response.no_header", "", "").addWarningf("tls.obsolete_cipher_suite", "", ""), Ineligible},
+	} {
+		if e := tt.issues.Eligibility(); e != tt.expected {
+			t.Errorf("Expected %s, got %s for %#v", tt.expected, e, tt.issues)
+		}
+	}
+}
+
+func TestPromoteWarnings(t *testing.T) {
+	issues := Issues{}.
+		addErrorf("response.no_header", "", "").
+		addWarningf("tls.obsolete_cipher_suite", "", "").
+		addWarningf("header.parse.empty_directive", "", "")
+
+	promoted := issues.PromoteWarnings("tls.obsolete_cipher_suite")
+	expected := Issues{
+		Errors:   []Issue{{Code: "response.no_header"}, {Code: "tls.obsolete_cipher_suite"}},
+		Warnings: []Issue{{Code: "header.parse.empty_directive"}},
+	}
+	if !promoted.Match(expected) {
+		t.Errorf(issuesShouldMatch, promoted, expected)
+	}
+
+	if len(issues.Errors) != 1 || len(issues.Warnings) != 2 {
+		t.Errorf("PromoteWarnings should not modify the original issues.")
+	}
+
+	promoted = Issues{}.addWarningf("tls.obsolete_cipher_suite", "", "").PromoteAllWarnings()
+	if promoted.Eligibility() != Ineligible {
+		t.Errorf("All warnings should be promoted: %#v", promoted)
+	}
+}
+
+func TestApplySeverityOverrides(t *testing.T) {
+	issues := Issues{}.
+		addErrorf("response.no_header", "", "").
+		addErrorf("domain.www.no_tls", "", "").
+		addWarningf("tls.obsolete_cipher_suite", "", "").
+		addWarningf("redirects.http.serves_content", "", "").
+		addWarningf("header.parse.empty_directive", "", "")
+
+	overridden := issues.ApplySeverityOverrides(SeverityOverrides{
+		"domain.www.no_tls":             SeverityWarning,
+		"tls.obsolete_cipher_suite":     SeverityError,
+		"redirects.http.serves_content": SeverityIgnore,
+		"response.no_header":            SeverityError,
+	})
+	expected := Issues{
+		Errors:   []Issue{{Code: "response.no_header"}, {Code: "tls.obsolete_cipher_suite"}},
+		Warnings: []Issue{{Code: "domain.www.no_tls"}, {Code: "header.parse.empty_directive"}},
+	}
+	if !overridden.Match(expected) {
+		t.Errorf(issuesShouldMatch, overridden, expected)
+	}
+}
+
+func TestParseSeverityOverrides(t *testing.T) {
+	o, err := ParseSeverityOverrides(strings.NewReader(`{"tls.obsolete_cipher_suite": "error", "domain.www.no_tls": "ignore"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(o) != 2 || o["tls.obsolete_cipher_suite"] != SeverityError || o["domain.www.no_tls"] != SeverityIgnore {
+		t.Errorf("Unexpected overrides: %#v", o)
+	}
+
+	if _, err := ParseSeverityOverrides(strings.NewReader(`{"tls.obsolete_cipher_suite": "fatal"}`)); err == nil {
+		t.Errorf("Expected an error for an invalid severity.")
+	}
+	if _, err := ParseSeverityOverrides(strings.NewReader(`[]`)); err == nil {
+		t.Errorf("Expected an error for invalid JSON.")
+	}
+}
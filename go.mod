@@ -3,3 +3,5 @@ module github.com/chromium/hstspreload
 go 1.19
 
 require golang.org/x/net v0.0.0-20220805013720-a33c5aa5df48
+
+require golang.org/x/text v0.3.7 // indirect
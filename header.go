@@ -7,10 +7,9 @@ import (
 )
 
 const (
-	oneYear  = 86400 * 365
-	tenYears = 10 * oneYear
-
-	hstsMinimumMaxAge = oneYear
+	eighteenWeeks = 86400 * 7 * 18
+	oneYear       = 86400 * 365
+	tenYears      = 10 * oneYear
 )
 
 // MaxAge holds the max-age of an HSTS header in seconds.
@@ -33,35 +32,49 @@ type HSTSHeader struct {
 // Iff Issues has no errors, the output integer is the max-age in seconds.
 // Note that according to the spec, the max-age value may optionally be quoted:
 // https://tools.ietf.org/html/rfc6797#section-6.2
-// However, it seems no one does this in practice, and certainly no one has
-// asked to be preloaded with a quoted max-age value. So to keep things simple,
-// we don't support quoted values.
-func parseMaxAge(directive string) (*MaxAge, Issues) {
+// Some CDNs emit quoted values, so we accept them, but warn, since the
+// unquoted form is far more common and other parsers may not support it.
+func parseMaxAge(directive headerDirective) (*MaxAge, Issues) {
 	issues := Issues{}
-	maxAgeNumericalString := directive[8:]
+	maxAgeNumericalString := directive.text[8:]
+
+	if len(maxAgeNumericalString) >= 2 && strings.HasPrefix(maxAgeNumericalString, `"`) && strings.HasSuffix(maxAgeNumericalString, `"`) {
+		maxAgeNumericalString = maxAgeNumericalString[1 : len(maxAgeNumericalString)-1]
+		issues = issues.addWarningWithParamsf(
+			"header.parse.max_age.quoted",
+			"Quoted max-age value",
+			directive.params(),
+			"The header's max-age value is quoted: `%s`. "+
+				"This is allowed, but unusual; consider using `max-age=%s` instead.",
+			directive.text,
+			maxAgeNumericalString)
+	}
 
 	// TODO: Use more concise validation code to parse a digit string to a signed int.
 	for i, c := range maxAgeNumericalString {
 		if i == 0 && c == '0' && len(maxAgeNumericalString) > 1 {
-			issues = issues.addWarningf(
+			issues = issues.addWarningWithParamsf(
 				"header.parse.max_age.leading_zero",
 				"Unexpected max-age syntax",
-				"The header's max-age value contains a leading 0: `%s`", directive)
+				directive.params(),
+				"The header's max-age value contains a leading 0: `%s`", directive.text)
 		}
 		if c < '0' || c > '9' {
-			return nil, issues.addErrorf(
+			return nil, issues.addErrorWithParamsf(
 				"header.parse.max_age.non_digit_characters",
 				"Invalid max-age syntax",
-				"The header's max-age value contains characters that are not digits: `%s`", directive)
+				directive.params(),
+				"The header's max-age value contains characters that are not digits: `%s`", directive.text)
 		}
 	}
 
 	seconds, err := strconv.ParseUint(maxAgeNumericalString, 10, 64)
 
 	if err != nil {
-		return nil, issues.addErrorf(
+		return nil, issues.addErrorWithParamsf(
 			"header.parse.max_age.parse_int_error",
 			"Invalid max-age syntax",
+			directive.params(),
 			"We could not parse the header's max-age value `%s`.", maxAgeNumericalString)
 	}
 
@@ -73,21 +86,18 @@ func parseMaxAge(directive string) (*MaxAge, Issues) {
 // header value is semantically valid. (See PreloadableHeaderString() for
 // that.)
 //
+// Issues about a single directive have the Params "directive" and
+// "offset" (the byte offset of the directive in `headerString`).
+//
 // To interpret the Issues that are returned, see the list of
 // conventions in the documentation for Issues.
 func ParseHeaderString(headerString string) (HSTSHeader, Issues) {
 	hstsHeader := HSTSHeader{}
 	issues := Issues{}
 
-	directives := strings.Split(headerString, ";")
-	for i, directive := range directives {
-		// TODO: this trims more than spaces and tabs (LWS). https://crbug.com/596561#c10
-		directives[i] = strings.TrimSpace(directive)
-	}
+	directives := tokenizeDirectives(headerString)
 
-	// If strings.Split() is given whitespace, it still returns an (empty) directive.
-	// So we handle this case separately.
-	if len(directives) == 1 && directives[0] == "" {
+	if len(directives) == 1 && directives[0].text == "" {
 		// Return immediately, because all the extra information is redundant.
 		return hstsHeader, issues.addWarningf(
 			"header.parse.empty",
@@ -97,44 +107,48 @@ func ParseHeaderString(headerString string) (HSTSHeader, Issues) {
 
 	for _, directive := range directives {
 		directiveEqualsIgnoringCase := func(s string) bool {
-			return strings.EqualFold(directive, s)
+			return strings.EqualFold(directive.text, s)
 		}
 
 		directiveHasPrefixIgnoringCase := func(prefix string) bool {
-			return strings.HasPrefix(strings.ToLower(directive), strings.ToLower(prefix))
+			return strings.HasPrefix(strings.ToLower(directive.text), strings.ToLower(prefix))
 		}
 
 		switch {
 		case directiveEqualsIgnoringCase("preload"):
 			if hstsHeader.Preload {
-				issues = issues.addUniqueWarningf(
+				issues = issues.addUniqueWarningWithParamsf(
 					"header.parse.repeated.preload",
 					"Repeated preload directive",
+					directive.params(),
 					"Header contains a repeated directive: `preload`")
 			} else {
 				hstsHeader.Preload = true
 			}
 
 		case directiveHasPrefixIgnoringCase("preload"):
-			issues = issues.addUniqueWarningf(
+			issues = issues.addUniqueWarningWithParamsf(
 				"header.parse.invalid.preload",
 				"Invalid preload directive",
+				directive.params(),
 				"Header contains a `preload` directive with extra parts.")
 
 		case directiveEqualsIgnoringCase("includeSubDomains"):
 			if hstsHeader.IncludeSubDomains {
-				issues = issues.addUniqueWarningf(
+				issues = issues.addUniqueWarningWithParamsf(
 					"header.parse.repeated.include_sub_domains",
 					"Repeated includeSubDomains directive",
+					directive.params(),
 					"Header contains a repeated directive: `includeSubDomains`")
 			} else {
 				hstsHeader.IncludeSubDomains = true
 			}
 
 		case directiveHasPrefixIgnoringCase("includeSubDomains"):
-			issues = issues.addUniqueWarningf(
+			issues = issues.addUniqueWarningWithParamsf(
 				"header.parse.invalid.include_sub_domains",
 				"Invalid includeSubDomains directive",
+				directive.params(),
 				"The header contains an `includeSubDomains` directive with extra directives.")
 
 		case directiveHasPrefixIgnoringCase("max-age="):
@@ -148,29 +162,40 @@ func ParseHeaderString(headerString string) (HSTSHeader, Issues) {
 			if hstsHeader.MaxAge == nil {
 				hstsHeader.MaxAge = maxAge
 			} else {
-				issues = issues.addUniqueWarningf(
+				issues = issues.addUniqueWarningWithParamsf(
 					"header.parse.repeated.max_age",
 					"Repeated max-age directive",
+					directive.params(),
 					"The header contains a repeated directive: `max-age`")
 			}
 
 		case directiveHasPrefixIgnoringCase("max-age"):
-			issues = issues.addUniqueErrorf(
+			issues = issues.addUniqueErrorWithParamsf(
 				"header.parse.invalid.max_age.no_value",
 				"Max-age drective without a value",
+				directive.params(),
 				"The header contains a max-age directive name without an associated value. Please specify the max-age in seconds.")
 
 		case directiveEqualsIgnoringCase(""):
-			issues = issues.addUniqueWarningf(
+			issues = issues.addUniqueWarningWithParamsf(
 				"header.parse.empty_directive",
 				"Empty directive or extra semicolon",
+				directive.params(),
 				"The header includes an empty directive or extra semicolon.")
 
+		case directive.invalid:
+			issues = issues.addWarningWithParamsf(
+				"header.parse.invalid_token",
+				"Invalid directive syntax",
+				directive.params(),
+				"The header contains a directive with characters that are not allowed in a directive name or value: `%s`", directive.text)
+
 		default:
-			issues = issues.addWarningf(
+			issues = issues.addWarningWithParamsf(
 				"header.parse.unknown_directive",
 				"Unknown directive",
-				"The header contains an unknown directive: `%s`", directive)
+				directive.params(),
+				"The header contains an unknown directive: `%s`", directive.text)
 		}
 	}
 	return hstsHeader, issues
@@ -202,7 +227,7 @@ func preloadableHeaderSubDomains(hstsHeader HSTSHeader) Issues {
 	return issues
 }
 
-func preloadableHeaderMaxAge(hstsHeader HSTSHeader) Issues {
+func preloadableHeaderMaxAge(hstsHeader HSTSHeader, policy PolicyVersion) Issues {
 	issues := Issues{}
 
 	switch {
@@ -218,30 +243,48 @@ func preloadableHeaderMaxAge(hstsHeader HSTSHeader) Issues {
 			"Negative max-age",
 			"Encountered an HSTSHeader with a negative max-age that does not equal MaxAgeNotPresent: %d", hstsHeader.MaxAge.Seconds)
 
-	case hstsHeader.MaxAge.Seconds < hstsMinimumMaxAge:
+	case hstsHeader.MaxAge.Seconds < policy.MinimumMaxAge:
+		params := map[string]string{
+			"max_age":     strconv.FormatUint(hstsHeader.MaxAge.Seconds, 10),
+			"min_max_age": strconv.FormatUint(policy.MinimumMaxAge, 10),
+		}
+		minimum := fmt.Sprintf("%d seconds", policy.MinimumMaxAge)
+		if d := describeMaxAge(policy.MinimumMaxAge); d != "" {
+			minimum += fmt.Sprintf(" (≈ %s)", d)
+		}
 		errorStr := fmt.Sprintf(
-			"The max-age must be at least 31536000 seconds (≈ 1 year), but the header currently only has max-age=%d.",
+			"The max-age must be at least %s, but the header currently only has max-age=%d.",
+			minimum,
 			hstsHeader.MaxAge.Seconds,
 		)
 		if hstsHeader.MaxAge.Seconds == 0 {
 			errorStr += " If you are trying to remove this domain from the preload list, please visit https://hstspreload.org/removal/"
-			issues = issues.addErrorf(
+			issues = issues.addErrorWithParamsf(
 				"header.preloadable.max_age.zero",
 				"Max-age is 0",
+				params,
 				errorStr,
 			)
 		} else {
-			issues = issues.addErrorf(
-				"header.preloadable.max_age.below_1_year",
+			// below_1_year predates other policy versions, so it is kept for
+			// the 1-year minimum.
+			code := IssueCode("header.preloadable.max_age.below_minimum")
+			if policy.MinimumMaxAge == oneYear {
+				code = "header.preloadable.max_age.below_1_year"
+			}
+			issues = issues.addErrorWithParamsf(
+				code,
 				"Max-age too low",
+				params,
 				errorStr,
 			)
 		}
 
 	case hstsHeader.MaxAge.Seconds > tenYears:
-		issues = issues.addWarningf(
+		issues = issues.addWarningWithParamsf(
 			"header.preloadable.max_age.over_10_years",
 			"Max-age > 10 years",
+			map[string]string{"max_age": strconv.FormatUint(hstsHeader.MaxAge.Seconds, 10)},
 			"FYI: The max-age (%d seconds) is longer than 10 years, which is an unusually long value.",
 			hstsHeader.MaxAge.Seconds,
 		)
@@ -252,21 +295,82 @@ func preloadableHeaderMaxAge(hstsHeader HSTSHeader) Issues {
 }
 
 // PreloadableHeader checks whether hstsHeader satisfies all requirements
-// for preloading in Chromium.
+// for preloading in Chromium, under DefaultPolicyVersion.
 //
 // To interpret the result, see the list of conventions in the
 // documentation for Issues.
 //
 // Most of the time, you'll probably want to use PreloadableHeaderString() instead.
 func PreloadableHeader(hstsHeader HSTSHeader) Issues {
+	return defaultChecker.PreloadableHeader(hstsHeader)
+}
+
+// PreloadableHeader is like the package-level PreloadableHeader(), but
+// enforces c.PolicyVersion. `c` may be nil.
+func (c *Checker) PreloadableHeader(hstsHeader HSTSHeader) Issues {
 	issues := Issues{}
+	policy := c.policyVersion()
+	criteria := policy.bulkCriteria()
 
-	issues = combineIssues(issues, preloadableHeaderSubDomains(hstsHeader))
-	issues = combineIssues(issues, preloadableHeaderPreload(hstsHeader))
-	issues = combineIssues(issues, preloadableHeaderMaxAge(hstsHeader))
+	if criteria.RequireIncludeSubDomains {
+		issues = combineIssues(issues, preloadableHeaderSubDomains(hstsHeader))
+	}
+	if criteria.RequirePreload {
+		issues = combineIssues(issues, preloadableHeaderPreload(hstsHeader))
+	}
+	issues = combineIssues(issues, preloadableHeaderMaxAge(hstsHeader, policy))
 	return issues
 }
 
+// SuggestHeader returns the header that a site currently serving the
+// (parsed) header `current` should serve instead in order to satisfy all
+// requirements for preloading, with as few changes as possible: missing
+// directives are added, a max-age below the minimum is raised to the
+// minimum, and the directives are written in their usual order and
+// capitalization.
+//
+// The returned Issues are those of PreloadableHeader(current), i.e. the
+// problems that the suggested header fixes.
+func SuggestHeader(current HSTSHeader) (string, Issues) {
+	return defaultChecker.SuggestHeader(current)
+}
+
+// SuggestHeader is like the package-level SuggestHeader(), but raises the
+// max-age to the minimum of c.PolicyVersion. `c` may be nil.
+func (c *Checker) SuggestHeader(current HSTSHeader) (string, Issues) {
+	policy := c.policyVersion()
+	criteria := policy.bulkCriteria()
+	maxAge := policy.MinimumMaxAge
+	if current.MaxAge != nil && current.MaxAge.Seconds > maxAge {
+		maxAge = current.MaxAge.Seconds
+	}
+	suggested := fmt.Sprintf("max-age=%d", maxAge)
+	if criteria.RequireIncludeSubDomains || current.IncludeSubDomains {
+		suggested += "; includeSubDomains"
+	}
+	if criteria.RequirePreload || current.Preload {
+		suggested += "; preload"
+	}
+	return suggested, c.PreloadableHeader(current)
+}
+
+// SuggestHeaderString is a convenience function that calls
+// ParseHeaderString() and then calls SuggestHeader() on the parsed header.
+// It returns the suggested header, and all issues from both calls,
+// combined (parse issues first).
+func SuggestHeaderString(headerString string) (string, Issues) {
+	return defaultChecker.SuggestHeaderString(headerString)
+}
+
+// SuggestHeaderString is like the package-level SuggestHeaderString(), but
+// enforces c.PolicyVersion (including its AllowedDirectives). `c` may be
+// nil.
+func (c *Checker) SuggestHeaderString(headerString string) (string, Issues) {
+	hstsHeader, issues := c.parseHeaderString(headerString)
+	suggested, suggestIssues := c.SuggestHeader(hstsHeader)
+	return suggested, combineIssues(issues, suggestIssues)
+}
+
 // RemovableHeader checks whether the header satisfies all requirements
 // for being removed from the Chromium preload list.
 //
@@ -294,6 +398,33 @@ func RemovableHeader(hstsHeader HSTSHeader) Issues {
 	return issues
 }
 
+// RemovableHeaderWithMaxAge is like RemovableHeader, but additionally
+// requires the max-age to be at most `maxAllowed` seconds. Pass 0 to require
+// `max-age=0`, which is what site operators are advised to serve in order
+// to wind down HSTS safely.
+//
+// To interpret the result, see the list of conventions in the
+// documentation for Issues.
+func RemovableHeaderWithMaxAge(hstsHeader HSTSHeader, maxAllowed uint64) Issues {
+	issues := RemovableHeader(hstsHeader)
+
+	if hstsHeader.MaxAge != nil && hstsHeader.MaxAge.Seconds > maxAllowed {
+		issues = issues.addErrorWithParamsf(
+			"header.removable.max_age.too_high",
+			"Max-age too high",
+			map[string]string{
+				"max_age":     strconv.FormatUint(hstsHeader.MaxAge.Seconds, 10),
+				"max_max_age": strconv.FormatUint(maxAllowed, 10),
+			},
+			"Header requirement error: For preload list removal, the max-age must be at most %d seconds, "+
+				"but the header currently has max-age=%d.",
+			maxAllowed,
+			hstsHeader.MaxAge.Seconds)
+	}
+
+	return issues
+}
+
 // PreloadableHeaderString is a convenience function that calls
 // ParseHeaderString() and then calls on PreloadableHeader() the parsed
 // header. It returns all issues from both calls, combined.
@@ -301,8 +432,15 @@ func RemovableHeader(hstsHeader HSTSHeader) Issues {
 // To interpret the result, see the list of conventions in the
 // documentation for Issues.
 func PreloadableHeaderString(headerString string) Issues {
-	hstsHeader, issues := ParseHeaderString(headerString)
-	return combineIssues(issues, PreloadableHeader(hstsHeader))
+	return defaultChecker.PreloadableHeaderString(headerString)
+}
+
+// PreloadableHeaderString is like the package-level
+// PreloadableHeaderString(), but enforces c.PolicyVersion (including its
+// AllowedDirectives). `c` may be nil.
+func (c *Checker) PreloadableHeaderString(headerString string) Issues {
+	hstsHeader, issues := c.parseHeaderString(headerString)
+	return combineIssues(issues, c.PreloadableHeader(hstsHeader))
 }
 
 // RemovableHeaderString is a convenience function that calls
@@ -323,3 +461,26 @@ func RemovableHeaderString(headerString string) Issues {
 	}
 	return combineIssues(issues, RemovableHeader(hstsHeader))
 }
+
+// RemovableHeaderStringWithMaxAge is like RemovableHeaderString, but calls
+// RemovableHeaderWithMaxAge() on the parsed header.
+//
+// To interpret the result, see the list of conventions in the
+// documentation for Issues.
+func RemovableHeaderStringWithMaxAge(headerString string, maxAllowed uint64) Issues {
+	hstsHeader, issues := ParseHeaderString(headerString)
+	issues = Issues{
+		Errors: issues.Errors,
+		// Ignore parse warnings for removal testing.
+	}
+	return combineIssues(issues, RemovableHeaderWithMaxAge(hstsHeader, maxAllowed))
+}
+
+// RemovableHeaderString is like the package-level RemovableHeaderString(),
+// but also enforces c.RemovalMaxAge (if set). `c` may be nil.
+func (c *Checker) RemovableHeaderString(headerString string) Issues {
+	if c == nil || c.RemovalMaxAge == nil {
+		return RemovableHeaderString(headerString)
+	}
+	return RemovableHeaderStringWithMaxAge(headerString, *c.RemovalMaxAge)
+}
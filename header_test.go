@@ -2,6 +2,8 @@ package hstspreload
 
 import (
 	"fmt"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -198,6 +200,30 @@ var parseHeaderStringTests = []struct {
 		Issues{Warnings: []Issue{{Code: "header.parse.max_age.leading_zero"}}},
 		HSTSHeader{Preload: false, IncludeSubDomains: false, MaxAge: &MaxAge{Seconds: 1234}},
 	},
+	{
+		"tabs around directives",
+		"max-age=31536000;\tincludeSubDomains\t;preload",
+		Issues{},
+		HSTSHeader{Preload: true, IncludeSubDomains: true, MaxAge: &MaxAge{Seconds: 31536000}},
+	},
+	{
+		"quoted string with a semicolon",
+		`max-age=31536000; foo="bar;preload"`,
+		Issues{Warnings: []Issue{{Code: "header.parse.unknown_directive"}}},
+		HSTSHeader{Preload: false, IncludeSubDomains: false, MaxAge: &MaxAge{Seconds: 31536000}},
+	},
+	{
+		"invalid token characters",
+		"max-age=31536000; foo bar",
+		Issues{Warnings: []Issue{{Code: "header.parse.invalid_token"}}},
+		HSTSHeader{Preload: false, IncludeSubDomains: false, MaxAge: &MaxAge{Seconds: 31536000}},
+	},
+	{
+		"quoted max-age",
+		`max-age="31536000"; includeSubDomains`,
+		Issues{Warnings: []Issue{{Code: "header.parse.max_age.quoted"}}},
+		HSTSHeader{Preload: false, IncludeSubDomains: true, MaxAge: &MaxAge{Seconds: 31536000}},
+	},
 }
 
 func TestParseHeaderString(t *testing.T) {
@@ -246,6 +272,11 @@ var parseHeaderStringWithErrorsTests = []struct {
 			Message: "The header's max-age value contains characters that are not digits: `max-age=+101`",
 		}}},
 	},
+	{
+		"bad max-age: unbalanced quote",
+		`max-age="31536000`,
+		Issues{Errors: []Issue{{Code: "header.parse.max_age.non_digit_characters"}}},
+	},
 
 	/******** errors and warnings ********/
 
@@ -268,6 +299,14 @@ var parseHeaderStringWithErrorsTests = []struct {
 			},
 		},
 	},
+	{
+		"error and warning: empty quoted max-age",
+		`max-age=""`,
+		Issues{
+			Errors:   []Issue{{Code: "header.parse.max_age.parse_int_error"}},
+			Warnings: []Issue{{Code: "header.parse.max_age.quoted"}},
+		},
+	},
 }
 
 func TestParseHeaderStringWithErrors(t *testing.T) {
@@ -508,3 +547,157 @@ func TestRemovableHeaderString(t *testing.T) {
 		}
 	}
 }
+
+var removableHeaderStringWithMaxAgeTests = []struct {
+	description    string
+	header         string
+	maxAllowed     uint64
+	expectedIssues Issues
+}{
+	{
+		"max-age=0",
+		"max-age=0",
+		0,
+		Issues{},
+	},
+	{
+		"below threshold",
+		"max-age=86400; includeSubDomains",
+		86400,
+		Issues{},
+	},
+	{
+		"above threshold",
+		"max-age=31536000",
+		0,
+		Issues{Errors: []Issue{{
+			Code:    "header.removable.max_age.too_high",
+			Message: "Header requirement error: For preload list removal, the max-age must be at most 0 seconds, but the header currently has max-age=31536000.",
+		}}},
+	},
+	{
+		"preload present and above threshold",
+		"max-age=31536000; preload",
+		86400,
+		Issues{Errors: []Issue{
+			{Code: "header.removable.contains.preload"},
+			{Code: "header.removable.max_age.too_high"},
+		}},
+	},
+	{
+		"max-age missing",
+		"includeSubDomains",
+		0,
+		Issues{Errors: []Issue{{Code: "header.removable.missing.max_age"}}},
+	},
+}
+
+func TestRemovableHeaderStringWithMaxAge(t *testing.T) {
+	for _, tt := range removableHeaderStringWithMaxAgeTests {
+		issues := RemovableHeaderStringWithMaxAge(tt.header, tt.maxAllowed)
+		if !issues.Match(tt.expectedIssues) {
+			t.Errorf("[%s] "+issuesShouldMatch, tt.description, issues, tt.expectedIssues)
+		}
+	}
+}
+
+func TestPreloadableHeaderParams(t *testing.T) {
+	var tests = []struct {
+		header   string
+		expected map[string]string
+	}{
+		{
+			"max-age=10; includeSubDomains; preload",
+			map[string]string{"max_age": "10", "min_max_age": "31536000"},
+		},
+		{
+			"max-age=0; includeSubDomains; preload",
+			map[string]string{"max_age": "0", "min_max_age": "31536000"},
+		},
+	}
+
+	for _, tt := range tests {
+		issues := PreloadableHeaderString(tt.header)
+		if len(issues.Errors) != 1 || !reflect.DeepEqual(issues.Errors[0].Params, tt.expected) {
+			t.Errorf("[%s] Unexpected issues: %#v", tt.header, issues)
+		}
+	}
+
+	issues := RemovableHeaderStringWithMaxAge("max-age=100", 0)
+	expected := map[string]string{"max_age": "100", "max_max_age": "0"}
+	if len(issues.Errors) != 1 || !reflect.DeepEqual(issues.Errors[0].Params, expected) {
+		t.Errorf("Unexpected issues: %#v", issues)
+	}
+}
+
+var suggestHeaderTests = []struct {
+	description    string
+	header         string
+	expected       string
+	expectedIssues Issues
+}{
+	{
+		"already preloadable",
+		"max-age=63072000; includeSubDomains; preload",
+		"max-age=63072000; includeSubDomains; preload",
+		Issues{},
+	},
+	{
+		"normalizes order and capitalization",
+		"PRELOAD; includesubdomains; Max-Age=31536000",
+		"max-age=31536000; includeSubDomains; preload",
+		Issues{},
+	},
+	{
+		"raises max-age and adds directives",
+		"max-age=86400",
+		"max-age=31536000; includeSubDomains; preload",
+		Issues{Errors: []Issue{
+			{Code: "header.preloadable.include_sub_domains.missing"},
+			{Code: "header.preloadable.preload.missing"},
+			{Code: "header.preloadable.max_age.below_1_year"},
+		}},
+	},
+	{
+		"adds max-age",
+		"includeSubDomains; preload",
+		"max-age=31536000; includeSubDomains; preload",
+		Issues{Errors: []Issue{{Code: "header.preloadable.max_age.missing"}}},
+	},
+}
+
+func TestSuggestHeader(t *testing.T) {
+	for _, tt := range suggestHeaderTests {
+		hstsHeader, _ := ParseHeaderString(tt.header)
+		suggested, issues := SuggestHeader(hstsHeader)
+		if suggested != tt.expected {
+			t.Errorf("[%s] SuggestHeader() = %q, want %q", tt.description, suggested, tt.expected)
+		}
+		if !issues.Match(tt.expectedIssues) {
+			t.Errorf("[%s] "+issuesShouldMatch, tt.description, issues, tt.expectedIssues)
+		}
+		if issues := PreloadableHeaderString(suggested); len(issues.Errors) > 0 {
+			t.Errorf("[%s] Suggested header is not preloadable: %v", tt.description, issues)
+		}
+	}
+}
+
+func TestSuggestHeaderString(t *testing.T) {
+	suggested, issues := SuggestHeaderString("max-age=86400; includeSubDomains; preload; foo")
+	if expected := "max-age=31536000; includeSubDomains; preload"; suggested != expected {
+		t.Errorf("SuggestHeaderString() = %q, want %q", suggested, expected)
+	}
+	expected := Issues{
+		Errors:   []Issue{{Code: "header.preloadable.max_age.below_1_year"}},
+		Warnings: []Issue{{Code: "header.parse.unknown_directive"}},
+	}
+	if !issues.Match(expected) {
+		t.Errorf(issuesShouldMatch, issues, expected)
+	}
+
+	// Parse issues come first.
+	_, issues = SuggestHeaderString("max-age=x; includeSubDomains; preload")
+	if len(issues.Errors) < 2 || !strings.HasPrefix(string(issues.Errors[0].Code), "header.parse.") {
+		t.Errorf("Expected a parse error before the other errors: %v", issues)
+	}
+}
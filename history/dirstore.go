@@ -0,0 +1,125 @@
+package history
+
+import (
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	dirStoreExtension = ".json"
+)
+
+// DirStore is a Store that keeps the records of each domain as a JSON list
+// in its own file in a directory.
+type DirStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewDirStore returns a DirStore using `dir`, creating the directory if it
+// does not exist.
+func NewDirStore(dir string) (*DirStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &DirStore{dir: dir}, nil
+}
+
+func (s *DirStore) path(domain string) string {
+	return filepath.Join(s.dir, url.PathEscape(strings.ToLower(domain))+dirStoreExtension)
+}
+
+// read must be called with s.mu held.
+func (s *DirStore) read(domain string) ([]Record, error) {
+	b, err := os.ReadFile(s.path(domain))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var records []Record
+	if err := json.Unmarshal(b, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// Add implements Store.
+func (s *DirStore) Add(r Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records, err := s.read(r.Domain)
+	if err != nil {
+		return err
+	}
+	records = append(records, r)
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Time.Before(records[j].Time)
+	})
+
+	b, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first, so that a crash cannot leave a
+	// truncated history behind.
+	tmp := s.path(r.Domain) + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path(r.Domain))
+}
+
+// Records implements Store.
+func (s *DirStore) Records(domain string, since time.Time) ([]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records, err := s.read(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	var filtered []Record
+	for _, r := range records {
+		if !r.Time.Before(since) {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered, nil
+}
+
+// Domains implements Store.
+func (s *DirStore) Domains() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var domains []string
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || !strings.HasSuffix(name, dirStoreExtension) {
+			continue
+		}
+		domain, err := url.PathUnescape(strings.TrimSuffix(name, dirStoreExtension))
+		if err != nil {
+			continue
+		}
+		domains = append(domains, domain)
+	}
+	return domains, nil
+}
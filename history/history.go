@@ -0,0 +1,223 @@
+// Package history records the outcome of preload checks over time, so that
+// changes in a domain's preload verdict can be detected.
+//
+// Storage is pluggable through the Store interface. NewDirStore() provides
+// an implementation that keeps one JSON file per domain in a directory;
+// other backends (e.g. a SQL database) can be provided by implementing
+// Store.
+package history
+
+import (
+	"time"
+
+	"github.com/chromium/hstspreload"
+)
+
+// A Verdict summarizes a set of Issues.
+type Verdict string
+
+const (
+	// VerdictPassed indicates that there were no errors or warnings.
+	VerdictPassed Verdict = "passed"
+	// VerdictWarnings indicates that there were warnings, but no errors.
+	VerdictWarnings Verdict = "warnings"
+	// VerdictErrors indicates that there was at least one error.
+	VerdictErrors Verdict = "errors"
+)
+
+// VerdictOf computes the Verdict for the given issues.
+func VerdictOf(issues hstspreload.Issues) Verdict {
+	switch {
+	case len(issues.Errors) > 0:
+		return VerdictErrors
+	case len(issues.Warnings) > 0:
+		return VerdictWarnings
+	default:
+		return VerdictPassed
+	}
+}
+
+// rank orders verdicts from best to worst.
+func (v Verdict) rank() int {
+	switch v {
+	case VerdictPassed:
+		return 0
+	case VerdictWarnings:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// A Record holds the outcome of a single check of a domain.
+type Record struct {
+	Domain  string             `json:"domain"`
+	Time    time.Time          `json:"time"`
+	Verdict Verdict            `json:"verdict"`
+	Header  string             `json:"header,omitempty"`
+	Issues  hstspreload.Issues `json:"issues"`
+}
+
+// NewRecord creates a Record for a check of `domain` at time `t`.
+// `header` may be `nil`, as returned by hstspreload.PreloadableDomain().
+func NewRecord(domain string, t time.Time, header *string, issues hstspreload.Issues) Record {
+	r := Record{
+		Domain:  domain,
+		Time:    t,
+		Verdict: VerdictOf(issues),
+		Issues:  issues,
+	}
+	if header != nil {
+		r.Header = *header
+	}
+	return r
+}
+
+// A Store persists Records.
+//
+// Implementations must be safe for concurrent use.
+type Store interface {
+	// Add records the outcome of a check.
+	Add(r Record) error
+	// Records returns all records for `domain` at or after `since`, in
+	// chronological order. A zero `since` returns all records.
+	Records(domain string, since time.Time) ([]Record, error)
+	// Domains returns all domains with at least one record.
+	Domains() ([]string, error)
+}
+
+// A Regression describes a domain whose verdict got worse between two
+// consecutive checks.
+type Regression struct {
+	Domain   string `json:"domain"`
+	Previous Record `json:"previous"`
+	Current  Record `json:"current"`
+	// Errors in Current with an IssueCode that did not occur in Previous.
+	NewErrors []hstspreload.Issue `json:"new_errors"`
+}
+
+// FindRegression compares the last two of the given chronologically
+// ordered records, and returns a Regression iff the verdict got worse.
+func FindRegression(records []Record) *Regression {
+	if len(records) < 2 {
+		return nil
+	}
+	previous := records[len(records)-2]
+	current := records[len(records)-1]
+	if current.Verdict.rank() <= previous.Verdict.rank() {
+		return nil
+	}
+
+	seen := make(map[hstspreload.IssueCode]bool)
+	for _, e := range previous.Issues.Errors {
+		seen[e.Code] = true
+	}
+	var newErrors []hstspreload.Issue
+	for _, e := range current.Issues.Errors {
+		if !seen[e.Code] {
+			newErrors = append(newErrors, e)
+		}
+	}
+
+	return &Regression{
+		Domain:    current.Domain,
+		Previous:  previous,
+		Current:   current,
+		NewErrors: newErrors,
+	}
+}
+
+// An IssueChange describes a domain whose issues changed between two
+// consecutive checks, e.g. between two runs of a batch scan.
+type IssueChange struct {
+	Domain   string `json:"domain"`
+	Previous Record `json:"previous"`
+	Current  Record `json:"current"`
+	// Codes of issues in Current that did not occur in Previous, and vice
+	// versa.
+	Added   []hstspreload.IssueCode `json:"added"`
+	Removed []hstspreload.IssueCode `json:"removed"`
+}
+
+// FindIssueChange compares the last two of the given chronologically
+// ordered records, and returns an IssueChange iff the set of issue codes or
+// the verdict changed.
+func FindIssueChange(records []Record) *IssueChange {
+	if len(records) < 2 {
+		return nil
+	}
+	previous := records[len(records)-2]
+	current := records[len(records)-1]
+
+	added := codesNotIn(current.Issues, previous.Issues)
+	removed := codesNotIn(previous.Issues, current.Issues)
+	if len(added) == 0 && len(removed) == 0 && current.Verdict == previous.Verdict {
+		return nil
+	}
+
+	return &IssueChange{
+		Domain:   current.Domain,
+		Previous: previous,
+		Current:  current,
+		Added:    added,
+		Removed:  removed,
+	}
+}
+
+// codesNotIn returns the codes of `issues` that don't occur in `other`.
+func codesNotIn(issues hstspreload.Issues, other hstspreload.Issues) []hstspreload.IssueCode {
+	codes := []hstspreload.IssueCode{}
+	for _, code := range issues.Codes() {
+		if !other.HasCode(code) {
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
+
+// IssueChanges returns an IssueChange for every one of `domains` whose
+// latest issues in `s` differ from the ones before them. Domains with fewer
+// than two records are skipped.
+func IssueChanges(s Store, domains []string) ([]IssueChange, error) {
+	changes := []IssueChange{}
+	for _, d := range domains {
+		records, err := s.Records(d, time.Time{})
+		if err != nil {
+			return nil, err
+		}
+		if c := FindIssueChange(records); c != nil {
+			changes = append(changes, *c)
+		}
+	}
+	return changes, nil
+}
+
+// Trend counts the verdicts of the given records.
+func Trend(records []Record) map[Verdict]int {
+	counts := make(map[Verdict]int)
+	for _, r := range records {
+		counts[r.Verdict]++
+	}
+	return counts
+}
+
+// Regressions returns a Regression for every domain in `s` whose latest
+// verdict is worse than the one before it.
+func Regressions(s Store) ([]Regression, error) {
+	domains, err := s.Domains()
+	if err != nil {
+		return nil, err
+	}
+
+	var regressions []Regression
+	for _, d := range domains {
+		records, err := s.Records(d, time.Time{})
+		if err != nil {
+			return nil, err
+		}
+		if r := FindRegression(records); r != nil {
+			regressions = append(regressions, *r)
+		}
+	}
+	return regressions, nil
+}
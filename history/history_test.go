@@ -0,0 +1,136 @@
+package history
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/chromium/hstspreload"
+)
+
+var (
+	day0 = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	day1 = day0.Add(24 * time.Hour)
+	day2 = day1.Add(24 * time.Hour)
+
+	passing = hstspreload.Issues{}
+	failing = hstspreload.Issues{Errors: []hstspreload.Issue{{Code: "response.no_header"}}}
+)
+
+func TestDirStore(t *testing.T) {
+	s, err := NewDirStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := "max-age=31536000; includeSubDomains; preload"
+	// Add out of order to check that records are kept sorted.
+	for _, r := range []Record{
+		NewRecord("example.com", day1, &header, passing),
+		NewRecord("example.com", day0, &header, passing),
+		NewRecord("example.com", day2, nil, failing),
+		NewRecord("example.org", day0, &header, passing),
+	} {
+		if err := s.Add(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	domains, err := s.Domains()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(domains) != 2 {
+		t.Errorf("Unexpected domains: %v", domains)
+	}
+
+	records, err := s.Records("example.com", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || !records[0].Time.Equal(day0) || !records[2].Time.Equal(day2) {
+		t.Errorf("Unexpected records: %v", records)
+	}
+	if records[0].Header != header || records[2].Header != "" {
+		t.Errorf("Unexpected headers: %v", records)
+	}
+
+	records, err = s.Records("example.com", day1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Errorf("Expected 2 records since day 1, got %d.", len(records))
+	}
+
+	trend := Trend(records)
+	if trend[VerdictPassed] != 1 || trend[VerdictErrors] != 1 {
+		t.Errorf("Unexpected trend: %v", trend)
+	}
+
+	regressions, err := Regressions(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(regressions) != 1 || regressions[0].Domain != "example.com" {
+		t.Fatalf("Unexpected regressions: %v", regressions)
+	}
+	if len(regressions[0].NewErrors) != 1 || regressions[0].NewErrors[0].Code != "response.no_header" {
+		t.Errorf("Unexpected new errors: %v", regressions[0].NewErrors)
+	}
+}
+
+func TestFindRegression(t *testing.T) {
+	warning := hstspreload.Issues{Warnings: []hstspreload.Issue{{Code: "header.parse.empty_directive"}}}
+
+	if FindRegression([]Record{NewRecord("example.com", day0, nil, failing)}) != nil {
+		t.Errorf("A single record cannot be a regression.")
+	}
+	if FindRegression([]Record{
+		NewRecord("example.com", day0, nil, failing),
+		NewRecord("example.com", day1, nil, warning),
+	}) != nil {
+		t.Errorf("An improvement should not be a regression.")
+	}
+	if FindRegression([]Record{
+		NewRecord("example.com", day0, nil, passing),
+		NewRecord("example.com", day1, nil, warning),
+	}) == nil {
+		t.Errorf("New warnings should be a regression.")
+	}
+}
+
+func TestIssueChanges(t *testing.T) {
+	warning := hstspreload.Issues{Warnings: []hstspreload.Issue{{Code: "header.parse.empty_directive"}}}
+	s, err := NewDirStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []Record{
+		NewRecord("changed.example", day0, nil, failing),
+		NewRecord("changed.example", day1, nil, warning),
+		NewRecord("unchanged.example", day0, nil, failing),
+		NewRecord("unchanged.example", day1, nil, failing),
+		NewRecord("new.example", day1, nil, failing),
+	} {
+		if err := s.Add(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	changes, err := IssueChanges(s, []string{"changed.example", "unchanged.example", "new.example"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Domain != "changed.example" {
+		t.Fatalf("Unexpected changes: %v", changes)
+	}
+	c := changes[0]
+	if !reflect.DeepEqual(c.Added, []hstspreload.IssueCode{"header.parse.empty_directive"}) ||
+		!reflect.DeepEqual(c.Removed, []hstspreload.IssueCode{"response.no_header"}) {
+		t.Errorf("Unexpected codes: added %v, removed %v", c.Added, c.Removed)
+	}
+	if c.Previous.Verdict != VerdictErrors || c.Current.Verdict != VerdictWarnings {
+		t.Errorf("Unexpected verdicts: %s, %s", c.Previous.Verdict, c.Current.Verdict)
+	}
+}
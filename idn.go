@@ -0,0 +1,135 @@
+package hstspreload
+
+import (
+	"net"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// UnicodeDomain returns the Unicode display form of `domain`, converting
+// any punycode (`xn--`) labels. If `domain` does not contain valid punycode,
+// it is returned unchanged.
+func UnicodeDomain(domain string) string {
+	u, err := idna.Display.ToUnicode(domain)
+	if err != nil {
+		return domain
+	}
+	return u
+}
+
+// DisplayDomain formats `domain` for messages. If the domain contains
+// punycode labels, the Unicode form is added in parentheses so that IDN
+// operators can recognize their domain, e.g.
+// "xn--bcher-kva.example (bücher.example)".
+func DisplayDomain(domain string) string {
+	if u := UnicodeDomain(domain); !strings.EqualFold(u, domain) {
+		return domain + " (" + u + ")"
+	}
+	return domain
+}
+
+// displayURL is like DisplayDomain(), but for the host of a URL, e.g.
+// "https://xn--bcher-kva.example (https://bücher.example)".
+func displayURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	host := u.Hostname()
+	if unicode := UnicodeDomain(host); !strings.EqualFold(unicode, host) {
+		return rawURL + " (" + strings.Replace(rawURL, host, unicode, 1) + ")"
+	}
+	return rawURL
+}
+
+// toASCIIDomain converts each Unicode label of `domain` to punycode using
+// IDNA, so that internationalized domains can be checked like ASCII ones.
+// ASCII labels are kept unchanged.
+//
+// Iff `issues` has no errors, `ascii` contains the converted domain.
+func toASCIIDomain(domain string) (ascii string, issues Issues) {
+	issues = Issues{}
+
+	labels := strings.Split(domain, ".")
+	for i, label := range labels {
+		if isASCII(label) {
+			continue
+		}
+		asciiLabel, err := idna.Lookup.ToASCII(label)
+		if err != nil {
+			return "", issues.addErrorWithParamsf(
+				IssueCode("domain.format.invalid_idna_label"),
+				"Invalid internationalized domain",
+				map[string]string{"label": label},
+				"The label `%s` of `%s` is not a valid internationalized domain label (%s).",
+				label,
+				domain,
+				err,
+			)
+		}
+		labels[i] = asciiLabel
+	}
+	return strings.Join(labels, "."), issues
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// CanonicalDomain converts messy user input (e.g. a URL pasted into a form)
+// into the ASCII domain that the checks expect: it strips the scheme,
+// userinfo, port, path, query, fragment, and trailing dots, lowercases the
+// domain, and converts Unicode labels to punycode using IDNA.
+//
+// Iff `issues` has no errors, `domain` contains the canonical domain.
+// CanonicalDomain does not check the domain for other problems (see
+// PreloadableDomain()).
+func CanonicalDomain(input string) (domain string, issues Issues) {
+	issues = Issues{}
+
+	host := strings.TrimSpace(input)
+	if i := strings.Index(host, "://"); i >= 0 {
+		host = host[i+3:]
+	}
+	if i := strings.IndexAny(host, "/?#"); i >= 0 {
+		host = host[:i]
+	}
+	if i := strings.LastIndex(host, "@"); i >= 0 {
+		host = host[i+1:]
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimRight(host, ".")
+
+	if host == "" {
+		return "", issues.addErrorWithParamsf(
+			IssueCode("domain.canonical.empty"),
+			"No domain",
+			map[string]string{"input": input},
+			"`%s` does not contain a domain.",
+			input,
+		)
+	}
+
+	ascii, err := idna.Lookup.ToASCII(strings.ToLower(host))
+	if err != nil {
+		return "", issues.addErrorWithParamsf(
+			IssueCode("domain.canonical.invalid_idna"),
+			"Invalid internationalized domain",
+			map[string]string{"input": input, "host": host},
+			"`%s` is not a valid domain name (%s).",
+			host,
+			err,
+		)
+	}
+
+	return ascii, issues
+}
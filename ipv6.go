@@ -0,0 +1,109 @@
+package hstspreload
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+// ipv6Available returns whether this machine can make IPv6 connections.
+// Without IPv6 connectivity, we cannot tell whether a site misbehaves over
+// IPv6.
+func ipv6Available() bool {
+	// Dialing UDP does not send any packets, but fails if there is no
+	// route.
+	conn, err := net.Dial("udp6", "[2001:4860:4860::8888]:53")
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// checkIPv6 checks that `domain` behaves the same over IPv6 as in
+// `apexResp`, which may have been received over IPv4. Many sites are
+// preloaded based on their IPv4 behavior, but break IPv6-only clients.
+//
+// The check is skipped if the domain has no AAAA records, if we cannot make
+// IPv6 connections, or if `c` does not connect to the domain directly.
+//
+// `c` may be nil.
+func (c *Checker) checkIPv6(ctx context.Context, domain string, apexResp *http.Response) Issues {
+	if c.resolvesRemotely(domain) || (c != nil && len(c.ConnectTo) > 0) {
+		return Issues{}
+	}
+
+	resolver := c.netDialer().Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	lookupCtx, cancel := context.WithTimeout(ctx, c.timeout())
+	ips, err := resolver.LookupIP(lookupCtx, "ip6", domain)
+	cancel()
+	if err != nil || len(ips) == 0 || !ipv6Available() {
+		return Issues{}
+	}
+
+	return c.checkIPv6Address(ctx, domain, ips[0].String(), apexResp)
+}
+
+// checkIPv6Address checks the IPv6 `address` of `domain` (see checkIPv6()).
+// `c` may be nil.
+func (c *Checker) checkIPv6Address(ctx context.Context, domain string, address string, apexResp *http.Response) Issues {
+	issues := Issues{}
+
+	resp, respIssues := c.getAddressResponse(ctx, domain, address)
+	if len(respIssues.Errors) > 0 {
+		return issues.addWarningWithParamsf(
+			IssueCode("domain.ipv6.cannot_connect"),
+			"Cannot connect over IPv6",
+			map[string]string{"address": address},
+			"The domain has the IPv6 address %s, but we cannot connect to it: %s "+
+				"Clients that only have IPv6 connectivity will not be able to visit the site.",
+			address,
+			respIssues.Errors[0].Message,
+		)
+	}
+	defer resp.Body.Close()
+
+	if chainIssues := checkChain(*resp.TLS); len(chainIssues.Errors) > 0 {
+		issues = issues.addWarningWithParamsf(
+			IssueCode("domain.ipv6.tls"),
+			"Different TLS configuration over IPv6",
+			map[string]string{"address": address},
+			"The IPv6 address %s serves a certificate chain with problems: %s",
+			address,
+			chainIssues.Errors[0].Message,
+		)
+	}
+
+	apexHeaders := apexResp.Header.Values(hstsHeaderName)
+	ipv6Headers := resp.Header.Values(hstsHeaderName)
+	switch {
+	case len(apexHeaders) != 1:
+		// Already reported by the main header check.
+	case len(ipv6Headers) != 1:
+		issues = issues.addWarningWithParamsf(
+			IssueCode("domain.ipv6.no_header"),
+			"No single HSTS header over IPv6",
+			map[string]string{"address": address, "header_count": strconv.Itoa(len(ipv6Headers)), "apex_header": apexHeaders[0]},
+			"The IPv6 address %s sent %d HSTS headers, but the domain sends `%s` otherwise.",
+			address,
+			len(ipv6Headers),
+			apexHeaders[0],
+		)
+	case ipv6Headers[0] != apexHeaders[0]:
+		issues = issues.addWarningWithParamsf(
+			IssueCode("domain.ipv6.inconsistent_header"),
+			"Different HSTS header over IPv6",
+			map[string]string{"address": address, "header": ipv6Headers[0], "apex_header": apexHeaders[0]},
+			"The IPv6 address %s sends the HSTS header `%s`, but the domain sends `%s` otherwise.",
+			address,
+			ipv6Headers[0],
+			apexHeaders[0],
+		)
+	}
+
+	return issues
+}
@@ -0,0 +1,76 @@
+package hstspreload
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const ipv6ApexHeader = "max-age=31536000; includeSubDomains; preload"
+
+var checkIPv6AddressTests = []struct {
+	description    string
+	ipv6Headers    []string
+	expectedIssues Issues
+}{
+	{
+		"same header",
+		[]string{ipv6ApexHeader},
+		Issues{},
+	},
+	{
+		"different header",
+		[]string{"max-age=300"},
+		Issues{Warnings: []Issue{{Code: "domain.ipv6.inconsistent_header"}}},
+	},
+	{
+		"no header",
+		nil,
+		Issues{Warnings: []Issue{{Code: "domain.ipv6.no_header"}}},
+	},
+}
+
+func TestCheckIPv6Address(t *testing.T) {
+	apexResp := &http.Response{Header: http.Header{}}
+	apexResp.Header.Set("Strict-Transport-Security", ipv6ApexHeader)
+
+	for _, tt := range checkIPv6AddressTests {
+		srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, h := range tt.ipv6Headers {
+				w.Header().Add("Strict-Transport-Security", h)
+			}
+		}))
+
+		// Pretend that the IPv6 loopback address is the test server.
+		c := &Checker{
+			ConnectTo: map[string]string{"[::1]:443": srv.Listener.Addr().String()},
+			Transport: srv.Client().Transport.(*http.Transport),
+		}
+		issues := c.checkIPv6Address(context.Background(), "example.com", "::1", apexResp)
+		srv.Close()
+
+		if !issues.Match(tt.expectedIssues) {
+			t.Errorf("[%s] "+issuesShouldMatch, tt.description, issues, tt.expectedIssues)
+		}
+	}
+}
+
+func TestCheckIPv6AddressCannotConnect(t *testing.T) {
+	c := &Checker{
+		ConnectTo:               map[string]string{"[::1]:443": "127.0.0.1:0"},
+		DisableInsecureFallback: true,
+	}
+	issues := c.checkIPv6Address(context.Background(), "example.com", "::1", &http.Response{})
+	expected := Issues{Warnings: []Issue{{Code: "domain.ipv6.cannot_connect"}}}
+	if !issues.Match(expected) {
+		t.Errorf(issuesShouldMatch, issues, expected)
+	}
+}
+
+func TestCheckIPv6Skipped(t *testing.T) {
+	c := &Checker{ConnectTo: map[string]string{"example.com": "192.0.2.1"}}
+	if issues := c.checkIPv6(context.Background(), "example.com", &http.Response{}); !issues.Match(Issues{}) {
+		t.Errorf("IPv6 checks should be skipped with ConnectTo: "+issuesShouldBeEmpty, issues)
+	}
+}
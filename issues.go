@@ -3,6 +3,7 @@ package hstspreload
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
 )
 
 // An IssueCode is a string identifier for an Issue.
@@ -21,6 +22,13 @@ type Issue struct {
 	Summary string `json:"summary"`
 	// A detailed explanation with instructions for fixing.
 	Message string `json:"message"`
+	// Optional structured values related to the issue (e.g. the hosts
+	// mentioned in Message), so that other programs don't have to parse
+	// Message. The available keys depend on Code, e.g. "max_age" (in
+	// decimal seconds) or "url" and "redirect_url". Unlike in Message,
+	// values that come from the checked site are not escaped, so frontends
+	// must escape them when rendering.
+	Params map[string]string `json:"params,omitempty"`
 }
 
 // The Issues struct encapsulates a set of errors and warnings.
@@ -46,21 +54,37 @@ type Issues struct {
 }
 
 func (iss Issues) addErrorf(code IssueCode, summary string, format string, args ...interface{}) Issues {
-	formattedError := fmt.Sprintf(format, args...)
+	formattedError := fmt.Sprintf(format, sanitizeArgs(args)...)
 	return Issues{
-		Errors:   append(iss.Errors, Issue{code, summary, formattedError}),
+		Errors:   append(iss.Errors, Issue{Code: code, Summary: summary, Message: formattedError}),
 		Warnings: iss.Warnings,
 	}
 }
 
 func (iss Issues) addWarningf(code IssueCode, summary string, format string, args ...interface{}) Issues {
-	formattedWarning := fmt.Sprintf(format, args...)
+	formattedWarning := fmt.Sprintf(format, sanitizeArgs(args)...)
 	return Issues{
 		Errors:   iss.Errors,
-		Warnings: append(iss.Warnings, Issue{code, summary, formattedWarning}),
+		Warnings: append(iss.Warnings, Issue{Code: code, Summary: summary, Message: formattedWarning}),
 	}
 }
 
+// addErrorWithParamsf is like addErrorf, but also sets the Params of the
+// new error.
+func (iss Issues) addErrorWithParamsf(code IssueCode, summary string, params map[string]string, format string, args ...interface{}) Issues {
+	iss = iss.addErrorf(code, summary, format, args...)
+	iss.Errors[len(iss.Errors)-1].Params = params
+	return iss
+}
+
+// addWarningWithParamsf is like addWarningf, but also sets the Params of
+// the new warning.
+func (iss Issues) addWarningWithParamsf(code IssueCode, summary string, params map[string]string, format string, args ...interface{}) Issues {
+	iss = iss.addWarningf(code, summary, format, args...)
+	iss.Warnings[len(iss.Warnings)-1].Params = params
+	return iss
+}
+
 func (iss Issues) addUniqueErrorf(code IssueCode, summary string, format string, args ...interface{}) Issues {
 	for _, err := range iss.Errors {
 		if err.Code == code {
@@ -79,11 +103,97 @@ func (iss Issues) addUniqueWarningf(code IssueCode, summary string, format strin
 	return iss.addWarningf(code, summary, format, args...)
 }
 
+// addUniqueErrorWithParamsf is like addUniqueErrorf, but also sets the
+// Params of the new error.
+func (iss Issues) addUniqueErrorWithParamsf(code IssueCode, summary string, params map[string]string, format string, args ...interface{}) Issues {
+	for _, err := range iss.Errors {
+		if err.Code == code {
+			return iss
+		}
+	}
+	return iss.addErrorWithParamsf(code, summary, params, format, args...)
+}
+
+// addUniqueWarningWithParamsf is like addUniqueWarningf, but also sets the
+// Params of the new warning.
+func (iss Issues) addUniqueWarningWithParamsf(code IssueCode, summary string, params map[string]string, format string, args ...interface{}) Issues {
+	for _, warning := range iss.Warnings {
+		if warning.Code == code {
+			return iss
+		}
+	}
+	return iss.addWarningWithParamsf(code, summary, params, format, args...)
+}
+
+// combineIssues concatenates the errors and warnings of two sets of issues.
+// The result never shares memory with the inputs, so that combining the same
+// issues with different sets (e.g. from concurrent checks) is safe.
 func combineIssues(issues1 Issues, issues2 Issues) Issues {
 	return Issues{
-		Errors:   append(issues1.Errors, issues2.Errors...),
-		Warnings: append(issues1.Warnings, issues2.Warnings...),
+		Errors:   concatIssues(issues1.Errors, issues2.Errors),
+		Warnings: concatIssues(issues1.Warnings, issues2.Warnings),
+	}
+}
+
+// concatIssues returns a new slice, or nil if both lists are empty (so that
+// an empty Issues{} stays unchanged).
+func concatIssues(list1 []Issue, list2 []Issue) []Issue {
+	if len(list1) == 0 && len(list2) == 0 {
+		return nil
+	}
+	return append(append(make([]Issue, 0, len(list1)+len(list2)), list1...), list2...)
+}
+
+// Sorted returns a copy of `iss` in canonical order, so that results can be
+// compared across runs. Errors and warnings are each sorted by section (in
+// the order of the fields of CheckSections), then by code. Issues with the
+// same code keep their relative order.
+func (iss Issues) Sorted() Issues {
+	sorted := Issues{
+		Errors:   concatIssues(nil, iss.Errors),
+		Warnings: concatIssues(nil, iss.Warnings),
+	}
+	sortIssues(sorted.Errors)
+	sortIssues(sorted.Warnings)
+	return sorted
+}
+
+func sortIssues(list []Issue) {
+	sort.SliceStable(list, func(i, j int) bool {
+		si, sj := sectionOf(list[i].Code), sectionOf(list[j].Code)
+		if si != sj {
+			return si < sj
+		}
+		return list[i].Code < list[j].Code
+	})
+}
+
+// HasCode returns whether `iss` contains an error or a warning with `code`.
+func (iss Issues) HasCode(code IssueCode) bool {
+	for _, list := range [][]Issue{iss.Errors, iss.Warnings} {
+		for _, i := range list {
+			if i.Code == code {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Codes returns the codes of the errors followed by the codes of the
+// warnings in `iss`, without duplicates.
+func (iss Issues) Codes() []IssueCode {
+	codes := []IssueCode{}
+	seen := make(map[IssueCode]bool)
+	for _, list := range [][]Issue{iss.Errors, iss.Warnings} {
+		for _, i := range list {
+			if !seen[i.Code] {
+				seen[i.Code] = true
+				codes = append(codes, i.Code)
+			}
+		}
 	}
+	return codes
 }
 
 // Match checks that the given issues match the `wanted` ones. This
@@ -1,6 +1,9 @@
 package hstspreload
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+)
 
 const (
 	issuesShouldMatch = `Issues should match expected.
@@ -212,3 +215,75 @@ func TestAddUniqueWarningf(t *testing.T) {
 		t.Errorf(issuesShouldMatch, iss, expected)
 	}
 }
+
+func TestSorted(t *testing.T) {
+	issues := Issues{
+		Errors: []Issue{
+			{Code: "redirects.http.no_redirect"},
+			{Code: "response.no_header"},
+			{Code: "example_org.caa.missing"},
+			{Code: "domain.tls.sha1", Message: "first"},
+			{Code: "domain.is_subdomain"},
+			{Code: "domain.tls.sha1", Message: "second"},
+		},
+		Warnings: []Issue{
+			{Code: "tls.obsolete_cipher_suite"},
+			{Code: "domain.tls.cannot_connect"},
+		},
+	}
+	expected := Issues{
+		Errors: []Issue{
+			{Code: "domain.is_subdomain"},
+			{Code: "domain.tls.sha1", Message: "first"},
+			{Code: "domain.tls.sha1", Message: "second"},
+			{Code: "response.no_header"},
+			{Code: "redirects.http.no_redirect"},
+			{Code: "example_org.caa.missing"},
+		},
+		Warnings: []Issue{
+			{Code: "domain.tls.cannot_connect"},
+			{Code: "tls.obsolete_cipher_suite"},
+		},
+	}
+
+	if sorted := issues.Sorted(); !sorted.Match(expected) {
+		t.Errorf(issuesShouldMatch, sorted, expected)
+	}
+	if issues.Errors[0].Code != "redirects.http.no_redirect" {
+		t.Errorf("Sorted() should not modify the original issues.")
+	}
+}
+
+func TestCombineIssuesDoesNotAlias(t *testing.T) {
+	base := Issues{Errors: make([]Issue, 1, 10)}
+	a := combineIssues(base, Issues{Errors: []Issue{{Code: "a"}}})
+	b := combineIssues(base, Issues{Errors: []Issue{{Code: "b"}}})
+	if a.Errors[1].Code != "a" || b.Errors[1].Code != "b" {
+		t.Errorf("Combined issues should not share memory: %v, %v", a, b)
+	}
+	if empty := combineIssues(Issues{}, Issues{}); empty.Errors != nil || empty.Warnings != nil {
+		t.Errorf("Combining empty issues should stay empty: %#v", empty)
+	}
+}
+
+func TestIssuesCodes(t *testing.T) {
+	issues := Issues{
+		Errors:   []Issue{{Code: "response.no_header"}, {Code: "domain.tls.sha1"}},
+		Warnings: []Issue{{Code: "tls.obsolete_cipher_suite"}, {Code: "response.no_header"}},
+	}
+
+	expected := []IssueCode{"response.no_header", "domain.tls.sha1", "tls.obsolete_cipher_suite"}
+	if codes := issues.Codes(); !reflect.DeepEqual(codes, expected) {
+		t.Errorf("Expected %v, got %v", expected, codes)
+	}
+	if codes := (Issues{}).Codes(); codes == nil || len(codes) != 0 {
+		t.Errorf("Expected no codes, got %#v", codes)
+	}
+
+	if !issues.HasCode("tls.obsolete_cipher_suite") || !issues.HasCode("domain.tls.sha1") {
+		t.Errorf("HasCode() should find errors and warnings.")
+	}
+	if issues.HasCode("domain.www.no_tls") {
+		t.Errorf("HasCode() should not find missing codes.")
+	}
+}
@@ -0,0 +1,34 @@
+package hstspreload
+
+import (
+	"crypto/tls"
+	"net/http"
+)
+
+// A Logger receives debug traces from checks (see Checker.Logger), e.g.
+// the URLs that are fetched, redirect hops, the TLS versions that are
+// negotiated, and retries. *log.Logger implements it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// logf writes a debug trace to c.Logger, if set. `c` may be nil.
+func (c *Checker) logf(format string, v ...interface{}) {
+	if c == nil || c.Logger == nil {
+		return
+	}
+	c.Logger.Printf(format, v...)
+}
+
+// logResponse traces the outcome of a request for `url`. `c` may be nil.
+func (c *Checker) logResponse(url string, resp *http.Response, err error) {
+	switch {
+	case err != nil:
+		c.logf("GET %s: %s", url, err)
+	case resp.TLS != nil:
+		c.logf("GET %s: %s (%s, %s, %s)", url, resp.Status, resp.Proto,
+			tlsVersionName(resp.TLS.Version), tls.CipherSuiteName(resp.TLS.CipherSuite))
+	default:
+		c.logf("GET %s: %s (%s)", url, resp.Status, resp.Proto)
+	}
+}
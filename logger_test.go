@@ -0,0 +1,64 @@
+package hstspreload
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordingLogger records the traces of concurrent checks.
+type recordingLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *recordingLogger) Printf(format string, v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func TestCheckerLogger(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			http.Redirect(w, r, "/final", http.StatusMovedPermanently)
+			return
+		}
+		w.Header().Set("Strict-Transport-Security", "max-age=31536000; includeSubDomains; preload")
+	}))
+	defer srv.Close()
+
+	logger := &recordingLogger{}
+	c := &Checker{
+		HTTPSPort: srv.Listener.Addr().(*net.TCPAddr).Port,
+		ConnectTo: map[string]string{"example.com": "127.0.0.1"},
+		Transport: srv.Client().Transport.(*http.Transport),
+		Logger:    logger,
+	}
+	url := c.httpsURL("example.com")
+	if _, issues := preloadableRedirects(context.Background(), url, c); len(issues.Errors) > 0 {
+		t.Fatalf("Unexpected errors: %v", issues.Errors)
+	}
+
+	// The cipher suite depends on the hardware, so only compare prefixes.
+	expected := []string{
+		"GET " + url + ": 301 Moved Permanently (HTTP/1.1, TLS 1.3, ",
+		"Redirect 1 from " + url + " to " + url + "/final",
+		"GET " + url + "/final: 200 OK (HTTP/1.1, TLS 1.3, ",
+	}
+	ok := len(logger.lines) == len(expected)
+	for i := 0; ok && i < len(expected); i++ {
+		ok = strings.HasPrefix(logger.lines[i], expected[i])
+	}
+	if !ok {
+		t.Errorf("Unexpected traces:\n%s", strings.Join(logger.lines, "\n"))
+	}
+
+	// A nil Checker doesn't log.
+	(*Checker)(nil).logf("unused")
+}
@@ -0,0 +1,86 @@
+package hstspreload
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+)
+
+// A MessageTemplate replaces the summary and/or message of an issue, e.g.
+// to point remediation text at an organization's own documentation.
+//
+// Each field is a text/template that is executed with the original Issue
+// as its data, e.g. "{{.Message}} See https://wiki.example/hsts#{{.Code}}".
+// Empty fields keep the original text.
+type MessageTemplate struct {
+	Summary string `json:"summary,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// MessageTemplates replaces the text of issues with specific codes. Issues
+// with codes that are not in the map keep their original text.
+type MessageTemplates map[IssueCode]MessageTemplate
+
+// ParseMessageTemplates reads MessageTemplates from a JSON object that maps
+// issue codes to templates, e.g.:
+//
+//	{"header.preloadable.max_age.zero": {"message": "See https://wiki.example/hsts-removal"}}
+func ParseMessageTemplates(r io.Reader) (MessageTemplates, error) {
+	var t MessageTemplates
+	if err := json.NewDecoder(r).Decode(&t); err != nil {
+		return nil, err
+	}
+	for code, mt := range t {
+		for _, text := range []string{mt.Summary, mt.Message} {
+			if _, err := template.New(string(code)).Parse(text); err != nil {
+				return nil, fmt.Errorf("invalid template for issue code %q: %s", code, err)
+			}
+		}
+	}
+	return t, nil
+}
+
+// ApplyMessageTemplates returns a copy of `iss` in which the text of issues
+// is replaced according to `t`. If a template cannot be executed, the
+// original text is kept.
+//
+// Like the original messages, the results are escaped with
+// EscapeControlCharacters(), since templates may include issue params.
+func (iss Issues) ApplyMessageTemplates(t MessageTemplates) Issues {
+	apply := func(list []Issue) []Issue {
+		var result []Issue
+		for _, issue := range list {
+			if mt, ok := t[issue.Code]; ok {
+				original := issue
+				issue.Summary = executeMessageTemplate(mt.Summary, original, original.Summary)
+				issue.Message = executeMessageTemplate(mt.Message, original, original.Message)
+			}
+			result = append(result, issue)
+		}
+		return result
+	}
+
+	return Issues{
+		Errors:   apply(iss.Errors),
+		Warnings: apply(iss.Warnings),
+	}
+}
+
+// executeMessageTemplate executes `text` with `issue` as its data, and
+// returns `fallback` if `text` is empty or fails.
+func executeMessageTemplate(text string, issue Issue, fallback string) string {
+	if text == "" {
+		return fallback
+	}
+	tmpl, err := template.New(string(issue.Code)).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return fallback
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, issue); err != nil {
+		return fallback
+	}
+	return EscapeControlCharacters(sb.String())
+}
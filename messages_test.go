@@ -0,0 +1,89 @@
+package hstspreload
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApplyMessageTemplates(t *testing.T) {
+	issues := Issues{
+		Errors: []Issue{
+			{Code: "header.preloadable.max_age.zero", Summary: "Max-age is 0", Message: "Original message."},
+			{Code: "response.no_header", Summary: "No HSTS header", Message: "No header."},
+		},
+		Warnings: []Issue{
+			{Code: "domain.tls.cert.expires_soon", Summary: "Certificate expires soon", Message: "Expires.",
+				Params: map[string]string{"common_name": "example.com\x1b[2J"}},
+		},
+	}
+
+	templates := MessageTemplates{
+		"header.preloadable.max_age.zero": {Message: "{{.Message}} See https://wiki.example/hsts#{{.Code}}"},
+		"domain.tls.cert.expires_soon": {
+			Summary: "Renew {{.Params.common_name}}",
+			Message: "{{.Params.missing}}Renew it.",
+		},
+		"response.multiple_headers": {Message: "Not used."},
+	}
+
+	applied := issues.ApplyMessageTemplates(templates)
+	expected := Issues{
+		Errors: []Issue{
+			{
+				Code:    "header.preloadable.max_age.zero",
+				Summary: "Max-age is 0",
+				Message: "Original message. See https://wiki.example/hsts#header.preloadable.max_age.zero",
+			},
+			{Code: "response.no_header", Summary: "No HSTS header", Message: "No header."},
+		},
+		Warnings: []Issue{
+			{Code: "domain.tls.cert.expires_soon", Summary: `Renew example.com\x1b[2J`, Message: "Renew it."},
+		},
+	}
+	if !applied.Match(expected) {
+		t.Errorf(issuesShouldMatch, applied, expected)
+	}
+
+	if issues.Errors[0].Message != "Original message." {
+		t.Errorf("The original issues should not be modified.")
+	}
+}
+
+func TestApplyMessageTemplatesExecutionError(t *testing.T) {
+	issues := Issues{Errors: []Issue{{Code: "response.no_header", Message: "No header."}}}
+	applied := issues.ApplyMessageTemplates(MessageTemplates{
+		"response.no_header": {Message: "{{.Unknown}}"},
+	})
+	if applied.Errors[0].Message != "No header." {
+		t.Errorf("A failing template should keep the original message: %q", applied.Errors[0].Message)
+	}
+}
+
+func TestParseMessageTemplates(t *testing.T) {
+	mt, err := ParseMessageTemplates(strings.NewReader(`{"response.no_header": {"message": "{{.Message}} See the runbook."}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mt) != 1 || mt["response.no_header"].Message != "{{.Message}} See the runbook." || mt["response.no_header"].Summary != "" {
+		t.Errorf("Unexpected templates: %#v", mt)
+	}
+
+	if _, err := ParseMessageTemplates(strings.NewReader(`{"response.no_header": {"message": "{{.Message"}}`)); err == nil {
+		t.Errorf("Expected an error for an invalid template.")
+	}
+	if _, err := ParseMessageTemplates(strings.NewReader(`[]`)); err == nil {
+		t.Errorf("Expected an error for invalid JSON.")
+	}
+}
+
+func TestCheckerMessageTemplates(t *testing.T) {
+	c := &Checker{
+		SeverityOverrides: SeverityOverrides{"response.no_header": SeverityWarning},
+		MessageTemplates:  MessageTemplates{"response.no_header": {Message: "Custom."}},
+	}
+	issues := c.applyPolicy(Issues{Errors: []Issue{{Code: "response.no_header", Message: "No header."}}})
+	expected := Issues{Warnings: []Issue{{Code: "response.no_header", Message: "Custom."}}}
+	if !issues.Match(expected) {
+		t.Errorf(issuesShouldMatch, issues, expected)
+	}
+}
@@ -0,0 +1,87 @@
+// Package notify sends notifications when the preload state of a domain
+// changes, e.g. when its verdict changes between two checks or when its
+// preload list entry is added or removed.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/chromium/hstspreload/history"
+)
+
+// An EventKind identifies the kind of change that an Event describes.
+type EventKind string
+
+const (
+	// VerdictChanged indicates that the verdict for a domain changed
+	// between two checks.
+	VerdictChanged EventKind = "verdict_changed"
+	// EntryAdded indicates that a domain was added to the preload list.
+	EntryAdded EventKind = "preload_list.entry_added"
+	// EntryRemoved indicates that a domain was removed from the preload list.
+	EntryRemoved EventKind = "preload_list.entry_removed"
+)
+
+// An Event describes a change that should be reported.
+type Event struct {
+	Kind   EventKind `json:"kind"`
+	Domain string    `json:"domain"`
+	Time   time.Time `json:"time"`
+	// Previous and Current are only set for VerdictChanged events.
+	Previous *history.Record `json:"previous,omitempty"`
+	Current  *history.Record `json:"current,omitempty"`
+}
+
+// VerdictChangedEvent creates a VerdictChanged event from two consecutive
+// records for the same domain.
+func VerdictChangedEvent(previous, current history.Record) Event {
+	return Event{
+		Kind:     VerdictChanged,
+		Domain:   current.Domain,
+		Time:     current.Time,
+		Previous: &previous,
+		Current:  &current,
+	}
+}
+
+// String returns a short, human-readable description of the event.
+func (e Event) String() string {
+	switch e.Kind {
+	case VerdictChanged:
+		if e.Previous != nil && e.Current != nil {
+			return fmt.Sprintf("%s: preload verdict changed from %s to %s",
+				e.Domain, e.Previous.Verdict, e.Current.Verdict)
+		}
+	case EntryAdded:
+		return fmt.Sprintf("%s: added to the preload list", e.Domain)
+	case EntryRemoved:
+		return fmt.Sprintf("%s: removed from the preload list", e.Domain)
+	}
+	return fmt.Sprintf("%s: %s", e.Domain, e.Kind)
+}
+
+// A Notifier delivers events to some destination.
+type Notifier interface {
+	Notify(ctx context.Context, e Event) error
+}
+
+// multi is a Notifier that notifies all of its notifiers.
+type multi []Notifier
+
+// Multi returns a Notifier that delivers each event to all of the given
+// notifiers. All notifiers are attempted, and the first error is returned.
+func Multi(notifiers ...Notifier) Notifier {
+	return multi(notifiers)
+}
+
+func (m multi) Notify(ctx context.Context, e Event) error {
+	var firstErr error
+	for _, n := range m {
+		if err := n.Notify(ctx, e); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
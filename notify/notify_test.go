@@ -0,0 +1,102 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chromium/hstspreload"
+	"github.com/chromium/hstspreload/history"
+)
+
+var (
+	previous = history.NewRecord("example.com", time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), nil, hstspreload.Issues{})
+	current  = history.NewRecord("example.com", time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC), nil,
+		hstspreload.Issues{Errors: []hstspreload.Issue{{Code: "response.no_header"}}})
+)
+
+func TestEventString(t *testing.T) {
+	for _, tt := range []struct {
+		event    Event
+		expected string
+	}{
+		{VerdictChangedEvent(previous, current), "example.com: preload verdict changed from passed to errors"},
+		{Event{Kind: EntryAdded, Domain: "example.com"}, "example.com: added to the preload list"},
+		{Event{Kind: EntryRemoved, Domain: "example.com"}, "example.com: removed from the preload list"},
+	} {
+		if s := tt.event.String(); s != tt.expected {
+			t.Errorf("Unexpected event string: %q", s)
+		}
+	}
+}
+
+func TestWebhookAndSlack(t *testing.T) {
+	var bodies []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		bodies = append(bodies, body)
+	}))
+	defer srv.Close()
+
+	n := Multi(Webhook{URL: srv.URL}, Slack{WebhookURL: srv.URL})
+	if err := n.Notify(context.Background(), VerdictChangedEvent(previous, current)); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(bodies) != 2 {
+		t.Fatalf("Expected 2 requests, got %d.", len(bodies))
+	}
+	if bodies[0]["kind"] != string(VerdictChanged) || bodies[0]["domain"] != "example.com" {
+		t.Errorf("Unexpected webhook body: %v", bodies[0])
+	}
+	if bodies[1]["text"] != "example.com: preload verdict changed from passed to errors" {
+		t.Errorf("Unexpected Slack body: %v", bodies[1])
+	}
+}
+
+func TestWebhookErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	err := Webhook{URL: srv.URL}.Notify(context.Background(), Event{Kind: EntryAdded, Domain: "example.com"})
+	if err == nil || !strings.Contains(err.Error(), "500") {
+		t.Errorf("Expected a status code error, got %v", err)
+	}
+}
+
+func TestSMTPMessage(t *testing.T) {
+	s := SMTP{From: "hsts@example.com", To: []string{"a@example.com", "b@example.com"}}
+	msg, err := s.message(Event{Kind: EntryRemoved, Domain: "example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"To: a@example.com, b@example.com\r\n",
+		"Subject: [hstspreload] example.com: removed from the preload list\r\n",
+	} {
+		if !strings.Contains(string(msg), want) {
+			t.Errorf("Message does not contain %q:\n%s", want, msg)
+		}
+	}
+}
+
+func TestSMTPSubject(t *testing.T) {
+	for domain, expected := range map[string]string{
+		"example.com":                "[hstspreload] example.com: added to the preload list",
+		"example.com\r\nBcc: x@evil": "[hstspreload] example.com  Bcc: x@evil: added to the preload list",
+		"bücher.example":             "=?UTF-8?q?[hstspreload]_b=C3=BCcher.example:_added_to_the_preload_list?=",
+	} {
+		if s := subject(Event{Kind: EntryAdded, Domain: domain}); s != expected {
+			t.Errorf("Unexpected subject for %q: %q", domain, s)
+		}
+	}
+}
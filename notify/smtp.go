@@ -0,0 +1,67 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/smtp"
+	"strings"
+	"unicode"
+)
+
+// SMTP is a Notifier that sends an email for each Event.
+type SMTP struct {
+	// Addr is the address of the SMTP server, including the port
+	// (e.g. "smtp.example.com:587").
+	Addr string
+	// Auth may be nil if the server does not require authentication.
+	Auth smtp.Auth
+	From string
+	To   []string
+}
+
+// Notify implements Notifier.
+//
+// The context is only checked before sending, since net/smtp does not
+// support cancellation.
+func (s SMTP) Notify(ctx context.Context, e Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	msg, err := s.message(e)
+	if err != nil {
+		return err
+	}
+	return smtp.SendMail(s.Addr, s.Auth, s.From, s.To, msg)
+}
+
+func (s SMTP) message(e Event) ([]byte, error) {
+	details, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", s.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(s.To, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", subject(e))
+	fmt.Fprintf(&buf, "Content-Type: text/plain; charset=UTF-8\r\n")
+	fmt.Fprintf(&buf, "\r\n%s\r\n\r\n%s\r\n", e, details)
+	return buf.Bytes(), nil
+}
+
+// subject returns the Subject header of the email for `e`. Control
+// characters (e.g. CR and LF in a malicious domain) are replaced, so that
+// they cannot inject headers, and non-ASCII text (e.g. an IDN) is encoded
+// as described in RFC 2047.
+func subject(e Event) string {
+	s := strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, "[hstspreload] "+e.String())
+	return mime.QEncoding.Encode("UTF-8", s)
+}
@@ -0,0 +1,66 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	requestTimeout = 10 * time.Second
+)
+
+var client = http.Client{
+	Timeout: requestTimeout,
+}
+
+func postJSON(ctx context.Context, url string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "hstspreload-bot")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Webhook is a Notifier that POSTs each Event as JSON to URL.
+type Webhook struct {
+	URL string
+}
+
+// Notify implements Notifier.
+func (w Webhook) Notify(ctx context.Context, e Event) error {
+	return postJSON(ctx, w.URL, e)
+}
+
+// Slack is a Notifier that posts a message for each Event to a Slack
+// incoming webhook URL.
+type Slack struct {
+	WebhookURL string
+}
+
+// Notify implements Notifier.
+func (s Slack) Notify(ctx context.Context, e Event) error {
+	return postJSON(ctx, s.WebhookURL, struct {
+		Text string `json:"text"`
+	}{e.String()})
+}
@@ -0,0 +1,79 @@
+package hstspreload
+
+import (
+	"math"
+	"time"
+)
+
+// maxPolicyLifetime caps the lifetime of a policy, since max-age values
+// may exceed the range of time.Duration (≈ 292 years).
+const maxPolicyLifetime = time.Duration(math.MaxInt64)
+
+// A Policy is the HSTS policy that a browser stores after observing a
+// header. See https://tools.ietf.org/html/rfc6797#section-8.1
+//
+// A preloaded domain is not affected by the dynamic policy, but the policy
+// still matters for browsers that don't have the domain preloaded (e.g.
+// older releases, or after the domain has been removed).
+type Policy struct {
+	// Observed is the time at which the header was received.
+	Observed time.Time `json:"observed"`
+	// Active is false if the header does not establish a policy: either it
+	// has no valid max-age, or max-age=0 removes any existing policy.
+	Active bool `json:"active"`
+	// Expires is the time at which the policy expires unless the header is
+	// observed again. It is only set if Active is true.
+	Expires *time.Time `json:"expires,omitempty"`
+	// IncludeSubDomains is whether the policy covers subdomains. It is
+	// always false if Active is false.
+	IncludeSubDomains bool `json:"include_subdomains"`
+}
+
+// EffectivePolicy computes the policy that a browser stores after
+// observing `header` at `now`.
+func EffectivePolicy(header HSTSHeader, now time.Time) Policy {
+	p := Policy{Observed: now}
+	if header.MaxAge == nil || header.MaxAge.Seconds == 0 {
+		return p
+	}
+
+	lifetime := maxPolicyLifetime
+	if header.MaxAge.Seconds < uint64(maxPolicyLifetime/time.Second) {
+		lifetime = time.Duration(header.MaxAge.Seconds) * time.Second
+	}
+	expires := now.Add(lifetime)
+
+	p.Active = true
+	p.Expires = &expires
+	p.IncludeSubDomains = header.IncludeSubDomains
+	return p
+}
+
+// ActiveAt returns whether the policy is still in effect at `t`, assuming
+// that the header is not observed again in the meantime.
+func (p Policy) ActiveAt(t time.Time) bool {
+	return p.Active && !t.Before(p.Observed) && t.Before(*p.Expires)
+}
+
+// CoversSubdomainsAt returns whether the policy applies to subdomains at
+// `t`, assuming that the header is not observed again in the meantime.
+func (p Policy) CoversSubdomainsAt(t time.Time) bool {
+	return p.IncludeSubDomains && p.ActiveAt(t)
+}
+
+// Remaining returns how long the policy stays in effect after `t`, or 0 if
+// it is not active at `t`.
+func (p Policy) Remaining(t time.Time) time.Duration {
+	if !p.ActiveAt(t) {
+		return 0
+	}
+	return p.Expires.Sub(t)
+}
+
+// NeedsReobservationBefore returns whether browsers must observe the
+// header again (i.e. users must revisit the site) for the policy to still
+// be in effect at `release`, e.g. the date of a browser release that
+// changes the preload list.
+func (p Policy) NeedsReobservationBefore(release time.Time) bool {
+	return !p.ActiveAt(release)
+}
@@ -0,0 +1,109 @@
+package hstspreload
+
+import (
+	"testing"
+	"time"
+)
+
+var (
+	policyObserved = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	policyDay      = 24 * time.Hour
+)
+
+var effectivePolicyTests = []struct {
+	description string
+	header      HSTSHeader
+	// Zero iff the policy is not expected to be active.
+	expectedLifetime  time.Duration
+	includeSubDomains bool
+}{
+	{
+		"no max-age",
+		HSTSHeader{IncludeSubDomains: true},
+		0, false,
+	},
+	{
+		"max-age=0 removes the policy",
+		HSTSHeader{MaxAge: &MaxAge{Seconds: 0}, IncludeSubDomains: true},
+		0, false,
+	},
+	{
+		"one year",
+		HSTSHeader{MaxAge: &MaxAge{Seconds: oneYear}},
+		365 * policyDay, false,
+	},
+	{
+		"one year with includeSubDomains",
+		HSTSHeader{MaxAge: &MaxAge{Seconds: oneYear}, IncludeSubDomains: true, Preload: true},
+		365 * policyDay, true,
+	},
+	{
+		"max-age beyond the range of time.Duration",
+		HSTSHeader{MaxAge: &MaxAge{Seconds: 1 << 62}},
+		maxPolicyLifetime, false,
+	},
+}
+
+func TestEffectivePolicy(t *testing.T) {
+	for _, tt := range effectivePolicyTests {
+		p := EffectivePolicy(tt.header, policyObserved)
+
+		if !p.Observed.Equal(policyObserved) {
+			t.Errorf("[%s] Unexpected observation time: %v", tt.description, p.Observed)
+		}
+		if p.Active != (tt.expectedLifetime != 0) {
+			t.Errorf("[%s] Expected active to be %t", tt.description, tt.expectedLifetime != 0)
+			continue
+		}
+		if !p.Active {
+			if p.Expires != nil || p.IncludeSubDomains {
+				t.Errorf("[%s] An inactive policy should not expire or cover subdomains: %v", tt.description, p)
+			}
+			continue
+		}
+		if expected := policyObserved.Add(tt.expectedLifetime); !p.Expires.Equal(expected) {
+			t.Errorf("[%s] Expected expiry %v, got %v", tt.description, expected, p.Expires)
+		}
+		if p.IncludeSubDomains != tt.includeSubDomains {
+			t.Errorf("[%s] Expected includeSubDomains to be %t", tt.description, tt.includeSubDomains)
+		}
+	}
+}
+
+func TestPolicyAt(t *testing.T) {
+	header := HSTSHeader{MaxAge: &MaxAge{Seconds: 30 * 86400}, IncludeSubDomains: true}
+	p := EffectivePolicy(header, policyObserved)
+
+	tests := []struct {
+		description        string
+		t                  time.Time
+		active             bool
+		remaining          time.Duration
+		needsReobservation bool
+	}{
+		{"before the observation", policyObserved.Add(-policyDay), false, 0, true},
+		{"at the observation", policyObserved, true, 30 * policyDay, false},
+		{"within max-age", policyObserved.Add(10 * policyDay), true, 20 * policyDay, false},
+		{"at expiry", policyObserved.Add(30 * policyDay), false, 0, true},
+		{"after expiry", policyObserved.Add(40 * policyDay), false, 0, true},
+	}
+	for _, tt := range tests {
+		if p.ActiveAt(tt.t) != tt.active {
+			t.Errorf("[%s] Expected ActiveAt() to be %t", tt.description, tt.active)
+		}
+		if p.CoversSubdomainsAt(tt.t) != tt.active {
+			t.Errorf("[%s] Expected CoversSubdomainsAt() to be %t", tt.description, tt.active)
+		}
+		if r := p.Remaining(tt.t); r != tt.remaining {
+			t.Errorf("[%s] Expected Remaining() to be %v, got %v", tt.description, tt.remaining, r)
+		}
+		if p.NeedsReobservationBefore(tt.t) != tt.needsReobservation {
+			t.Errorf("[%s] Expected NeedsReobservationBefore() to be %t", tt.description, tt.needsReobservation)
+		}
+	}
+
+	inactive := EffectivePolicy(HSTSHeader{MaxAge: &MaxAge{Seconds: 0}}, policyObserved)
+	if inactive.ActiveAt(policyObserved) || !inactive.NeedsReobservationBefore(policyObserved) {
+		t.Errorf("max-age=0 should not establish a policy.")
+	}
+}
@@ -0,0 +1,148 @@
+package hstspreload
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// fetchPolicyFileTimeout bounds FetchPolicyFile(), in addition to its
+	// context.
+	fetchPolicyFileTimeout = 10 * time.Second
+)
+
+// A PolicyFile describes the preload policy versions of Chromium, so that
+// checks can enforce the current requirements (see Checker.PolicyVersion)
+// without updating this package. It is stored as JSON, e.g.
+//
+//	{
+//	  "revision": "2024-01-01",
+//	  "current": "bulk-1-year",
+//	  "versions": [
+//	    {"name": "bulk-18-weeks", "minimum_max_age": 10886400},
+//	    {"name": "bulk-1-year", "minimum_max_age": 31536000,
+//	     "bulk_criteria": {"require_include_subdomains": true, "require_preload": true}}
+//	  ]
+//	}
+type PolicyFile struct {
+	// Revision identifies the contents of the file, e.g. the date when it
+	// was last updated.
+	Revision string `json:"revision"`
+	// Current is the name of the version that Chromium currently enforces
+	// for new entries.
+	Current  string          `json:"current"`
+	Versions []PolicyVersion `json:"versions"`
+}
+
+// DefaultPolicyFile holds the policy versions that are built into this
+// package. It is used when no other file is available (e.g. offline).
+var DefaultPolicyFile = PolicyFile{
+	Revision: "builtin",
+	Current:  DefaultPolicyVersion.Name,
+	Versions: []PolicyVersion{PolicyVersionBulk18Weeks, PolicyVersionBulk1Year},
+}
+
+// Lookup returns the version with the given name.
+func (f PolicyFile) Lookup(name string) (PolicyVersion, bool) {
+	for _, v := range f.Versions {
+		if v.Name == name {
+			return v, true
+		}
+	}
+	return PolicyVersion{}, false
+}
+
+// CurrentVersion returns the version that Chromium currently enforces.
+func (f PolicyFile) CurrentVersion() PolicyVersion {
+	v, _ := f.Lookup(f.Current)
+	return v
+}
+
+// validate checks that the versions have distinct names and a minimum
+// max-age, and that the current version exists.
+func (f PolicyFile) validate() error {
+	names := make(map[string]bool)
+	for _, v := range f.Versions {
+		switch {
+		case v.Name == "":
+			return fmt.Errorf("policy version without a name")
+		case names[v.Name]:
+			return fmt.Errorf("repeated policy version %q", v.Name)
+		case v.MinimumMaxAge == 0:
+			return fmt.Errorf("policy version %q has no minimum_max_age", v.Name)
+		}
+		names[v.Name] = true
+	}
+	if !names[f.Current] {
+		return fmt.Errorf("unknown current policy version %q", f.Current)
+	}
+	return nil
+}
+
+// ParsePolicyFile reads and validates a PolicyFile in JSON format.
+func ParsePolicyFile(r io.Reader) (PolicyFile, error) {
+	var f PolicyFile
+	if err := json.NewDecoder(r).Decode(&f); err != nil {
+		return PolicyFile{}, err
+	}
+	if err := f.validate(); err != nil {
+		return PolicyFile{}, err
+	}
+	return f, nil
+}
+
+// LoadPolicyFile reads a PolicyFile from a JSON file.
+func LoadPolicyFile(fileName string) (PolicyFile, error) {
+	r, err := os.Open(fileName)
+	if err != nil {
+		return PolicyFile{}, err
+	}
+	defer r.Close()
+
+	return ParsePolicyFile(r)
+}
+
+// FetchPolicyFile downloads a PolicyFile from `url`. It gives up after 10
+// seconds, even if `ctx` has no deadline.
+func FetchPolicyFile(ctx context.Context, url string) (PolicyFile, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return PolicyFile{}, err
+	}
+	client := http.Client{Timeout: fetchPolicyFileTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return PolicyFile{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return PolicyFile{}, fmt.Errorf("status code %d", resp.StatusCode)
+	}
+	return ParsePolicyFile(resp.Body)
+}
+
+// LoadPolicyFileOrDefault reads a PolicyFile from `source` (an http(s) URL
+// or a file name), or returns DefaultPolicyFile if `source` is empty or
+// cannot be read. If `source` cannot be read, `err` is also set, so that
+// callers can report that the built-in policy is used.
+func LoadPolicyFileOrDefault(ctx context.Context, source string) (f PolicyFile, err error) {
+	switch {
+	case source == "":
+		return DefaultPolicyFile, nil
+	case strings.HasPrefix(source, "https://"), strings.HasPrefix(source, "http://"):
+		f, err = FetchPolicyFile(ctx, source)
+	default:
+		f, err = LoadPolicyFile(source)
+	}
+	if err != nil {
+		return DefaultPolicyFile, err
+	}
+	return f, nil
+}
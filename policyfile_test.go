@@ -0,0 +1,122 @@
+package hstspreload
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testPolicyFile = `{
+  "revision": "2030-01-01",
+  "current": "bulk-2-years",
+  "versions": [
+    {"name": "bulk-1-year", "minimum_max_age": 31536000},
+    {"name": "bulk-2-years", "minimum_max_age": 63072000, "allowed_directives": ["future"]},
+    {"name": "preload-only", "minimum_max_age": 31536000, "bulk_criteria": {"require_preload": true}}
+  ]
+}`
+
+func TestParsePolicyFile(t *testing.T) {
+	f, err := ParsePolicyFile(strings.NewReader(testPolicyFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := PolicyVersion{Name: "bulk-2-years", MinimumMaxAge: 63072000, AllowedDirectives: []string{"future"}}
+	if v := f.CurrentVersion(); !reflect.DeepEqual(v, expected) {
+		t.Errorf("CurrentVersion() = %v, want %v", v, expected)
+	}
+	if v, err := f.ParseVersion("bulk-1-year"); err != nil || v.MinimumMaxAge != 31536000 {
+		t.Errorf("ParseVersion() = %v, %v", v, err)
+	}
+	if _, err := f.ParseVersion("bulk-18-weeks"); err == nil {
+		t.Errorf("ParseVersion() should only know the versions of the file")
+	}
+
+	c := &Checker{PolicyVersion: f.CurrentVersion()}
+	issues := c.PreloadableHeaderString("max-age=31536000; includeSubDomains; preload; future")
+	expectedIssues := Issues{Errors: []Issue{{Code: "header.preloadable.max_age.below_minimum"}}}
+	if !issues.Match(expectedIssues) {
+		t.Errorf(issuesShouldMatch, issues, expectedIssues)
+	}
+
+	v, err := f.ParseVersion("preload-only")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedCriteria := BulkCriteria{RequirePreload: true}
+	if v.BulkCriteria == nil || *v.BulkCriteria != expectedCriteria {
+		t.Errorf("Unexpected bulk criteria: %v", v.BulkCriteria)
+	}
+	c = &Checker{PolicyVersion: v}
+	issues = c.PreloadableHeaderString("max-age=31536000; preload")
+	if !issues.Match(Issues{}) {
+		t.Errorf(issuesShouldMatch, issues, Issues{})
+	}
+}
+
+func TestParsePolicyFileInvalid(t *testing.T) {
+	tests := []struct {
+		description string
+		file        string
+	}{
+		{"not JSON", `bulk-1-year`},
+		{"unknown current version", `{"current": "bulk-2-years", "versions": [{"name": "bulk-1-year", "minimum_max_age": 1}]}`},
+		{"no minimum", `{"current": "bulk-1-year", "versions": [{"name": "bulk-1-year"}]}`},
+		{"repeated version", `{"current": "a", "versions": [{"name": "a", "minimum_max_age": 1}, {"name": "a", "minimum_max_age": 2}]}`},
+	}
+	for _, tt := range tests {
+		if _, err := ParsePolicyFile(strings.NewReader(tt.file)); err == nil {
+			t.Errorf("[%s] ParsePolicyFile() should fail", tt.description)
+		}
+	}
+}
+
+func TestDefaultPolicyFile(t *testing.T) {
+	if err := DefaultPolicyFile.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if v := DefaultPolicyFile.CurrentVersion(); !reflect.DeepEqual(v, DefaultPolicyVersion) {
+		t.Errorf("CurrentVersion() = %v, want %v", v, DefaultPolicyVersion)
+	}
+}
+
+func TestLoadPolicyFileOrDefault(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/policy.json" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(testPolicyFile))
+	}))
+	defer srv.Close()
+
+	fileName := filepath.Join(t.TempDir(), "policy.json")
+	if err := os.WriteFile(fileName, []byte(testPolicyFile), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	for _, source := range []string{srv.URL + "/policy.json", fileName} {
+		f, err := LoadPolicyFileOrDefault(ctx, source)
+		if err != nil || f.Revision != "2030-01-01" {
+			t.Errorf("[%s] LoadPolicyFileOrDefault() = %v, %v", source, f, err)
+		}
+	}
+
+	for _, source := range []string{srv.URL + "/missing.json", filepath.Join(t.TempDir(), "missing.json")} {
+		f, err := LoadPolicyFileOrDefault(ctx, source)
+		if err == nil || f.Revision != DefaultPolicyFile.Revision {
+			t.Errorf("[%s] LoadPolicyFileOrDefault() = %v, %v, want the default file and an error", source, f, err)
+		}
+	}
+
+	if f, err := LoadPolicyFileOrDefault(ctx, ""); err != nil || f.Revision != DefaultPolicyFile.Revision {
+		t.Errorf("LoadPolicyFileOrDefault(\"\") = %v, %v", f, err)
+	}
+}
@@ -0,0 +1,146 @@
+package hstspreload
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/chromium/hstspreload/chromium/preloadlist"
+)
+
+// A PolicyVersion is a version of the preload requirements, which differ
+// in the minimum max-age of the header and in the bulk entry criteria. Chromium has raised the minimum
+// over time, and records the version that an entry was preloaded under as
+// its policy (e.g. preloadlist.PolicyBulk1Year).
+//
+// The error for a max-age below the minimum has the code
+// "header.preloadable.max_age.below_1_year" if the minimum is 1 year, and
+// "header.preloadable.max_age.below_minimum" otherwise. Both have the params
+// "max_age" and "min_max_age".
+type PolicyVersion struct {
+	// Name identifies the version, e.g. "bulk-1-year".
+	Name string `json:"name"`
+	// MinimumMaxAge is the minimum max-age in seconds.
+	MinimumMaxAge uint64 `json:"minimum_max_age"`
+	// AllowedDirectives lists the names of directives other than max-age,
+	// includeSubDomains, and preload that are not reported as unknown
+	// (e.g. a directive that browsers have started to support).
+	AllowedDirectives []string `json:"allowed_directives,omitempty"`
+	// BulkCriteria are the other requirements for the header of a bulk
+	// entry. If nil, DefaultBulkCriteria is used.
+	BulkCriteria *BulkCriteria `json:"bulk_criteria,omitempty"`
+}
+
+// BulkCriteria are the requirements for the header of a bulk entry, apart
+// from the minimum max-age.
+type BulkCriteria struct {
+	// RequireIncludeSubDomains requires the includeSubDomains directive.
+	RequireIncludeSubDomains bool `json:"require_include_subdomains"`
+	// RequirePreload requires the preload directive.
+	RequirePreload bool `json:"require_preload"`
+}
+
+// DefaultBulkCriteria are the bulk entry criteria of all policy versions
+// that Chromium has used so far.
+var DefaultBulkCriteria = BulkCriteria{RequireIncludeSubDomains: true, RequirePreload: true}
+
+// bulkCriteria returns the bulk entry criteria of `v`.
+func (v PolicyVersion) bulkCriteria() BulkCriteria {
+	if v.BulkCriteria == nil {
+		return DefaultBulkCriteria
+	}
+	return *v.BulkCriteria
+}
+
+var (
+	// PolicyVersionBulk18Weeks requires a max-age of at least 18 weeks.
+	PolicyVersionBulk18Weeks = PolicyVersion{Name: preloadlist.PolicyBulk18Weeks, MinimumMaxAge: eighteenWeeks}
+	// PolicyVersionBulk1Year requires a max-age of at least 1 year.
+	PolicyVersionBulk1Year = PolicyVersion{Name: preloadlist.PolicyBulk1Year, MinimumMaxAge: oneYear}
+
+	// DefaultPolicyVersion is the version that Chromium currently enforces
+	// for new entries. It is used if no version is selected.
+	DefaultPolicyVersion = PolicyVersionBulk1Year
+)
+
+// ParsePolicyVersion parses the name of a policy version in
+// DefaultPolicyFile ("bulk-18-weeks" or "bulk-1-year"), or a custom minimum
+// max-age in seconds.
+func ParsePolicyVersion(s string) (PolicyVersion, error) {
+	return DefaultPolicyFile.ParseVersion(s)
+}
+
+// ParseVersion parses the name of a policy version in `f`, or a custom
+// minimum max-age in seconds.
+func (f PolicyFile) ParseVersion(s string) (PolicyVersion, error) {
+	if v, ok := f.Lookup(s); ok {
+		return v, nil
+	}
+
+	seconds, err := strconv.ParseUint(s, 10, 64)
+	if err != nil || seconds == 0 {
+		return PolicyVersion{}, fmt.Errorf("unknown policy version %q", s)
+	}
+	return PolicyVersion{Name: "custom", MinimumMaxAge: seconds}, nil
+}
+
+// describeMaxAge approximates `seconds` in years or weeks for messages, or
+// returns "" if it is not a whole number of either.
+func describeMaxAge(seconds uint64) string {
+	const oneWeek = 86400 * 7
+	var n uint64
+	var unit string
+	switch {
+	case seconds == 0:
+		return ""
+	case seconds%oneYear == 0:
+		n, unit = seconds/oneYear, "year"
+	case seconds%oneWeek == 0:
+		n, unit = seconds/oneWeek, "week"
+	default:
+		return ""
+	}
+	if n != 1 {
+		unit += "s"
+	}
+	return fmt.Sprintf("%d %s", n, unit)
+}
+
+// policyVersion returns the policy version that header checks enforce.
+// `c` may be nil.
+func (c *Checker) policyVersion() PolicyVersion {
+	if c == nil || c.PolicyVersion.MinimumMaxAge == 0 {
+		return DefaultPolicyVersion
+	}
+	return c.PolicyVersion
+}
+
+// parseHeaderString is like ParseHeaderString, but does not report the
+// AllowedDirectives of the policy version as unknown. `c` may be nil.
+func (c *Checker) parseHeaderString(headerString string) (HSTSHeader, Issues) {
+	hstsHeader, issues := ParseHeaderString(headerString)
+	allowed := c.policyVersion().AllowedDirectives
+	if len(allowed) == 0 {
+		return hstsHeader, issues
+	}
+
+	warnings := []Issue{}
+	for _, w := range issues.Warnings {
+		if w.Code != "header.parse.unknown_directive" || !directiveAllowed(w.Params["directive"], allowed) {
+			warnings = append(warnings, w)
+		}
+	}
+	return hstsHeader, Issues{Errors: issues.Errors, Warnings: warnings}
+}
+
+// directiveAllowed returns whether the name of `directive` is in `allowed`
+// (case-insensitively).
+func directiveAllowed(directive string, allowed []string) bool {
+	name, _, _ := strings.Cut(directive, "=")
+	for _, a := range allowed {
+		if strings.EqualFold(name, a) {
+			return true
+		}
+	}
+	return false
+}
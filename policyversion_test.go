@@ -0,0 +1,103 @@
+package hstspreload
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePolicyVersion(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected PolicyVersion
+	}{
+		{"bulk-18-weeks", PolicyVersionBulk18Weeks},
+		{"bulk-1-year", PolicyVersionBulk1Year},
+		{"86400", PolicyVersion{Name: "custom", MinimumMaxAge: 86400}},
+	}
+	for _, tt := range tests {
+		policy, err := ParsePolicyVersion(tt.s)
+		if err != nil || !reflect.DeepEqual(policy, tt.expected) {
+			t.Errorf("ParsePolicyVersion(%q) = %v, %v, want %v", tt.s, policy, err, tt.expected)
+		}
+	}
+
+	for _, s := range []string{"", "bulk-legacy", "-1", "0", "1 year"} {
+		if _, err := ParsePolicyVersion(s); err == nil {
+			t.Errorf("ParsePolicyVersion(%q) should fail", s)
+		}
+	}
+}
+
+var checkerPolicyVersionTests = []struct {
+	description        string
+	policy             PolicyVersion
+	header             string
+	expectedIssues     Issues
+	expectedSuggestion string
+}{
+	{
+		"default policy",
+		PolicyVersion{},
+		"max-age=10886400; includeSubDomains; preload",
+		Issues{Errors: []Issue{{
+			Code:    "header.preloadable.max_age.below_1_year",
+			Message: "The max-age must be at least 31536000 seconds (≈ 1 year), but the header currently only has max-age=10886400.",
+		}}},
+		"max-age=31536000; includeSubDomains; preload",
+	},
+	{
+		"18 weeks",
+		PolicyVersionBulk18Weeks,
+		"max-age=10886400; includeSubDomains; preload",
+		Issues{},
+		"max-age=10886400; includeSubDomains; preload",
+	},
+	{
+		"18 weeks, too low",
+		PolicyVersionBulk18Weeks,
+		"max-age=86400; includeSubDomains; preload",
+		Issues{Errors: []Issue{{
+			Code:    "header.preloadable.max_age.below_minimum",
+			Message: "The max-age must be at least 10886400 seconds (≈ 18 weeks), but the header currently only has max-age=86400.",
+		}}},
+		"max-age=10886400; includeSubDomains; preload",
+	},
+	{
+		"allowed directive",
+		PolicyVersion{Name: "custom", MinimumMaxAge: 1000, AllowedDirectives: []string{"future"}},
+		"max-age=1000; includeSubDomains; preload; Future=1; other",
+		Issues{Warnings: []Issue{{Code: "header.parse.unknown_directive"}}},
+		"max-age=1000; includeSubDomains; preload",
+	},
+	{
+		"bulk criteria",
+		PolicyVersion{Name: "custom", MinimumMaxAge: 1000, BulkCriteria: &BulkCriteria{RequireIncludeSubDomains: true}},
+		"max-age=1000",
+		Issues{Errors: []Issue{{Code: "header.preloadable.include_sub_domains.missing"}}},
+		"max-age=1000; includeSubDomains",
+	},
+	{
+		"custom",
+		PolicyVersion{Name: "custom", MinimumMaxAge: 1000},
+		"max-age=100; includeSubDomains; preload",
+		Issues{Errors: []Issue{{
+			Code:    "header.preloadable.max_age.below_minimum",
+			Message: "The max-age must be at least 1000 seconds, but the header currently only has max-age=100.",
+		}}},
+		"max-age=1000; includeSubDomains; preload",
+	},
+}
+
+func TestCheckerPolicyVersion(t *testing.T) {
+	for _, tt := range checkerPolicyVersionTests {
+		c := &Checker{PolicyVersion: tt.policy}
+		if issues := c.PreloadableHeaderString(tt.header); !issues.Match(tt.expectedIssues) {
+			t.Errorf("[%s] "+issuesShouldMatch, tt.description, issues, tt.expectedIssues)
+		}
+
+		hstsHeader, _ := ParseHeaderString(tt.header)
+		if suggested, _ := c.SuggestHeader(hstsHeader); suggested != tt.expectedSuggestion {
+			t.Errorf("[%s] SuggestHeader() = %q, want %q", tt.description, suggested, tt.expectedSuggestion)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package hstspreload
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+)
+
+// A protocolProbe requests a page over a specific HTTP version.
+type protocolProbe struct {
+	// Name is the name of the protocol in messages, e.g. "HTTP/2".
+	Name string
+	// ProtoMajor is the major version of responses over the protocol.
+	ProtoMajor int
+	// Transport returns a transport that only uses the protocol, or nil if
+	// the protocol cannot be probed.
+	Transport func(c *Checker) http.RoundTripper
+}
+
+var protocolProbes = []protocolProbe{
+	{Name: "HTTP/1.1", ProtoMajor: 1, Transport: func(c *Checker) http.RoundTripper { return c.http1Transport() }},
+	{Name: "HTTP/2", ProtoMajor: 2, Transport: func(c *Checker) http.RoundTripper { return c.http2Transport() }},
+	{Name: "HTTP/3", ProtoMajor: 3, Transport: (*Checker).http3Transport},
+}
+
+// http1Transport returns a transport that does not negotiate HTTP/2.
+// `c` may be nil.
+func (c *Checker) http1Transport() *http.Transport {
+	t := c.transport(false)
+	t.ForceAttemptHTTP2 = false
+	// A non-nil, empty map disables HTTP/2.
+	t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	if t.TLSClientConfig == nil {
+		t.TLSClientConfig = &tls.Config{}
+	} else {
+		t.TLSClientConfig = t.TLSClientConfig.Clone()
+	}
+	t.TLSClientConfig.NextProtos = []string{"http/1.1"}
+	return t
+}
+
+// http2Transport returns a transport that negotiates HTTP/2 if the server
+// supports it. `c` may be nil.
+func (c *Checker) http2Transport() *http.Transport {
+	t := c.transport(false)
+	t.ForceAttemptHTTP2 = true
+	// Let the transport configure HTTP/2 from scratch, in case
+	// c.Transport disables it.
+	t.TLSNextProto = nil
+	if t.TLSClientConfig != nil {
+		t.TLSClientConfig = t.TLSClientConfig.Clone()
+		t.TLSClientConfig.NextProtos = nil
+	}
+	return t
+}
+
+// http3Transport returns c.HTTP3Transport, or nil if it is not set. `c` may
+// be nil.
+func (c *Checker) http3Transport() http.RoundTripper {
+	if c == nil {
+		return nil
+	}
+	return c.HTTP3Transport
+}
+
+// checkProtocols checks that `domain` serves an HSTS header equivalent to
+// the one in `resp` over each HTTP version that it supports, if
+// c.CheckProtocols is set. Servers and CDNs often configure each protocol
+// separately, so browsers that negotiate another protocol may not see the
+// same header.
+//
+// The protocol of `resp` is not probed again. Protocols that the server
+// does not negotiate, and probes that fail, are skipped: connection
+// problems are reported by the other checks. HTTP/3 is only probed if
+// c.HTTP3Transport is set, since it needs a QUIC implementation.
+//
+// `c` may be nil.
+func (c *Checker) checkProtocols(ctx context.Context, domain string, resp *http.Response) Issues {
+	issues := Issues{}
+	if c == nil || !c.CheckProtocols {
+		return issues
+	}
+
+	header, _ := checkSingleHeader(resp)
+	for _, p := range protocolProbes {
+		if p.ProtoMajor == resp.ProtoMajor {
+			continue
+		}
+
+		transport := p.Transport(c)
+		if transport == nil {
+			continue
+		}
+		probeResp, err := getFirstResponseWithTransport(ctx, c.httpsURL(domain), transport, c)
+		if err != nil {
+			continue
+		}
+		probeResp.Body.Close()
+		if probeResp.ProtoMajor != p.ProtoMajor {
+			continue
+		}
+
+		probeHeader, _ := checkSingleHeader(probeResp)
+		if !equivalentHeaders(header, probeHeader) {
+			issues = issues.addWarningWithParamsf(
+				IssueCode("response.protocol_mismatch"),
+				"Inconsistent HSTS header across HTTP versions",
+				map[string]string{"protocol": p.Name, "initial_protocol": resp.Proto},
+				"Over %s, the site serves %s instead of %s (over %s). "+
+					"Browsers may use either protocol, so the site should serve an equivalent HSTS header over both.",
+				p.Name,
+				describeHeader(probeHeader),
+				describeHeader(header),
+				resp.Proto,
+			)
+		}
+	}
+
+	return issues
+}
@@ -0,0 +1,108 @@
+package hstspreload
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckProtocols(t *testing.T) {
+	const header = "max-age=31536000; includeSubDomains; preload"
+
+	var http2Header string
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor == 2 {
+			w.Header().Set("Strict-Transport-Security", http2Header)
+		} else {
+			w.Header().Set("Strict-Transport-Security", header)
+		}
+	}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	c := &Checker{
+		HTTPSPort:      srv.Listener.Addr().(*net.TCPAddr).Port,
+		ConnectTo:      map[string]string{"example.com": "127.0.0.1"},
+		Transport:      srv.Client().Transport.(*http.Transport),
+		CheckProtocols: true,
+	}
+	resp := &http.Response{ProtoMajor: 1, Proto: "HTTP/1.1", Header: http.Header{}}
+	resp.Header.Set("Strict-Transport-Security", header)
+
+	http2Header = "preload; includeSubDomains; max-age=31536000"
+	if issues := c.checkProtocols(context.Background(), "example.com", resp); !issues.Match(Issues{}) {
+		t.Errorf(issuesShouldBeEmpty, issues)
+	}
+
+	http2Header = "max-age=300"
+	expected := Issues{Warnings: []Issue{{Code: "response.protocol_mismatch"}}}
+	if issues := c.checkProtocols(context.Background(), "example.com", resp); !issues.Match(expected) {
+		t.Errorf(issuesShouldMatch, issues, expected)
+	}
+
+	// Probe HTTP/1.1 after an HTTP/2 response.
+	resp = &http.Response{ProtoMajor: 2, Proto: "HTTP/2.0", Header: http.Header{}}
+	resp.Header.Set("Strict-Transport-Security", http2Header)
+	if issues := c.checkProtocols(context.Background(), "example.com", resp); !issues.Match(expected) {
+		t.Errorf(issuesShouldMatch, issues, expected)
+	}
+
+	c.CheckProtocols = false
+	if issues := c.checkProtocols(context.Background(), "example.com", resp); !issues.Match(Issues{}) {
+		t.Errorf(issuesShouldBeEmpty, issues)
+	}
+}
+
+// fakeHTTP3Transport responds to every request with an HTTP/3 response
+// with the given HSTS header.
+type fakeHTTP3Transport struct {
+	header string
+}
+
+func (t fakeHTTP3Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Proto:      "HTTP/3.0",
+		ProtoMajor: 3,
+		Header:     http.Header{},
+		Body:       http.NoBody,
+		Request:    req,
+	}
+	resp.Header.Set("Strict-Transport-Security", t.header)
+	return resp, nil
+}
+
+func TestCheckProtocolsHTTP3(t *testing.T) {
+	const header = "max-age=31536000; includeSubDomains; preload"
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", header)
+	}))
+	defer srv.Close()
+
+	c := &Checker{
+		HTTPSPort:      srv.Listener.Addr().(*net.TCPAddr).Port,
+		ConnectTo:      map[string]string{"example.com": "127.0.0.1"},
+		Transport:      srv.Client().Transport.(*http.Transport),
+		CheckProtocols: true,
+		HTTP3Transport: fakeHTTP3Transport{header: header},
+	}
+	resp := &http.Response{ProtoMajor: 1, Proto: "HTTP/1.1", Header: http.Header{}}
+	resp.Header.Set("Strict-Transport-Security", header)
+
+	if issues := c.checkProtocols(context.Background(), "example.com", resp); !issues.Match(Issues{}) {
+		t.Errorf(issuesShouldBeEmpty, issues)
+	}
+
+	c.HTTP3Transport = fakeHTTP3Transport{header: "max-age=300"}
+	issues := c.checkProtocols(context.Background(), "example.com", resp)
+	expected := Issues{Warnings: []Issue{{Code: "response.protocol_mismatch"}}}
+	if !issues.Match(expected) {
+		t.Errorf(issuesShouldMatch, issues, expected)
+	} else if p := issues.Warnings[0].Params["protocol"]; p != "HTTP/3" {
+		t.Errorf("Unexpected protocol: %q", p)
+	}
+}
@@ -0,0 +1,167 @@
+package hstspreload
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+	"golang.org/x/net/proxy"
+)
+
+// proxyFunc returns the function that selects the proxy for a connection,
+// or nil if connections are made directly. A Dialer or ConnectTo replaces
+// any proxy configuration. `c` may be nil.
+func (c *Checker) proxyFunc() func(*http.Request) (*url.URL, error) {
+	switch {
+	case c != nil && (c.Dialer != nil || len(c.ConnectTo) > 0):
+		return nil
+	case c != nil && c.Proxy != nil:
+		return http.ProxyURL(c.Proxy)
+	case c != nil && c.Transport != nil:
+		return c.Transport.Proxy
+	default:
+		return environmentProxy
+	}
+}
+
+// environmentProxy is like http.ProxyFromEnvironment, but reads the
+// environment for each connection rather than once per process, and also
+// supports ALL_PROXY (e.g. for a SOCKS5 proxy).
+func environmentProxy(req *http.Request) (*url.URL, error) {
+	cfg := httpproxy.FromEnvironment()
+	if cfg.HTTPSProxy == "" {
+		cfg.HTTPSProxy = getenvEitherCase("ALL_PROXY")
+	}
+	if cfg.HTTPProxy == "" {
+		cfg.HTTPProxy = getenvEitherCase("ALL_PROXY")
+	}
+	return cfg.ProxyFunc()(req.URL)
+}
+
+// getenvEitherCase returns the environment variable `name`, or its
+// lowercase form if it is not set.
+func getenvEitherCase(name string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return os.Getenv(strings.ToLower(name))
+}
+
+// proxyFor returns the proxy for a connection to `addr`, or nil if it is
+// made directly. Connections to the plain HTTP port use the proxy for
+// "http" URLs, and all others the proxy for "https" URLs (e.g.
+// HTTP_PROXY and HTTPS_PROXY in the environment). `c` may be nil.
+func (c *Checker) proxyFor(addr string) (*url.URL, error) {
+	f := c.proxyFunc()
+	if f == nil {
+		return nil, nil
+	}
+
+	httpPort := "80"
+	if c != nil && c.HTTPPort != 0 {
+		httpPort = strconv.Itoa(c.HTTPPort)
+	}
+	scheme := httpsScheme
+	if _, port, err := net.SplitHostPort(addr); err == nil && port == httpPort {
+		scheme = "http"
+	}
+	return f(&http.Request{URL: &url.URL{Scheme: scheme, Host: addr}})
+}
+
+// dialProxy connects to `addr` through the proxy at `proxyURL`: an HTTP
+// or HTTPS proxy (using CONNECT), or a SOCKS5 proxy. Names are resolved
+// by the proxy.
+func (c *Checker) dialProxy(ctx context.Context, proxyURL *url.URL, network, addr string) (net.Conn, error) {
+	switch proxyURL.Scheme {
+	case "socks5", "socks5h":
+		d, err := proxy.FromURL(proxyURL, c.netDialer())
+		if err != nil {
+			return nil, err
+		}
+		if cd, ok := d.(proxy.ContextDialer); ok {
+			return cd.DialContext(ctx, network, addr)
+		}
+		return d.Dial(network, addr)
+	case "http", "https":
+		return c.dialConnect(ctx, proxyURL, addr)
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", proxyURL.Scheme)
+	}
+}
+
+// dialConnect opens a tunnel to `addr` through the HTTP(S) proxy at
+// `proxyURL`, using the CONNECT method.
+func (c *Checker) dialConnect(ctx context.Context, proxyURL *url.URL, addr string) (net.Conn, error) {
+	proxyAddr := proxyURL.Host
+	if proxyURL.Port() == "" {
+		port := "80"
+		if proxyURL.Scheme == httpsScheme {
+			port = "443"
+		}
+		proxyAddr = net.JoinHostPort(proxyURL.Hostname(), port)
+	}
+
+	conn, err := c.netDialer().DialContext(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(c.timeout()))
+	}
+	if proxyURL.Scheme == httpsScheme {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: proxyURL.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	req := &http.Request{
+		Method: "CONNECT",
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: http.Header{},
+	}
+	if u := proxyURL.User; u != nil {
+		password, _ := u.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(u.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s refused to connect to %s: %s", proxyURL.Host, addr, resp.Status)
+	}
+	if br.Buffered() > 0 {
+		// The server must not send anything before the client does.
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s sent unexpected data after CONNECT", proxyURL.Host)
+	}
+
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
@@ -0,0 +1,153 @@
+package hstspreload
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newConnectProxy returns an HTTP proxy that tunnels CONNECT requests to
+// the requested port on 127.0.0.1, and records their targets.
+func newConnectProxy() (srv *httptest.Server, targets func() []string) {
+	var mu sync.Mutex
+	var seen []string
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "CONNECT" || r.Header.Get("Proxy-Authorization") != "Basic dXNlcjpzZWNyZXQ=" {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		mu.Lock()
+		seen = append(seen, r.Host)
+		mu.Unlock()
+
+		_, port, _ := net.SplitHostPort(r.Host)
+		upstream, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", port))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			upstream.Close()
+			return
+		}
+		conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		go func() {
+			io.Copy(upstream, conn)
+			upstream.Close()
+		}()
+		io.Copy(conn, upstream)
+		conn.Close()
+	}))
+	return srv, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string{}, seen...)
+	}
+}
+
+func TestCheckerProxy(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	proxySrv, targets := newConnectProxy()
+	defer proxySrv.Close()
+
+	proxyURL, _ := url.Parse(proxySrv.URL)
+	proxyURL.User = url.UserPassword("user", "secret")
+	port := srv.Listener.Addr().(*net.TCPAddr).Port
+	c := &Checker{
+		HTTPSPort: port,
+		Proxy:     proxyURL,
+		Transport: srv.Client().Transport.(*http.Transport),
+	}
+
+	resp, _, issues := getResponse(context.Background(), "example.com", c)
+	if !issues.Match(Issues{}) {
+		t.Errorf(issuesShouldBeEmpty, issues)
+	}
+	if resp != nil {
+		resp.Body.Close()
+	}
+
+	// Raw TLS connections use the proxy as well.
+	conn, err := c.dialTLS(context.Background(), net.JoinHostPort("www.example.com", c.httpsPort()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	expected := []string{"example.com:" + c.httpsPort(), "www.example.com:" + c.httpsPort()}
+	if got := targets(); len(got) != 2 || got[0] != expected[0] || got[1] != expected[1] {
+		t.Errorf("Expected the proxy to connect to %v, got %v", expected, got)
+	}
+
+	// The proxy refuses connections without credentials.
+	proxyURL.User = nil
+	if _, err := c.dialContext(context.Background(), "tcp", "example.com:443"); err == nil {
+		t.Errorf("Expected an error from the proxy.")
+	}
+}
+
+func TestCheckerResolvesRemotely(t *testing.T) {
+	proxyURL, _ := url.Parse("socks5://proxy.example:1080")
+	for _, name := range []string{"HTTPS_PROXY", "HTTP_PROXY", "ALL_PROXY", "NO_PROXY"} {
+		t.Setenv(name, "")
+		t.Setenv(strings.ToLower(name), "")
+	}
+
+	if (&Checker{}).resolvesRemotely("example.com") {
+		t.Errorf("Names should be resolved locally without a proxy.")
+	}
+	if !(&Checker{Proxy: proxyURL}).resolvesRemotely("example.com") {
+		t.Errorf("Names should be resolved by an explicit proxy.")
+	}
+	if !(&Checker{Dialer: &net.Dialer{}}).resolvesRemotely("example.com") {
+		t.Errorf("Names should be resolved by a Dialer.")
+	}
+
+	for _, name := range []string{"HTTPS_PROXY", "ALL_PROXY"} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, "http://proxy.example:3128")
+			if !(&Checker{}).resolvesRemotely("example.com") {
+				t.Errorf("Names should be resolved by the proxy in %s.", name)
+			}
+			if !(*Checker)(nil).resolvesRemotely("example.com") {
+				t.Errorf("Names should be resolved by the proxy in %s for the default configuration.", name)
+			}
+			if issues := (&Checker{}).checkDNS(context.Background(), "example.com"); !issues.Match(Issues{}) {
+				t.Errorf(issuesShouldBeEmpty, issues)
+			}
+
+			t.Setenv("NO_PROXY", "example.com")
+			if (&Checker{}).resolvesRemotely("example.com") {
+				t.Errorf("Names should be resolved locally for domains in NO_PROXY.")
+			}
+		})
+	}
+}
+
+func TestCheckerProxyFunc(t *testing.T) {
+	proxyURL, _ := url.Parse("socks5://proxy.example:1080")
+	for _, tt := range []struct {
+		description string
+		c           *Checker
+		expected    *url.URL
+	}{
+		{"explicit proxy", &Checker{Proxy: proxyURL}, proxyURL},
+		{"transport proxy", &Checker{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}, proxyURL},
+		{"transport without proxy", &Checker{Transport: &http.Transport{}}, nil},
+		{"dialer", &Checker{Proxy: proxyURL, Dialer: &net.Dialer{}}, nil},
+		{"connect to", &Checker{Proxy: proxyURL, ConnectTo: map[string]string{"example.com": "192.0.2.1"}}, nil},
+	} {
+		u, err := tt.c.proxyFor("example.com:443")
+		if err != nil || u != tt.expected {
+			t.Errorf("[%s] Expected proxy %v, got %v (%v)", tt.description, tt.expected, u, err)
+		}
+	}
+}
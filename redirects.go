@@ -1,10 +1,19 @@
 package hstspreload
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"io"
+	"net"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
+
+	"golang.org/x/net/idna"
+	"golang.org/x/net/publicsuffix"
 )
 
 const (
@@ -12,6 +21,10 @@ const (
 	// domain over HTTP or HTTPS.
 	maxRedirects = 3
 	httpsScheme  = "https"
+
+	// The number of bytes of an HTTP response body to read when checking
+	// whether the response serves content.
+	maxContentSniffBytes = 4096
 )
 
 // preloadableHTTPRedirects checks for two kinds of issues:
@@ -23,105 +36,276 @@ const (
 // It is often extra noise to report issues related to #2, so we return
 // firstRedirectHSTS separately and allow the caller to decide whether
 // to use or ignore those issues.
-func preloadableHTTPRedirects(domain string) (general, firstRedirectHSTS Issues) {
-	return preloadableHTTPRedirectsURL("http://"+domain, domain)
+//
+// preloadableHTTPRedirects also returns the redirects that it followed from
+// http://domain, which show where the site actually went.
+//
+// `c` may be nil.
+func preloadableHTTPRedirects(ctx context.Context, domain string, c *Checker) (hops []RedirectHop, general, firstRedirectHSTS Issues) {
+	return preloadableHTTPRedirectHopsURL(ctx, c.httpURL(domain), domain, c)
 }
 
-func preloadableHTTPSRedirects(domain string) Issues {
-	return preloadableHTTPSRedirectsURL("https://" + domain)
+// preloadableHTTPSRedirects also returns the redirects that it followed,
+// since "works, but bounces through three hosts" is useful information even
+// if there are no issues.
+//
+// `c` may be nil.
+func preloadableHTTPSRedirects(ctx context.Context, domain string, c *Checker) ([]RedirectHop, Issues) {
+	return preloadableHTTPSRedirectsURL(ctx, c.httpsURL(domain), c)
 }
 
-func preloadableRedirectChain(initialURL string, chain []*url.URL) Issues {
-	issues := Issues{}
+// A RedirectHop is a single redirect that was followed from the initial URL.
+type RedirectHop struct {
+	// The status code of the response that redirected to URL.
+	StatusCode int    `json:"status_code"`
+	URL        string `json:"url"`
+	Scheme     string `json:"scheme"`
+	Host       string `json:"host"`
+}
+
+// `c` may be nil.
+func preloadableRedirectChain(initialURL string, chain []*url.URL, c *Checker) Issues {
+	issues := checkRedirectTargets(initialURL, chain, c.httpsPort())
 
 	for i, u := range chain {
 		if u.Scheme != httpsScheme {
 			if i == 0 {
-				return issues.addErrorf(
+				return issues.addErrorWithParamsf(
 					IssueCode("redirects.insecure.initial"),
 					"Insecure redirect",
+					map[string]string{"url": initialURL, "redirect_url": u.String()},
 					"`%s` redirects to an insecure page: `%s`", initialURL, u)
 			}
 
-			return issues.addErrorf(
+			return issues.addErrorWithParamsf(
 				IssueCode("redirects.insecure.subsequent"),
 				"Insecure redirect",
+				map[string]string{"url": initialURL, "redirect_url": u.String(), "redirect_number": strconv.Itoa(i + 1)},
 				"`%s` redirects to an insecure page on redirect #%d: `%s`", initialURL, i+1, u)
 		}
 	}
 	return issues
 }
 
+// checkRedirectTargets warns about redirect targets with unusual hosts,
+// since browsers treat them inconsistently once a domain is preloaded. Only
+// the first redirect with each problem is reported. An IP address or port
+// that is already used by `initialURL` is not reported.
+func checkRedirectTargets(initialURL string, chain []*url.URL, httpsPort string) Issues {
+	issues := Issues{}
+	reported := make(map[IssueCode]bool)
+	initial, err := url.Parse(initialURL)
+	if err != nil {
+		initial = &url.URL{}
+	}
+
+	warn := func(code IssueCode, summary string, i int, u *url.URL, problem string) {
+		if reported[code] {
+			return
+		}
+		reported[code] = true
+		issues = issues.addWarningWithParamsf(
+			code,
+			summary,
+			map[string]string{"url": u.Redacted()},
+			"Redirect #%d from `%s` goes to `%s`, which %s. "+
+				"Browsers handle such redirects inconsistently once a domain is preloaded, "+
+				"so redirect to an HTTPS URL with a domain name and the default port instead.",
+			i+1,
+			initialURL,
+			u.Redacted(),
+			problem,
+		)
+	}
+
+	for i, u := range chain {
+		host := u.Hostname()
+		if u.User != nil {
+			warn(IssueCode("redirects.target.credentials"), "Redirect contains credentials",
+				i, u, "contains credentials")
+		}
+		switch {
+		case host == "":
+			warn(IssueCode("redirects.target.invalid_host"), "Redirect to an invalid host",
+				i, u, "does not have a host")
+		case net.ParseIP(host) != nil:
+			if host == initial.Hostname() {
+				break
+			}
+			warn(IssueCode("redirects.target.ip_address"), "Redirect to an IP address",
+				i, u, "uses an IP address instead of a domain name")
+		case !isValidHostname(host):
+			warn(IssueCode("redirects.target.invalid_host"), "Redirect to an invalid host",
+				i, u, "has an invalid host name")
+		}
+		if u.Scheme == httpsScheme && u.Port() != "" && u.Port() != "443" && u.Port() != httpsPort && u.Port() != initial.Port() {
+			warn(IssueCode("redirects.target.port"), "Redirect to a non-standard port",
+				i, u, "uses port "+u.Port()+" instead of the default HTTPS port (443)")
+		}
+	}
+
+	return issues
+}
+
+// isValidHostname returns whether `host` consists of non-empty labels of
+// letters, digits, and hyphens (after conversion to ASCII). Labels may not
+// begin or end with a hyphen.
+func isValidHostname(host string) bool {
+	ascii, err := idna.Lookup.ToASCII(host)
+	if err != nil {
+		return false
+	}
+	for _, label := range strings.Split(strings.TrimSuffix(ascii, "."), ".") {
+		if label == "" || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return false
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
 // `cont` indicates whether the scan should continue.
-func checkHSTSOverHTTP(initialURL string) (issues Issues, cont bool) {
+func checkHSTSOverHTTP(ctx context.Context, initialURL string, c *Checker) (issues Issues, cont bool) {
 	issues = Issues{}
 
-	resp, err := getFirstResponse(initialURL)
+	resp, err := getFirstResponse(ctx, initialURL, c)
 	if err != nil {
-		return Issues{}.addWarningf(
-			"redirects.http.does_not_exist",
-			"Unavailable over HTTP",
-			"The site appears to be unavailable over plain HTTP (%s). "+
-				"This can prevent users without a freshly updated modern browser from connecting to the site when they "+
-				"visit a URL with the http:// scheme (or with an unspecified scheme). "+
-				"However, this is okay if the site does not wish to support those users.",
-			initialURL,
-		), false
+		return httpDoesNotExist(initialURL), false
 	}
+	defer resp.Body.Close()
+
+	return checkHTTPResponse(initialURL, resp), true
+}
+
+func httpDoesNotExist(initialURL string) Issues {
+	return Issues{}.addWarningWithParamsf(
+		"redirects.http.does_not_exist",
+		"Unavailable over HTTP",
+		map[string]string{"url": initialURL},
+		"The site appears to be unavailable over plain HTTP (%s). "+
+			"This can prevent users without a freshly updated modern browser from connecting to the site when they "+
+			"visit a URL with the http:// scheme (or with an unspecified scheme). "+
+			"However, this is okay if the site does not wish to support those users.",
+		initialURL,
+	)
+}
+
+// checkHTTPResponse checks the response `resp` to the plain HTTP URL
+// `initialURL`.
+func checkHTTPResponse(initialURL string, resp *http.Response) (issues Issues) {
+	issues = Issues{}
 
 	key := http.CanonicalHeaderKey("Strict-Transport-Security")
 	if len(resp.Header[key]) != 0 {
-		return issues.addWarningf(
+		issues = issues.addWarningWithParamsf(
 			IssueCode("redirects.http.useless_header"),
 			"Unnecessary HSTS header over HTTP",
+			map[string]string{"url": initialURL},
 			"The HTTP page at %s sends an HSTS header. This has no effect over HTTP, and should be removed.",
 			initialURL,
-		), true
+		)
+	}
+
+	if servesContent(resp) {
+		issues = issues.addWarningWithParamsf(
+			IssueCode("redirects.http.serves_content"),
+			"Content served over HTTP",
+			map[string]string{"url": initialURL, "status_code": strconv.Itoa(resp.StatusCode)},
+			"The HTTP page at %s serves content (status code %d) instead of immediately redirecting to HTTPS. "+
+				"Any content served over plain HTTP can be read and modified by an attacker, "+
+				"which undermines the protection that preloading provides.",
+			initialURL,
+			resp.StatusCode,
+		)
 	}
 
-	return issues, true
+	return issues
+}
+
+// servesContent returns whether `resp` is not a redirect and has a
+// non-empty body. Only the start of the body is read.
+func servesContent(resp *http.Response) bool {
+	if resp.StatusCode >= 300 && resp.StatusCode < 400 {
+		return false
+	}
+	if resp.Body == nil {
+		return false
+	}
+
+	start, err := io.ReadAll(io.LimitReader(resp.Body, maxContentSniffBytes))
+	if err != nil {
+		return false
+	}
+	return len(bytes.TrimSpace(start)) > 0
 }
 
 // Taking a URL allows us to test more easily. Use preloadableHTTPRedirects()
 // where possible.
-func preloadableHTTPRedirectsURL(initialURL string, domain string) (general, firstRedirectHSTS Issues) {
-	general, cont := checkHSTSOverHTTP(initialURL)
-	if !cont {
-		return general, Issues{}
+func preloadableHTTPRedirectsURL(ctx context.Context, initialURL string, domain string, c *Checker) (general, firstRedirectHSTS Issues) {
+	_, general, firstRedirectHSTS = preloadableHTTPRedirectHopsURL(ctx, initialURL, domain, c)
+	return general, firstRedirectHSTS
+}
+
+// preloadableHTTPRedirectHopsURL is like preloadableHTTPRedirectsURL, but
+// also describes each redirect.
+func preloadableHTTPRedirectHopsURL(ctx context.Context, initialURL string, domain string, c *Checker) (hops []RedirectHop, general, firstRedirectHSTS Issues) {
+	// Walk the redirect chain once, and check the responses that we received
+	// along the way instead of requesting them again.
+	walk := followRedirects(ctx, initialURL, c)
+	defer walk.close()
+	general, firstRedirectHSTS = checkHTTPRedirectWalk(initialURL, domain, walk, c)
+	return walk.hops, general, firstRedirectHSTS
+}
+
+// checkHTTPRedirectWalk checks the redirects followed from `initialURL`
+// (see preloadableHTTPRedirects()).
+func checkHTTPRedirectWalk(initialURL string, domain string, walk *redirectWalk, c *Checker) (general, firstRedirectHSTS Issues) {
+	var rl *RateLimitError
+	if len(walk.responses) == 0 && errors.As(walk.err, &rl) {
+		return walk.issues(initialURL), Issues{}
+	}
+	if len(walk.responses) == 0 {
+		return httpDoesNotExist(initialURL), Issues{}
 	}
 
-	chain, preloadableRedirectsIssues := preloadableRedirects(initialURL)
-	general = combineIssues(general, preloadableRedirectsIssues)
+	general = combineIssues(checkHTTPResponse(initialURL, walk.responses[0]), walk.issues(initialURL))
+	chain := walk.chain
 	if len(chain) == 0 {
-		return general.addErrorf(
+		return general.addErrorWithParamsf(
 			IssueCode("redirects.http.no_redirect"),
 			"No redirect from HTTP",
+			map[string]string{"url": initialURL, "expected_url": c.httpsURL(domain)},
 			"`%s` does not redirect to `%s`.",
 			initialURL,
-			"https://"+domain,
+			c.httpsURL(domain),
 		), firstRedirectHSTS
 	}
 
 	if chain[0].Scheme == httpsScheme && chain[0].Hostname() == domain {
 		// Check for HSTS on the first redirect.
-		resp, err := getFirstResponse(chain[0].String())
-		if err != nil {
+		if len(walk.responses) < 2 {
 			// We cannot connect this time. This error has high priority,
 			// so return immediately and allow it to mask other errors.
-			return general, firstRedirectHSTS.addErrorf(
+			return general, firstRedirectHSTS.addErrorWithParamsf(
 				IssueCode("redirects.http.first_redirect.invalid"),
 				"Invalid redirect",
+				map[string]string{"url": initialURL, "redirect_url": chain[0].String(), "error": walk.err.Error()},
 				"`%s` redirects to `%s`, which we could not connect to: %s",
 				initialURL,
 				chain[0],
-				err,
+				walk.err,
 			)
 		}
-		_, redirectHSTSIssues := PreloadableResponse(resp)
+		_, redirectHSTSIssues := c.PreloadableResponse(walk.responses[1])
 		if len(redirectHSTSIssues.Errors) > 0 {
-			firstRedirectHSTS = firstRedirectHSTS.addErrorf(
+			firstRedirectHSTS = firstRedirectHSTS.addErrorWithParamsf(
 				IssueCode("redirects.http.first_redirect.no_hsts"),
 				"HTTP redirects to a page without HSTS",
+				map[string]string{"url": initialURL, "redirect_url": chain[0].String()},
 				"`%s` redirects to `%s`, which does not serve a HSTS header that satisfies preload conditions. First error: %s",
 				initialURL,
 				chain[0],
@@ -129,7 +313,7 @@ func preloadableHTTPRedirectsURL(initialURL string, domain string) (general, fir
 			)
 		}
 
-		general = combineIssues(general, preloadableRedirectChain(initialURL, chain))
+		general = combineIssues(general, preloadableRedirectChain(initialURL, chain, c))
 		return general, firstRedirectHSTS
 	}
 
@@ -137,75 +321,192 @@ func preloadableHTTPRedirectsURL(initialURL string, domain string) (general, fir
 		// For simplicity, we use the same message for two cases:
 		// - http://example.com -> http://www.example.com
 		// - http://example.com -> https://www.example.com
-		return general.addErrorf(
+		return general.addErrorWithParamsf(
 			IssueCode("redirects.http.www_first"),
 			"HTTP redirects to www first",
+			map[string]string{"url": initialURL, "expected_url": c.httpsURL(domain), "redirect_url": chain[0].String()},
 			"`%s` (HTTP) should immediately redirect to `%s` (HTTPS) "+
 				"before adding the www subdomain. Right now, the first redirect is to `%s`. "+
 				"The extra redirect is required to ensure that any browser which supports HSTS will "+
 				"record the HSTS entry for the top level domain, not just the subdomain.",
 			initialURL,
-			"https://"+domain,
+			c.httpsURL(domain),
 			chain[0],
 		), firstRedirectHSTS
 	}
 
-	return general.addErrorf(
-		IssueCode("redirects.http.first_redirect.insecure"),
-		"HTTP does not redirect to HTTPS",
+	// Distinguish the most common reasons for the first redirect to be
+	// wrong, so that automation can tell e.g. redirects to a vanity domain
+	// apart from plain misconfigurations.
+	code := IssueCode("redirects.http.first_redirect.insecure")
+	summary := "HTTP does not redirect to HTTPS"
+	sourceETLD1, _ := publicsuffix.EffectiveTLDPlusOne(domain)
+	destinationETLD1, _ := publicsuffix.EffectiveTLDPlusOne(chain[0].Hostname())
+	switch {
+	case chain[0].Hostname() == domain:
+		code = IssueCode("redirects.http.first_redirect.same_host_insecure")
+		summary = "HTTP redirects to HTTP on the same host"
+	case sourceETLD1 != destinationETLD1:
+		code = IssueCode("redirects.http.first_redirect.different_domain")
+		summary = "HTTP redirects to a different domain"
+	}
+
+	return general.addErrorWithParamsf(
+		code,
+		summary,
+		map[string]string{
+			"url":              initialURL,
+			"redirect_url":     chain[0].String(),
+			"source_host":      domain,
+			"destination_host": chain[0].Hostname(),
+		},
 		"`%s` (HTTP) redirects to `%s`. The first redirect "+
 			"from `%s` should be to a secure page on the same host (`%s`).",
 		initialURL,
 		chain[0],
 		initialURL,
-		"https://"+domain,
+		c.httpsURL(domain),
 	), firstRedirectHSTS
 }
 
 // Taking a URL allows us to test more easily. Use preloadableHTTPSRedirects()
 // where possible.
-func preloadableHTTPSRedirectsURL(initialURL string) Issues {
-	chain, issues := preloadableRedirects(initialURL)
-	return combineIssues(issues, preloadableRedirectChain(initialURL, chain))
+func preloadableHTTPSRedirectsURL(ctx context.Context, initialURL string, c *Checker) ([]RedirectHop, Issues) {
+	chain, hops, issues := preloadableRedirectHops(ctx, initialURL, c)
+	return hops, combineIssues(issues, preloadableRedirectChain(initialURL, chain, c))
 }
 
-func preloadableRedirects(initialURL string) (chain []*url.URL, issues Issues) {
-	var redirectChain []*url.URL
-	tooManyRedirects := errors.New("TOO_MANY_REDIRECTS")
+// `c` may be nil.
+func preloadableRedirects(ctx context.Context, initialURL string, c *Checker) (chain []*url.URL, issues Issues) {
+	chain, _, issues = preloadableRedirectHops(ctx, initialURL, c)
+	return chain, issues
+}
+
+// preloadableRedirectHops is like preloadableRedirects, but also describes
+// each redirect. `c` may be nil.
+func preloadableRedirectHops(ctx context.Context, initialURL string, c *Checker) (chain []*url.URL, hops []RedirectHop, issues Issues) {
+	walk := followRedirects(ctx, initialURL, c)
+	walk.close()
+	return walk.chain, walk.hops, walk.issues(initialURL)
+}
+
+// A redirectWalk records the redirects that were followed from an initial
+// URL.
+type redirectWalk struct {
+	chain []*url.URL
+	hops  []RedirectHop
+	// responses[0] is the response to the initial URL, and responses[i] is
+	// the response to chain[i-1]. There is no response for a URL that we
+	// could not connect to.
+	responses []*http.Response
+	// The error that stopped the walk, if any.
+	err error
+	// The number of times that the walk was attempted (see
+	// Checker.Retry).
+	attempts int
+}
+
+var errTooManyRedirects = errors.New("TOO_MANY_REDIRECTS")
+
+// followRedirects follows up to maxRedirects redirects from `initialURL`.
+// The whole walk is retried after transient errors according to c.Retry.
+// The caller must call close() on the result. `c` may be nil.
+func followRedirects(ctx context.Context, initialURL string, c *Checker) *redirectWalk {
+	var walk *redirectWalk
+	attempts, _ := c.withRetries(ctx, func() error {
+		walk = followRedirectsOnce(ctx, initialURL, c)
+		return walk.err
+	})
+	walk.attempts = attempts
+	return walk
+}
+
+// followRedirectsOnce makes a single attempt of followRedirects().
+func followRedirectsOnce(ctx context.Context, initialURL string, c *Checker) *redirectWalk {
+	walk := &redirectWalk{}
 
 	client := http.Client{
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			redirectChain = append(redirectChain, req.URL)
+			walk.chain = append(walk.chain, req.URL)
+			hop := RedirectHop{
+				URL:    req.URL.String(),
+				Scheme: req.URL.Scheme,
+				Host:   req.URL.Host,
+			}
+			if req.Response != nil {
+				hop.StatusCode = req.Response.StatusCode
+				walk.responses = append(walk.responses, req.Response)
+				c.logResponse(via[len(via)-1].URL.String(), req.Response, nil)
+			}
+			walk.hops = append(walk.hops, hop)
+			c.logf("Redirect %d from %s to %s", len(walk.hops), initialURL, hop.URL)
 
-			if len(redirectChain) > maxRedirects {
-				return tooManyRedirects
+			if len(walk.chain) > maxRedirects {
+				return errTooManyRedirects
 			}
 
 			return nil
 		},
-		Timeout: dialTimeout,
+		Timeout:   c.timeout(),
+		Transport: c.transport(false),
 	}
-	req, err := http.NewRequest("GET", initialURL, nil)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", initialURL, nil)
 	if err != nil {
-		return nil, issues
+		walk.err = err
+		return walk
 	}
 
 	req.Header.Set("User-Agent", "hstspreload-bot")
-	_, err = client.Do(req)
-
+	resp, err := client.Do(req)
 	if err != nil {
-		if strings.HasSuffix(err.Error(), tooManyRedirects.Error()) {
-			issues = issues.addErrorf(
-				IssueCode("redirects.too_many"),
-				"Too many redirects",
-				"There are more than %d redirects starting from `%s`.", maxRedirects, initialURL)
-		} else {
-			issues = issues.addErrorf(
-				IssueCode("redirects.follow_error"),
-				"Error following redirects",
-				"Redirect error: %s", err.Error())
-		}
+		// Any response was already recorded by CheckRedirect.
+		c.logf("Following redirects from %s: %s", initialURL, err)
+		walk.err = err
+		return walk
+	}
+	c.logResponse(resp.Request.URL.String(), resp, nil)
+	if err := rateLimitError(resp.Request.URL.String(), resp, time.Now()); err != nil {
+		resp.Body.Close()
+		walk.err = err
+		return walk
 	}
+	walk.responses = append(walk.responses, resp)
 
-	return redirectChain, issues
+	return walk
+}
+
+// close closes the body of the final response. The bodies of redirect
+// responses are closed by the client.
+func (walk *redirectWalk) close() {
+	if walk.err == nil && len(walk.responses) > 0 {
+		walk.responses[len(walk.responses)-1].Body.Close()
+	}
+}
+
+// issues returns issues about following the redirects from `initialURL`.
+func (walk *redirectWalk) issues(initialURL string) Issues {
+	issues := Issues{}
+
+	var rl *RateLimitError
+	switch {
+	case walk.err == nil:
+		issues = combineIssues(issues, retriedIssues(IssueCode("redirects.retried"), initialURL, walk.attempts))
+	case errors.As(walk.err, &rl):
+		issues = combineIssues(issues, rateLimitedIssues(rl.URL, rl))
+	case errors.Is(walk.err, errTooManyRedirects):
+		issues = issues.addErrorWithParamsf(
+			IssueCode("redirects.too_many"),
+			"Too many redirects",
+			map[string]string{"url": initialURL, "max_redirects": strconv.Itoa(maxRedirects)},
+			"There are more than %d redirects starting from `%s`.", maxRedirects, initialURL)
+	default:
+		issues = issues.addErrorWithParamsf(
+			IssueCode("redirects.follow_error"),
+			"Error following redirects",
+			map[string]string{"error": walk.err.Error()},
+			"Redirect error: %s", walk.err.Error())
+	}
+
+	return issues
 }
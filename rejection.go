@@ -0,0 +1,74 @@
+package hstspreload
+
+import "strings"
+
+// A RejectionCategory is one of the broad reasons for rejecting a preload
+// submission that the preload list maintainers use to triage domains.
+type RejectionCategory string
+
+const (
+	// RejectionTLS covers problems connecting over HTTPS, including
+	// certificate problems and HTTPS on the www subdomain.
+	RejectionTLS RejectionCategory = "tls"
+	// RejectionRedirect covers problems with redirects from HTTP to HTTPS.
+	RejectionRedirect RejectionCategory = "redirect"
+	// RejectionHeader covers a missing or non-preloadable HSTS header.
+	RejectionHeader RejectionCategory = "header"
+	// RejectionSubdomain covers submissions of subdomains rather than
+	// registered domains.
+	RejectionSubdomain RejectionCategory = "subdomain"
+	// RejectionOther covers everything else.
+	RejectionOther RejectionCategory = "other"
+)
+
+// rejectionCategoryOrder lists the categories in the order of their
+// declaration, which is used for RejectionCategories().
+var rejectionCategoryOrder = []RejectionCategory{
+	RejectionTLS,
+	RejectionRedirect,
+	RejectionHeader,
+	RejectionSubdomain,
+	RejectionOther,
+}
+
+// RejectionCategoryOf returns the rejection category for issues with
+// `code`.
+func RejectionCategoryOf(code IssueCode) RejectionCategory {
+	switch {
+	case code == "domain.is_subdomain":
+		return RejectionSubdomain
+	case code == "domain.www.no_tls":
+		return RejectionTLS
+	case strings.HasPrefix(string(code), "domain.www.http."):
+		return RejectionRedirect
+	}
+
+	switch sectionOf(code) {
+	case sectionTLS:
+		return RejectionTLS
+	case sectionRedirects:
+		return RejectionRedirect
+	case sectionHeader:
+		return RejectionHeader
+	default:
+		return RejectionOther
+	}
+}
+
+// RejectionCategories returns the distinct rejection categories of the
+// errors in `iss`, in a fixed order (TLS, redirect, header, subdomain,
+// other). Warnings are ignored, since they don't cause a rejection.
+func (iss Issues) RejectionCategories() []RejectionCategory {
+	found := make(map[RejectionCategory]bool)
+	for _, e := range iss.Errors {
+		found[RejectionCategoryOf(e.Code)] = true
+	}
+
+	var categories []RejectionCategory
+	for _, c := range rejectionCategoryOrder {
+		if found[c] {
+			categories = append(categories, c)
+		}
+	}
+	return categories
+}
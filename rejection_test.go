@@ -0,0 +1,50 @@
+package hstspreload
+
+import (
+	"reflect"
+	"testing"
+)
+
+var rejectionCategoryOfTests = []struct {
+	code     IssueCode
+	expected RejectionCategory
+}{
+	{"domain.tls.invalid_cert_chain", RejectionTLS},
+	{"tls.obsolete_cipher_suite", RejectionTLS},
+	{"domain.www.no_tls", RejectionTLS},
+	{"redirects.http.no_redirect", RejectionRedirect},
+	{"domain.www.http.no_redirect", RejectionRedirect},
+	{"response.no_header", RejectionHeader},
+	{"header.preloadable.preload.missing", RejectionHeader},
+	{"domain.is_subdomain", RejectionSubdomain},
+	{"domain.format.public_suffix", RejectionOther},
+	{"example_org.caa.missing", RejectionOther},
+}
+
+func TestRejectionCategoryOf(t *testing.T) {
+	for _, tt := range rejectionCategoryOfTests {
+		if c := RejectionCategoryOf(tt.code); c != tt.expected {
+			t.Errorf("[%s] Expected %s, got %s", tt.code, tt.expected, c)
+		}
+	}
+}
+
+func TestRejectionCategories(t *testing.T) {
+	issues := Issues{
+		Errors: []Issue{
+			{Code: "domain.is_subdomain"},
+			{Code: "response.no_header"},
+			{Code: "domain.tls.sha1"},
+			{Code: "header.preloadable.preload.missing"},
+		},
+		Warnings: []Issue{{Code: "redirects.http.serves_content"}},
+	}
+	expected := []RejectionCategory{RejectionTLS, RejectionHeader, RejectionSubdomain}
+	if c := issues.RejectionCategories(); !reflect.DeepEqual(c, expected) {
+		t.Errorf("Expected %v, got %v", expected, c)
+	}
+
+	if c := (Issues{}).RejectionCategories(); len(c) != 0 {
+		t.Errorf("Expected no categories, got %v", c)
+	}
+}
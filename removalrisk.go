@@ -0,0 +1,128 @@
+package hstspreload
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/chromium/hstspreload/chromium/preloadlist"
+)
+
+// AutomatedRemovalRiskHeader checks whether a preloaded domain serving
+// `hstsHeader` is at risk of being removed from the Chromium preload list
+// by automated cleanups, given the `policy` field of its preload list
+// entry (e.g. preloadlist.PolicyBulk1Year).
+//
+// Only entries with a bulk policy are subject to automated removal, so
+// other policies never result in errors. For bulk entries, each error
+// describes a requirement that the domain met when it was preloaded but no
+// longer meets.
+//
+// To interpret the result, see the list of conventions in the
+// documentation for Issues.
+func AutomatedRemovalRiskHeader(hstsHeader HSTSHeader, policy string) Issues {
+	issues := Issues{}
+
+	if !(preloadlist.Entry{Policy: policy}).IsBulk() {
+		return issues
+	}
+
+	if !hstsHeader.Preload {
+		issues = issues.addErrorWithParamsf(
+			"removal_risk.preload.missing",
+			"No preload directive",
+			map[string]string{"policy": policy},
+			"The domain is preloaded under the `%s` policy, but the header no longer contains the `preload` directive.",
+			policy)
+	}
+
+	if !hstsHeader.IncludeSubDomains {
+		issues = issues.addErrorWithParamsf(
+			"removal_risk.include_sub_domains.missing",
+			"No includeSubDomains directive",
+			map[string]string{"policy": policy},
+			"The domain is preloaded under the `%s` policy, but the header no longer contains the `includeSubDomains` directive.",
+			policy)
+	}
+
+	minimumMaxAge := PolicyVersionBulk1Year.MinimumMaxAge
+	if policy != preloadlist.PolicyBulk1Year {
+		minimumMaxAge = PolicyVersionBulk18Weeks.MinimumMaxAge
+	}
+	switch {
+	case hstsHeader.MaxAge == nil:
+		issues = issues.addErrorWithParamsf(
+			"removal_risk.max_age.missing",
+			"No max-age directive",
+			map[string]string{"policy": policy},
+			"The domain is preloaded under the `%s` policy, but the header no longer contains a valid `max-age` directive.",
+			policy)
+
+	case hstsHeader.MaxAge.Seconds < minimumMaxAge:
+		issues = issues.addErrorWithParamsf(
+			"removal_risk.max_age.too_low",
+			"Max-age too low",
+			map[string]string{"policy": policy, "max_age": strconv.FormatUint(hstsHeader.MaxAge.Seconds, 10), "min_max_age": strconv.FormatUint(minimumMaxAge, 10)},
+			"The domain is preloaded under the `%s` policy, which requires a max-age of at least %d seconds, "+
+				"but the header currently only has max-age=%d.",
+			policy,
+			minimumMaxAge,
+			hstsHeader.MaxAge.Seconds)
+	}
+
+	return issues
+}
+
+// AutomatedRemovalRiskHeaderString is a convenience function that calls
+// ParseHeaderString() and then calls AutomatedRemovalRiskHeader() on the
+// parsed header. Like RemovableHeaderString(), it ignores parse warnings.
+//
+// To interpret the result, see the list of conventions in the
+// documentation for Issues.
+func AutomatedRemovalRiskHeaderString(headerString string, policy string) Issues {
+	hstsHeader, issues := ParseHeaderString(headerString)
+	issues = Issues{
+		Errors: issues.Errors,
+		// Ignore parse warnings, since they do not affect removal.
+	}
+	return combineIssues(issues, AutomatedRemovalRiskHeader(hstsHeader, policy))
+}
+
+// AutomatedRemovalRiskResponse checks whether resp has a single HSTS header
+// that keeps a domain preloaded under `policy` safe from automated removal.
+//
+// Iff a single HSTS header was received, `header` contains its value, else
+// `header` is `nil`.
+// To interpret `issues`, see the list of conventions in the
+// documentation for Issues.
+func AutomatedRemovalRiskResponse(resp *http.Response, policy string) (header *string, issues Issues) {
+	return checkResponse(resp, func(headerString string) Issues {
+		return AutomatedRemovalRiskHeaderString(headerString, policy)
+	})
+}
+
+// AutomatedRemovalRiskDomain connects to `domain` and checks whether it is
+// at risk of automated removal from the preload list, given the `policy`
+// field of its preload list entry.
+//
+// Iff a single HSTS header was received, `header` contains its value, else
+// `header` is `nil`.
+// To interpret `issues`, see the list of conventions in the
+// documentation for Issues.
+func AutomatedRemovalRiskDomain(domain string, policy string) (header *string, issues Issues) {
+	return defaultChecker.AutomatedRemovalRiskDomain(domain, policy)
+}
+
+// AutomatedRemovalRiskDomain is like the package-level
+// AutomatedRemovalRiskDomain, but uses the configuration of `c`.
+func (c *Checker) AutomatedRemovalRiskDomain(domain string, policy string) (header *string, issues Issues) {
+	resp, _, respIssues := getResponse(context.Background(), domain, c.withDNSCache())
+	issues = combineIssues(issues, respIssues)
+	if len(respIssues.Errors) == 0 {
+		var riskIssues Issues
+		header, riskIssues = AutomatedRemovalRiskResponse(resp, policy)
+		issues = combineIssues(issues, riskIssues)
+	}
+
+	return header, c.applyDomainPolicy(domain, issues)
+}
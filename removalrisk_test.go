@@ -0,0 +1,65 @@
+package hstspreload
+
+import "testing"
+
+var automatedRemovalRiskTests = []struct {
+	description    string
+	header         string
+	policy         string
+	expectedIssues Issues
+}{
+	{
+		"not a bulk entry",
+		"max-age=0",
+		"custom",
+		Issues{},
+	},
+	{
+		"bulk-1-year, still preloadable",
+		"max-age=31536000; includeSubDomains; preload",
+		"bulk-1-year",
+		Issues{},
+	},
+	{
+		"bulk-18-weeks, still preloadable",
+		"max-age=10886400; includeSubDomains; preload",
+		"bulk-18-weeks",
+		Issues{},
+	},
+	{
+		"bulk-1-year, 18 week max-age",
+		"max-age=10886400; includeSubDomains; preload",
+		"bulk-1-year",
+		Issues{Errors: []Issue{{
+			Code:    "removal_risk.max_age.too_low",
+			Message: "The domain is preloaded under the `bulk-1-year` policy, which requires a max-age of at least 31536000 seconds, but the header currently only has max-age=10886400.",
+		}}},
+	},
+	{
+		"bulk-legacy, dropped directives",
+		"max-age=10886400",
+		"bulk-legacy",
+		Issues{Errors: []Issue{
+			{Code: "removal_risk.preload.missing"},
+			{Code: "removal_risk.include_sub_domains.missing"},
+		}},
+	},
+	{
+		"bulk-18-weeks, invalid max-age",
+		"max-age=abc; includeSubDomains; preload",
+		"bulk-18-weeks",
+		Issues{Errors: []Issue{
+			{Code: "header.parse.max_age.non_digit_characters"},
+			{Code: "removal_risk.max_age.missing"},
+		}},
+	},
+}
+
+func TestAutomatedRemovalRiskHeaderString(t *testing.T) {
+	for _, tt := range automatedRemovalRiskTests {
+		issues := AutomatedRemovalRiskHeaderString(tt.header, tt.policy)
+		if !issues.Match(tt.expectedIssues) {
+			t.Errorf("[%s] "+issuesShouldMatch, tt.description, issues, tt.expectedIssues)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+package report
+
+import (
+	"github.com/chromium/hstspreload/ct"
+)
+
+// DefaultCrtShURL is the search endpoint of https://crt.sh/.
+const DefaultCrtShURL = ct.DefaultCrtShURL
+
+// CrtSh is a SubdomainSource that searches the Certificate Transparency
+// logs using https://crt.sh/. See ct.CrtSh.
+type CrtSh = ct.CrtSh
@@ -0,0 +1,129 @@
+package report
+
+import (
+	"html/template"
+	"io"
+)
+
+// htmlTemplate renders a Report as a standalone HTML page. html/template
+// escapes all values that come from the checked site.
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>HSTS preload report for {{.Domain}}</title>
+<style>
+body { font-family: sans-serif; max-width: 60em; margin: 2em auto; }
+.error { color: #c00; }
+.warning { color: #b60; }
+td, th { text-align: left; padding: 0.2em 1em 0.2em 0; }
+pre { background: #f4f4f4; padding: 0.5em; overflow-x: auto; }
+</style>
+</head>
+<body>
+<h1>HSTS preload report for {{.Domain}}</h1>
+<p>Generated {{.Generated.Format "2006-01-02 15:04:05 MST"}}.</p>
+
+<h2>Live check</h2>
+{{with .Check}}
+<p>Eligibility: <strong>{{.Eligibility}}</strong></p>
+{{with .Header}}<p>Observed header: <code>{{.}}</code></p>{{end}}
+{{with .CNAME}}<p>CNAME target: <code>{{.CanonicalName}}</code>{{with .TargetEntry}} (preloaded by the entry for <code>{{.Name}}</code>){{end}}</p>{{end}}
+{{if or .Issues.Errors .Issues.Warnings}}
+<ul>
+{{range .Issues.Errors}}<li class="error"><strong>Error: {{.Summary}}</strong> ({{.Code}}): {{.Message}}</li>
+{{end}}
+{{range .Issues.Warnings}}<li class="warning"><strong>Warning: {{.Summary}}</strong> ({{.Code}}): {{.Message}}</li>
+{{end}}
+</ul>
+{{else}}
+<p>No issues.</p>
+{{end}}
+{{else}}
+<p>Not available.</p>
+{{end}}
+
+{{with .Remediation}}
+<h2>How to fix</h2>
+{{range .}}
+<h3>{{.Title}}</h3>
+<ul>
+{{range .Issues.Errors}}<li class="error"><strong>Error: {{.Summary}}</strong> ({{.Code}})</li>
+{{end}}
+{{range .Issues.Warnings}}<li class="warning"><strong>Warning: {{.Summary}}</strong> ({{.Code}})</li>
+{{end}}
+</ul>
+{{range .Fixes}}
+<h4>{{.Title}}</h4>
+<p>{{.Advice}}</p>
+{{range .Snippets}}<p>{{.Server}}:</p>
+<pre><code>{{.Config}}</code></pre>
+{{end}}
+{{end}}
+{{end}}
+{{end}}
+
+<h2>Chromium preload list</h2>
+{{with .ListStatus}}
+{{if .Preloaded}}
+<p>{{if .CoveredByParent}}Covered by the entry for <code>{{.Entry.Name}}</code>{{else}}Preloaded{{end}}
+(mode: {{.Entry.Mode}}, includeSubDomains: {{.Entry.IncludeSubDomains}}{{with .Entry.Policy}}, policy: {{.}}{{end}}).</p>
+{{else}}
+<p>Not preloaded.</p>
+{{end}}
+{{else}}
+<p>Not available.</p>
+{{end}}
+
+<h2>hstspreload.org</h2>
+{{with .Submission}}
+<p>Status: <strong>{{.Status}}</strong>{{with .Message}} ({{.}}){{end}}</p>
+{{else}}
+<p>Not available.</p>
+{{end}}
+
+<h2>History</h2>
+{{with .History}}
+{{if .Checks}}
+<table>
+<tr><th>Checks</th><td>{{.Checks}}</td></tr>
+<tr><th>First checked</th><td>{{.FirstChecked.Format "2006-01-02"}}</td></tr>
+<tr><th>Last checked</th><td>{{.LastChecked.Format "2006-01-02"}}</td></tr>
+<tr><th>Last verdict</th><td>{{.LastVerdict}}</td></tr>
+</table>
+{{with .Regression}}<p class="error">The verdict regressed from {{.Previous.Verdict}} to {{.Current.Verdict}} in the last check.</p>{{end}}
+{{else}}
+<p>The domain has not been checked before.</p>
+{{end}}
+{{else}}
+<p>Not available.</p>
+{{end}}
+
+<h2>Subdomains in Certificate Transparency logs</h2>
+{{with .Subdomains}}
+<p>{{.Count}} subdomains found. Preloading with includeSubDomains forces all of them to use HTTPS.</p>
+{{if .Names}}
+<ul>
+{{range .Names}}<li><code>{{.}}</code></li>
+{{end}}
+</ul>
+{{end}}
+{{else}}
+<p>Not available.</p>
+{{end}}
+
+{{with .SourceErrors}}
+<h2>Unavailable sources</h2>
+<ul>
+{{range $source, $err := .}}<li>{{$source}}: {{$err}}</li>
+{{end}}
+</ul>
+{{end}}
+</body>
+</html>
+`))
+
+// WriteHTML renders `r` to `w` as a standalone HTML page.
+func WriteHTML(w io.Writer, r *Report) error {
+	return htmlTemplate.Execute(w, r)
+}
@@ -0,0 +1,126 @@
+package report
+
+import (
+	"io"
+	"strings"
+	"text/template"
+)
+
+// markdownCode formats `s` as a Markdown code span, even if it contains
+// backticks.
+func markdownCode(s string) string {
+	fence := "`"
+	for strings.Contains(s, fence) {
+		fence += "`"
+	}
+	if strings.HasPrefix(s, "`") || strings.HasSuffix(s, "`") {
+		s = " " + s + " "
+	}
+	return fence + s + fence
+}
+
+// markdownEscaper escapes the characters that have a meaning in inline
+// Markdown (including HTML tags and table cells), and joins lines.
+var markdownEscaper = strings.NewReplacer(
+	"\\", "\\\\",
+	"`", "\\`",
+	"*", "\\*",
+	"_", "\\_",
+	"[", "\\[",
+	"]", "\\]",
+	"<", "\\<",
+	">", "\\>",
+	"|", "\\|",
+	"~", "\\~",
+	"\r\n", " ",
+	"\n", " ",
+	"\r", " ",
+)
+
+// markdownText formats `s` as literal text in a Markdown paragraph or list
+// item, e.g. for messages that come from a remote server.
+func markdownText(s string) string {
+	return markdownEscaper.Replace(s)
+}
+
+// markdownTemplate renders a Report as a Markdown document, e.g. for an
+// issue tracker or a wiki page.
+var markdownTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"code": markdownCode,
+	"text": markdownText,
+}).Parse(`# HSTS preload report for {{.Domain}}
+
+Generated {{.Generated.Format "2006-01-02 15:04:05 MST"}}.
+
+## Live check
+{{with .Check}}
+Eligibility: **{{.Eligibility}}**
+{{with .Header}}
+Observed header: {{code .}}
+{{end}}{{with .CNAME}}
+CNAME target: {{code .CanonicalName}}{{with .TargetEntry}} (preloaded by the entry for {{code .Name}}){{end}}
+{{end}}{{if or .Issues.Errors .Issues.Warnings}}{{else}}
+No issues.
+{{end}}{{else}}
+Not available.
+{{end}}{{with .Remediation}}
+## How to fix
+{{range .}}
+### {{.Title}}
+{{range .Issues.Errors}}
+- **Error: {{.Summary}}** ({{code (print .Code)}}): {{text .Message}}{{end}}{{range .Issues.Warnings}}
+- **Warning: {{.Summary}}** ({{code (print .Code)}}): {{text .Message}}{{end}}
+{{range .Fixes}}
+#### {{.Title}}
+
+{{.Advice}}
+{{range .Snippets}}
+{{.Server}}:
+
+` + "```" + `
+{{.Config}}
+` + "```" + `
+{{end}}{{end}}{{end}}{{end}}
+## Chromium preload list
+{{with .ListStatus}}{{if .Preloaded}}
+{{if .CoveredByParent}}Covered by the entry for {{code .Entry.Name}}{{else}}Preloaded{{end}} (mode: {{.Entry.Mode}}, includeSubDomains: {{.Entry.IncludeSubDomains}}{{with .Entry.Policy}}, policy: {{.}}{{end}}).
+{{else}}
+Not preloaded.
+{{end}}{{else}}
+Not available.
+{{end}}
+## hstspreload.org
+{{with .Submission}}
+Status: **{{.Status}}**{{with .Message}} ({{text .}}){{end}}
+{{else}}
+Not available.
+{{end}}
+## History
+{{with .History}}{{if .Checks}}
+| Checks | First checked | Last checked | Last verdict |
+| --- | --- | --- | --- |
+| {{.Checks}} | {{.FirstChecked.Format "2006-01-02"}} | {{.LastChecked.Format "2006-01-02"}} | {{.LastVerdict}} |
+{{with .Regression}}
+**The verdict regressed from {{.Previous.Verdict}} to {{.Current.Verdict}} in the last check.**
+{{end}}{{else}}
+The domain has not been checked before.
+{{end}}{{else}}
+Not available.
+{{end}}
+## Subdomains in Certificate Transparency logs
+{{with .Subdomains}}
+{{.Count}} subdomains found. Preloading with includeSubDomains forces all of them to use HTTPS.
+{{if .Names}}
+{{range .Names}}- {{code .}}
+{{end}}{{end}}{{else}}
+Not available.
+{{end}}{{with .SourceErrors}}
+## Unavailable sources
+{{range $source, $err := .}}
+- {{$source}}: {{text (print $err)}}{{end}}
+{{end}}`))
+
+// WriteMarkdown renders `r` to `w` as a Markdown document.
+func WriteMarkdown(w io.Writer, r *Report) error {
+	return markdownTemplate.Execute(w, r)
+}
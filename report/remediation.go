@@ -0,0 +1,303 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/chromium/hstspreload"
+)
+
+// RecommendedHeader is the HSTS header that remediation snippets set. It
+// satisfies the preload requirements with a max-age of two years.
+const RecommendedHeader = "max-age=63072000; includeSubDomains; preload"
+
+// A Snippet is an example configuration that fixes an issue.
+type Snippet struct {
+	// Server is the software that the snippet configures: "nginx",
+	// "apache", "caddy", or "dns" (a zone file record).
+	Server string `json:"server"`
+	Config string `json:"config"`
+}
+
+// A Fix describes how to resolve one or more issues.
+type Fix struct {
+	Title  string `json:"title"`
+	Advice string `json:"advice"`
+	// Codes are the codes of the issues that the fix resolves.
+	Codes    []hstspreload.IssueCode `json:"codes"`
+	Snippets []Snippet               `json:"snippets,omitempty"`
+}
+
+// A SectionRemediation holds the issues of a section of the checks (see
+// hstspreload.CheckSections), and the fixes for them. Issues without a
+// known fix have no Fix, but are still listed.
+type SectionRemediation struct {
+	// Section is the JSON key of the section in hstspreload.CheckSections,
+	// e.g. "tls".
+	Section string             `json:"section"`
+	Title   string             `json:"title"`
+	Issues  hstspreload.Issues `json:"issues"`
+	Fixes   []Fix              `json:"fixes"`
+}
+
+// A fixRule describes the fix for the issues whose code starts with any of
+// the prefixes.
+type fixRule struct {
+	prefixes []string
+	// fix returns the fix for `domain`. Codes are filled in by Remediate().
+	fix func(domain string) Fix
+}
+
+func headerSnippets(header string) []Snippet {
+	return []Snippet{
+		{"nginx", fmt.Sprintf("# In the server block for port 443:\nadd_header Strict-Transport-Security %q always;", header)},
+		{"apache", fmt.Sprintf("# In the <VirtualHost *:443> block (requires mod_headers):\nHeader always set Strict-Transport-Security %q", header)},
+		{"caddy", fmt.Sprintf("header Strict-Transport-Security %q", header)},
+	}
+}
+
+func redirectSnippets(domain string) []Snippet {
+	return []Snippet{
+		{"nginx", fmt.Sprintf("server {\n\tlisten 80;\n\tserver_name %s www.%s;\n\treturn 301 https://$host$request_uri;\n}", domain, domain)},
+		{"apache", fmt.Sprintf("<VirtualHost *:80>\n\tServerName %s\n\tServerAlias www.%s\n\tRewriteEngine On\n\tRewriteRule ^ https://%%{HTTP_HOST}%%{REQUEST_URI} [L,R=301]\n</VirtualHost>", domain, domain)},
+		{"caddy", fmt.Sprintf("# Caddy redirects HTTP to HTTPS automatically for site addresses\n# without a scheme. Make sure that the site is not served over HTTP:\n%s, www.%s {\n\t# ...\n}", domain, domain)},
+	}
+}
+
+// fixRules are tried in order; the first rule with a matching prefix
+// applies to an issue.
+var fixRules = []fixRule{
+	{
+		prefixes: []string{"response.multiple_headers"},
+		fix: func(domain string) Fix {
+			return Fix{
+				Title: "Send a single HSTS header",
+				Advice: "Several layers (e.g. the application and a reverse proxy or CDN) add their own header. " +
+					"Remove all but one, or make the outermost layer replace the header of the upstream.",
+				Snippets: []Snippet{
+					{"nginx", fmt.Sprintf("proxy_hide_header Strict-Transport-Security;\nadd_header Strict-Transport-Security %q always;", RecommendedHeader)},
+					{"apache", fmt.Sprintf("# \"set\" replaces any header of the same name; \"add\" does not.\nHeader always set Strict-Transport-Security %q", RecommendedHeader)},
+					{"caddy", fmt.Sprintf("reverse_proxy upstream:8080 {\n\theader_down -Strict-Transport-Security\n}\nheader Strict-Transport-Security %q", RecommendedHeader)},
+				},
+			}
+		},
+	},
+	{
+		prefixes: []string{
+			"response.no_header", "response.protocol_mismatch", "header.parse.", "header.preloadable.",
+			"redirects.http.first_redirect.no_hsts", "domain.multi_ip.inconsistent_hsts",
+			"domain.ipv6.no_header", "domain.ipv6.inconsistent_header", "alt_svc.inconsistent_header",
+			"config.",
+		},
+		fix: func(domain string) Fix {
+			return Fix{
+				Title: "Serve a preloadable HSTS header",
+				Advice: "Send exactly this header on all HTTPS responses, including redirects and error pages, " +
+					"from every server, protocol, and address that serves the domain. " +
+					"Before adding includeSubDomains, make sure that all subdomains support HTTPS.",
+				Snippets: headerSnippets(RecommendedHeader),
+			}
+		},
+	},
+	{
+		prefixes: []string{"redirects.http."},
+		fix: func(domain string) Fix {
+			return Fix{
+				Title: "Redirect HTTP to HTTPS on the same host",
+				Advice: fmt.Sprintf("http://%s must immediately redirect to https://%s (not to another host, "+
+					"e.g. the www subdomain), so that browsers receive the HSTS header for %s.", domain, domain, domain),
+				Snippets: redirectSnippets(domain),
+			}
+		},
+	},
+	{
+		prefixes: []string{"redirects.insecure.", "redirects.too_many", "redirects.target.", "redirects.follow_error"},
+		fix: func(domain string) Fix {
+			return Fix{
+				Title: "Keep redirects short and on HTTPS",
+				Advice: "Redirects from https://" + domain + " must only point to HTTPS URLs on standard hosts and ports, " +
+					"with at most 3 hops. Point redirects directly to the final HTTPS URL.",
+			}
+		},
+	},
+	{
+		prefixes: []string{"domain.tls.invalid_cert_chain", "domain.tls.cert."},
+		fix: func(domain string) Fix {
+			return Fix{
+				Title: "Install a valid certificate chain",
+				Advice: fmt.Sprintf("Serve a current certificate for %s (and www.%s) from a publicly trusted CA, "+
+					"together with all intermediate certificates, and renew it automatically (e.g. with ACME).", domain, domain),
+				Snippets: []Snippet{
+					{"nginx", fmt.Sprintf("ssl_certificate /etc/letsencrypt/live/%s/fullchain.pem;\nssl_certificate_key /etc/letsencrypt/live/%s/privkey.pem;", domain, domain)},
+					{"apache", fmt.Sprintf("SSLCertificateFile /etc/letsencrypt/live/%s/fullchain.pem\nSSLCertificateKeyFile /etc/letsencrypt/live/%s/privkey.pem", domain, domain)},
+					{"caddy", fmt.Sprintf("# Caddy obtains and renews certificates automatically.\n%s {\n\ttls admin@%s\n}", domain, domain)},
+				},
+			}
+		},
+	},
+	{
+		prefixes: []string{"domain.tls.version.too_low", "tls.obsolete_cipher_suite"},
+		fix: func(domain string) Fix {
+			return Fix{
+				Title:  "Use modern TLS versions and cipher suites",
+				Advice: "Only enable TLS 1.2 and 1.3, with forward-secret AEAD cipher suites.",
+				Snippets: []Snippet{
+					{"nginx", "ssl_protocols TLSv1.2 TLSv1.3;\nssl_ciphers ECDHE-ECDSA-AES128-GCM-SHA256:ECDHE-RSA-AES128-GCM-SHA256:ECDHE-ECDSA-CHACHA20-POLY1305:ECDHE-RSA-CHACHA20-POLY1305;"},
+					{"apache", "SSLProtocol -all +TLSv1.2 +TLSv1.3\nSSLCipherSuite ECDHE-ECDSA-AES128-GCM-SHA256:ECDHE-RSA-AES128-GCM-SHA256:ECDHE-ECDSA-CHACHA20-POLY1305:ECDHE-RSA-CHACHA20-POLY1305"},
+					{"caddy", "tls {\n\tprotocols tls1.2 tls1.3\n}"},
+				},
+			}
+		},
+	},
+	{
+		prefixes: []string{"domain.tls.sha1", "domain.tls.rsa_key_too_small", "domain.tls.eku.", "domain.tls.key_usage."},
+		fix: func(domain string) Fix {
+			return Fix{
+				Title: "Reissue the certificate",
+				Advice: "Request a new certificate with a SHA-256 (or stronger) signature, an RSA key of at least " +
+					"2048 bits or an ECDSA key, and the serverAuth extended key usage.",
+			}
+		},
+	},
+	{
+		prefixes: []string{"domain.tls.cannot_connect", "domain.tls.handshake_failure", "domain.tls.handshake_timeout", "domain.tcp.", "domain.https.not_http"},
+		fix: func(domain string) Fix {
+			return Fix{
+				Title:  "Serve HTTPS on port 443",
+				Advice: fmt.Sprintf("Make sure that %s accepts TLS connections on port 443, and that no firewall blocks them.", domain),
+				Snippets: []Snippet{
+					{"nginx", fmt.Sprintf("server {\n\tlisten 443 ssl;\n\tserver_name %s;\n\t# ...\n}", domain)},
+					{"apache", fmt.Sprintf("<VirtualHost *:443>\n\tServerName %s\n\tSSLEngine on\n\t# ...\n</VirtualHost>", domain)},
+				},
+			}
+		},
+	},
+	{
+		prefixes: []string{"domain.dns.no_records", "domain.dns.timeout"},
+		fix: func(domain string) Fix {
+			return Fix{
+				Title:  "Publish DNS records",
+				Advice: fmt.Sprintf("Add A and/or AAAA records for %s at your DNS provider, and check that its nameservers respond.", domain),
+			}
+		},
+	},
+	{
+		prefixes: []string{"domain.http.rate_limited"},
+		fix: func(domain string) Fix {
+			return Fix{
+				Title:  "Allow the preload checks",
+				Advice: "A firewall or CDN throttled the checks. Allow requests with the User-Agent hstspreload-bot, or try again later.",
+			}
+		},
+	},
+	{
+		prefixes: []string{"domain.www."},
+		fix: func(domain string) Fix {
+			return Fix{
+				Title: "Support HTTPS on the www subdomain",
+				Advice: fmt.Sprintf("www.%s must have a valid certificate, redirect from HTTP to HTTPS, "+
+					"and send the same HSTS header as %s.", domain, domain),
+				Snippets: append(redirectSnippets(domain), headerSnippets(RecommendedHeader)...),
+			}
+		},
+	},
+	{
+		prefixes: []string{"dns.lookup_failed", "dns.no_addresses"},
+		fix: func(domain string) Fix {
+			return Fix{
+				Title:  "Publish address records",
+				Advice: fmt.Sprintf("Add A or AAAA records for %s, so that browsers can connect to it.", domain),
+			}
+		},
+	},
+	{
+		prefixes: []string{"domain.dns.caa.missing"},
+		fix: func(domain string) Fix {
+			return Fix{
+				Title:  "Restrict certificate issuance with CAA",
+				Advice: "Add CAA records for the CAs that issue your certificates.",
+				Snippets: []Snippet{
+					{"dns", fmt.Sprintf("%s. IN CAA 0 issue \"letsencrypt.org\"", domain)},
+				},
+			}
+		},
+	},
+	{
+		prefixes: []string{"domain.format.", "domain.is_subdomain"},
+		fix: func(domain string) Fix {
+			return Fix{
+				Title:  "Submit the registrable domain",
+				Advice: "Only registrable domains (e.g. example.com, not www.example.com) can be preloaded.",
+			}
+		},
+	},
+}
+
+// sectionTitles are the titles of the sections of hstspreload.CheckSections,
+// in their canonical order.
+var sectionTitles = []struct {
+	key    string
+	title  string
+	issues func(s *hstspreload.CheckSections) hstspreload.Issues
+}{
+	{"format", "Domain name", func(s *hstspreload.CheckSections) hstspreload.Issues { return s.Format }},
+	{"dns", "DNS", func(s *hstspreload.CheckSections) hstspreload.Issues { return s.DNS }},
+	{"tls", "TLS and certificates", func(s *hstspreload.CheckSections) hstspreload.Issues { return s.TLS }},
+	{"header", "HSTS header", func(s *hstspreload.CheckSections) hstspreload.Issues { return s.Header }},
+	{"redirects", "Redirects", func(s *hstspreload.CheckSections) hstspreload.Issues { return s.Redirects }},
+	{"www", "www subdomain", func(s *hstspreload.CheckSections) hstspreload.Issues { return s.WWW }},
+	{"preload_status", "Preload status", func(s *hstspreload.CheckSections) hstspreload.Issues { return s.PreloadStatus }},
+	{"other", "Other", func(s *hstspreload.CheckSections) hstspreload.Issues { return s.Other }},
+}
+
+// Remediate returns the issues of `check` grouped by section, with fixes
+// and configuration snippets derived from their codes. Sections without
+// issues are left out.
+func Remediate(check *hstspreload.CheckAllResult) []SectionRemediation {
+	remediations := []SectionRemediation{}
+	for _, st := range sectionTitles {
+		issues := st.issues(&check.Sections)
+		if len(issues.Errors) == 0 && len(issues.Warnings) == 0 {
+			continue
+		}
+
+		sr := SectionRemediation{Section: st.key, Title: st.title, Issues: issues, Fixes: []Fix{}}
+		fixes := make(map[int]int)
+		for _, issue := range append(append([]hstspreload.Issue{}, issues.Errors...), issues.Warnings...) {
+			rule := findFixRule(issue.Code)
+			if rule < 0 {
+				continue
+			}
+			i, ok := fixes[rule]
+			if !ok {
+				i = len(sr.Fixes)
+				fixes[rule] = i
+				sr.Fixes = append(sr.Fixes, fixRules[rule].fix(check.Domain))
+			}
+			sr.Fixes[i].Codes = appendCode(sr.Fixes[i].Codes, issue.Code)
+		}
+		remediations = append(remediations, sr)
+	}
+	return remediations
+}
+
+// findFixRule returns the index of the rule in fixRules for `code`, or -1.
+func findFixRule(code hstspreload.IssueCode) int {
+	for i, rule := range fixRules {
+		for _, prefix := range rule.prefixes {
+			if strings.HasPrefix(string(code), prefix) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func appendCode(codes []hstspreload.IssueCode, code hstspreload.IssueCode) []hstspreload.IssueCode {
+	for _, c := range codes {
+		if c == code {
+			return codes
+		}
+	}
+	return append(codes, code)
+}
@@ -0,0 +1,229 @@
+// Package report combines everything that is known about a domain into a
+// single document: the live check results (with advice and configuration
+// snippets for fixing the issues), its status on the Chromium preload list
+// and on hstspreload.org, its check history, and the subdomains that are
+// exposed through Certificate Transparency logs. This is the full picture
+// to review before deciding to submit a domain. Reports can be rendered as
+// JSON, HTML (WriteHTML()), or Markdown (WriteMarkdown()).
+//
+// Each data source is optional. If a source fails, the report is still
+// produced, and the failure is recorded in Report.SourceErrors.
+package report
+
+import (
+	"context"
+	"time"
+
+	"github.com/chromium/hstspreload"
+	"github.com/chromium/hstspreload/chromium/preloadapi"
+	"github.com/chromium/hstspreload/chromium/preloadlist"
+	"github.com/chromium/hstspreload/history"
+)
+
+// Names of the data sources, used as keys of Report.SourceErrors.
+const (
+	SourceCheck      = "check"
+	SourceSubmission = "submission"
+	SourceHistory    = "history"
+	SourceSubdomains = "subdomains"
+)
+
+// A SubdomainSource lists known subdomains of a domain, e.g. from
+// Certificate Transparency logs (see CrtSh).
+type SubdomainSource interface {
+	Subdomains(ctx context.Context, domain string) ([]string, error)
+}
+
+// Options configures a Reporter. Sources that are nil are left out of the
+// report.
+type Options struct {
+	// Checker is used for the live checks. If nil, the default
+	// configuration is used.
+	Checker *hstspreload.Checker
+	// PreloadList is used for the status on the Chromium preload list.
+	PreloadList *preloadlist.IndexedEntries
+	// API is used for the status of the domain on hstspreload.org.
+	API *preloadapi.Client
+	// History is used for the past check results of the domain.
+	History history.Store
+	// Subdomains is used for the subdomains that would be affected by
+	// preloading with includeSubDomains.
+	Subdomains SubdomainSource
+}
+
+// A ListStatus describes whether a domain is on the Chromium preload list.
+type ListStatus struct {
+	Preloaded bool `json:"preloaded"`
+	// Set iff Preloaded is true. If the domain is covered by a parent
+	// domain, this is the entry of the parent.
+	Entry *preloadlist.Entry `json:"entry,omitempty"`
+	// Whether Entry is for a parent domain rather than the domain itself.
+	CoveredByParent bool `json:"covered_by_parent,omitempty"`
+}
+
+// A HistorySummary summarizes the past check results of a domain.
+type HistorySummary struct {
+	Checks int `json:"checks"`
+	// The remaining fields are only set if Checks is positive.
+	FirstChecked *time.Time              `json:"first_checked,omitempty"`
+	LastChecked  *time.Time              `json:"last_checked,omitempty"`
+	LastVerdict  history.Verdict         `json:"last_verdict,omitempty"`
+	Trend        map[history.Verdict]int `json:"trend,omitempty"`
+	// Set iff the verdict got worse in the most recent check.
+	Regression *history.Regression `json:"regression,omitempty"`
+}
+
+// SubdomainExposure lists the known subdomains of a domain. Preloading the
+// domain with includeSubDomains forces all of them to use HTTPS.
+type SubdomainExposure struct {
+	Count int      `json:"count"`
+	Names []string `json:"names"`
+}
+
+// A Report is the outcome of Reporter.Report().
+type Report struct {
+	Domain    string    `json:"domain"`
+	Generated time.Time `json:"generated"`
+	// Check holds the live check results, or is nil if the check could not
+	// be completed.
+	Check *hstspreload.CheckAllResult `json:"check,omitempty"`
+	// ListStatus is nil iff Options.PreloadList is nil.
+	ListStatus *ListStatus `json:"list_status,omitempty"`
+	// Submission is the status on hstspreload.org, or nil if unavailable.
+	Submission *preloadapi.DomainStatus `json:"submission,omitempty"`
+	// History is nil if unavailable.
+	History *HistorySummary `json:"history,omitempty"`
+	// Subdomains is nil if unavailable.
+	Subdomains *SubdomainExposure `json:"subdomains,omitempty"`
+	// Remediation explains how to fix the issues of Check (see
+	// Remediate()). It is nil iff Check is nil.
+	Remediation []SectionRemediation `json:"remediation,omitempty"`
+	// SourceErrors maps the names of data sources (e.g. SourceHistory)
+	// that failed to the error.
+	SourceErrors map[string]string `json:"source_errors,omitempty"`
+}
+
+// A Reporter produces reports for domains.
+type Reporter struct {
+	opts     Options
+	checkAll func(ctx context.Context, domain string, opts hstspreload.CheckOptions) (hstspreload.CheckAllResult, error)
+}
+
+// New returns a Reporter using the given options.
+func New(opts Options) *Reporter {
+	return &Reporter{opts: opts, checkAll: hstspreload.CheckAll}
+}
+
+// Report gathers the data for `domain` from all sources of `r`
+// concurrently. The returned error is only set if `ctx` is done before the
+// report is complete.
+func (r *Reporter) Report(ctx context.Context, domain string) (*Report, error) {
+	rep := &Report{
+		Domain:       domain,
+		Generated:    time.Now(),
+		SourceErrors: map[string]string{},
+	}
+	if r.opts.PreloadList != nil {
+		rep.ListStatus = listStatus(domain, *r.opts.PreloadList)
+	}
+
+	type sourceResult struct {
+		source string
+		err    error
+	}
+	results := make(chan sourceResult)
+	sources := 0
+	start := func(source string, f func() error) {
+		sources++
+		go func() {
+			results <- sourceResult{source, f()}
+		}()
+	}
+
+	var check hstspreload.CheckAllResult
+	start(SourceCheck, func() (err error) {
+		check, err = r.checkAll(ctx, domain, hstspreload.CheckOptions{
+			Checker:     r.opts.Checker,
+			PreloadList: r.opts.PreloadList,
+		})
+		return err
+	})
+	var submission preloadapi.DomainStatus
+	if r.opts.API != nil {
+		start(SourceSubmission, func() (err error) {
+			submission, err = r.opts.API.Status(ctx, domain)
+			return err
+		})
+	}
+	var records []history.Record
+	if r.opts.History != nil {
+		start(SourceHistory, func() (err error) {
+			records, err = r.opts.History.Records(domain, time.Time{})
+			return err
+		})
+	}
+	var subdomains []string
+	if r.opts.Subdomains != nil {
+		start(SourceSubdomains, func() (err error) {
+			subdomains, err = r.opts.Subdomains.Subdomains(ctx, domain)
+			return err
+		})
+	}
+
+	for i := 0; i < sources; i++ {
+		res := <-results
+		if res.err != nil {
+			rep.SourceErrors[res.source] = res.err.Error()
+			continue
+		}
+		switch res.source {
+		case SourceCheck:
+			rep.Check = &check
+		case SourceSubmission:
+			rep.Submission = &submission
+		case SourceHistory:
+			rep.History = summarizeHistory(records)
+		case SourceSubdomains:
+			rep.Subdomains = &SubdomainExposure{Count: len(subdomains), Names: subdomains}
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if rep.Check != nil {
+		rep.Remediation = Remediate(rep.Check)
+	}
+	if len(rep.SourceErrors) == 0 {
+		rep.SourceErrors = nil
+	}
+	return rep, nil
+}
+
+// listStatus looks up `domain` in `idx`.
+func listStatus(domain string, idx preloadlist.IndexedEntries) *ListStatus {
+	entry, found := idx.Get(domain)
+	if found == preloadlist.EntryNotFound {
+		return &ListStatus{}
+	}
+	return &ListStatus{
+		Preloaded:       true,
+		Entry:           &entry,
+		CoveredByParent: found == preloadlist.AncestorEntryFound,
+	}
+}
+
+// summarizeHistory summarizes the chronologically ordered `records`.
+func summarizeHistory(records []history.Record) *HistorySummary {
+	s := &HistorySummary{Checks: len(records)}
+	if len(records) == 0 {
+		return s
+	}
+	last := records[len(records)-1]
+	s.FirstChecked = &records[0].Time
+	s.LastChecked = &last.Time
+	s.LastVerdict = last.Verdict
+	s.Trend = history.Trend(records)
+	s.Regression = history.FindRegression(records)
+	return s
+}
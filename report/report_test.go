@@ -0,0 +1,325 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chromium/hstspreload"
+	"github.com/chromium/hstspreload/chromium/preloadapi"
+	"github.com/chromium/hstspreload/chromium/preloadlist"
+	"github.com/chromium/hstspreload/history"
+)
+
+var (
+	day0 = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	day1 = day0.Add(24 * time.Hour)
+)
+
+type fakeSubdomains struct {
+	names []string
+	err   error
+}
+
+func (f fakeSubdomains) Subdomains(ctx context.Context, domain string) ([]string, error) {
+	return f.names, f.err
+}
+
+func fakeCheckAll(ctx context.Context, domain string, opts hstspreload.CheckOptions) (hstspreload.CheckAllResult, error) {
+	header := "max-age=10"
+	issues := hstspreload.Issues{Errors: []hstspreload.Issue{{
+		Code:    "header.preloadable.max_age.too_low",
+		Summary: "max-age too low",
+		Message: "<script>alert(1)</script>",
+	}}}
+	return hstspreload.CheckAllResult{
+		Domain: domain,
+		Header: &header,
+		CNAME: &hstspreload.CNAMEInfo{
+			CanonicalName: "customer.platform.example",
+			TargetEntry:   &preloadlist.Entry{Name: "platform.example", IncludeSubDomains: true},
+		},
+		Sections:    hstspreload.CheckSections{Header: issues},
+		Issues:      issues,
+		Eligibility: issues.Eligibility(),
+	}, nil
+}
+
+func testReporter(t *testing.T) *Reporter {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"name": "example.com", "status": "pending"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	store, err := history.NewDirStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := "max-age=31536000; includeSubDomains; preload"
+	failing := hstspreload.Issues{Errors: []hstspreload.Issue{{Code: "response.no_header"}}}
+	store.Add(history.NewRecord("example.com", day0, &header, hstspreload.Issues{}))
+	store.Add(history.NewRecord("example.com", day1, nil, failing))
+
+	idx := preloadlist.PreloadList{Entries: []preloadlist.Entry{
+		{Name: "com", Mode: preloadlist.ForceHTTPS, IncludeSubDomains: true},
+	}}.Index()
+
+	r := New(Options{
+		PreloadList: &idx,
+		API:         &preloadapi.Client{BaseURL: srv.URL, HTTPClient: srv.Client()},
+		History:     store,
+		Subdomains:  fakeSubdomains{names: []string{"a.example.com", "b.example.com"}},
+	})
+	r.checkAll = fakeCheckAll
+	return r
+}
+
+func TestReport(t *testing.T) {
+	rep, err := testReporter(t).Report(context.Background(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rep.Check == nil || rep.Check.Eligibility != hstspreload.Ineligible {
+		t.Errorf("Unexpected check: %v", rep.Check)
+	}
+	if rep.ListStatus == nil || !rep.ListStatus.Preloaded || !rep.ListStatus.CoveredByParent ||
+		rep.ListStatus.Entry.Name != "com" {
+		t.Errorf("Unexpected list status: %v", rep.ListStatus)
+	}
+	if rep.Submission == nil || rep.Submission.Status != preloadapi.StatusPending {
+		t.Errorf("Unexpected submission status: %v", rep.Submission)
+	}
+	if rep.History == nil || rep.History.Checks != 2 || !rep.History.FirstChecked.Equal(day0) ||
+		rep.History.LastVerdict != history.VerdictErrors || rep.History.Regression == nil {
+		t.Errorf("Unexpected history: %v", rep.History)
+	}
+	expectedSubdomains := &SubdomainExposure{Count: 2, Names: []string{"a.example.com", "b.example.com"}}
+	if !reflect.DeepEqual(rep.Subdomains, expectedSubdomains) {
+		t.Errorf("Expected subdomains %v, got %v", expectedSubdomains, rep.Subdomains)
+	}
+	if rep.SourceErrors != nil {
+		t.Errorf("Unexpected source errors: %v", rep.SourceErrors)
+	}
+}
+
+func TestReportSourceErrors(t *testing.T) {
+	r := New(Options{Subdomains: fakeSubdomains{err: errors.New("crt.sh is down")}})
+	r.checkAll = fakeCheckAll
+
+	rep, err := r.Report(context.Background(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Check == nil {
+		t.Errorf("The check should be reported even if another source fails.")
+	}
+	if rep.ListStatus != nil || rep.Submission != nil || rep.History != nil || rep.Subdomains != nil {
+		t.Errorf("Sources that are not configured should be left out: %v", rep)
+	}
+	expected := map[string]string{SourceSubdomains: "crt.sh is down"}
+	if !reflect.DeepEqual(rep.SourceErrors, expected) {
+		t.Errorf("Expected source errors %v, got %v", expected, rep.SourceErrors)
+	}
+}
+
+func TestReportContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	r := New(Options{})
+	r.checkAll = func(ctx context.Context, domain string, opts hstspreload.CheckOptions) (hstspreload.CheckAllResult, error) {
+		return hstspreload.CheckAllResult{}, ctx.Err()
+	}
+	if _, err := r.Report(ctx, "example.com"); err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	rep, err := testReporter(t).Report(context.Background(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b bytes.Buffer
+	if err := WriteHTML(&b, rep); err != nil {
+		t.Fatal(err)
+	}
+	html := b.String()
+	for _, s := range []string{
+		"HSTS preload report for example.com",
+		"ineligible",
+		"<code>max-age=10</code>",
+		"CNAME target: <code>customer.platform.example</code> (preloaded by the entry for <code>platform.example</code>)",
+		"Covered by the entry for <code>com</code>",
+		"<strong>pending</strong>",
+		"regressed from passed to errors",
+		"<code>b.example.com</code>",
+		"<h4>Serve a preloadable HSTS header</h4>",
+		"add_header Strict-Transport-Security &#34;max-age=63072000; includeSubDomains; preload&#34; always;",
+	} {
+		if !strings.Contains(html, s) {
+			t.Errorf("Expected the HTML to contain %q:\n%s", s, html)
+		}
+	}
+	if strings.Contains(html, "<script>") {
+		t.Errorf("Issue messages should be escaped:\n%s", html)
+	}
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	rep, err := testReporter(t).Report(context.Background(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b bytes.Buffer
+	if err := WriteMarkdown(&b, rep); err != nil {
+		t.Fatal(err)
+	}
+	md := b.String()
+	for _, s := range []string{
+		"# HSTS preload report for example.com\n",
+		"Eligibility: **ineligible**",
+		"Observed header: `max-age=10`",
+		"## How to fix\n\n### HSTS header\n",
+		"- **Error: max-age too low** (`header.preloadable.max_age.too_low`): \\<script\\>alert(1)\\</script\\>",
+		"#### Serve a preloadable HSTS header",
+		"nginx:\n\n```\n# In the server block for port 443:\nadd_header Strict-Transport-Security \"max-age=63072000; includeSubDomains; preload\" always;\n```",
+		"Covered by the entry for `com`",
+		"Status: **pending**",
+		"- `b.example.com`",
+	} {
+		if !strings.Contains(md, s) {
+			t.Errorf("Expected the Markdown to contain %q:\n%s", s, md)
+		}
+	}
+}
+
+func TestMarkdownCode(t *testing.T) {
+	for s, expected := range map[string]string{
+		"max-age=10": "`max-age=10`",
+		"a`b":        "``a`b``",
+		"`a":         "`` `a ``",
+	} {
+		if code := markdownCode(s); code != expected {
+			t.Errorf("Expected markdownCode(%q) to be %q, got %q", s, expected, code)
+		}
+	}
+}
+
+func TestMarkdownText(t *testing.T) {
+	for s, expected := range map[string]string{
+		"max-age=10":              "max-age=10",
+		"<b>bold</b>":             "\\<b\\>bold\\</b\\>",
+		"[link](https://evil)":    "\\[link\\](https://evil)",
+		"*a* _b_ `c` \\":          "\\*a\\* \\_b\\_ \\`c\\` \\\\",
+		"line 1\n# line 2\r\n- x": "line 1 # line 2 - x",
+	} {
+		if text := markdownText(s); text != expected {
+			t.Errorf("Expected markdownText(%q) to be %q, got %q", s, expected, text)
+		}
+	}
+}
+
+func TestRemediate(t *testing.T) {
+	tlsIssues := hstspreload.Issues{
+		Errors: []hstspreload.Issue{
+			{Code: "domain.tls.invalid_cert_chain"},
+			{Code: "domain.tls.cert.expired"},
+		},
+		Warnings: []hstspreload.Issue{{Code: "tls.obsolete_cipher_suite"}},
+	}
+	redirectIssues := hstspreload.Issues{
+		Errors: []hstspreload.Issue{
+			{Code: "redirects.http.no_redirect"},
+			{Code: "redirects.http.first_redirect.no_hsts"},
+		},
+	}
+	otherIssues := hstspreload.Issues{Warnings: []hstspreload.Issue{{Code: "example_org.caa"}}}
+
+	remediations := Remediate(&hstspreload.CheckAllResult{
+		Domain: "example.com",
+		Sections: hstspreload.CheckSections{
+			TLS:       tlsIssues,
+			Redirects: redirectIssues,
+			Other:     otherIssues,
+		},
+	})
+
+	type summary struct {
+		section string
+		fixes   map[string][]hstspreload.IssueCode
+	}
+	var got []summary
+	for _, sr := range remediations {
+		s := summary{sr.Section, map[string][]hstspreload.IssueCode{}}
+		for _, fix := range sr.Fixes {
+			s.fixes[fix.Title] = fix.Codes
+		}
+		got = append(got, s)
+	}
+	expected := []summary{
+		{"tls", map[string][]hstspreload.IssueCode{
+			"Install a valid certificate chain":         {"domain.tls.invalid_cert_chain", "domain.tls.cert.expired"},
+			"Use modern TLS versions and cipher suites": {"tls.obsolete_cipher_suite"},
+		}},
+		{"redirects", map[string][]hstspreload.IssueCode{
+			"Redirect HTTP to HTTPS on the same host": {"redirects.http.no_redirect"},
+			"Serve a preloadable HSTS header":         {"redirects.http.first_redirect.no_hsts"},
+		}},
+		{"other", map[string][]hstspreload.IssueCode{}},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+	if !reflect.DeepEqual(remediations[2].Issues, otherIssues) {
+		t.Errorf("Issues without a fix should still be listed: %v", remediations[2].Issues)
+	}
+	if snippet := remediations[1].Fixes[0].Snippets[0].Config; !strings.Contains(snippet, "server_name example.com www.example.com;") {
+		t.Errorf("Snippets should use the domain: %s", snippet)
+	}
+}
+
+func TestCrtSh(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if q := r.URL.Query().Get("q"); q != "%.example.com" {
+			t.Errorf("Unexpected query: %q", q)
+		}
+		w.Write([]byte(`[
+			{"name_value": "example.com\nwww.example.com"},
+			{"name_value": "*.Dev.Example.com"},
+			{"name_value": "www.example.com\nexample.org\nnotexample.com"}
+		]`))
+	}))
+	defer srv.Close()
+
+	c := &CrtSh{BaseURL: srv.URL, HTTPClient: srv.Client()}
+	names, err := c.Subdomains(context.Background(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"dev.example.com", "www.example.com"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected %v, got %v", expected, names)
+	}
+}
+
+func TestCrtShError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Overloaded", http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	c := &CrtSh{BaseURL: srv.URL, HTTPClient: srv.Client()}
+	if _, err := c.Subdomains(context.Background(), "example.com"); err == nil {
+		t.Errorf("Expected an error.")
+	}
+}
@@ -1,11 +1,46 @@
 package hstspreload
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"net/url"
+	"strconv"
+	"time"
 )
 
+// ResponseMetadata summarizes an HTTP response, to help diagnose issues like
+// "response.no_header".
+type ResponseMetadata struct {
+	StatusCode int    `json:"status_code"`
+	Proto      string `json:"proto"`
+	Server     string `json:"server,omitempty"`
+	// The URL that was requested. Redirects are not followed, so Location
+	// contains the redirect target (if any).
+	URL      string `json:"url"`
+	Location string `json:"location,omitempty"`
+	// The time taken to receive the response.
+	ResponseTime time.Duration `json:"response_time_ns"`
+	// The caching-related headers of the response, or nil if there are
+	// none.
+	Caching *CachingHeaders `json:"caching,omitempty"`
+}
+
+func newResponseMetadata(resp *http.Response, responseTime time.Duration) *ResponseMetadata {
+	m := &ResponseMetadata{
+		StatusCode:   resp.StatusCode,
+		Proto:        resp.Proto,
+		Server:       resp.Header.Get("Server"),
+		Location:     resp.Header.Get("Location"),
+		ResponseTime: responseTime,
+		Caching:      newCachingHeaders(resp.Header),
+	}
+	if resp.Request != nil && resp.Request.URL != nil {
+		m.URL = resp.Request.URL.String()
+	}
+	return m
+}
+
 func checkSingleHeader(resp *http.Response) (header *string, issues Issues) {
 	key := http.CanonicalHeaderKey("Strict-Transport-Security")
 	hstsHeaders := resp.Header[key]
@@ -19,9 +54,10 @@ func checkSingleHeader(resp *http.Response) (header *string, issues Issues) {
 
 	case len(hstsHeaders) > 1:
 		// TODO: Give feedback on the first(last?) HSTS header?
-		return nil, issues.addErrorf(
+		return nil, issues.addErrorWithParamsf(
 			"response.multiple_headers",
 			"Multiple HSTS headers",
+			map[string]string{"header_count": strconv.Itoa(len(hstsHeaders))},
 			"Response error: Multiple HSTS headers (number of HSTS headers: %d).", len(hstsHeaders))
 	}
 
@@ -38,14 +74,32 @@ func checkResponse(resp *http.Response, headerCondition func(string) Issues) (he
 }
 
 // PreloadableResponse checks whether an resp has a single HSTS header that
-// passes the preload requirements.
+// passes the preload requirements of DefaultPolicyVersion.
 //
 // Iff a single HSTS header was received, `header` contains its value, else
 // `header` is `nil`.
 // To interpret `issues`, see the list of conventions in the
 // documentation for Issues.
 func PreloadableResponse(resp *http.Response) (header *string, issues Issues) {
-	return checkResponse(resp, PreloadableHeaderString)
+	return defaultChecker.PreloadableResponse(resp)
+}
+
+// PreloadableResponse is like the package-level PreloadableResponse(),
+// but enforces c.PolicyVersion. `c` may be nil.
+func (c *Checker) PreloadableResponse(resp *http.Response) (header *string, issues Issues) {
+	header, _, issues = c.preloadableResponseParsed(resp)
+	return header, issues
+}
+
+// preloadableResponseParsed is like PreloadableResponse, but also returns
+// the parsed header (iff `header` is not `nil`). `c` may be nil.
+func (c *Checker) preloadableResponseParsed(resp *http.Response) (header *string, parsedHeader *HSTSHeader, issues Issues) {
+	header, issues = checkResponse(resp, func(headerString string) Issues {
+		hstsHeader, parseIssues := c.parseHeaderString(headerString)
+		parsedHeader = &hstsHeader
+		return combineIssues(parseIssues, c.PreloadableHeader(hstsHeader))
+	})
+	return header, parsedHeader, issues
 }
 
 // RemovableResponse checks whether an resp has a single HSTS header that
@@ -59,24 +113,35 @@ func RemovableResponse(resp *http.Response) (header *string, issues Issues) {
 	return checkResponse(resp, RemovableHeaderString)
 }
 
+// RemovableResponse is like the package-level RemovableResponse(), but
+// also enforces c.RemovalMaxAge (if set). `c` may be nil.
+func (c *Checker) RemovableResponse(resp *http.Response) (header *string, issues Issues) {
+	return checkResponse(resp, c.RemovableHeaderString)
+}
+
 // getFirstResponse makes a GET request to `initialURL` without redirecting.
-func getFirstResponse(initialURL string) (*http.Response, error) {
-	return getFirstResponseWithTransport(initialURL, nil)
+// `c` may be nil.
+func getFirstResponse(ctx context.Context, initialURL string, c *Checker) (*http.Response, error) {
+	return getFirstResponseWithTransport(ctx, initialURL, c.transport(false), c)
 }
 
-// `transport` can be `nil`.
-func getFirstResponseWithTransport(initialURL string, transport *http.Transport) (*http.Response, error) {
+// `c` may be nil.
+func getFirstResponseWithTransport(ctx context.Context, initialURL string, transport http.RoundTripper, c *Checker) (*http.Response, error) {
+	return getFirstResponseAttempt(ctx, initialURL, transport, c, newConnectAttempt())
+}
+
+// getFirstResponseAttempt is like getFirstResponseWithTransport, but records
+// the progress of the request in `attempt`. `c` may be nil.
+func getFirstResponseAttempt(ctx context.Context, initialURL string, transport http.RoundTripper, c *Checker, attempt *connectAttempt) (*http.Response, error) {
+	defer attempt.finish()
 	redirectPrevented := errors.New("REDIRECT_PREVENTED")
 
 	client := http.Client{
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			return redirectPrevented
 		},
-		Timeout: dialTimeout,
-	}
-
-	if transport != nil {
-		client.Transport = transport
+		Timeout:   c.timeout(),
+		Transport: transport,
 	}
 
 	isRedirectPrevented := func(err error) bool {
@@ -84,7 +149,7 @@ func getFirstResponseWithTransport(initialURL string, transport *http.Transport)
 		return ok && urlError.Err == redirectPrevented
 	}
 
-	req, err := http.NewRequest("GET", initialURL, nil)
+	req, err := http.NewRequestWithContext(attempt.withTrace(ctx), "GET", initialURL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -93,7 +158,8 @@ func getFirstResponseWithTransport(initialURL string, transport *http.Transport)
 	resp, err := client.Do(req)
 
 	if isRedirectPrevented(err) {
-		return resp, nil
+		err = nil
 	}
+	c.logResponse(initialURL, resp, err)
 	return resp, err
 }
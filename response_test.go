@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"testing"
+	"time"
 )
 
 /******** Examples. ********/
@@ -147,3 +148,59 @@ func TestPreloabableResponseAndRemovableResponse(t *testing.T) {
 		}
 	}
 }
+
+func TestPreloadableResponseParsed(t *testing.T) {
+	resp := &http.Response{Header: http.Header{}}
+	resp.Header.Add("Strict-Transport-Security", "max-age=31536000; includeSubDomains; preload;")
+
+	header, parsedHeader, issues := defaultChecker.preloadableResponseParsed(resp)
+	if header == nil || parsedHeader == nil {
+		t.Fatalf("Expected a header and a parsed header.")
+	}
+	expectedHeader := HSTSHeader{
+		MaxAge:            &MaxAge{Seconds: 31536000},
+		IncludeSubDomains: true,
+		Preload:           true,
+	}
+	if !headersEqual(*parsedHeader, expectedHeader) {
+		t.Errorf(headersShouldBeEqual, *parsedHeader, expectedHeader)
+	}
+	expectedIssues := Issues{Warnings: []Issue{{Code: "header.parse.empty_directive"}}}
+	if !issues.Match(expectedIssues) {
+		t.Errorf(issuesShouldMatch, issues, expectedIssues)
+	}
+
+	resp.Header.Add("Strict-Transport-Security", "max-age=0")
+	header, parsedHeader, _ = defaultChecker.preloadableResponseParsed(resp)
+	if header != nil || parsedHeader != nil {
+		t.Errorf("Did not expect a header for a response with multiple HSTS headers.")
+	}
+}
+
+func TestNewResponseMetadata(t *testing.T) {
+	req, err := http.NewRequest("GET", "https://example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := &http.Response{
+		StatusCode: http.StatusMovedPermanently,
+		Proto:      "HTTP/2.0",
+		Header:     http.Header{},
+		Request:    req,
+	}
+	resp.Header.Set("Server", "nginx")
+	resp.Header.Set("Location", "https://www.example.com/")
+
+	m := newResponseMetadata(resp, time.Second)
+	expected := ResponseMetadata{
+		StatusCode:   http.StatusMovedPermanently,
+		Proto:        "HTTP/2.0",
+		Server:       "nginx",
+		URL:          "https://example.com",
+		Location:     "https://www.example.com/",
+		ResponseTime: time.Second,
+	}
+	if *m != expected {
+		t.Errorf("Unexpected metadata: %#v", *m)
+	}
+}
@@ -0,0 +1,166 @@
+package hstspreload
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// A ResultCache caches the results of PreloadableDomainResult() for a short
+// time, so that services embedding this package (e.g. a web frontend) don't
+// run a full check again when a user submits the same domain twice within
+// seconds.
+//
+// Results are keyed by domain and by Checker, so that checks with different
+// policies are cached separately. Domains are compared in their lowercase
+// ASCII form (see resultCacheDomain()), so e.g. "Example.com" and
+// "example.com" share a result. Checkers are compared by identity, since
+// they hold functions and transports that cannot be compared: callers must
+// reuse the same *Checker for the same policy, and must not modify it while
+// it is used with the cache.
+//
+// A ResultCache is safe for concurrent use, and concurrent checks of the same
+// domain with the same Checker are coalesced into one. Cached results are
+// shared between callers and must not be modified.
+type ResultCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[resultCacheKey]*resultCacheEntry
+
+	// check runs a check that is not cached. It can be replaced in tests.
+	check func(c *Checker, domain string) DomainResult
+}
+
+type resultCacheKey struct {
+	checker *Checker
+	domain  string
+}
+
+type resultCacheEntry struct {
+	// done is closed when the check has finished.
+	done    chan struct{}
+	result  DomainResult
+	expires time.Time
+	// panicked is set (before done is closed) if the check panicked, in
+	// which case the entry is removed and waiters run the check again.
+	panicked bool
+}
+
+// NewResultCache returns an empty ResultCache that keeps results for `ttl`.
+func NewResultCache(ttl time.Duration) *ResultCache {
+	return &ResultCache{
+		ttl:     ttl,
+		entries: make(map[resultCacheKey]*resultCacheEntry),
+		check:   (*Checker).PreloadableDomainResult,
+	}
+}
+
+// PreloadableDomain is like c.PreloadableDomain(), but uses cached results.
+// `c` may be nil, which uses the default configuration.
+func (rc *ResultCache) PreloadableDomain(c *Checker, domain string) (header *string, issues Issues) {
+	r := rc.PreloadableDomainResult(c, domain)
+	return r.Header, r.Issues
+}
+
+// PreloadableDomainResult is like c.PreloadableDomainResult(), but returns a
+// cached result if `domain` was checked with `c` less than the TTL ago, or
+// waits for the result if the same check is in progress. `c` may be nil,
+// which uses the default configuration.
+func (rc *ResultCache) PreloadableDomainResult(c *Checker, domain string) DomainResult {
+	if c == nil {
+		c = defaultChecker
+	}
+	domain = resultCacheDomain(domain)
+	key := resultCacheKey{checker: c, domain: domain}
+
+	rc.mu.Lock()
+	entry, ok := rc.entries[key]
+	if ok {
+		select {
+		case <-entry.done:
+			if time.Now().After(entry.expires) {
+				ok = false
+			}
+		default:
+			// A check is in progress.
+		}
+	}
+	if !ok {
+		rc.removeExpiredLocked()
+		entry = &resultCacheEntry{done: make(chan struct{})}
+		rc.entries[key] = entry
+		rc.mu.Unlock()
+
+		rc.run(c, domain, key, entry)
+	} else {
+		rc.mu.Unlock()
+	}
+
+	<-entry.done
+	if entry.panicked {
+		return rc.PreloadableDomainResult(c, domain)
+	}
+	return entry.result
+}
+
+// run performs the check for `entry`. If the check panics, the entry is
+// removed before the panic propagates, so that waiters are not blocked
+// forever and later calls don't receive an empty result.
+func (rc *ResultCache) run(c *Checker, domain string, key resultCacheKey, entry *resultCacheEntry) {
+	finished := false
+	defer func() {
+		if !finished {
+			rc.mu.Lock()
+			if rc.entries[key] == entry {
+				delete(rc.entries, key)
+			}
+			rc.mu.Unlock()
+			entry.panicked = true
+		}
+		close(entry.done)
+	}()
+
+	entry.result = rc.check(c, domain)
+	entry.expires = time.Now().Add(rc.ttl)
+	finished = true
+}
+
+// Forget removes the cached results for `domain` (for all Checkers), e.g.
+// after the site owner reports that they fixed an issue.
+func (rc *ResultCache) Forget(domain string) {
+	domain = resultCacheDomain(domain)
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	for key := range rc.entries {
+		if key.domain == domain {
+			delete(rc.entries, key)
+		}
+	}
+}
+
+// resultCacheDomain returns the domain that `domain` is cached (and
+// checked) as: lowercase, with Unicode labels converted to punycode. Other
+// differences (e.g. a trailing dot) are kept, since the checks report them.
+func resultCacheDomain(domain string) string {
+	ascii, issues := toASCIIDomain(strings.ToLower(domain))
+	if len(issues.Errors) > 0 {
+		return domain
+	}
+	return ascii
+}
+
+// removeExpiredLocked removes the expired entries, so that the cache does not
+// grow without bounds. rc.mu must be held.
+func (rc *ResultCache) removeExpiredLocked() {
+	now := time.Now()
+	for key, entry := range rc.entries {
+		select {
+		case <-entry.done:
+			if now.After(entry.expires) {
+				delete(rc.entries, key)
+			}
+		default:
+		}
+	}
+}
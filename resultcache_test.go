@@ -0,0 +1,156 @@
+package hstspreload
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func countingResultCache(ttl time.Duration) (*ResultCache, *int32, chan struct{}) {
+	var checks int32
+	release := make(chan struct{})
+	rc := NewResultCache(ttl)
+	rc.check = func(c *Checker, domain string) DomainResult {
+		atomic.AddInt32(&checks, 1)
+		<-release
+		return DomainResult{Issues: Issues{Warnings: []Issue{{Code: IssueCode(domain)}}}}
+	}
+	return rc, &checks, release
+}
+
+func TestResultCacheCoalescesChecks(t *testing.T) {
+	rc, checks, release := countingResultCache(time.Minute)
+
+	var wg sync.WaitGroup
+	results := make([]DomainResult, 5)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = rc.PreloadableDomainResult(nil, "example.com")
+		}(i)
+	}
+	// Let the goroutines start waiting for the first check.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := atomic.LoadInt32(checks); n != 1 {
+		t.Errorf("Expected 1 check, got %d.", n)
+	}
+	for _, r := range results {
+		expected := Issues{Warnings: []Issue{{Code: "example.com"}}}
+		if !r.Issues.Match(expected) {
+			t.Errorf(issuesShouldMatch, r.Issues, expected)
+		}
+	}
+
+	// A cached result.
+	rc.PreloadableDomainResult(nil, "example.com")
+	if n := atomic.LoadInt32(checks); n != 1 {
+		t.Errorf("Expected the result to be cached, got %d checks.", n)
+	}
+}
+
+func TestResultCacheKeys(t *testing.T) {
+	rc, checks, release := countingResultCache(time.Minute)
+	close(release)
+
+	strict := &Checker{PromoteWarnings: []IssueCode{"domain.www.no_tls"}}
+	rc.PreloadableDomainResult(nil, "example.com")
+	rc.PreloadableDomainResult(strict, "example.com")
+	rc.PreloadableDomainResult(strict, "example.net")
+	rc.PreloadableDomainResult(strict, "example.net")
+	if n := atomic.LoadInt32(checks); n != 3 {
+		t.Errorf("Expected 3 checks, got %d.", n)
+	}
+
+	rc.Forget("example.net")
+	rc.PreloadableDomainResult(strict, "example.net")
+	if n := atomic.LoadInt32(checks); n != 4 {
+		t.Errorf("Expected a new check after Forget(), got %d checks.", n)
+	}
+}
+
+func TestResultCacheCanonicalDomains(t *testing.T) {
+	rc, checks, release := countingResultCache(time.Minute)
+	close(release)
+
+	for _, domain := range []string{"example.com", "Example.COM"} {
+		r := rc.PreloadableDomainResult(nil, domain)
+		expected := Issues{Warnings: []Issue{{Code: "example.com"}}}
+		if !r.Issues.Match(expected) {
+			t.Errorf("[%s] "+issuesShouldMatch, domain, r.Issues, expected)
+		}
+	}
+	rc.PreloadableDomainResult(nil, "Bücher.example")
+	rc.PreloadableDomainResult(nil, "xn--bcher-kva.example")
+	if n := atomic.LoadInt32(checks); n != 2 {
+		t.Errorf("Expected 2 checks, got %d.", n)
+	}
+
+	// Trailing dots are reported by the checks, so they are not removed.
+	rc.PreloadableDomainResult(nil, "example.com.")
+	if n := atomic.LoadInt32(checks); n != 3 {
+		t.Errorf("Expected 3 checks, got %d.", n)
+	}
+
+	rc.Forget("EXAMPLE.com")
+	rc.PreloadableDomainResult(nil, "example.com")
+	if n := atomic.LoadInt32(checks); n != 4 {
+		t.Errorf("Expected a new check after Forget(), got %d checks.", n)
+	}
+}
+
+func TestResultCacheExpiry(t *testing.T) {
+	rc, checks, release := countingResultCache(0)
+	close(release)
+
+	rc.PreloadableDomainResult(nil, "example.com")
+	time.Sleep(time.Millisecond)
+	rc.PreloadableDomainResult(nil, "example.com")
+	if n := atomic.LoadInt32(checks); n != 2 {
+		t.Errorf("Expected expired results to be checked again, got %d checks.", n)
+	}
+	if len(rc.entries) != 1 {
+		t.Errorf("Expected expired entries to be removed, got %d entries.", len(rc.entries))
+	}
+}
+
+func TestResultCachePanic(t *testing.T) {
+	var checks int32
+	rc := NewResultCache(time.Minute)
+	rc.check = func(c *Checker, domain string) DomainResult {
+		if atomic.AddInt32(&checks, 1) == 1 {
+			panic("check failed")
+		}
+		return DomainResult{Issues: Issues{Warnings: []Issue{{Code: IssueCode(domain)}}}}
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected the panic of the check to propagate.")
+			}
+		}()
+		rc.PreloadableDomainResult(nil, "example.com")
+	}()
+
+	done := make(chan DomainResult)
+	go func() {
+		done <- rc.PreloadableDomainResult(nil, "example.com")
+	}()
+	select {
+	case r := <-done:
+		expected := Issues{Warnings: []Issue{{Code: "example.com"}}}
+		if !r.Issues.Match(expected) {
+			t.Errorf(issuesShouldMatch, r.Issues, expected)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for the check after a panic.")
+	}
+	if n := atomic.LoadInt32(&checks); n != 2 {
+		t.Errorf("Expected the check to run again after a panic, got %d checks.", n)
+	}
+}
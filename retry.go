@@ -0,0 +1,267 @@
+package hstspreload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// A RetryPolicy controls how network probes (the initial HTTPS request,
+// following redirects, and the www checks) are retried when they fail with
+// a transient error, so that a flaky origin doesn't make results
+// nondeterministic.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts for each probe. Values
+	// below 1 are treated as 1 (no retries).
+	MaxAttempts int
+	// Backoff is the delay before the first retry. It doubles for each
+	// further retry, up to MaxBackoff (if positive).
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Jitter randomizes each delay by up to this fraction (between 0 and 1)
+	// in either direction, so that parallel scans don't retry in lockstep.
+	Jitter float64
+	// Retryable classifies errors. If nil, IsRetryableError() is used.
+	Retryable func(error) bool
+	// MaxRetryAfter bounds the delay before the single retry of a probe
+	// that was rate limited (see RateLimitError), regardless of the other
+	// fields. If zero, DefaultMaxRetryAfter is used. If negative, rate
+	// limited probes are not retried.
+	MaxRetryAfter time.Duration
+}
+
+const (
+	// DefaultMaxRetryAfter is the default of RetryPolicy.MaxRetryAfter.
+	DefaultMaxRetryAfter = 10 * time.Second
+
+	// defaultRetryAfter is the delay before retrying a rate limited probe
+	// whose response has no valid Retry-After header.
+	defaultRetryAfter = time.Second
+)
+
+// DefaultRetryPolicy is used if Checker.Retry is nil. It retries once,
+// without delay.
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 2}
+
+// IsRetryableError returns whether `err` is likely to be transient:
+// timeouts, temporary DNS failures, refused or reset connections, and
+// connections that were closed unexpectedly. Errors that will most likely
+// recur (e.g. an invalid certificate or a nonexistent domain) are not
+// retryable.
+func IsRetryableError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTimeout || dnsErr.IsTemporary
+	}
+	return isTimeout(err) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// A RateLimitError is returned by probes that receive a 429 (Too Many
+// Requests) response, or a 503 (Service Unavailable) response with a
+// Retry-After header. Such responses usually come from a WAF or CDN that
+// throttles scans, rather than from the site itself.
+type RateLimitError struct {
+	URL        string
+	StatusCode int
+	// RetryAfter is the delay requested by the Retry-After header, or
+	// zero if there was none.
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("%s: rate limited (status code %d, retry after %s)", e.URL, e.StatusCode, e.RetryAfter)
+	}
+	return fmt.Sprintf("%s: rate limited (status code %d)", e.URL, e.StatusCode)
+}
+
+// rateLimitError returns a *RateLimitError if `resp` (for `url`) indicates
+// rate limiting, or nil otherwise.
+func rateLimitError(url string, resp *http.Response, now time.Time) error {
+	retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), now)
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+	case resp.StatusCode == http.StatusServiceUnavailable && ok:
+	default:
+		return nil
+	}
+	return &RateLimitError{URL: url, StatusCode: resp.StatusCode, RetryAfter: retryAfter}
+}
+
+// parseRetryAfter parses the value of a Retry-After header, which is
+// either a number of seconds or an HTTP date. Dates in the past result in
+// a delay of zero.
+func parseRetryAfter(value string, now time.Time) (delay time.Duration, ok bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	t, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if delay = t.Sub(now); delay < 0 {
+		delay = 0
+	}
+	return delay, true
+}
+
+// retryAfter returns the delay before retrying the probe that failed with
+// `rl`, and whether it should be retried at all.
+func (p RetryPolicy) retryAfter(rl *RateLimitError) (time.Duration, bool) {
+	max := p.MaxRetryAfter
+	if max == 0 {
+		max = DefaultMaxRetryAfter
+	}
+	if max < 0 {
+		return 0, false
+	}
+	d := rl.RetryAfter
+	if d == 0 {
+		d = defaultRetryAfter
+	}
+	if d > max {
+		d = max
+	}
+	return d, true
+}
+
+// retryable classifies `err` according to `p`.
+func (p RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return IsRetryableError(err)
+}
+
+// delay returns the delay before retry number `retry` (starting at 1).
+func (p RetryPolicy) delay(retry int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < retry && d > 0; i++ {
+		d *= 2
+		if p.MaxBackoff > 0 && d >= p.MaxBackoff {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		d += time.Duration(float64(d) * p.Jitter * (2*rand.Float64() - 1))
+	}
+	return d
+}
+
+// retryPolicy returns the retry policy of `c`. `c` may be nil.
+func (c *Checker) retryPolicy() RetryPolicy {
+	if c == nil || c.Retry == nil {
+		return DefaultRetryPolicy
+	}
+	return *c.Retry
+}
+
+// withRetries calls `probe` until it succeeds, fails with an error that is
+// not retryable, or the attempts of the retry policy of `c` are exhausted.
+// A probe that is rate limited (see RateLimitError) is retried once after
+// the delay requested by the server, bounded by the MaxRetryAfter of the
+// policy. That retry doesn't count as an attempt, since rate limiting is
+// not a sign of a flaky connection. It returns the number of attempts and
+// the error of the last one. `c` may be nil.
+func (c *Checker) withRetries(ctx context.Context, probe func() error) (attempts int, err error) {
+	p := c.retryPolicy()
+	rateLimitRetried := false
+	attempts = 1
+	for {
+		err = probe()
+		if err == nil || ctx.Err() != nil {
+			return attempts, err
+		}
+
+		var delay time.Duration
+		var rl *RateLimitError
+		if errors.As(err, &rl) {
+			var ok bool
+			if delay, ok = p.retryAfter(rl); !ok || rateLimitRetried {
+				return attempts, err
+			}
+			rateLimitRetried = true
+			c.logf("Retrying in %s after attempt %d was rate limited: %s", delay, attempts, err)
+		} else {
+			if attempts >= p.MaxAttempts || !p.retryable(err) {
+				return attempts, err
+			}
+			delay = p.delay(attempts)
+			c.logf("Retrying in %s after attempt %d of %d failed: %s", delay, attempts, p.MaxAttempts, err)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return attempts, err
+		}
+		if rl == nil {
+			attempts++
+		}
+	}
+}
+
+// retriedIssues reports that a probe of `target` only succeeded after
+// `attempts` attempts (as counted by withRetries()), using `code` (which
+// ends in ".retried" by convention).
+func retriedIssues(code IssueCode, target string, attempts int) Issues {
+	if attempts <= 1 {
+		return Issues{}
+	}
+	return Issues{}.addWarningWithParamsf(
+		code,
+		"Flaky connection",
+		map[string]string{"url": target, "attempts": strconv.Itoa(attempts)},
+		"The request to `%s` only succeeded after %d attempts. "+
+			"Intermittent failures make the site unreliable for users, "+
+			"and make check results nondeterministic.",
+		target,
+		attempts,
+	)
+}
+
+// rateLimitedIssues reports that the probe of `target` was rate limited,
+// even after a retry.
+func rateLimitedIssues(target string, rl *RateLimitError) Issues {
+	retryAfter := "none"
+	if rl.RetryAfter > 0 {
+		retryAfter = rl.RetryAfter.String()
+	}
+	return Issues{}.addErrorWithParamsf(
+		IssueCode("domain.http.rate_limited"),
+		"Rate limited",
+		map[string]string{"url": target, "status_code": strconv.Itoa(rl.StatusCode), "retry_after": retryAfter},
+		"The request to `%s` was rate limited (status code %d), even after a retry, "+
+			"so we could not check the site. This usually means that a firewall or CDN "+
+			"throttles automated requests. Please try again later.",
+		target,
+		rl.StatusCode,
+	)
+}
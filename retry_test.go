@@ -0,0 +1,250 @@
+package hstspreload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := RetryPolicy{Backoff: 100 * time.Millisecond, MaxBackoff: 350 * time.Millisecond}
+	for retry, expected := range map[int]time.Duration{
+		1: 100 * time.Millisecond,
+		2: 200 * time.Millisecond,
+		3: 350 * time.Millisecond,
+		9: 350 * time.Millisecond,
+	} {
+		if d := p.delay(retry); d != expected {
+			t.Errorf("Expected a delay of %v before retry %d, got %v", expected, retry, d)
+		}
+	}
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if d := p.delay(1); d < 50*time.Millisecond || d > 150*time.Millisecond {
+			t.Fatalf("Delay %v is outside of the jitter range.", d)
+		}
+	}
+
+	if d := (RetryPolicy{}).delay(3); d != 0 {
+		t.Errorf("Expected no delay without backoff, got %v", d)
+	}
+}
+
+func TestIsRetryableError(t *testing.T) {
+	for _, tt := range []struct {
+		err       error
+		retryable bool
+	}{
+		{nil, false},
+		{errors.New("x509: certificate signed by unknown authority"), false},
+		{context.Canceled, false},
+		{context.DeadlineExceeded, true},
+		{fmt.Errorf("Get \"https://example.com\": %w", io.EOF), true},
+		{&net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, true},
+		{&net.OpError{Op: "read", Err: syscall.ECONNRESET}, true},
+		{&net.DNSError{Err: "no such host", IsNotFound: true}, false},
+		{&net.DNSError{Err: "server misbehaving", IsTemporary: true}, true},
+	} {
+		if IsRetryableError(tt.err) != tt.retryable {
+			t.Errorf("Expected IsRetryableError(%v) to be %t", tt.err, tt.retryable)
+		}
+	}
+}
+
+func TestWithRetries(t *testing.T) {
+	transient := &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}
+	permanent := errors.New("permanent")
+
+	for _, tt := range []struct {
+		description      string
+		policy           *RetryPolicy
+		errs             []error
+		expectedAttempts int
+		expectedErr      error
+	}{
+		{"success", nil, []error{nil}, 1, nil},
+		{"default policy retries once", nil, []error{transient, nil}, 2, nil},
+		{"default policy gives up", nil, []error{transient, transient, nil}, 2, transient},
+		{"permanent error", nil, []error{permanent, nil}, 1, permanent},
+		{"no retries", &RetryPolicy{}, []error{transient, nil}, 1, transient},
+		{"more attempts", &RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}, []error{transient, transient, nil}, 3, nil},
+		{
+			"custom classification",
+			&RetryPolicy{MaxAttempts: 2, Retryable: func(err error) bool { return err == permanent }},
+			[]error{permanent, nil}, 2, nil,
+		},
+	} {
+		c := &Checker{Retry: tt.policy}
+		calls := 0
+		attempts, err := c.withRetries(context.Background(), func() error {
+			calls++
+			return tt.errs[calls-1]
+		})
+		if attempts != tt.expectedAttempts || calls != tt.expectedAttempts || err != tt.expectedErr {
+			t.Errorf("[%s] Expected %d attempts and error %v, got %d attempts (%d calls) and error %v",
+				tt.description, tt.expectedAttempts, tt.expectedErr, attempts, calls, err)
+		}
+	}
+}
+
+func TestWithRetriesContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &Checker{Retry: &RetryPolicy{MaxAttempts: 3, Backoff: time.Hour}}
+
+	start := time.Now()
+	attempts, _ := c.withRetries(ctx, func() error {
+		cancel()
+		return context.DeadlineExceeded
+	})
+	if attempts != 1 || time.Since(start) > time.Minute {
+		t.Errorf("Retries should stop when the context is done.")
+	}
+}
+
+func TestGetResponseRetried(t *testing.T) {
+	var requests int32
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			// Drop the first request.
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				conn.Close()
+			}
+			return
+		}
+	}))
+	defer srv.Close()
+
+	c := &Checker{
+		ConnectTo: map[string]string{"example.com:443": srv.Listener.Addr().String()},
+		Transport: srv.Client().Transport.(*http.Transport),
+	}
+	resp, insecureFallback, issues := getResponse(context.Background(), "example.com", c)
+	expected := Issues{Warnings: []Issue{{Code: "domain.https.retried"}}}
+	if !issues.Match(expected) {
+		t.Errorf(issuesShouldMatch, issues, expected)
+	}
+	if resp == nil || insecureFallback {
+		t.Errorf("Expected a response after retrying.")
+	} else {
+		resp.Body.Close()
+	}
+	if len(issues.Warnings) == 1 && issues.Warnings[0].Params["attempts"] != "2" {
+		t.Errorf("Unexpected params: %#v", issues.Warnings[0].Params)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		{"", 0, false},
+		{"120", 2 * time.Minute, true},
+		{" 0 ", 0, true},
+		{"-1", 0, false},
+		{"Wed, 01 Jan 2020 00:00:30 GMT", 30 * time.Second, true},
+		{"Tue, 31 Dec 2019 00:00:00 GMT", 0, true},
+		{"soon", 0, false},
+	} {
+		if d, ok := parseRetryAfter(tt.value, now); d != tt.expected || ok != tt.ok {
+			t.Errorf("Expected parseRetryAfter(%q) to be %v, %t, got %v, %t", tt.value, tt.expected, tt.ok, d, ok)
+		}
+	}
+}
+
+func TestWithRetriesRateLimited(t *testing.T) {
+	rateLimited := &RateLimitError{URL: "https://example.com", StatusCode: 429, RetryAfter: time.Hour}
+	transient := &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}
+
+	for _, tt := range []struct {
+		description      string
+		policy           *RetryPolicy
+		errs             []error
+		expectedCalls    int
+		expectedAttempts int
+		expectedErr      error
+	}{
+		{"retried once", &RetryPolicy{MaxRetryAfter: time.Millisecond}, []error{rateLimited, nil}, 2, 1, nil},
+		{"only retried once", &RetryPolicy{MaxRetryAfter: time.Millisecond}, []error{rateLimited, rateLimited, nil}, 2, 1, rateLimited},
+		{"not retried", &RetryPolicy{MaxRetryAfter: -1}, []error{rateLimited, nil}, 1, 1, rateLimited},
+		{"does not use up attempts", &RetryPolicy{MaxAttempts: 2, MaxRetryAfter: time.Millisecond}, []error{rateLimited, transient, nil}, 3, 2, nil},
+	} {
+		c := &Checker{Retry: tt.policy}
+		calls := 0
+		start := time.Now()
+		attempts, err := c.withRetries(context.Background(), func() error {
+			calls++
+			return tt.errs[calls-1]
+		})
+		if attempts != tt.expectedAttempts || calls != tt.expectedCalls || err != tt.expectedErr {
+			t.Errorf("[%s] Expected %d attempts (%d calls) and error %v, got %d attempts (%d calls) and error %v",
+				tt.description, tt.expectedAttempts, tt.expectedCalls, tt.expectedErr, attempts, calls, err)
+		}
+		if time.Since(start) > time.Minute {
+			t.Errorf("[%s] The delay should be bounded by MaxRetryAfter.", tt.description)
+		}
+	}
+}
+
+func TestGetResponseRateLimited(t *testing.T) {
+	var requests int32
+	var limit int32
+	var status int32
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) <= atomic.LoadInt32(&limit) {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(int(atomic.LoadInt32(&status)))
+		}
+	}))
+	defer srv.Close()
+
+	c := &Checker{
+		ConnectTo: map[string]string{"example.com:443": srv.Listener.Addr().String()},
+		Transport: srv.Client().Transport.(*http.Transport),
+		Retry:     &RetryPolicy{MaxAttempts: 2, MaxRetryAfter: time.Millisecond},
+	}
+
+	// A single rate limited response is retried, without a warning about a
+	// flaky connection.
+	atomic.StoreInt32(&limit, 1)
+	atomic.StoreInt32(&status, http.StatusTooManyRequests)
+	resp, _, issues := getResponse(context.Background(), "example.com", c)
+	expected := Issues{}
+	if !issues.Match(expected) {
+		t.Errorf(issuesShouldMatch, issues, expected)
+	}
+	if resp != nil {
+		resp.Body.Close()
+	}
+
+	if n := atomic.LoadInt32(&requests); resp == nil || n != 2 {
+		t.Errorf("Expected a response after 2 requests, got %d requests.", n)
+	}
+
+	atomic.StoreInt32(&requests, 0)
+	atomic.StoreInt32(&limit, 100)
+	atomic.StoreInt32(&status, http.StatusServiceUnavailable)
+	resp, _, issues = getResponse(context.Background(), "example.com", c)
+	expected = Issues{Errors: []Issue{{Code: "domain.http.rate_limited"}}}
+	if !issues.Match(expected) {
+		t.Errorf(issuesShouldMatch, issues, expected)
+	}
+	if n := atomic.LoadInt32(&requests); resp != nil || n != 2 {
+		t.Errorf("Expected no response after 2 requests, got %d requests.", n)
+	}
+	if len(issues.Errors) == 1 && issues.Errors[0].Params["status_code"] != "503" {
+		t.Errorf("Unexpected params: %#v", issues.Errors[0].Params)
+	}
+}
@@ -0,0 +1,68 @@
+package hstspreload
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// EscapeControlCharacters escapes the control characters (including
+// terminal escape sequences) and bidirectional formatting characters in
+// `s`, so that it can be printed without changing the display of the
+// surrounding text. Other characters, including non-ASCII letters, are kept.
+//
+// Issue messages are already escaped, but other values that come from a
+// checked site (e.g. a header value) should be escaped before printing them
+// to a terminal.
+func EscapeControlCharacters(s string) string {
+	if strings.IndexFunc(s, isUnsafeRune) < 0 {
+		return s
+	}
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case !isUnsafeRune(r):
+			b.WriteRune(r)
+		case r < 0x80:
+			fmt.Fprintf(&b, `\x%02x`, r)
+		default:
+			fmt.Fprintf(&b, `\u%04x`, r)
+		}
+	}
+	return b.String()
+}
+
+// isUnsafeRune returns whether `r` can change how the text around it is
+// displayed.
+func isUnsafeRune(r rune) bool {
+	return unicode.IsControl(r) || unicode.Is(unicode.Bidi_Control, r) || r == unicode.ReplacementChar
+}
+
+// sanitizeArgs escapes the string values in `args` (see
+// EscapeControlCharacters()), since they may come from the checked site
+// (e.g. header directives or certificate names).
+func sanitizeArgs(args []interface{}) []interface{} {
+	sanitized := args
+	copied := false
+	for i, arg := range args {
+		var s string
+		switch v := arg.(type) {
+		case string:
+			s = v
+		case error:
+			s = v.Error()
+		case fmt.Stringer:
+			s = v.String()
+		default:
+			continue
+		}
+		if escaped := EscapeControlCharacters(s); escaped != s {
+			if !copied {
+				sanitized = append([]interface{}(nil), args...)
+				copied = true
+			}
+			sanitized[i] = escaped
+		}
+	}
+	return sanitized
+}
@@ -0,0 +1,56 @@
+package hstspreload
+
+import (
+	"errors"
+	"testing"
+)
+
+var escapeControlCharactersTests = []struct {
+	input    string
+	expected string
+}{
+	{"max-age=31536000; preload", "max-age=31536000; preload"},
+	{"bücher.example", "bücher.example"},
+	{"\x1b[31mred\x1b[0m", `\x1b[31mred\x1b[0m`},
+	{"line\nbreak\r", `line\x0abreak\x0d`},
+	{"abc\u202edcba", `abc\u202edcba`},
+	{"\u0085", `\u0085`},
+}
+
+func TestEscapeControlCharacters(t *testing.T) {
+	for _, tt := range escapeControlCharactersTests {
+		if actual := EscapeControlCharacters(tt.input); actual != tt.expected {
+			t.Errorf("EscapeControlCharacters(%q) = %q, expected %q", tt.input, actual, tt.expected)
+		}
+	}
+}
+
+func TestSanitizeArgs(t *testing.T) {
+	args := []interface{}{"\x1b[2J", errors.New("bad\x07"), 42, "fine"}
+	sanitized := sanitizeArgs(args)
+
+	expected := []interface{}{`\x1b[2J`, `bad\x07`, 42, "fine"}
+	for i := range expected {
+		if sanitized[i] != expected[i] {
+			t.Errorf("Unexpected sanitized arg #%d: %#v", i, sanitized[i])
+		}
+	}
+	if args[0] != "\x1b[2J" {
+		t.Errorf("sanitizeArgs() should not modify its input.")
+	}
+}
+
+func TestIssueMessagesAreEscaped(t *testing.T) {
+	_, issues := ParseHeaderString("max-age=31536000; \x1b[31mevil")
+
+	expected := Issues{Warnings: []Issue{{
+		Code:    "header.parse.invalid_token",
+		Message: "The header contains a directive with characters that are not allowed in a directive name or value: `\\x1b[31mevil`",
+	}}}
+	if !issues.Match(expected) {
+		t.Fatalf(issuesShouldMatch, issues, expected)
+	}
+	if directive := issues.Warnings[0].Params["directive"]; directive != "\x1b[31mevil" {
+		t.Errorf("Params should contain the raw directive, got %q", directive)
+	}
+}
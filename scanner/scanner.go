@@ -0,0 +1,183 @@
+// Package scanner scans the domains that are pending on hstspreload.org or
+// already on the Chromium preload list, and returns the results rather
+// than printing them, so that services can monitor the pending queue.
+package scanner
+
+import (
+	"context"
+	"sort"
+
+	"github.com/chromium/hstspreload/batch"
+	"github.com/chromium/hstspreload/chromium/preloadapi"
+	"github.com/chromium/hstspreload/chromium/preloadlist"
+)
+
+// Options configures a Scanner.
+type Options struct {
+	// Batch configures the checks of the individual domains.
+	Batch batch.Options
+	// API is used to get the pending domains. If nil, the default client
+	// for hstspreload.org is used.
+	API *preloadapi.Client
+	// Progress is called after each domain has been checked, with the
+	// number of domains checked so far, the total number of domains, and
+	// the result for the domain. It is called from a single goroutine, and
+	// may be nil.
+	Progress func(checked int, total int, result batch.Result)
+}
+
+// A Scanner checks all pending or preloaded domains.
+type Scanner struct {
+	opts Options
+
+	// run and runRemovable check the domains in parallel for preload and
+	// removal requirements. They can be replaced in tests.
+	run          func(ctx context.Context, domains []string) <-chan batch.Result
+	runRemovable func(ctx context.Context, domains []string) <-chan batch.Result
+	// latestList gets the preload list. It can be replaced in tests.
+	latestList func() (preloadlist.PreloadList, error)
+}
+
+// New returns a Scanner using the given options.
+func New(opts Options) *Scanner {
+	runner := batch.New(opts.Batch)
+	return &Scanner{
+		opts:         opts,
+		run:          runner.Run,
+		runRemovable: runner.RunRemovable,
+		latestList:   preloadlist.NewFromLatest,
+	}
+}
+
+// api returns the client for hstspreload.org.
+func (s *Scanner) api() *preloadapi.Client {
+	if s.opts.API == nil {
+		return &preloadapi.Client{}
+	}
+	return s.opts.API
+}
+
+// PendingDomains returns the domains that have been submitted to
+// hstspreload.org, but are not on the preload list yet.
+func (s *Scanner) PendingDomains(ctx context.Context) ([]string, error) {
+	entries, err := s.api().Pending(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	domains := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		domains = append(domains, entry.Name)
+	}
+	return domains, nil
+}
+
+// PendingRemovalDomains returns the domains that are pending removal on
+// hstspreload.org.
+func (s *Scanner) PendingRemovalDomains(ctx context.Context) ([]string, error) {
+	return s.api().PendingRemoval(ctx)
+}
+
+// PreloadedDomains returns the domains on the latest Chromium preload
+// list.
+func (s *Scanner) PreloadedDomains() ([]string, error) {
+	list, err := s.latestList()
+	if err != nil {
+		return nil, err
+	}
+
+	domains := make([]string, 0, len(list.Entries))
+	for _, entry := range list.Entries {
+		domains = append(domains, entry.Name)
+	}
+	return domains, nil
+}
+
+// Scan checks `domains` in parallel, and returns the results sorted by
+// domain. If `ctx` is done before all domains have been checked, Scan
+// returns the complete results so far, and ctx.Err().
+func (s *Scanner) Scan(ctx context.Context, domains []string) ([]batch.Result, error) {
+	return s.scan(ctx, s.run, domains)
+}
+
+// ScanRemovable is like Scan, but checks the domains for removal
+// requirements (see hstspreload.RemovableDomain()).
+func (s *Scanner) ScanRemovable(ctx context.Context, domains []string) ([]batch.Result, error) {
+	return s.scan(ctx, s.runRemovable, domains)
+}
+
+func (s *Scanner) scan(ctx context.Context, run func(ctx context.Context, domains []string) <-chan batch.Result, domains []string) ([]batch.Result, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]batch.Result, 0, len(domains))
+	for r := range run(ctx, domains) {
+		results = append(results, r)
+		if s.opts.Progress != nil {
+			s.opts.Progress(len(results), len(domains), r)
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Domain < results[j].Domain
+	})
+	return results, ctx.Err()
+}
+
+// ScanPending checks all pending domains (see PendingDomains() and Scan()).
+func (s *Scanner) ScanPending(ctx context.Context) ([]batch.Result, error) {
+	domains, err := s.PendingDomains(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.Scan(ctx, domains)
+}
+
+// ScanPendingRemovals checks all domains that are pending removal for
+// removal requirements (see PendingRemovalDomains() and ScanRemovable()).
+func (s *Scanner) ScanPendingRemovals(ctx context.Context) ([]batch.Result, error) {
+	domains, err := s.PendingRemovalDomains(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.ScanRemovable(ctx, domains)
+}
+
+// ScanPreloaded checks all preloaded domains (see PreloadedDomains() and
+// Scan()).
+func (s *Scanner) ScanPreloaded(ctx context.Context) ([]batch.Result, error) {
+	domains, err := s.PreloadedDomains()
+	if err != nil {
+		return nil, err
+	}
+	return s.Scan(ctx, domains)
+}
+
+// A SetMessage is an entry in the JSON accepted by the /setmessages
+// endpoint of hstspreload.org, which sets the rejection message shown for a
+// pending domain.
+type SetMessage struct {
+	Name    string `json:"name"`
+	Message string `json:"message"`
+}
+
+// SetMessages returns a message for each result with errors, using the
+// first error in canonical order (see hstspreload.Issues.Sorted()), so that
+// the message does not depend on the order in which checks reported their
+// errors. Messages are sorted by domain.
+func SetMessages(results []batch.Result) []SetMessage {
+	messages := []SetMessage{}
+	for _, r := range results {
+		if len(r.Issues.Errors) == 0 {
+			continue
+		}
+		messages = append(messages, SetMessage{
+			Name:    r.Domain,
+			Message: r.Issues.Sorted().Errors[0].Message,
+		})
+	}
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].Name < messages[j].Name
+	})
+	return messages
+}
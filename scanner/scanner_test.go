@@ -0,0 +1,163 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/chromium/hstspreload"
+	"github.com/chromium/hstspreload/batch"
+	"github.com/chromium/hstspreload/chromium/preloadapi"
+	"github.com/chromium/hstspreload/chromium/preloadlist"
+)
+
+// fakeRun returns a result for each domain, in reverse order. Domains
+// starting with "bad" get an error.
+func fakeRun(ctx context.Context, domains []string) <-chan batch.Result {
+	results := make(chan batch.Result, len(domains))
+	for i := len(domains) - 1; i >= 0; i-- {
+		r := batch.Result{Domain: domains[i]}
+		if domains[i][:3] == "bad" {
+			r.Issues.Errors = []hstspreload.Issue{{Code: "response.no_header", Message: "No header for " + domains[i]}}
+		}
+		results <- r
+	}
+	close(results)
+	return results
+}
+
+func testScanner(t *testing.T, opts Options) *Scanner {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/pending":
+			w.Write([]byte(`[{"name": "good.example"}, {"name": "bad.example"}, {"name": "another.example"}]`))
+		case "/pending-removal":
+			w.Write([]byte(`["removed.example", "bad-removed.example"]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	opts.API = &preloadapi.Client{BaseURL: srv.URL, HTTPClient: srv.Client()}
+	s := New(opts)
+	s.run = fakeRun
+	s.runRemovable = fakeRun
+	s.latestList = func() (preloadlist.PreloadList, error) {
+		return preloadlist.PreloadList{Entries: []preloadlist.Entry{
+			{Name: "preloaded.example"},
+			{Name: "bad-preloaded.example"},
+		}}, nil
+	}
+	return s
+}
+
+func resultDomains(results []batch.Result) []string {
+	domains := []string{}
+	for _, r := range results {
+		domains = append(domains, r.Domain)
+	}
+	return domains
+}
+
+func TestScanPending(t *testing.T) {
+	var progress []int
+	s := testScanner(t, Options{Progress: func(checked int, total int, result batch.Result) {
+		if total != 3 {
+			t.Errorf("Unexpected total: %d", total)
+		}
+		progress = append(progress, checked)
+	}})
+
+	results, err := s.ScanPending(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"another.example", "bad.example", "good.example"}
+	if domains := resultDomains(results); !reflect.DeepEqual(domains, expected) {
+		t.Errorf("Expected results for %v, got %v", expected, domains)
+	}
+	if !reflect.DeepEqual(progress, []int{1, 2, 3}) {
+		t.Errorf("Unexpected progress: %v", progress)
+	}
+
+	messages := SetMessages(results)
+	expectedMessages := []SetMessage{{Name: "bad.example", Message: "No header for bad.example"}}
+	if !reflect.DeepEqual(messages, expectedMessages) {
+		t.Errorf("Expected messages %v, got %v", expectedMessages, messages)
+	}
+}
+
+func TestSetMessagesFirstSortedError(t *testing.T) {
+	results := []batch.Result{{
+		Domain: "example.com",
+		Issues: hstspreload.Issues{Errors: []hstspreload.Issue{
+			{Code: "redirects.http.no_redirect", Message: "No redirect"},
+			{Code: "response.no_header", Message: "No header"},
+			{Code: "header.preloadable.max_age.too_low", Message: "Max-age too low"},
+		}},
+	}}
+	expected := []SetMessage{{Name: "example.com", Message: "Max-age too low"}}
+	if messages := SetMessages(results); !reflect.DeepEqual(messages, expected) {
+		t.Errorf("Expected messages %v, got %v", expected, messages)
+	}
+}
+
+func TestScanPreloaded(t *testing.T) {
+	results, err := testScanner(t, Options{}).ScanPreloaded(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"bad-preloaded.example", "preloaded.example"}
+	if domains := resultDomains(results); !reflect.DeepEqual(domains, expected) {
+		t.Errorf("Expected results for %v, got %v", expected, domains)
+	}
+}
+
+func TestScanPendingRemovals(t *testing.T) {
+	s := testScanner(t, Options{})
+	s.run = nil // Removals must not be checked for preload requirements.
+
+	results, err := s.ScanPendingRemovals(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"bad-removed.example", "removed.example"}
+	if domains := resultDomains(results); !reflect.DeepEqual(domains, expected) {
+		t.Errorf("Expected results for %v, got %v", expected, domains)
+	}
+}
+
+func TestScanSourceError(t *testing.T) {
+	s := testScanner(t, Options{})
+	s.opts.API.BaseURL += "/missing"
+	if _, err := s.ScanPending(context.Background()); err == nil {
+		t.Errorf("Expected an error for the pending domains.")
+	}
+
+	listErr := errors.New("cannot download the list")
+	s.latestList = func() (preloadlist.PreloadList, error) {
+		return preloadlist.PreloadList{}, listErr
+	}
+	if _, err := s.ScanPreloaded(context.Background()); err != listErr {
+		t.Errorf("Expected %v, got %v", listErr, err)
+	}
+}
+
+func TestScanContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := testScanner(t, Options{}).Scan(ctx, []string{"good.example"}); err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestSetMessagesEmpty(t *testing.T) {
+	if messages := SetMessages(nil); messages == nil || len(messages) != 0 {
+		t.Errorf("Expected an empty list, got %#v", messages)
+	}
+}
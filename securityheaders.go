@@ -0,0 +1,74 @@
+package hstspreload
+
+import (
+	"net/http"
+	"strings"
+)
+
+// checkSecurityHeaders reports informational warnings about the security
+// headers next to HSTS in the HTTPS response `resp`, if
+// c.CheckSecurityHeaders is set:
+//
+//   - Expect-CT is deprecated, since browsers require Certificate
+//     Transparency for all publicly trusted certificates anyway.
+//   - X-Content-Type-Options: nosniff prevents MIME type sniffing.
+//   - The upgrade-insecure-requests directive of Content-Security-Policy
+//     upgrades mixed content that HSTS does not cover (e.g. subresources
+//     on other hosts).
+//
+// `c` may be nil.
+func (c *Checker) checkSecurityHeaders(resp *http.Response) Issues {
+	issues := Issues{}
+	if c == nil || !c.CheckSecurityHeaders {
+		return issues
+	}
+
+	if expectCT := resp.Header.Get("Expect-CT"); expectCT != "" {
+		issues = issues.addWarningWithParamsf(
+			IssueCode("response.security_headers.expect_ct"),
+			"Deprecated Expect-CT header",
+			map[string]string{"value": expectCT},
+			"The response has an `Expect-CT` header (`%s`). "+
+				"This header is deprecated, since browsers require Certificate Transparency for all "+
+				"publicly trusted certificates. It can be removed.",
+			EscapeControlCharacters(expectCT),
+		)
+	}
+
+	if nosniff := resp.Header.Get("X-Content-Type-Options"); !strings.EqualFold(strings.TrimSpace(nosniff), "nosniff") {
+		issues = issues.addWarningWithParamsf(
+			IssueCode("response.security_headers.no_nosniff"),
+			"No X-Content-Type-Options: nosniff",
+			map[string]string{"value": nosniff},
+			"The response does not have an `X-Content-Type-Options: nosniff` header. "+
+				"Without it, browsers may interpret responses as a different content type than declared.",
+		)
+	}
+
+	if !hasCSPDirective(resp.Header.Values("Content-Security-Policy"), "upgrade-insecure-requests") {
+		issues = issues.addWarningf(
+			IssueCode("response.security_headers.no_upgrade_insecure_requests"),
+			"No upgrade-insecure-requests",
+			"The response does not have a `Content-Security-Policy` header with the "+
+				"`upgrade-insecure-requests` directive. HSTS only upgrades requests to the domain "+
+				"(and its subdomains with includeSubDomains); the directive also upgrades "+
+				"insecure subresources on other hosts.",
+		)
+	}
+
+	return issues
+}
+
+// hasCSPDirective returns whether any of the Content-Security-Policy header
+// values `policies` contains the directive `name`.
+func hasCSPDirective(policies []string, name string) bool {
+	for _, policy := range policies {
+		for _, directive := range strings.Split(policy, ";") {
+			fields := strings.Fields(directive)
+			if len(fields) > 0 && strings.EqualFold(fields[0], name) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package hstspreload
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCheckSecurityHeaders(t *testing.T) {
+	c := &Checker{CheckSecurityHeaders: true}
+
+	for _, tt := range []struct {
+		description string
+		header      http.Header
+		expected    Issues
+	}{
+		{
+			"all headers",
+			http.Header{
+				"X-Content-Type-Options":  {"NoSniff"},
+				"Content-Security-Policy": {"default-src 'self'", "Upgrade-Insecure-Requests; block-all-mixed-content"},
+			},
+			Issues{},
+		},
+		{
+			"no headers",
+			http.Header{},
+			Issues{Warnings: []Issue{
+				{Code: "response.security_headers.no_nosniff"},
+				{Code: "response.security_headers.no_upgrade_insecure_requests"},
+			}},
+		},
+		{
+			"expect-ct and report-only policy",
+			http.Header{
+				"Expect-Ct":                           {"max-age=86400, enforce"},
+				"X-Content-Type-Options":              {"nosniff"},
+				"Content-Security-Policy-Report-Only": {"upgrade-insecure-requests"},
+			},
+			Issues{Warnings: []Issue{
+				{Code: "response.security_headers.expect_ct"},
+				{Code: "response.security_headers.no_upgrade_insecure_requests"},
+			}},
+		},
+	} {
+		issues := c.checkSecurityHeaders(&http.Response{Header: tt.header})
+		if !issues.Match(tt.expected) {
+			t.Errorf("[%s] "+issuesShouldMatch, tt.description, issues, tt.expected)
+		}
+		for _, w := range issues.Warnings {
+			if WarningSeverity(w.Code) != SeverityInfo {
+				t.Errorf("[%s] %s should be informational.", tt.description, w.Code)
+			}
+		}
+	}
+
+	if issues := (&Checker{}).checkSecurityHeaders(&http.Response{Header: http.Header{}}); !issues.Match(Issues{}) {
+		t.Errorf(issuesShouldBeEmpty, issues)
+	}
+}
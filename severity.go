@@ -0,0 +1,55 @@
+package hstspreload
+
+// informationalWarnings lists the codes of warnings that are purely
+// informational: they don't indicate a problem that affects users, and
+// don't need to be fixed.
+var informationalWarnings = map[IssueCode]bool{
+	"redirects.http.useless_header":    true,
+	"dns.cname.target_preloaded":       true,
+	"domain.dns.caa.missing":           true,
+	"preload_status.preloaded":         true,
+	"preload_status.covered_by_parent": true,
+	"removal.policy.bulk":              true,
+
+	"response.security_headers.expect_ct":                    true,
+	"response.security_headers.no_nosniff":                   true,
+	"response.security_headers.no_upgrade_insecure_requests": true,
+}
+
+// severityRanks orders the severities from least to most severe.
+var severityRanks = map[Severity]int{
+	SeverityIgnore:  0,
+	SeverityInfo:    1,
+	SeverityWarning: 2,
+	SeverityError:   3,
+}
+
+// WarningSeverity returns the severity of warnings with `code`:
+// SeverityInfo if they are purely informational (e.g. an HSTS header over
+// plain HTTP, which has no effect), and SeverityWarning otherwise.
+func WarningSeverity(code IssueCode) Severity {
+	if informationalWarnings[code] {
+		return SeverityInfo
+	}
+	return SeverityWarning
+}
+
+// Filter returns a copy of `iss` that only contains the issues with at
+// least the severity `min`: errors have SeverityError, and warnings have
+// the severity given by WarningSeverity(). For example,
+// Filter(SeverityWarning) drops the informational warnings.
+//
+// Filter(SeverityIgnore) and Filter(SeverityInfo) keep all issues.
+func (iss Issues) Filter(min Severity) Issues {
+	rank := severityRanks[min]
+	result := Issues{}
+	if rank <= severityRanks[SeverityError] {
+		result.Errors = concatIssues(nil, iss.Errors)
+	}
+	for _, w := range iss.Warnings {
+		if severityRanks[WarningSeverity(w.Code)] >= rank {
+			result.Warnings = append(result.Warnings, w)
+		}
+	}
+	return result
+}
@@ -0,0 +1,66 @@
+package hstspreload
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWarningSeverity(t *testing.T) {
+	if s := WarningSeverity("redirects.http.useless_header"); s != SeverityInfo {
+		t.Errorf("Expected %s, got %s", SeverityInfo, s)
+	}
+	if s := WarningSeverity("tls.obsolete_cipher_suite"); s != SeverityWarning {
+		t.Errorf("Expected %s, got %s", SeverityWarning, s)
+	}
+
+	// Informational codes should be codes of built-in checks.
+	for code := range informationalWarnings {
+		if sectionOf(code) == sectionOther {
+			t.Errorf("Informational warning %s is not in a section.", code)
+		}
+	}
+}
+
+func TestIssuesFilter(t *testing.T) {
+	issues := Issues{
+		Errors: []Issue{{Code: "response.no_header"}},
+		Warnings: []Issue{
+			{Code: "redirects.http.useless_header"},
+			{Code: "tls.obsolete_cipher_suite"},
+		},
+	}
+
+	var tests = []struct {
+		min      Severity
+		expected Issues
+	}{
+		{SeverityIgnore, issues},
+		{SeverityInfo, issues},
+		{
+			SeverityWarning,
+			Issues{
+				Errors:   []Issue{{Code: "response.no_header"}},
+				Warnings: []Issue{{Code: "tls.obsolete_cipher_suite"}},
+			},
+		},
+		{
+			SeverityError,
+			Issues{Errors: []Issue{{Code: "response.no_header"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		if filtered := issues.Filter(tt.min); !filtered.Match(tt.expected) {
+			t.Errorf("[%s] "+issuesShouldMatch, tt.min, filtered, tt.expected)
+		}
+	}
+	if len(issues.Warnings) != 2 {
+		t.Errorf("Filter() should not modify the original issues.")
+	}
+}
+
+func TestParseSeverityOverridesInfo(t *testing.T) {
+	if _, err := ParseSeverityOverrides(strings.NewReader(`{"tls.obsolete_cipher_suite": "info"}`)); err == nil {
+		t.Errorf("Expected an error, since overrides cannot make issues informational.")
+	}
+}
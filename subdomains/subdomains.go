@@ -0,0 +1,211 @@
+// Package subdomains checks which subdomains of a domain would break if the
+// domain was preloaded with includeSubDomains. Browsers then only connect
+// to the subdomains over HTTPS, and don't let users bypass certificate
+// errors, so subdomains without HTTPS or with an invalid certificate become
+// unreachable.
+//
+// The subdomains are either given by the caller (e.g. from an inventory),
+// or discovered from a Source such as Certificate Transparency logs (see
+// ct.CrtSh).
+package subdomains
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromium/hstspreload"
+	"github.com/chromium/hstspreload/ct"
+)
+
+const defaultParallelism = 20
+
+// A Source lists known subdomains of a domain. ct.CrtSh implements it. If
+// a Source also implements ct.Source, the certificates are summarized in
+// Report.CT.
+type Source interface {
+	Subdomains(ctx context.Context, domain string) ([]string, error)
+}
+
+// A Status classifies a subdomain.
+type Status string
+
+const (
+	// StatusOK indicates that the subdomain serves HTTPS with a valid
+	// certificate, so it keeps working under includeSubDomains. Its
+	// result may still have warnings.
+	StatusOK Status = "ok"
+	// StatusBreaks indicates that the subdomain resolves, but cannot be
+	// visited over HTTPS with a valid certificate, so it becomes
+	// unreachable under includeSubDomains.
+	StatusBreaks Status = "breaks"
+	// StatusUnresolved indicates that the subdomain does not resolve
+	// (e.g. a name from an old certificate), so there is nothing to break.
+	StatusUnresolved Status = "unresolved"
+)
+
+// A Result is the outcome of checking a single subdomain.
+type Result struct {
+	Name   string             `json:"name"`
+	Status Status             `json:"status"`
+	Issues hstspreload.Issues `json:"issues"`
+}
+
+// A Report holds the results for the subdomains of Domain.
+type Report struct {
+	Domain string `json:"domain"`
+	// Counts maps each status to the number of subdomains with it.
+	Counts map[Status]int `json:"counts"`
+	// Results are sorted by name.
+	Results []Result `json:"results"`
+	// CT summarizes the logged certificates of Domain, if the subdomains
+	// were discovered from a ct.Source.
+	CT *ct.Summary `json:"ct,omitempty"`
+}
+
+// Breaking returns the results of the subdomains that would break.
+func (r *Report) Breaking() []Result {
+	breaking := []Result{}
+	for _, res := range r.Results {
+		if res.Status == StatusBreaks {
+			breaking = append(breaking, res)
+		}
+	}
+	return breaking
+}
+
+// Options configures a Scanner.
+type Options struct {
+	// Checker is used to check the subdomains. If nil, the default
+	// configuration is used.
+	Checker *hstspreload.Checker
+	// Parallelism is the maximum number of subdomains that are checked at
+	// the same time. If zero, 20 subdomains are checked at the same time.
+	Parallelism int
+	// Source is used by CheckDiscovered(). It may be nil otherwise.
+	Source Source
+}
+
+// A Scanner checks the subdomains of domains.
+type Scanner struct {
+	opts Options
+
+	// check checks a single subdomain. It can be replaced in tests.
+	check func(ctx context.Context, c *hstspreload.Checker, name string) hstspreload.Issues
+}
+
+// New returns a Scanner using the given options.
+func New(opts Options) *Scanner {
+	if opts.Parallelism <= 0 {
+		opts.Parallelism = defaultParallelism
+	}
+	return &Scanner{opts: opts, check: checkSubdomain}
+}
+
+func checkSubdomain(ctx context.Context, c *hstspreload.Checker, name string) hstspreload.Issues {
+	return c.HTTPSAvailableContext(ctx, name)
+}
+
+// Check checks `names`, which must be subdomains of `domain`. Duplicates
+// and wildcard labels (e.g. "*.example.com") are ignored.
+//
+// If `ctx` is done before all subdomains have been checked, Check returns
+// ctx.Err().
+func (s *Scanner) Check(ctx context.Context, domain string, names []string) (*Report, error) {
+	domain = strings.ToLower(domain)
+	seen := make(map[string]bool)
+	var subdomains []string
+	for _, name := range names {
+		name = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(name)), "*.")
+		if name == "" || seen[name] {
+			continue
+		}
+		if !strings.HasSuffix(name, "."+domain) {
+			return nil, fmt.Errorf("%s is not a subdomain of %s", name, domain)
+		}
+		seen[name] = true
+		subdomains = append(subdomains, name)
+	}
+	sort.Strings(subdomains)
+
+	results := make([]Result, len(subdomains))
+	sem := make(chan struct{}, s.opts.Parallelism)
+	var wg sync.WaitGroup
+	for i, name := range subdomains {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, name string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			issues := s.check(ctx, s.opts.Checker, name).Sorted()
+			results[i] = Result{Name: name, Status: status(issues), Issues: issues}
+		}(i, name)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r := &Report{Domain: domain, Counts: make(map[Status]int), Results: results}
+	for _, res := range results {
+		r.Counts[res.Status]++
+	}
+	return r, nil
+}
+
+// CheckDiscovered checks the subdomains of `domain` that are listed by
+// Options.Source. If the Source is a ct.Source, the summary of the
+// certificates is included in the report; certificates that expire within
+// the CertExpiryWarning of Options.Checker are reported as expiring.
+func (s *Scanner) CheckDiscovered(ctx context.Context, domain string) (*Report, error) {
+	if s.opts.Source == nil {
+		return nil, errors.New("no source of subdomains")
+	}
+	if certs, ok := s.opts.Source.(ct.Source); ok {
+		return s.checkCertificates(ctx, domain, certs)
+	}
+	names, err := s.opts.Source.Subdomains(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+	return s.Check(ctx, domain, names)
+}
+
+// checkCertificates checks the subdomains of `domain` in the certificates
+// from `source`.
+func (s *Scanner) checkCertificates(ctx context.Context, domain string, source ct.Source) (*Report, error) {
+	certs, err := source.Certificates(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+	var expiryWarning time.Duration
+	if s.opts.Checker != nil {
+		expiryWarning = s.opts.Checker.CertExpiryWarning
+	}
+	summary := ct.Summarize(domain, certs, time.Now(), expiryWarning)
+
+	r, err := s.Check(ctx, domain, summary.Subdomains)
+	if err != nil {
+		return nil, err
+	}
+	r.CT = summary
+	return r, nil
+}
+
+// status classifies the outcome of hstspreload.HTTPSAvailable().
+func status(issues hstspreload.Issues) Status {
+	for _, e := range issues.Errors {
+		if strings.HasPrefix(string(e.Code), "dns.") {
+			return StatusUnresolved
+		}
+	}
+	if len(issues.Errors) > 0 {
+		return StatusBreaks
+	}
+	return StatusOK
+}
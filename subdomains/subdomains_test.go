@@ -0,0 +1,106 @@
+package subdomains
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chromium/hstspreload"
+	"github.com/chromium/hstspreload/ct"
+)
+
+// fakeCheck reports a DNS error for names starting with "old", and a
+// certificate error for names starting with "bad".
+func fakeCheck(ctx context.Context, c *hstspreload.Checker, name string) hstspreload.Issues {
+	switch {
+	case strings.HasPrefix(name, "old"):
+		return hstspreload.Issues{Errors: []hstspreload.Issue{{Code: "dns.lookup_failed"}}}
+	case strings.HasPrefix(name, "bad"):
+		return hstspreload.Issues{Errors: []hstspreload.Issue{{Code: "domain.tls.invalid_cert_chain"}}}
+	}
+	return hstspreload.Issues{}
+}
+
+type fakeSource []string
+
+func (f fakeSource) Subdomains(ctx context.Context, domain string) ([]string, error) {
+	return f, nil
+}
+
+func TestCheck(t *testing.T) {
+	s := New(Options{Parallelism: 2})
+	s.check = fakeCheck
+
+	r, err := s.Check(context.Background(), "Example.com", []string{
+		"www.example.com", "bad.example.com", "*.old.example.com", "WWW.example.com", "",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, res := range r.Results {
+		names = append(names, res.Name)
+	}
+	if expected := []string{"bad.example.com", "old.example.com", "www.example.com"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected results for %v, got %v", expected, names)
+	}
+	expectedCounts := map[Status]int{StatusOK: 1, StatusBreaks: 1, StatusUnresolved: 1}
+	if !reflect.DeepEqual(r.Counts, expectedCounts) {
+		t.Errorf("Unexpected counts: %v", r.Counts)
+	}
+	if breaking := r.Breaking(); len(breaking) != 1 || breaking[0].Name != "bad.example.com" {
+		t.Errorf("Unexpected breaking subdomains: %v", breaking)
+	}
+
+	if _, err := s.Check(context.Background(), "example.com", []string{"example.net"}); err == nil {
+		t.Errorf("Expected an error for a name that is not a subdomain.")
+	}
+}
+
+func TestCheckDiscovered(t *testing.T) {
+	s := New(Options{})
+	s.check = fakeCheck
+	if _, err := s.CheckDiscovered(context.Background(), "example.com"); err == nil {
+		t.Errorf("Expected an error without a source.")
+	}
+
+	s.opts.Source = fakeSource{"a.example.com", "bad.example.com"}
+	r, err := s.CheckDiscovered(context.Background(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Results) != 2 || r.Counts[StatusBreaks] != 1 {
+		t.Errorf("Unexpected report: %#v", r)
+	}
+}
+
+type fakeCTSource struct {
+	fakeSource
+	certs []ct.Certificate
+}
+
+func (f fakeCTSource) Certificates(ctx context.Context, domain string) ([]ct.Certificate, error) {
+	return f.certs, nil
+}
+
+func TestCheckDiscoveredCertificates(t *testing.T) {
+	now := time.Now()
+	s := New(Options{Source: fakeCTSource{certs: []ct.Certificate{
+		{Issuer: "CN=R3", Names: []string{"*.example.com", "bad.example.com"}, NotBefore: now.Add(-time.Hour), NotAfter: now.Add(time.Hour)},
+	}}})
+	s.check = fakeCheck
+
+	r, err := s.CheckDiscovered(context.Background(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Results) != 1 || r.Results[0].Status != StatusBreaks {
+		t.Errorf("Unexpected results: %v", r.Results)
+	}
+	if r.CT == nil || len(r.CT.Expiring) != 1 {
+		t.Errorf("Expected a CT summary with an expiring certificate, got %#v", r.CT)
+	}
+}
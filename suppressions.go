@@ -0,0 +1,137 @@
+package hstspreload
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// suppressionDateFormat is the format of Suppression.Expires in JSON.
+const suppressionDateFormat = "2006-01-02"
+
+// A Suppression accepts a known issue for a domain until it expires, so
+// that the issue does not fail automated runs (e.g. in CI) repeatedly.
+type Suppression struct {
+	Domain string
+	Code   IssueCode
+	// The suppression applies until the start of this day (UTC).
+	Expires time.Time
+	// Why the issue is accepted, for humans.
+	Reason string
+}
+
+type suppressionJSON struct {
+	Domain  string    `json:"domain"`
+	Code    IssueCode `json:"code"`
+	Expires string    `json:"expires"`
+	Reason  string    `json:"reason,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler. Expires is formatted as a date
+// (e.g. "2025-12-31").
+func (s Suppression) MarshalJSON() ([]byte, error) {
+	return json.Marshal(suppressionJSON{
+		Domain:  s.Domain,
+		Code:    s.Code,
+		Expires: s.Expires.Format(suppressionDateFormat),
+		Reason:  s.Reason,
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (s *Suppression) UnmarshalJSON(b []byte) error {
+	var j suppressionJSON
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	if j.Domain == "" || j.Code == "" {
+		return fmt.Errorf("suppression must have a domain and a code")
+	}
+	expires, err := time.Parse(suppressionDateFormat, j.Expires)
+	if err != nil {
+		return fmt.Errorf("invalid expiry date for suppression of %s on %s: %s", j.Code, j.Domain, err)
+	}
+	*s = Suppression{
+		Domain:  strings.ToLower(j.Domain),
+		Code:    j.Code,
+		Expires: expires,
+		Reason:  j.Reason,
+	}
+	return nil
+}
+
+// Suppressions is a list of known issues.
+type Suppressions []Suppression
+
+// ParseSuppressions reads Suppressions from a JSON array, e.g.:
+//
+//	[{"domain": "example.com", "code": "domain.www.no_tls", "expires": "2025-12-31", "reason": "..."}]
+func ParseSuppressions(r io.Reader) (Suppressions, error) {
+	var s Suppressions
+	if err := json.NewDecoder(r).Decode(&s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Apply returns a copy of `iss` (the issues for `domain`) without the
+// issues that are suppressed at time `now`. Each expired suppression for
+// `domain` is reported as a "suppression.expired" warning, so that it can be
+// renewed or removed.
+func (s Suppressions) Apply(domain string, iss Issues, now time.Time) Issues {
+	return combineIssues(s.filter(domain, iss, now), s.expired(domain, now))
+}
+
+// filter removes the issues that are suppressed at time `now`.
+func (s Suppressions) filter(domain string, iss Issues, now time.Time) Issues {
+	if len(s) == 0 {
+		return iss
+	}
+
+	active := make(map[IssueCode]bool)
+	for _, sup := range s {
+		if strings.EqualFold(sup.Domain, domain) && now.Before(sup.Expires) {
+			active[sup.Code] = true
+		}
+	}
+
+	result := Issues{}
+	for _, e := range iss.Errors {
+		if !active[e.Code] {
+			result.Errors = append(result.Errors, e)
+		}
+	}
+	for _, w := range iss.Warnings {
+		if !active[w.Code] {
+			result.Warnings = append(result.Warnings, w)
+		}
+	}
+	return result
+}
+
+// expired reports the suppressions for `domain` that have expired at time
+// `now`.
+func (s Suppressions) expired(domain string, now time.Time) Issues {
+	issues := Issues{}
+	for _, sup := range s {
+		if !strings.EqualFold(sup.Domain, domain) || now.Before(sup.Expires) {
+			continue
+		}
+		issues = issues.addWarningWithParamsf(
+			IssueCode("suppression.expired"),
+			"Expired suppression",
+			map[string]string{
+				"code":    string(sup.Code),
+				"expires": sup.Expires.Format(suppressionDateFormat),
+			},
+			"The suppression of `%s` for `%s` expired on %s, so the issue is no longer suppressed. "+
+				"Fix the issue, or renew or remove the suppression.",
+			sup.Code,
+			domain,
+			sup.Expires.Format(suppressionDateFormat),
+		)
+	}
+	return issues
+}
@@ -0,0 +1,71 @@
+package hstspreload
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSuppressions(t *testing.T) {
+	s, err := ParseSuppressions(strings.NewReader(`[
+		{"domain": "Example.com", "code": "domain.www.no_tls", "expires": "2030-01-02", "reason": "Migrating www."}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s) != 1 || s[0].Domain != "example.com" || s[0].Code != "domain.www.no_tls" ||
+		!s[0].Expires.Equal(time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)) || s[0].Reason != "Migrating www." {
+		t.Errorf("Unexpected suppressions: %#v", s)
+	}
+
+	j, err := json.Marshal(s[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"domain":"example.com","code":"domain.www.no_tls","expires":"2030-01-02","reason":"Migrating www."}`; string(j) != expected {
+		t.Errorf("Unexpected JSON: %s", j)
+	}
+
+	for _, invalid := range []string{
+		`[{"domain": "example.com", "code": "domain.www.no_tls", "expires": "next year"}]`,
+		`[{"domain": "example.com", "expires": "2030-01-02"}]`,
+		`{}`,
+	} {
+		if _, err := ParseSuppressions(strings.NewReader(invalid)); err == nil {
+			t.Errorf("Expected an error for %s", invalid)
+		}
+	}
+}
+
+func TestSuppressionsApply(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	s := Suppressions{
+		{Domain: "example.com", Code: "domain.www.no_tls", Expires: time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)},
+		{Domain: "example.com", Code: "tls.obsolete_cipher_suite", Expires: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{Domain: "example.net", Code: "response.no_header", Expires: time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	issues := Issues{
+		Errors:   []Issue{{Code: "response.no_header"}, {Code: "domain.www.no_tls"}},
+		Warnings: []Issue{{Code: "tls.obsolete_cipher_suite"}},
+	}
+
+	applied := s.Apply("example.com", issues, now)
+	expected := Issues{
+		Errors: []Issue{{Code: "response.no_header"}},
+		Warnings: []Issue{
+			{Code: "tls.obsolete_cipher_suite"},
+			{Code: "suppression.expired"},
+		},
+	}
+	if !applied.Match(expected) {
+		t.Errorf(issuesShouldMatch, applied, expected)
+	}
+	if p := applied.Warnings[1].Params; p["code"] != "tls.obsolete_cipher_suite" || p["expires"] != "2025-06-01" {
+		t.Errorf("Unexpected params: %v", p)
+	}
+
+	if applied := (Suppressions{}).Apply("example.com", issues, now); !applied.Match(issues) {
+		t.Errorf(issuesShouldMatch, applied, issues)
+	}
+}
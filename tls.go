@@ -1,14 +1,18 @@
 package hstspreload
 
 import (
+	"bytes"
+	"crypto/rsa"
 	"crypto/tls"
 	"crypto/x509"
+	"strconv"
+	"time"
 )
 
 func checkChain(connState tls.ConnectionState) Issues {
 	fullChain := connState.VerifiedChains[0]
 	chain := fullChain[:len(fullChain)-1] // Ignore the root CA
-	return checkSHA1(chain)
+	return combineIssues(checkSHA1(chain), checkLeafUsage(fullChain[0]))
 }
 
 func checkSHA1(chain []*x509.Certificate) Issues {
@@ -16,9 +20,10 @@ func checkSHA1(chain []*x509.Certificate) Issues {
 
 	for _, cert := range chain {
 		if cert.SignatureAlgorithm == x509.SHA1WithRSA || cert.SignatureAlgorithm == x509.ECDSAWithSHA1 {
-			return issues.addErrorf(
+			return issues.addErrorWithParamsf(
 				IssueCode("domain.tls.sha1"),
 				"SHA-1 Certificate",
+				map[string]string{"common_name": cert.Subject.CommonName},
 				"One or more of the certificates in your certificate chain "+
 					"is signed using SHA-1. This needs to be replaced. "+
 					"See https://security.googleblog.com/2015/12/an-update-on-sha-1-certificates-in.html. "+
@@ -31,34 +36,198 @@ func checkSHA1(chain []*x509.Certificate) Issues {
 	return issues
 }
 
-func checkCipherSuite(connState tls.ConnectionState) Issues {
+// checkLeafUsage checks that the leaf certificate may be used for a TLS
+// server. Depending on the configuration, Go's verifier may accept a
+// certificate without the serverAuth EKU or with an unsuitable key usage,
+// but Chrome rejects it.
+func checkLeafUsage(leaf *x509.Certificate) Issues {
 	issues := Issues{}
 
-	// All cipher suites in TLS 1.3 are considered modern.
-	if connState.Version > tls.VersionTLS12 {
-		return Issues{}
+	// A leaf without an extended key usage extension may be used for any
+	// purpose, which is fine.
+	hasEKU := len(leaf.ExtKeyUsage) > 0 || len(leaf.UnknownExtKeyUsage) > 0
+	hasServerAuth := false
+	for _, eku := range leaf.ExtKeyUsage {
+		if eku == x509.ExtKeyUsageServerAuth || eku == x509.ExtKeyUsageAny {
+			hasServerAuth = true
+		}
+	}
+	if hasEKU && !hasServerAuth {
+		issues = issues.addErrorWithParamsf(
+			IssueCode("domain.tls.eku.no_server_auth"),
+			"Certificate not valid for TLS servers",
+			map[string]string{"common_name": leaf.Subject.CommonName},
+			"The extended key usage of the leaf certificate (common-name %q) does not include serverAuth, "+
+				"so Chrome will not accept it for a TLS server.",
+			leaf.Subject.CommonName,
+		)
+	}
+
+	// A KeyUsage of 0 means that the extension is not present, which is
+	// fine.
+	if leaf.KeyUsage != 0 && leaf.KeyUsage&allowedKeyUsage(leaf.PublicKey) == 0 {
+		issues = issues.addErrorWithParamsf(
+			IssueCode("domain.tls.key_usage.invalid"),
+			"Invalid Key Usage",
+			map[string]string{"common_name": leaf.Subject.CommonName},
+			"The key usage of the leaf certificate (common-name %q) does not allow "+
+				"its key to be used for a TLS server (it should include digitalSignature).",
+			leaf.Subject.CommonName,
+		)
+	}
+
+	return issues
+}
+
+// checkCertExpiry checks that the leaf and intermediate certificates of
+// `connState` have not expired at `now`, and warns if they expire soon (see
+// Checker.CertExpiryWarning). If the chain was not verified, the
+// certificates sent by the server are checked instead. `c` may be nil.
+func (c *Checker) checkCertExpiry(connState tls.ConnectionState, now time.Time) Issues {
+	issues := Issues{}
+
+	var chain []*x509.Certificate
+	if len(connState.VerifiedChains) > 0 {
+		fullChain := connState.VerifiedChains[0]
+		chain = fullChain[:len(fullChain)-1] // Ignore the root CA
+	} else {
+		for _, cert := range connState.PeerCertificates {
+			// Ignore any root CA sent by the server.
+			if !bytes.Equal(cert.RawIssuer, cert.RawSubject) {
+				chain = append(chain, cert)
+			}
+		}
+	}
+
+	// Report the certificate that expires first.
+	var first *x509.Certificate
+	for _, cert := range chain {
+		if first == nil || cert.NotAfter.Before(first.NotAfter) {
+			first = cert
+		}
+	}
+	if first == nil {
+		return issues
+	}
+
+	params := map[string]string{
+		"common_name": first.Subject.CommonName,
+		"not_after":   first.NotAfter.UTC().Format(time.RFC3339),
+	}
+	remaining := first.NotAfter.Sub(now)
+	switch {
+	case remaining < 0:
+		return issues.addErrorWithParamsf(
+			IssueCode("domain.tls.cert.expired"),
+			"Expired certificate",
+			params,
+			"The certificate chain contains a certificate (common-name %q) that expired on %s.",
+			first.Subject.CommonName,
+			first.NotAfter.UTC().Format("2006-01-02"),
+		)
+	case remaining < c.certExpiryWarning():
+		days := int(remaining.Hours() / 24)
+		params["days_remaining"] = strconv.Itoa(days)
+		return issues.addWarningWithParamsf(
+			IssueCode("domain.tls.cert.expires_soon"),
+			"Certificate expires soon",
+			params,
+			"The certificate chain contains a certificate (common-name %q) that expires on %s (in %d days). "+
+				"Renew it before then, or browsers will refuse to connect to the site.",
+			first.Subject.CommonName,
+			first.NotAfter.UTC().Format("2006-01-02"),
+			days,
+		)
 	}
 
-	// These modern cipher suites are only supported in TLS 1.2.
-	switch connState.CipherSuite {
-	case tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256:
-		fallthrough
-	case tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384:
-		fallthrough
-	case tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305:
-		fallthrough
-	case tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256:
-		fallthrough
-	case tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384:
-		fallthrough
-	case tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305:
-		return Issues{}
-	default:
-		return issues.addWarningf(
+	return issues
+}
+
+// allowedKeyUsage returns the key usages that allow a TLS server to use a
+// key of the type of `publicKey`.
+func allowedKeyUsage(publicKey interface{}) x509.KeyUsage {
+	if _, ok := publicKey.(*rsa.PublicKey); ok {
+		// Allow RSA key exchange for TLS 1.2 and below.
+		return x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment
+	}
+	return x509.KeyUsageDigitalSignature
+}
+
+// minRSAKeyBits is the minimum size of the RSA key of a leaf certificate.
+const minRSAKeyBits = 2048
+
+// checkTLSConfig checks the TLS settings negotiated for `connState`: the
+// protocol version, the cipher suite, and the size of the RSA key of the
+// leaf certificate (if any).
+//
+// Note that Go clients refuse versions below TLS 1.2 unless the Transport
+// of the Checker allows them, in which case the connection fails before
+// these checks.
+func checkTLSConfig(connState tls.ConnectionState) Issues {
+	issues := Issues{}
+
+	if connState.Version < tls.VersionTLS12 {
+		issues = issues.addErrorWithParamsf(
+			IssueCode("domain.tls.version.too_low"),
+			"Obsolete TLS version",
+			map[string]string{"version": tlsVersionName(connState.Version)},
+			"The site negotiated %s. Chrome requires TLS 1.2 or later.",
+			tlsVersionName(connState.Version),
+		)
+	}
+
+	if len(connState.PeerCertificates) > 0 {
+		leaf := connState.PeerCertificates[0]
+		if key, ok := leaf.PublicKey.(*rsa.PublicKey); ok && key.N != nil && key.N.BitLen() < minRSAKeyBits {
+			issues = issues.addErrorWithParamsf(
+				IssueCode("domain.tls.rsa_key_too_small"),
+				"RSA key too small",
+				map[string]string{"common_name": leaf.Subject.CommonName, "bits": strconv.Itoa(key.N.BitLen())},
+				"The leaf certificate (common-name %q) has a %d-bit RSA key. "+
+					"RSA keys must have at least %d bits.",
+				leaf.Subject.CommonName,
+				key.N.BitLen(),
+				minRSAKeyBits,
+			)
+		}
+	}
+
+	if connState.Version >= tls.VersionTLS12 && !isModernCipherSuite(connState.Version, connState.CipherSuite) {
+		issues = issues.addWarningf(
 			IssueCode("tls.obsolete_cipher_suite"),
 			"Obsolete Cipher Suite",
 			"The site is using obsolete TLS settings. "+
 				"Check out the site at https://www.ssllabs.com/ssltest/",
 		)
 	}
+
+	return issues
+}
+
+// isModernCipherSuite returns whether `suite` is a modern cipher suite for
+// TLS `version`.
+func isModernCipherSuite(version uint16, suite uint16) bool {
+	switch version {
+	case tls.VersionTLS13:
+		// All cipher suites of TLS 1.3 are modern.
+		switch suite {
+		case tls.TLS_AES_128_GCM_SHA256,
+			tls.TLS_AES_256_GCM_SHA384,
+			tls.TLS_CHACHA20_POLY1305_SHA256:
+			return true
+		}
+
+	case tls.VersionTLS12:
+		// These modern cipher suites are only supported in TLS 1.2.
+		switch suite {
+		case tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305:
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,296 @@
+package hstspreload
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var checkLeafUsageTests = []struct {
+	description    string
+	leaf           *x509.Certificate
+	expectedIssues Issues
+}{
+	{
+		"valid RSA",
+		&x509.Certificate{
+			PublicKey:   &rsa.PublicKey{},
+			KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		},
+		Issues{},
+	},
+	{
+		"valid ECDSA without key usage",
+		&x509.Certificate{
+			PublicKey:   &ecdsa.PublicKey{},
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		},
+		Issues{},
+	},
+	{
+		"no EKU extension",
+		&x509.Certificate{
+			PublicKey: &rsa.PublicKey{},
+			KeyUsage:  x509.KeyUsageDigitalSignature,
+		},
+		Issues{},
+	},
+	{
+		"unknown EKU only",
+		&x509.Certificate{
+			PublicKey:          &rsa.PublicKey{},
+			UnknownExtKeyUsage: []asn1.ObjectIdentifier{{1, 2, 3, 4}},
+		},
+		Issues{Errors: []Issue{{Code: "domain.tls.eku.no_server_auth"}}},
+	},
+	{
+		"client-only EKU",
+		&x509.Certificate{
+			PublicKey:   &rsa.PublicKey{},
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		},
+		Issues{Errors: []Issue{{Code: "domain.tls.eku.no_server_auth"}}},
+	},
+	{
+		"ECDSA with key encipherment only",
+		&x509.Certificate{
+			PublicKey:   &ecdsa.PublicKey{},
+			KeyUsage:    x509.KeyUsageKeyEncipherment,
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		},
+		Issues{Errors: []Issue{{Code: "domain.tls.key_usage.invalid"}}},
+	},
+	{
+		"CA key usage only",
+		&x509.Certificate{
+			PublicKey:   &rsa.PublicKey{},
+			KeyUsage:    x509.KeyUsageCertSign,
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+		},
+		Issues{Errors: []Issue{
+			{Code: "domain.tls.eku.no_server_auth"},
+			{Code: "domain.tls.key_usage.invalid"},
+		}},
+	},
+}
+
+func TestCheckLeafUsage(t *testing.T) {
+	for _, tt := range checkLeafUsageTests {
+		if issues := checkLeafUsage(tt.leaf); !issues.Match(tt.expectedIssues) {
+			t.Errorf("[%s] "+issuesShouldMatch, tt.description, issues, tt.expectedIssues)
+		}
+	}
+}
+
+var certExpiryNow = time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+
+func certExpiringIn(commonName string, d time.Duration) *x509.Certificate {
+	return &x509.Certificate{
+		Subject:    pkix.Name{CommonName: commonName},
+		RawSubject: []byte(commonName),
+		RawIssuer:  []byte("issuer of " + commonName),
+		NotAfter:   certExpiryNow.Add(d),
+	}
+}
+
+var checkCertExpiryTests = []struct {
+	description    string
+	checker        *Checker
+	connState      tls.ConnectionState
+	expectedIssues Issues
+}{
+	{
+		"valid for a year",
+		nil,
+		tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{
+			certExpiringIn("leaf", 365*24*time.Hour),
+			certExpiringIn("intermediate", 2*365*24*time.Hour),
+			certExpiringIn("root", 10*365*24*time.Hour),
+		}}},
+		Issues{},
+	},
+	{
+		"leaf expires soon",
+		nil,
+		tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{
+			certExpiringIn("leaf", 10*24*time.Hour),
+			certExpiringIn("intermediate", 2*365*24*time.Hour),
+			certExpiringIn("root", 10*365*24*time.Hour),
+		}}},
+		Issues{Warnings: []Issue{{Code: "domain.tls.cert.expires_soon"}}},
+	},
+	{
+		"intermediate expires soon",
+		nil,
+		tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{
+			certExpiringIn("leaf", 365*24*time.Hour),
+			certExpiringIn("intermediate", 20*24*time.Hour),
+			certExpiringIn("root", 10*365*24*time.Hour),
+		}}},
+		Issues{Warnings: []Issue{{Code: "domain.tls.cert.expires_soon"}}},
+	},
+	{
+		"root expires soon",
+		nil,
+		tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{
+			certExpiringIn("leaf", 365*24*time.Hour),
+			certExpiringIn("root", 10*24*time.Hour),
+		}}},
+		Issues{},
+	},
+	{
+		"custom threshold",
+		&Checker{CertExpiryWarning: 90 * 24 * time.Hour},
+		tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{
+			certExpiringIn("leaf", 60*24*time.Hour),
+			certExpiringIn("root", 10*365*24*time.Hour),
+		}}},
+		Issues{Warnings: []Issue{{Code: "domain.tls.cert.expires_soon"}}},
+	},
+	{
+		"warnings disabled",
+		&Checker{CertExpiryWarning: -1},
+		tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{
+			certExpiringIn("leaf", time.Hour),
+			certExpiringIn("root", 10*365*24*time.Hour),
+		}}},
+		Issues{},
+	},
+	{
+		"unverified chain with expired intermediate",
+		&Checker{CertExpiryWarning: -1},
+		tls.ConnectionState{PeerCertificates: []*x509.Certificate{
+			certExpiringIn("leaf", 10*24*time.Hour),
+			certExpiringIn("intermediate", -24*time.Hour),
+		}},
+		Issues{Errors: []Issue{{Code: "domain.tls.cert.expired"}}},
+	},
+	{
+		"unverified chain with expired self-signed root",
+		nil,
+		tls.ConnectionState{PeerCertificates: []*x509.Certificate{
+			certExpiringIn("leaf", 365*24*time.Hour),
+			{RawSubject: []byte("root"), RawIssuer: []byte("root"), NotAfter: certExpiryNow.Add(-time.Hour)},
+		}},
+		Issues{},
+	},
+}
+
+func TestCheckCertExpiry(t *testing.T) {
+	for _, tt := range checkCertExpiryTests {
+		issues := tt.checker.checkCertExpiry(tt.connState, certExpiryNow)
+		if !issues.Match(tt.expectedIssues) {
+			t.Errorf("[%s] "+issuesShouldMatch, tt.description, issues, tt.expectedIssues)
+		}
+	}
+}
+
+func TestCheckCertExpiryParams(t *testing.T) {
+	connState := tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{
+		certExpiringIn("leaf", 10*24*time.Hour),
+		certExpiringIn("root", 10*365*24*time.Hour),
+	}}}
+	issues := (*Checker)(nil).checkCertExpiry(connState, certExpiryNow)
+	if len(issues.Warnings) != 1 {
+		t.Fatalf("Expected a single warning: %v", issues)
+	}
+
+	expected := map[string]string{
+		"common_name":    "leaf",
+		"not_after":      "2020-06-11T00:00:00Z",
+		"days_remaining": "10",
+	}
+	if !reflect.DeepEqual(issues.Warnings[0].Params, expected) {
+		t.Errorf("Unexpected params: %v", issues.Warnings[0].Params)
+	}
+}
+
+func rsaLeaf(bits int) *x509.Certificate {
+	return &x509.Certificate{
+		Subject:   pkix.Name{CommonName: "leaf"},
+		PublicKey: &rsa.PublicKey{N: new(big.Int).Lsh(big.NewInt(1), uint(bits-1)), E: 65537},
+	}
+}
+
+var checkTLSConfigTests = []struct {
+	description    string
+	connState      tls.ConnectionState
+	expectedIssues Issues
+}{
+	{
+		"TLS 1.3",
+		tls.ConnectionState{Version: tls.VersionTLS13, CipherSuite: tls.TLS_AES_128_GCM_SHA256},
+		Issues{},
+	},
+	{
+		"TLS 1.3 with an unknown suite",
+		tls.ConnectionState{Version: tls.VersionTLS13, CipherSuite: tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA},
+		Issues{Warnings: []Issue{{Code: "tls.obsolete_cipher_suite"}}},
+	},
+	{
+		"TLS 1.2 with a modern suite",
+		tls.ConnectionState{Version: tls.VersionTLS12, CipherSuite: tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305},
+		Issues{},
+	},
+	{
+		"TLS 1.2 with an obsolete suite",
+		tls.ConnectionState{Version: tls.VersionTLS12, CipherSuite: tls.TLS_RSA_WITH_AES_128_CBC_SHA},
+		Issues{Warnings: []Issue{{Code: "tls.obsolete_cipher_suite"}}},
+	},
+	{
+		"TLS 1.2 with a TLS 1.3 suite",
+		tls.ConnectionState{Version: tls.VersionTLS12, CipherSuite: tls.TLS_AES_128_GCM_SHA256},
+		Issues{Warnings: []Issue{{Code: "tls.obsolete_cipher_suite"}}},
+	},
+	{
+		"TLS 1.0",
+		tls.ConnectionState{Version: tls.VersionTLS10, CipherSuite: tls.TLS_RSA_WITH_AES_128_CBC_SHA},
+		Issues{Errors: []Issue{{Code: "domain.tls.version.too_low"}}},
+	},
+	{
+		"2048-bit RSA key",
+		tls.ConnectionState{
+			Version:          tls.VersionTLS13,
+			CipherSuite:      tls.TLS_AES_256_GCM_SHA384,
+			PeerCertificates: []*x509.Certificate{rsaLeaf(2048)},
+		},
+		Issues{},
+	},
+	{
+		"1024-bit RSA key",
+		tls.ConnectionState{
+			Version:          tls.VersionTLS13,
+			CipherSuite:      tls.TLS_AES_256_GCM_SHA384,
+			PeerCertificates: []*x509.Certificate{rsaLeaf(1024), rsaLeaf(512)},
+		},
+		Issues{Errors: []Issue{{Code: "domain.tls.rsa_key_too_small"}}},
+	},
+}
+
+func TestCheckTLSConfig(t *testing.T) {
+	for _, tt := range checkTLSConfigTests {
+		issues := checkTLSConfig(tt.connState)
+		if !issues.Match(tt.expectedIssues) {
+			t.Errorf("[%s] "+issuesShouldMatch, tt.description, issues, tt.expectedIssues)
+		}
+	}
+
+	issues := checkTLSConfig(tls.ConnectionState{
+		Version:          tls.VersionTLS11,
+		PeerCertificates: []*x509.Certificate{rsaLeaf(1024)},
+	})
+	if v := issues.Errors[0].Params["version"]; v != "TLS 1.1" {
+		t.Errorf("Unexpected version: %q", v)
+	}
+	if bits := issues.Errors[1].Params["bits"]; bits != "1024" {
+		t.Errorf("Unexpected key size: %q", bits)
+	}
+}
@@ -0,0 +1,214 @@
+package hstspreload
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	// The maximum number of bytes of the server's first flight to record
+	// while looking for the ServerHello.
+	maxServerHelloBytes = 16384
+
+	renegotiationInfoExtension = 0xff01
+)
+
+// A TLSReport describes the TLS configuration of a server. It does not affect
+// preload eligibility, but gives context about the operational maturity of a
+// site.
+type TLSReport struct {
+	Version     string `json:"version"`
+	CipherSuite string `json:"cipher_suite"`
+	// The protocol negotiated using ALPN, if any.
+	ALPN string `json:"alpn,omitempty"`
+	// Whether a second connection could resume the TLS session of the
+	// first.
+	SessionResumption bool `json:"session_resumption"`
+	// Whether the server supports secure renegotiation (RFC 5746). This is
+	// nil for TLS 1.3, which does not support renegotiation at all.
+	SecureRenegotiation *bool `json:"secure_renegotiation,omitempty"`
+}
+
+// TLSReportForDomain connects to `domain` over HTTPS (twice, to test session
+// resumption) and describes its TLS configuration. If it cannot connect,
+// the error is a *ConnectError.
+func TLSReportForDomain(domain string) (*TLSReport, error) {
+	return defaultChecker.TLSReportForDomain(domain)
+}
+
+// TLSReportForDomain is like the package-level TLSReportForDomain(), but
+// uses the configuration of `c`.
+func (c *Checker) TLSReportForDomain(domain string) (*TLSReport, error) {
+	return c.TLSReportForDomainContext(context.Background(), domain)
+}
+
+// TLSReportForDomainContext is like TLSReportForDomain, but the connections
+// are aborted when `ctx` is done.
+func (c *Checker) TLSReportForDomainContext(ctx context.Context, domain string) (*TLSReport, error) {
+	cache := tls.NewLRUClientSessionCache(1)
+	addr := net.JoinHostPort(domain, c.httpsPort())
+
+	first, serverHello, err := c.dialTLSForReport(ctx, addr, cache)
+	if err != nil {
+		return nil, err
+	}
+	state := first.ConnectionState()
+	// TLS 1.3 session tickets are sent after the handshake, so make a
+	// request to receive them.
+	c.requestOverConn(ctx, first, domain)
+	first.Close()
+
+	report := &TLSReport{
+		Version:     tlsVersionName(state.Version),
+		CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+		ALPN:        state.NegotiatedProtocol,
+	}
+	if state.Version <= tls.VersionTLS12 {
+		secure := hasRenegotiationInfo(serverHello)
+		report.SecureRenegotiation = &secure
+	}
+
+	second, _, err := c.dialTLSForReport(ctx, addr, cache)
+	if err == nil {
+		report.SessionResumption = second.ConnectionState().DidResume
+		second.Close()
+	}
+
+	return report, nil
+}
+
+// dialTLSForReport is like dialTLS, but uses `cache` for session resumption
+// and returns the start of the data received from the server, which
+// contains the ServerHello. `c` may be nil.
+func (c *Checker) dialTLSForReport(ctx context.Context, addr string, cache tls.ClientSessionCache) (*tls.Conn, []byte, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout())
+	defer cancel()
+
+	rawConn, err := c.dialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, nil, newConnectError(phaseTCPConnect, err)
+	}
+	recorder := &recordingConn{Conn: rawConn}
+	cfg := c.tlsConfig(host)
+	cfg.ClientSessionCache = cache
+	cfg.NextProtos = []string{"http/1.1"}
+	conn := tls.Client(recorder, cfg)
+	if err := conn.HandshakeContext(ctx); err != nil {
+		rawConn.Close()
+		return nil, nil, newConnectError(phaseTLSHandshake, err)
+	}
+	return conn, recorder.recorded, nil
+}
+
+// requestOverConn makes a minimal HTTP/1.1 request over `conn` and reads the
+// response headers, until the deadline of `ctx` (or the timeout of `c`, if
+// `ctx` has none). Errors are ignored. `c` may be nil.
+func (c *Checker) requestOverConn(ctx context.Context, conn *tls.Conn, host string) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(c.timeout())
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "HEAD", "https://"+host+"/", nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("User-Agent", "hstspreload-bot")
+	req.Close = true
+	if err := req.Write(conn); err != nil {
+		return
+	}
+	if resp, err := http.ReadResponse(bufio.NewReader(conn), req); err == nil {
+		resp.Body.Close()
+	}
+}
+
+// recordingConn records the first bytes that are read from a net.Conn.
+type recordingConn struct {
+	net.Conn
+	recorded []byte
+}
+
+func (r *recordingConn) Read(b []byte) (int, error) {
+	n, err := r.Conn.Read(b)
+	if remaining := maxServerHelloBytes - len(r.recorded); remaining > 0 {
+		if n < remaining {
+			remaining = n
+		}
+		r.recorded = append(r.recorded, b[:remaining]...)
+	}
+	return n, err
+}
+
+// hasRenegotiationInfo returns whether the ServerHello at the start of
+// `data` contains the renegotiation_info extension.
+func hasRenegotiationInfo(data []byte) bool {
+	// Record header: type (1), version (2), length (2).
+	if len(data) < 5 || data[0] != 22 {
+		return false
+	}
+	record := data[5:]
+	if recordLength := int(binary.BigEndian.Uint16(data[3:5])); recordLength < len(record) {
+		record = record[:recordLength]
+	}
+
+	// Handshake header: type (1), length (3). Then the ServerHello:
+	// version (2), random (32).
+	if len(record) < 4+2+32+1 || record[0] != 2 {
+		return false
+	}
+	hello := record[4+2+32:]
+
+	// Session ID, cipher suite (2), compression method (1).
+	sessionIDLength := int(hello[0])
+	if len(hello) < 1+sessionIDLength+3+2 {
+		return false
+	}
+	hello = hello[1+sessionIDLength+3:]
+
+	extensionsLength := int(binary.BigEndian.Uint16(hello))
+	extensions := hello[2:]
+	if extensionsLength < len(extensions) {
+		extensions = extensions[:extensionsLength]
+	}
+	for len(extensions) >= 4 {
+		extensionType := binary.BigEndian.Uint16(extensions)
+		length := int(binary.BigEndian.Uint16(extensions[2:]))
+		if extensionType == renegotiationInfoExtension {
+			return true
+		}
+		if len(extensions) < 4+length {
+			return false
+		}
+		extensions = extensions[4+length:]
+	}
+	return false
+}
+
+func tlsVersionName(version uint16) string {
+	switch version {
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	default:
+		return "unknown"
+	}
+}
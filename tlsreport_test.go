@@ -0,0 +1,100 @@
+package hstspreload
+
+import (
+	"crypto/tls"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTLSReportForDomain(t *testing.T) {
+	for _, tt := range []struct {
+		description         string
+		maxVersion          uint16
+		expectedVersion     string
+		expectRenegotiation bool
+	}{
+		{"TLS 1.3", tls.VersionTLS13, "TLS 1.3", false},
+		{"TLS 1.2", tls.VersionTLS12, "TLS 1.2", true},
+	} {
+		srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		srv.TLS = &tls.Config{MaxVersion: tt.maxVersion}
+		// The report closes connections right after the handshake.
+		srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+		srv.StartTLS()
+
+		c := &Checker{
+			ConnectTo: map[string]string{"example.com:443": srv.Listener.Addr().String()},
+			Transport: srv.Client().Transport.(*http.Transport),
+		}
+		report, err := c.TLSReportForDomain("example.com")
+		srv.Close()
+		if err != nil {
+			t.Errorf("[%s] %s", tt.description, err)
+			continue
+		}
+
+		if report.Version != tt.expectedVersion {
+			t.Errorf("[%s] Unexpected version: %s", tt.description, report.Version)
+		}
+		if report.ALPN != "http/1.1" {
+			t.Errorf("[%s] Unexpected ALPN: %q", tt.description, report.ALPN)
+		}
+		if !report.SessionResumption {
+			t.Errorf("[%s] Expected session resumption.", tt.description)
+		}
+		if tt.expectRenegotiation {
+			if report.SecureRenegotiation == nil || !*report.SecureRenegotiation {
+				t.Errorf("[%s] Expected secure renegotiation.", tt.description)
+			}
+		} else if report.SecureRenegotiation != nil {
+			t.Errorf("[%s] Secure renegotiation should not apply.", tt.description)
+		}
+	}
+}
+
+func TestTLSReportForDomainNoResponse(t *testing.T) {
+	// A server that completes the handshake, but never responds.
+	done := make(chan struct{})
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+	defer close(done)
+
+	c := &Checker{
+		Timeout:   200 * time.Millisecond,
+		ConnectTo: map[string]string{"example.com:443": srv.Listener.Addr().String()},
+		Transport: srv.Client().Transport.(*http.Transport),
+	}
+	result := make(chan error, 1)
+	go func() {
+		_, err := c.TLSReportForDomain("example.com")
+		result <- err
+	}()
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("The report should not wait for a response forever.")
+	}
+}
+
+func TestHasRenegotiationInfo(t *testing.T) {
+	for _, data := range [][]byte{
+		nil,
+		{22, 3, 3, 0, 1},
+		{23, 3, 3, 0, 0},
+	} {
+		if hasRenegotiationInfo(data) {
+			t.Errorf("Unexpected renegotiation_info in %v", data)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package hstspreload
+
+import (
+	"sort"
+	"strings"
+)
+
+// A VantagePoint is a named location from which checks can be run.
+type VantagePoint struct {
+	Name   string
+	Dialer Dialer
+}
+
+// A VantagePointResult holds the outcome of PreloadableDomainFrom() for a
+// single vantage point.
+type VantagePointResult struct {
+	Name   string  `json:"name"`
+	Header *string `json:"header,omitempty"`
+	Issues Issues  `json:"issues"`
+}
+
+// CompareVantagePoints runs PreloadableDomainFrom() for `domain` from each
+// of the given vantage points in parallel, and compares the outcomes.
+//
+// The returned results are in the same order as `vantagePoints`. The
+// returned issues contain an error for each kind of inconsistency between
+// the vantage points (e.g. different headers), since a site that behaves
+// differently depending on where it is visited from is likely to break for
+// some users once it is preloaded.
+func CompareVantagePoints(domain string, vantagePoints []VantagePoint) ([]VantagePointResult, Issues) {
+	return defaultChecker.CompareVantagePoints(domain, vantagePoints)
+}
+
+// CompareVantagePoints is like the package-level CompareVantagePoints(), but
+// uses the configuration of `c` (other than c.Dialer) for each vantage point.
+func (c *Checker) CompareVantagePoints(domain string, vantagePoints []VantagePoint) ([]VantagePointResult, Issues) {
+	results := make([]VantagePointResult, len(vantagePoints))
+	done := make(chan bool)
+	for i, vp := range vantagePoints {
+		go func(i int, vp VantagePoint) {
+			vc := Checker{}
+			if c != nil {
+				vc = *c
+			}
+			vc.Dialer = vp.Dialer
+			r := vc.PreloadableDomainResult(domain)
+			results[i] = VantagePointResult{
+				Name:   vp.Name,
+				Header: r.Header,
+				Issues: r.Issues,
+			}
+			done <- true
+		}(i, vp)
+	}
+	for range vantagePoints {
+		<-done
+	}
+
+	return results, compareVantagePointResults(results)
+}
+
+func compareVantagePointResults(results []VantagePointResult) Issues {
+	issues := Issues{}
+	if len(results) < 2 {
+		return issues
+	}
+
+	headers := make(map[string][]string)
+	verdicts := make(map[string][]string)
+	errorCodes := make(map[string][]string)
+	for _, r := range results {
+		header := "(none)"
+		if r.Header != nil {
+			header = "`" + *r.Header + "`"
+		}
+		headers[header] = append(headers[header], r.Name)
+
+		verdict := "passes"
+		if len(r.Issues.Errors) > 0 {
+			verdict = "fails"
+		}
+		verdicts[verdict] = append(verdicts[verdict], r.Name)
+
+		var codes []string
+		for _, e := range r.Issues.Errors {
+			codes = append(codes, string(e.Code))
+		}
+		sort.Strings(codes)
+		key := strings.Join(codes, ", ")
+		errorCodes[key] = append(errorCodes[key], r.Name)
+	}
+
+	if len(headers) > 1 {
+		issues = issues.addErrorf(
+			"vantage_points.inconsistent.header",
+			"Inconsistent HSTS header",
+			"Different vantage points observed different HSTS headers: %s",
+			describeVantagePointGroups(headers))
+	}
+	if len(verdicts) > 1 {
+		issues = issues.addErrorf(
+			"vantage_points.inconsistent.verdict",
+			"Inconsistent preload verdict",
+			"The site passes the preload requirements from some vantage points, but not from others: %s",
+			describeVantagePointGroups(verdicts))
+	} else if len(errorCodes) > 1 {
+		issues = issues.addWarningf(
+			"vantage_points.inconsistent.errors",
+			"Inconsistent errors",
+			"Different vantage points observed different errors: %s",
+			describeVantagePointGroups(errorCodes))
+	}
+
+	return issues
+}
+
+// describeVantagePointGroups formats the names of the vantage points for
+// each observed value, in deterministic order.
+func describeVantagePointGroups(groups map[string][]string) string {
+	var values []string
+	for v := range groups {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+
+	var parts []string
+	for _, v := range values {
+		if v == "" {
+			parts = append(parts, "no errors from "+strings.Join(groups[v], ", "))
+			continue
+		}
+		parts = append(parts, v+" from "+strings.Join(groups[v], ", "))
+	}
+	return strings.Join(parts, "; ")
+}
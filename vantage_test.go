@@ -0,0 +1,57 @@
+package hstspreload
+
+import "testing"
+
+func TestCompareVantagePointResults(t *testing.T) {
+	good := "max-age=31536000; includeSubDomains; preload"
+	bad := "max-age=300"
+
+	for _, tt := range []struct {
+		description string
+		results     []VantagePointResult
+		expected    Issues
+	}{
+		{
+			"consistent",
+			[]VantagePointResult{
+				{Name: "us", Header: &good},
+				{Name: "eu", Header: &good},
+			},
+			Issues{},
+		},
+		{
+			"different headers",
+			[]VantagePointResult{
+				{Name: "us", Header: &good},
+				{Name: "eu", Header: &bad, Issues: Issues{}.addErrorf("header.preloadable.max_age.below_1_year", "", "")},
+				{Name: "asia", Header: &good},
+			},
+			Issues{Errors: []Issue{
+				{
+					Code:    "vantage_points.inconsistent.header",
+					Message: "Different vantage points observed different HSTS headers: `max-age=300` from eu; `max-age=31536000; includeSubDomains; preload` from us, asia",
+				},
+				{
+					Code:    "vantage_points.inconsistent.verdict",
+					Message: "The site passes the preload requirements from some vantage points, but not from others: fails from eu; passes from us, asia",
+				},
+			}},
+		},
+		{
+			"different errors",
+			[]VantagePointResult{
+				{Name: "us", Issues: Issues{}.addErrorf("response.no_header", "", "")},
+				{Name: "eu", Issues: Issues{}.addErrorf("domain.tls.cannot_connect", "", "")},
+			},
+			Issues{Warnings: []Issue{{
+				Code:    "vantage_points.inconsistent.errors",
+				Message: "Different vantage points observed different errors: domain.tls.cannot_connect from eu; response.no_header from us",
+			}}},
+		},
+	} {
+		issues := compareVantagePointResults(tt.results)
+		if !issues.Match(tt.expected) {
+			t.Errorf("[%s] "+issuesShouldMatch, tt.description, issues, tt.expected)
+		}
+	}
+}